@@ -0,0 +1,95 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"go-api-example/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// chain builds n correctly linked records.
+func chain(n int) []model.AuditRecord {
+	records := make([]model.AuditRecord, n)
+	prev := GenesisHash
+	for i := range records {
+		rec := model.AuditRecord{
+			Seq:       int64(i + 1),
+			CreatedAt: time.Date(2025, 1, 1, 0, 0, i, 0, time.UTC),
+			Actor:     "cli:alice",
+			RequestID: "req-1",
+			Action:    ActionAccountStatus,
+			Before:    json.RawMessage(`{"account_id":1,"status":"active"}`),
+			After:     json.RawMessage(`{"account_id":1,"status":"frozen"}`),
+			PrevHash:  prev,
+		}
+		rec.Hash = Hash(rec)
+		prev = rec.Hash
+		records[i] = rec
+	}
+	return records
+}
+
+func verify(records []model.AuditRecord) error {
+	v := NewVerifier()
+	for _, rec := range records {
+		if err := v.Check(rec); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestVerifier(t *testing.T) {
+	t.Run("intact chain", func(t *testing.T) {
+		v := NewVerifier()
+		for _, rec := range chain(3) {
+			require.NoError(t, v.Check(rec))
+		}
+		assert.Equal(t, int64(3), v.Checked())
+	})
+
+	t.Run("edited record", func(t *testing.T) {
+		records := chain(3)
+		records[1].After = json.RawMessage(`{"account_id":1,"status":"active"}`)
+
+		var broken *BrokenLinkError
+		require.ErrorAs(t, verify(records), &broken)
+		assert.Equal(t, int64(2), broken.Seq)
+		assert.Contains(t, broken.Reason, "hash does not match")
+	})
+
+	t.Run("edited record with recomputed hash", func(t *testing.T) {
+		records := chain(3)
+		records[1].Actor = "someone-else"
+		records[1].Hash = Hash(records[1])
+
+		var broken *BrokenLinkError
+		require.ErrorAs(t, verify(records), &broken)
+		assert.Equal(t, int64(3), broken.Seq)
+		assert.Contains(t, broken.Reason, "prev_hash")
+	})
+
+	t.Run("deleted record", func(t *testing.T) {
+		records := chain(3)
+		records = append(records[:1], records[2:]...)
+
+		var broken *BrokenLinkError
+		require.ErrorAs(t, verify(records), &broken)
+		assert.Equal(t, int64(2), broken.Seq)
+	})
+}
+
+func TestContext(t *testing.T) {
+	ctx := context.Background()
+	assert.Equal(t, UnknownActor, ActorFrom(ctx))
+	assert.Equal(t, "", RequestIDFrom(ctx))
+
+	ctx = WithRequestID(WithActor(ctx, "api"), "abc")
+	assert.Equal(t, "api", ActorFrom(ctx))
+	assert.Equal(t, "abc", RequestIDFrom(ctx))
+}
@@ -0,0 +1,145 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"text/tabwriter"
+
+	"go-api-example/model"
+	"go-api-example/storage"
+
+	"github.com/shopspring/decimal"
+)
+
+// runAccount dispatches the "account" subcommands.
+func runAccount(ctx context.Context, c *cmdContext, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("%w: account requires a subcommand: create, get or list", errUsage)
+	}
+	switch args[0] {
+	case "create":
+		return runAccountCreate(ctx, c, args[1:])
+	case "get":
+		return runAccountGet(ctx, c, args[1:])
+	case "list":
+		return runAccountList(ctx, c, args[1:])
+	default:
+		return fmt.Errorf("%w: unknown account subcommand %q", errUsage, args[0])
+	}
+}
+
+// accountResult is printed by "account create".
+type accountResult struct {
+	model.Account
+	Result string `json:"result"` // "created" or "exists"
+}
+
+// runAccountCreate creates an account with the same validation and idempotency as POST /accounts.
+func runAccountCreate(ctx context.Context, c *cmdContext, args []string) error {
+	fs, opts := c.newFlagSet("account create")
+	id := fs.Int64("id", 0, "account ID (required, positive)")
+	balance := fs.String("balance", "0", "initial balance")
+	if _, err := parse(fs, opts, args); err != nil {
+		return err
+	}
+
+	initial, err := decimal.NewFromString(*balance)
+	if err != nil {
+		return fmt.Errorf("%w: invalid --balance %q", errUsage, *balance)
+	}
+	req := model.CreateAccountRequest{AccountID: *id, InitialBalance: initial}
+	if err := model.Validate(req); err != nil {
+		return err
+	}
+
+	store, closeStore, err := c.open(ctx, opts)
+	if err != nil {
+		return err
+	}
+	defer closeStore()
+
+	existing, err := store.GetAccount(ctx, req.AccountID)
+	if err != nil && !errors.Is(err, storage.ErrNotFound) {
+		return err
+	}
+	acc := model.Account{AccountID: req.AccountID, Balance: req.InitialBalance}
+	if err := store.CreateAccount(ctx, acc); err != nil {
+		return err
+	}
+
+	result := accountResult{Account: acc, Result: "created"}
+	if existing != nil {
+		result = accountResult{Account: *existing, Result: "exists"}
+	}
+	return c.print(opts, result, func(w *tabwriter.Writer) {
+		fmt.Fprintln(w, "ACCOUNT_ID\tBALANCE\tRESULT")
+		fmt.Fprintf(w, "%d\t%s\t%s\n", result.AccountID, result.Balance, result.Result)
+	})
+}
+
+// runAccountGet prints a single account.
+func runAccountGet(ctx context.Context, c *cmdContext, args []string) error {
+	fs, opts := c.newFlagSet("account get")
+	positional, err := parse(fs, opts, args)
+	if err != nil {
+		return err
+	}
+	id, err := parseAccountID(positional)
+	if err != nil {
+		return err
+	}
+
+	store, closeStore, err := c.open(ctx, opts)
+	if err != nil {
+		return err
+	}
+	defer closeStore()
+
+	acc, err := store.GetAccount(ctx, id)
+	if err != nil {
+		return err
+	}
+	return c.print(opts, acc, func(w *tabwriter.Writer) {
+		printAccountHeader(w)
+		printAccountRow(w, *acc)
+	})
+}
+
+// runAccountList prints one page of accounts ordered by ID.
+func runAccountList(ctx context.Context, c *cmdContext, args []string) error {
+	fs, opts := c.newFlagSet("account list")
+	after := fs.Int64("after", 0, "list accounts with an ID greater than this")
+	limit := fs.Int("limit", 100, fmt.Sprintf("maximum number of accounts (at most %d)", model.MaxPageSize))
+	if _, err := parse(fs, opts, args); err != nil {
+		return err
+	}
+	if *limit < 1 || *limit > model.MaxPageSize {
+		return fmt.Errorf("%w: --limit must be between 1 and %d", errUsage, model.MaxPageSize)
+	}
+
+	store, closeStore, err := c.open(ctx, opts)
+	if err != nil {
+		return err
+	}
+	defer closeStore()
+
+	page, err := store.ListAccounts(ctx, model.AccountFilter{AfterID: *after, Limit: *limit})
+	if err != nil {
+		return err
+	}
+	return c.print(opts, page.Accounts, func(w *tabwriter.Writer) {
+		printAccountHeader(w)
+		for _, acc := range page.Accounts {
+			printAccountRow(w, acc)
+		}
+	})
+}
+
+func printAccountHeader(w *tabwriter.Writer) {
+	fmt.Fprintln(w, "ACCOUNT_ID\tBALANCE\tSTATUS")
+}
+
+func printAccountRow(w *tabwriter.Writer, acc model.Account) {
+	fmt.Fprintf(w, "%d\t%s\t%s\n", acc.AccountID, acc.Balance, acc.Status)
+}
@@ -0,0 +1,153 @@
+package cli
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"go-api-example/model"
+)
+
+// runFreeze freezes an account, or unfreezes it with --unfreeze.
+func runFreeze(ctx context.Context, c *cmdContext, args []string) error {
+	fs, opts := c.newFlagSet("freeze")
+	unfreeze := fs.Bool("unfreeze", false, "make the account active again")
+	positional, err := parse(fs, opts, args)
+	if err != nil {
+		return err
+	}
+	id, err := parseAccountID(positional)
+	if err != nil {
+		return err
+	}
+
+	status := model.AccountStatusFrozen
+	if *unfreeze {
+		status = model.AccountStatusActive
+	}
+
+	store, closeStore, err := c.open(ctx, opts)
+	if err != nil {
+		return err
+	}
+	defer closeStore()
+
+	if err := store.SetAccountStatus(ctx, id, status); err != nil {
+		return err
+	}
+	acc, err := store.GetAccount(ctx, id)
+	if err != nil {
+		return err
+	}
+	return c.print(opts, acc, func(w *tabwriter.Writer) {
+		printAccountHeader(w)
+		printAccountRow(w, *acc)
+	})
+}
+
+// runExport streams every account as CSV or NDJSON, one page at a time.
+// With --as-of it dumps the balances as they stood at that instant.
+func runExport(ctx context.Context, c *cmdContext, args []string) error {
+	fs, opts := c.newFlagSet("export")
+	format := fs.String("format", "csv", "export format: csv or ndjson")
+	out := fs.String("out", "", "file to write (default stdout)")
+	asOf := fs.String("as-of", "", "export balances as of this RFC 3339 timestamp")
+	if _, err := parse(fs, opts, args); err != nil {
+		return err
+	}
+	if *format != "csv" && *format != "ndjson" {
+		return fmt.Errorf("%w: --format must be csv or ndjson", errUsage)
+	}
+	filter := model.AccountFilter{Limit: model.MaxPageSize}
+	if *asOf != "" {
+		t, err := time.Parse(time.RFC3339Nano, *asOf)
+		if err != nil {
+			return fmt.Errorf("%w: invalid --as-of %q, want an RFC 3339 timestamp", errUsage, *asOf)
+		}
+		filter.AsOf = t
+	}
+
+	store, closeStore, err := c.open(ctx, opts)
+	if err != nil {
+		return err
+	}
+	defer closeStore()
+
+	var w io.Writer = c.stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+
+	write := newExportWriter(*format, w)
+	for {
+		page, err := store.ListAccounts(ctx, filter)
+		if err != nil {
+			return err
+		}
+		for _, acc := range page.Accounts {
+			if err := write(acc); err != nil {
+				return err
+			}
+		}
+		if page.Next == nil {
+			break
+		}
+		filter.After = page.Next
+	}
+	return write(model.Account{}) // flush
+}
+
+// newExportWriter returns a function that writes one account per call.
+// Calling it with a zero Account flushes buffered output.
+func newExportWriter(format string, w io.Writer) func(model.Account) error {
+	if format == "ndjson" {
+		enc := json.NewEncoder(w)
+		return func(acc model.Account) error {
+			if acc.AccountID == 0 {
+				return nil
+			}
+			return enc.Encode(acc)
+		}
+	}
+
+	cw := csv.NewWriter(w)
+	header := false
+	return func(acc model.Account) error {
+		if !header {
+			header = true
+			if err := cw.Write([]string{"account_id", "balance", "status"}); err != nil {
+				return err
+			}
+		}
+		if acc.AccountID == 0 {
+			cw.Flush()
+			return cw.Error()
+		}
+		return cw.Write([]string{strconv.FormatInt(acc.AccountID, 10), acc.Balance.String(), acc.Status})
+	}
+}
+
+// runConfig prints the effective configuration as YAML with secrets redacted.
+func runConfig(ctx context.Context, c *cmdContext, args []string) error {
+	fs, opts := c.newFlagSet("config")
+	if _, err := parse(fs, opts, args); err != nil {
+		return err
+	}
+	cfg, err := c.loadConfig(opts)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprint(c.stdout, cfg)
+	return err
+}
@@ -0,0 +1,263 @@
+// Package cli implements the command-line interface of the binary: the HTTP
+// server ("serve") and the operator commands that call storage.Store directly.
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"go-api-example/audit"
+	"go-api-example/config"
+	"go-api-example/model"
+	"go-api-example/storage"
+)
+
+// Exit codes returned by Run. Scripts can branch on these instead of parsing output.
+const (
+	ExitOK                = 0
+	ExitInternal          = 1
+	ExitUsage             = 2
+	ExitNotFound          = 3
+	ExitInsufficientFunds = 4
+	ExitInvalid           = 5
+	ExitFrozen            = 6
+	ExitMismatch          = 7
+	ExitAuditBroken       = 8
+)
+
+// Output formats accepted by --output.
+const (
+	OutputTable = "table"
+	OutputJSON  = "json"
+)
+
+// errUsage marks errors caused by bad command-line arguments.
+var errUsage = errors.New("usage error")
+
+// openStore connects to the database. Tests replace it with a fake.
+var openStore = func(ctx context.Context, db config.DatabaseConfig, now func() time.Time) (storage.Store, func(), error) {
+	store, err := storage.NewPostgresStore(ctx, db.URL, storage.Options{
+		ConnectRetries:       db.ConnectRetries,
+		ConnectRetryInterval: db.ConnectRetryInterval,
+		MaxConns:             db.MaxConns,
+		MinConns:             db.MinConns,
+		StatementTimeout:     db.StatementTimeout,
+		LockTimeout:          db.LockTimeout,
+		TxAttempts:           db.TxAttempts,
+		TxRetryBaseDelay:     db.TxRetryBaseDelay,
+		TxRetryMaxDelay:      db.TxRetryMaxDelay,
+		ReadURL:              db.ReadURL,
+		ReplicaCheckInterval: db.ReplicaCheckInterval,
+		ReplicaMaxLag:        db.ReplicaMaxLag,
+		Now:                  now,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return store, store.Close, nil
+}
+
+// command is one top-level subcommand.
+type command struct {
+	name    string
+	summary string
+	run     func(ctx context.Context, c *cmdContext, args []string) error
+}
+
+var commands []command
+
+func init() {
+	commands = []command{
+		{"serve", "Run the HTTP API server (default)", runServe},
+		{"account", "Manage accounts: create | get | list", runAccount},
+		{"transfer", "Transfer an amount between two accounts", runTransfer},
+		{"freeze", "Freeze (or --unfreeze) an account", runFreeze},
+		{"reconcile", "Verify stored balances against the ledger", runReconcile},
+		{"interest", "Accrue and post interest: backfill", runInterest},
+		{"export", "Export all accounts as CSV or JSON", runExport},
+		{"import", "Create accounts from a CSV file (resumable)", runImport},
+		{"verify-audit", "Verify the hash chain of the audit log", runVerifyAudit},
+		{"config", "Print the effective configuration with secrets redacted", runConfig},
+	}
+}
+
+// cmdContext carries the I/O streams and environment shared by all commands.
+type cmdContext struct {
+	stdout io.Writer
+	stderr io.Writer
+	getenv func(string) string
+	now    func() time.Time // clock of the interest job and "interest backfill"
+}
+
+// Run executes the command named by args[0] and returns the process exit code.
+// With no arguments it runs "serve", so the container image keeps working unchanged.
+// Changes made by the operator commands are audited as actor "cli:<user>".
+func Run(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	return run(ctx, &cmdContext{stdout: stdout, stderr: stderr, getenv: os.Getenv, now: time.Now}, args)
+}
+
+// run is Run with c, so that tests can set its clock.
+func run(ctx context.Context, c *cmdContext, args []string) int {
+	ctx = audit.WithActor(ctx, "cli:"+envOr(c.getenv, "USER", audit.UnknownActor))
+
+	name := "serve"
+	if len(args) > 0 {
+		name, args = args[0], args[1:]
+	}
+	if name == "help" || name == "-h" || name == "--help" {
+		c.usage()
+		return ExitOK
+	}
+
+	for _, cmd := range commands {
+		if cmd.name == name {
+			err := cmd.run(ctx, c, args)
+			if err != nil && !errors.Is(err, flag.ErrHelp) {
+				fmt.Fprintf(c.stderr, "error: %v\n", err)
+			}
+			return exitCode(err)
+		}
+	}
+
+	fmt.Fprintf(c.stderr, "unknown command %q\n\n", name)
+	c.usage()
+	return ExitUsage
+}
+
+func (c *cmdContext) usage() {
+	fmt.Fprintln(c.stderr, "Usage: go-api-example <command> [flags]")
+	fmt.Fprintln(c.stderr)
+	fmt.Fprintln(c.stderr, "Commands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(c.stderr, "  %-13s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintln(c.stderr)
+	fmt.Fprintln(c.stderr, "Run '<command> -h' for the flags of a command.")
+}
+
+// exitCode maps an error from a command to a process exit code.
+func exitCode(err error) int {
+	var verrs model.ValidationErrors
+	switch {
+	case err == nil, errors.Is(err, flag.ErrHelp):
+		return ExitOK
+	case errors.Is(err, errUsage):
+		return ExitUsage
+	case errors.As(err, &verrs), errors.Is(err, errInvalidRows):
+		return ExitInvalid
+	case errors.Is(err, storage.ErrNotFound):
+		return ExitNotFound
+	case errors.Is(err, storage.ErrInsufficientFunds):
+		return ExitInsufficientFunds
+	case errors.Is(err, storage.ErrAccountFrozen), errors.Is(err, storage.ErrAccountReadOnly):
+		return ExitFrozen
+	case errors.Is(err, errMismatch):
+		return ExitMismatch
+	case errors.As(err, new(*audit.BrokenLinkError)):
+		return ExitAuditBroken
+	default:
+		return ExitInternal
+	}
+}
+
+// options are the flags shared by every command: the configuration flags
+// (see package config) and --output.
+type options struct {
+	flags  *config.Flags
+	output string
+	cfg    *config.Config
+}
+
+// newFlagSet creates a FlagSet with the shared configuration and --output flags.
+func (c *cmdContext) newFlagSet(name string) (*flag.FlagSet, *options) {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(c.stderr)
+	opts := &options{flags: config.RegisterFlags(fs)}
+	fs.StringVar(&opts.output, "output", envOr(c.getenv, "OUTPUT", OutputTable), "output format: table or json (env OUTPUT)")
+	return fs, opts
+}
+
+// parse parses args, allowing flags before and after positional arguments,
+// checks the shared options and returns the positional arguments.
+// The configuration is loaded afterwards by open or loadConfig.
+func parse(fs *flag.FlagSet, opts *options, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			if errors.Is(err, flag.ErrHelp) {
+				return nil, err
+			}
+			return nil, fmt.Errorf("%w: %v", errUsage, err)
+		}
+		if fs.NArg() == 0 {
+			break
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+	if opts.output != OutputTable && opts.output != OutputJSON {
+		return nil, fmt.Errorf("%w: --output must be %q or %q", errUsage, OutputTable, OutputJSON)
+	}
+	return positional, nil
+}
+
+// parseAccountID parses a positional account ID argument.
+func parseAccountID(positional []string) (int64, error) {
+	if len(positional) != 1 {
+		return 0, fmt.Errorf("%w: expected exactly one account ID", errUsage)
+	}
+	id, err := strconv.ParseInt(positional[0], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%w: invalid account ID %q", errUsage, positional[0])
+	}
+	return id, nil
+}
+
+// loadConfig layers the config file, environment and flags into opts.cfg.
+func (c *cmdContext) loadConfig(opts *options) (*config.Config, error) {
+	if opts.cfg != nil {
+		return opts.cfg, nil
+	}
+	cfg, err := config.Load(opts.flags, c.getenv)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errUsage, err)
+	}
+	opts.cfg = cfg
+	return cfg, nil
+}
+
+// open loads the configuration and connects to the database.
+func (c *cmdContext) open(ctx context.Context, opts *options) (storage.Store, func(), error) {
+	cfg, err := c.loadConfig(opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	return openStore(ctx, cfg.Database, c.now)
+}
+
+// print writes v as indented JSON, or calls table to render it as a table.
+func (c *cmdContext) print(opts *options, v any, table func(w *tabwriter.Writer)) error {
+	if opts.output == OutputJSON {
+		enc := json.NewEncoder(c.stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	}
+	tw := tabwriter.NewWriter(c.stdout, 0, 4, 2, ' ', 0)
+	table(tw)
+	return tw.Flush()
+}
+
+func envOr(getenv func(string) string, key, fallback string) string {
+	if v := getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
@@ -0,0 +1,589 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go-api-example/audit"
+	"go-api-example/config"
+	"go-api-example/model"
+	"go-api-example/ratelimit"
+	"go-api-example/rules"
+	"go-api-example/storage"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memStore is an in-memory storage.Store for CLI tests. Methods a test does not
+// need fall through to the embedded nil interface and panic if called.
+type memStore struct {
+	storage.Store
+	accounts   map[int64]*model.Account
+	lastFilter model.AccountFilter
+	report     *model.ReconciliationReport
+	reconciled model.ReconcileOptions
+	auditLog   []model.AuditRecord
+	bulkCalls  int
+	bulkErr    map[int]error // CreateAccounts fails on these calls, counted from 1
+	accrued    []string      // days passed to AccrueInterest
+	postBefore []string      // days passed to PostInterest
+	postings   []model.InterestPosting
+}
+
+func (m *memStore) CreateAccount(ctx context.Context, acc model.Account) error {
+	if _, ok := m.accounts[acc.AccountID]; !ok {
+		acc.Status = model.AccountStatusActive
+		m.accounts[acc.AccountID] = &acc
+	}
+	return nil
+}
+
+func (m *memStore) CreateAccounts(ctx context.Context, accounts []model.Account) ([]bool, error) {
+	m.bulkCalls++
+	if err := m.bulkErr[m.bulkCalls]; err != nil {
+		return nil, err
+	}
+	created := make([]bool, len(accounts))
+	for i, acc := range accounts {
+		_, exists := m.accounts[acc.AccountID]
+		created[i] = !exists
+		m.CreateAccount(ctx, acc)
+	}
+	return created, nil
+}
+
+func (m *memStore) GetAccount(ctx context.Context, id int64) (*model.Account, error) {
+	acc, ok := m.accounts[id]
+	if !ok {
+		return nil, &storage.AccountError{AccountID: id, Err: storage.ErrNotFound}
+	}
+	cp := *acc
+	return &cp, nil
+}
+
+func (m *memStore) ExecuteTransfer(ctx context.Context, req model.TransactionRequest) (*model.Transfer, error) {
+	src, ok := m.accounts[req.SourceAccountID]
+	if !ok {
+		return nil, &storage.AccountError{AccountID: req.SourceAccountID, Err: storage.ErrNotFound}
+	}
+	dst, ok := m.accounts[req.DestinationAccountID]
+	if !ok {
+		return nil, &storage.AccountError{AccountID: req.DestinationAccountID, Err: storage.ErrNotFound}
+	}
+	if src.Balance.LessThan(req.Amount) {
+		return nil, &storage.AccountError{AccountID: src.AccountID, Err: storage.ErrInsufficientFunds}
+	}
+	src.Balance = src.Balance.Sub(req.Amount)
+	dst.Balance = dst.Balance.Add(req.Amount)
+	return &model.Transfer{TransactionRequest: req, Total: req.Amount}, nil
+}
+
+func (m *memStore) ListAccounts(ctx context.Context, filter model.AccountFilter) (*model.AccountPage, error) {
+	m.lastFilter = filter
+	after := filter.AfterID
+	if filter.After != nil {
+		after = filter.After.AccountID
+	}
+	var out []model.Account
+	for _, acc := range m.accounts {
+		if acc.AccountID > after {
+			out = append(out, *acc)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].AccountID < out[j].AccountID })
+	page := &model.AccountPage{Accounts: out}
+	if len(out) > filter.Limit {
+		page.Accounts = out[:filter.Limit]
+		page.Next = model.CursorOf(out[filter.Limit-1])
+	}
+	return page, nil
+}
+
+func (m *memStore) Reconcile(ctx context.Context, opts model.ReconcileOptions) (*model.ReconciliationReport, error) {
+	m.reconciled = opts
+	return m.report, nil
+}
+
+func (m *memStore) AccrueInterest(ctx context.Context, day time.Time) (int, error) {
+	m.accrued = append(m.accrued, day.Format(time.DateOnly))
+	return 2, nil
+}
+
+func (m *memStore) PostInterest(ctx context.Context, before time.Time, expenseAccountID int64) ([]model.InterestPosting, error) {
+	m.postBefore = append(m.postBefore, before.Format(time.DateOnly))
+	return m.postings, nil
+}
+
+func (m *memStore) AuditLog(ctx context.Context, filter model.AuditFilter) ([]model.AuditRecord, error) {
+	var out []model.AuditRecord
+	for _, rec := range m.auditLog {
+		if rec.Seq > filter.AfterSeq && len(out) < filter.Limit {
+			out = append(out, rec)
+		}
+	}
+	return out, nil
+}
+
+func (m *memStore) SetAccountStatus(ctx context.Context, id int64, status string) error {
+	acc, ok := m.accounts[id]
+	if !ok {
+		return &storage.AccountError{AccountID: id, Err: storage.ErrNotFound}
+	}
+	acc.Status = status
+	return nil
+}
+
+// runCLI runs the CLI against store and returns the exit code and stdout.
+func runCLI(t *testing.T, store storage.Store, args ...string) (int, string) {
+	t.Helper()
+	return runCLIAt(t, time.Time{}, store, args...)
+}
+
+// runCLIAt is runCLI with the clock stopped at at, or running if at is zero.
+func runCLIAt(t *testing.T, at time.Time, store storage.Store, args ...string) (int, string) {
+	t.Helper()
+	orig := openStore
+	openStore = func(ctx context.Context, db config.DatabaseConfig, now func() time.Time) (storage.Store, func(), error) {
+		return store, func() {}, nil
+	}
+	t.Cleanup(func() { openStore = orig })
+
+	var stdout, stderr bytes.Buffer
+	c := &cmdContext{stdout: &stdout, stderr: &stderr, getenv: os.Getenv, now: time.Now}
+	if !at.IsZero() {
+		c.now = func() time.Time { return at }
+	}
+	args = append(args, "--database-url", "postgres://test")
+	code := run(context.Background(), c, args)
+	return code, stdout.String()
+}
+
+func newMemStore(accounts ...model.Account) *memStore {
+	m := &memStore{accounts: map[int64]*model.Account{}}
+	for _, acc := range accounts {
+		m.CreateAccount(context.Background(), acc)
+	}
+	return m
+}
+
+func TestAccountCommands(t *testing.T) {
+	t.Run("create then create again is idempotent", func(t *testing.T) {
+		store := newMemStore()
+
+		code, out := runCLI(t, store, "account", "create", "--id", "1001", "--balance", "1800.95", "--output", "json")
+		require.Equal(t, ExitOK, code)
+		assert.Contains(t, out, `"result": "created"`)
+
+		code, out = runCLI(t, store, "account", "create", "--id", "1001", "--balance", "5")
+		require.Equal(t, ExitOK, code)
+		assert.Contains(t, out, "exists")
+		assert.Contains(t, out, "1800.95")
+	})
+
+	t.Run("create applies the HTTP validation rules", func(t *testing.T) {
+		code, _ := runCLI(t, newMemStore(), "account", "create", "--id", "0", "--balance", "-1")
+		assert.Equal(t, ExitInvalid, code)
+	})
+
+	t.Run("get prints json with flags after the ID", func(t *testing.T) {
+		store := newMemStore(model.Account{AccountID: 7, Balance: decimal.NewFromInt(70)})
+
+		code, out := runCLI(t, store, "account", "get", "7", "--output", "json")
+
+		require.Equal(t, ExitOK, code)
+		var acc model.Account
+		require.NoError(t, json.Unmarshal([]byte(out), &acc))
+		assert.True(t, decimal.NewFromInt(70).Equal(acc.Balance))
+	})
+
+	t.Run("get unknown account", func(t *testing.T) {
+		code, _ := runCLI(t, newMemStore(), "account", "get", "404")
+		assert.Equal(t, ExitNotFound, code)
+	})
+}
+
+func TestTransferCommand(t *testing.T) {
+	store := newMemStore(
+		model.Account{AccountID: 1, Balance: decimal.NewFromInt(100)},
+		model.Account{AccountID: 2, Balance: decimal.Zero},
+	)
+
+	t.Run("success", func(t *testing.T) {
+		code, out := runCLI(t, store, "transfer", "--from", "1", "--to", "2", "--amount", "40")
+		require.Equal(t, ExitOK, code)
+		assert.Contains(t, out, "40")
+		assert.True(t, decimal.NewFromInt(60).Equal(store.accounts[1].Balance))
+	})
+
+	t.Run("insufficient funds", func(t *testing.T) {
+		code, _ := runCLI(t, store, "transfer", "--from", "1", "--to", "2", "--amount", "1000")
+		assert.Equal(t, ExitInsufficientFunds, code)
+	})
+
+	t.Run("same account is rejected before storage", func(t *testing.T) {
+		code, _ := runCLI(t, store, "transfer", "--from", "1", "--to", "1", "--amount", "1")
+		assert.Equal(t, ExitInvalid, code)
+	})
+}
+
+func TestFreezeCommand(t *testing.T) {
+	store := newMemStore(model.Account{AccountID: 5, Balance: decimal.NewFromInt(1)})
+
+	code, out := runCLI(t, store, "freeze", "5")
+	require.Equal(t, ExitOK, code)
+	assert.Contains(t, out, model.AccountStatusFrozen)
+
+	code, _ = runCLI(t, store, "freeze", "5", "--unfreeze")
+	require.Equal(t, ExitOK, code)
+	assert.Equal(t, model.AccountStatusActive, store.accounts[5].Status)
+}
+
+func TestExportCommand(t *testing.T) {
+	store := newMemStore(
+		model.Account{AccountID: 1, Balance: decimal.NewFromInt(10)},
+		model.Account{AccountID: 2, Balance: decimal.NewFromInt(20)},
+	)
+
+	code, out := runCLI(t, store, "export", "--as-of", "2025-03-31T23:59:59Z")
+	require.Equal(t, ExitOK, code)
+	assert.Equal(t, "account_id,balance,status\n1,10,active\n2,20,active\n", out)
+	assert.True(t, store.lastFilter.AsOf.Equal(time.Date(2025, 3, 31, 23, 59, 59, 0, time.UTC)))
+}
+
+func TestImportCommand(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "accounts.csv")
+	csv := "account_id,initial_balance,currency\n" +
+		"1,10,EUR\n" +
+		"2,-5,EUR\n" +
+		"3,30,\n" +
+		"4,40,EUR\n" +
+		"5,50,EUR\n"
+	require.NoError(t, os.WriteFile(file, []byte(csv), 0o644))
+
+	store := newMemStore(model.Account{AccountID: 3, Balance: decimal.NewFromInt(3)})
+	store.bulkErr = map[int]error{2: errors.New("connection reset")}
+
+	// The second batch fails: rows up to line 4 are done and checkpointed.
+	code, out := runCLI(t, store, "import", file, "--batch-size", "2", "--output", "json")
+	assert.Equal(t, ExitInternal, code)
+	var report model.BulkAccountReport
+	require.NoError(t, json.Unmarshal([]byte(out), &report))
+	assert.Equal(t, []model.BulkAccountResult{
+		{Row: 2, AccountID: 1, Status: model.BulkStatusCreated},
+		{Row: 3, AccountID: 2, Status: model.BulkStatusInvalid, Error: "initial_balance: cannot be negative"},
+		{Row: 4, AccountID: 3, Status: model.BulkStatusExists},
+	}, report.Results)
+	checkpoint, err := os.ReadFile(file + ".checkpoint")
+	require.NoError(t, err)
+	assert.Equal(t, "4\n", string(checkpoint))
+
+	// Running again resumes after the checkpoint.
+	code, out = runCLI(t, store, "import", file, "--batch-size", "2", "--output", "json")
+	assert.Equal(t, ExitOK, code)
+	report = model.BulkAccountReport{}
+	require.NoError(t, json.Unmarshal([]byte(out), &report))
+	assert.Equal(t, 2, report.Created)
+	assert.Equal(t, 5, report.Results[0].Row)
+	assert.NoFileExists(t, file+".checkpoint")
+	assert.Len(t, store.accounts, 4)
+	assert.Equal(t, "EUR", store.accounts[5].Currency)
+
+	// Invalid rows make the exit code non-zero.
+	code, _ = runCLI(t, newMemStore(), "import", file)
+	assert.Equal(t, ExitInvalid, code)
+}
+
+func TestReconcileCommand(t *testing.T) {
+	store := newMemStore()
+	store.report = &model.ReconciliationReport{
+		ReportID:        3,
+		AccountsChecked: 10,
+		Mismatches: []model.ReconciliationMismatch{
+			{AccountID: 4, StoredBalance: decimal.NewFromInt(9), ExpectedBalance: decimal.NewFromInt(8), Reason: model.ReasonLedgerDrift},
+		},
+		ReadOnlyAccounts: []int64{4},
+	}
+	runs := reconcileRuns.Value()
+
+	code, out := runCLI(t, store, "reconcile", "--reconcile-read-only")
+
+	assert.Equal(t, ExitMismatch, code)
+	assert.Contains(t, out, model.ReasonLedgerDrift)
+	assert.True(t, store.reconciled.ReadOnly)
+	assert.Equal(t, runs+1, reconcileRuns.Value())
+	assert.Equal(t, float64(1), reconcileMismatches.Value())
+}
+
+// jobQueue is a storage.Store that hands out transfer job lines, failing the
+// first errs calls, and cancels the workers once it runs out.
+type jobQueue struct {
+	storage.Store
+	mu     sync.Mutex
+	lines  []model.TransferJobLine
+	errs   int
+	cancel context.CancelFunc
+}
+
+func (q *jobQueue) ProcessNextTransferJobLine(ctx context.Context) (*model.TransferJobLine, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.errs > 0 {
+		q.errs--
+		return nil, errors.New("deadlock detected")
+	}
+	if len(q.lines) == 0 {
+		q.cancel()
+		return nil, nil
+	}
+	line := q.lines[0]
+	q.lines = q.lines[1:]
+	return &line, nil
+}
+
+func TestRunTransferWorkers(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	q := &jobQueue{errs: 1, cancel: cancel}
+	for i := 1; i <= 5; i++ {
+		status := model.LineStatusSucceeded
+		if i == 3 {
+			status = model.LineStatusFailed
+		}
+		q.lines = append(q.lines, model.TransferJobLine{Line: i, Status: status})
+	}
+	succeeded, failed, errs := transferJobLinesSucceeded.Value(), transferJobLinesFailed.Value(), transferJobWorkerErrors.Value()
+
+	runTransferWorkers(ctx, q, 3, time.Millisecond)
+
+	assert.ErrorIs(t, ctx.Err(), context.Canceled, "workers stopped before the queue was drained")
+	assert.Empty(t, q.lines)
+	assert.Equal(t, succeeded+4, transferJobLinesSucceeded.Value())
+	assert.Equal(t, failed+1, transferJobLinesFailed.Value())
+	assert.Equal(t, errs+1, transferJobWorkerErrors.Value())
+}
+
+func TestRunRulesReloader(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	good := []model.Rule{{Name: "large", When: "amount > 100", Action: model.RuleReview}}
+	loads := [][]model.Rule{
+		{{Name: "broken", When: "amount >", Action: model.RuleReview}},
+		good,
+	}
+	engine := new(rules.Engine)
+	require.NoError(t, engine.Load([]model.Rule{{Name: "old", When: "true", Action: model.RuleAllow}}))
+	load := func(context.Context) ([]model.Rule, error) {
+		if len(loads) == 0 {
+			cancel()
+			return good, nil
+		}
+		next := loads[0]
+		assert.Equal(t, "old", engine.Rules()[0].Name, "invalid rules are not loaded")
+		loads = loads[1:]
+		return next, nil
+	}
+
+	runRulesReloader(ctx, engine, load, time.Millisecond)
+
+	assert.Equal(t, good, engine.Rules())
+}
+
+func TestInterestBackfillCommand(t *testing.T) {
+	t.Run("accrues up to yesterday across a leap day and posts the months ended", func(t *testing.T) {
+		at := time.Date(2024, time.March, 1, 0, 30, 0, 0, time.UTC)
+		store := newMemStore()
+		store.postings = []model.InterestPosting{{AccountID: 1, Month: "2024-02", Amount: decimal.RequireFromString("1.23"), EntryID: 7}}
+
+		code, out := runCLIAt(t, at, store, "interest", "backfill", "--from", "2024-02-27", "--interest-expense-account", "900")
+
+		require.Equal(t, ExitOK, code)
+		assert.Equal(t, []string{"2024-02-27", "2024-02-28", "2024-02-29"}, store.accrued)
+		assert.Equal(t, []string{"2024-03-01"}, store.postBefore, "February has ended")
+		assert.Contains(t, out, "Accruals:  6")
+		assert.Contains(t, out, "2024-02")
+	})
+
+	t.Run("a month is posted only once its last day is accrued", func(t *testing.T) {
+		at := time.Date(2025, time.June, 15, 12, 0, 0, 0, time.UTC)
+		store := newMemStore()
+
+		code, _ := runCLIAt(t, at, store, "interest", "backfill", "--from", "2025-04-30", "--to", "2025-05-30", "--interest-expense-account", "900")
+
+		require.Equal(t, ExitOK, code)
+		assert.Len(t, store.accrued, 31)
+		assert.Equal(t, []string{"2025-05-31"}, store.postBefore, "only April has ended")
+	})
+
+	t.Run("failed postings exit non-zero", func(t *testing.T) {
+		at := time.Date(2025, time.January, 2, 0, 0, 0, 0, time.UTC)
+		store := newMemStore()
+		store.postings = []model.InterestPosting{{AccountID: 1, Month: "2024-12", Amount: decimal.NewFromInt(3), Error: "account 1: account is frozen"}}
+
+		code, out := runCLIAt(t, at, store, "interest", "backfill", "--from", "2025-01-01", "--interest-expense-account", "900", "--output", "json")
+
+		assert.Equal(t, ExitInternal, code)
+		var result interestResult
+		require.NoError(t, json.Unmarshal([]byte(out), &result))
+		require.Len(t, result.Postings, 1)
+		assert.Equal(t, store.postings[0].Error, result.Postings[0].Error)
+	})
+
+	t.Run("invalid ranges are usage errors", func(t *testing.T) {
+		at := time.Date(2025, time.January, 2, 0, 0, 0, 0, time.UTC)
+		for _, args := range [][]string{
+			{"--from", "2025-01-02"},
+			{"--from", "2024-12-31", "--to", "2024-12-30"},
+			{"--from", "2024-12-31", "--to", "2025-01-02"},
+			{"--from", "2024-12-31", "--to", "2024-12-31", "--interest-expense-account", "0"},
+		} {
+			if !slices.Contains(args, "--interest-expense-account") {
+				args = append(args, "--interest-expense-account", "900")
+			}
+			code, _ := runCLIAt(t, at, newMemStore(), append([]string{"interest", "backfill"}, args...)...)
+			assert.Equal(t, ExitUsage, code, "%v", args)
+		}
+	})
+}
+
+func TestRunInterestJob(t *testing.T) {
+	at := time.Date(2025, time.January, 1, 0, 5, 0, 0, time.UTC)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	store := &cancellingStore{memStore: newMemStore(), cancel: cancel}
+
+	runInterestJob(ctx, store, func() time.Time { return at }, time.Millisecond, 900)
+
+	assert.ErrorIs(t, ctx.Err(), context.Canceled, "job stopped before its first run")
+	assert.Equal(t, []string{"2024-12-31"}, store.accrued)
+	assert.Equal(t, []string{"2025-01-01"}, store.postBefore)
+}
+
+// cancellingStore cancels the interest job after its first posting.
+type cancellingStore struct {
+	*memStore
+	cancel context.CancelFunc
+}
+
+func (s *cancellingStore) PostInterest(ctx context.Context, before time.Time, expenseAccountID int64) ([]model.InterestPosting, error) {
+	defer s.cancel()
+	return s.memStore.PostInterest(ctx, before, expenseAccountID)
+}
+
+func TestRateLimitPolicy(t *testing.T) {
+	cfg := config.Default().RateLimit
+	assert.Nil(t, rateLimitPolicy(newMemStore(), cfg).Backend, "no key disables rate limiting")
+
+	cfg.Key, cfg.Gateways = "client", "10.0.0.0/8"
+	policy := rateLimitPolicy(newMemStore(), cfg)
+	assert.IsType(t, &ratelimit.Memory{}, policy.Backend)
+	assert.Equal(t, ratelimit.Limit{Rate: 5, Burst: 10}, policy.Transfer)
+	assert.Equal(t, []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}, policy.Gateways)
+
+	cfg.Backend = "postgres"
+	assert.IsType(t, ratelimit.BackendFunc(nil), rateLimitPolicy(newMemStore(), cfg).Backend)
+}
+
+func TestTransferQueue(t *testing.T) {
+	store := newMemStore()
+	cfg := config.Default().TransferQueue
+
+	apiStore, routing := transferQueue(store, cfg)
+	assert.Same(t, store, apiStore, "the queue is disabled by default")
+	assert.Nil(t, routing.Ring)
+
+	cfg.Enabled = true
+	apiStore, routing = transferQueue(store, cfg)
+	assert.IsType(t, &storage.TransferQueue{}, apiStore)
+	assert.Nil(t, routing.Ring, "a single replica routes nothing")
+
+	cfg.Peers, cfg.Self, cfg.ForwardSecret = "http://api-1:8080,http://api-2:8080", "http://api-1:8080", "s3cret"
+	_, routing = transferQueue(store, cfg)
+	require.NotNil(t, routing.Ring)
+	assert.Equal(t, "http://api-1:8080", routing.Self)
+	assert.Equal(t, "s3cret", routing.Secret)
+}
+
+func TestRunRateLimitPruner(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	store := &pruningStore{memStore: newMemStore(), cancel: cancel}
+
+	runRateLimitPruner(ctx, store, time.Millisecond)
+
+	assert.ErrorIs(t, ctx.Err(), context.Canceled, "pruner stopped before its runs")
+	assert.Equal(t, 3, store.prunes)
+}
+
+// pruningStore fails its first prune and cancels the pruner after its third.
+type pruningStore struct {
+	*memStore
+	cancel context.CancelFunc
+	prunes int
+}
+
+func (s *pruningStore) PruneRateLimitBuckets(ctx context.Context) (int64, error) {
+	s.prunes++
+	switch s.prunes {
+	case 1:
+		return 0, errors.New("connection refused")
+	case 3:
+		s.cancel()
+	}
+	return 1, nil
+}
+
+func TestVerifyAuditCommand(t *testing.T) {
+	store := newMemStore()
+	prev := audit.GenesisHash
+	for seq := int64(1); seq <= 3; seq++ {
+		rec := model.AuditRecord{Seq: seq, Actor: "cli:test", Action: audit.ActionAccountCreate, PrevHash: prev}
+		rec.Hash = audit.Hash(rec)
+		prev = rec.Hash
+		store.auditLog = append(store.auditLog, rec)
+	}
+
+	code, out := runCLI(t, store, "verify-audit")
+	require.Equal(t, ExitOK, code)
+	assert.Contains(t, out, "intact")
+
+	store.auditLog[1].Actor = "cli:mallory"
+	code, out = runCLI(t, store, "verify-audit", "--output", "json")
+	assert.Equal(t, ExitAuditBroken, code)
+	var result auditResult
+	require.NoError(t, json.Unmarshal([]byte(out), &result))
+	assert.Equal(t, int64(1), result.RecordsChecked)
+	assert.Equal(t, int64(2), result.BrokenAt)
+}
+
+func TestUsageErrors(t *testing.T) {
+	tests := [][]string{
+		{"bogus"},
+		{"account"},
+		{"account", "get"},
+		{"account", "get", "abc"},
+		{"account", "list", "--output", "yaml"},
+		{"transfer", "--from", "1", "--to", "2", "--amount", "lots"},
+		{"export", "--as-of", "yesterday"},
+		{"import"},
+	}
+	for _, args := range tests {
+		t.Run(strings.Join(args, " "), func(t *testing.T) {
+			code, _ := runCLI(t, newMemStore(), args...)
+			assert.Equal(t, ExitUsage, code)
+		})
+	}
+}
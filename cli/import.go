@@ -0,0 +1,262 @@
+package cli
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"go-api-example/model"
+
+	"github.com/shopspring/decimal"
+)
+
+// errInvalidRows reports that an import finished but skipped invalid rows.
+var errInvalidRows = errors.New("some rows were invalid")
+
+// importColumns maps the accepted CSV header names to the field they fill.
+// "balance" is accepted so that the output of export can be imported as is.
+var importColumns = map[string]string{
+	"account_id":      "account_id",
+	"initial_balance": "initial_balance",
+	"balance":         "initial_balance",
+	"currency":        "currency",
+	"owner_name":      "owner_name",
+}
+
+// runImport creates accounts from a CSV file with an account_id column, an
+// initial_balance (or balance) column and optional currency and owner_name
+// columns; other columns are ignored. Rows are written in batches, each in one
+// transaction.
+// After every batch the number of the last line done is written to the
+// checkpoint file, and a later run with the same file skips those lines, so an
+// import that failed part-way can be resumed. The checkpoint is removed once
+// the import completes. Rows are idempotent anyway: re-importing an account
+// that exists reports "exists" and leaves it untouched.
+func runImport(ctx context.Context, c *cmdContext, args []string) error {
+	fs, opts := c.newFlagSet("import")
+	batchSize := fs.Int("batch-size", 1000, "accounts written per transaction")
+	checkpoint := fs.String("checkpoint", "", "checkpoint file for resuming (default <file>.checkpoint)")
+	positional, err := parse(fs, opts, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return fmt.Errorf("%w: expected exactly one CSV file", errUsage)
+	}
+	if *batchSize < 1 {
+		return fmt.Errorf("%w: --batch-size must be at least 1", errUsage)
+	}
+	path := positional[0]
+	if *checkpoint == "" {
+		*checkpoint = path + ".checkpoint"
+	}
+
+	done, err := readCheckpoint(*checkpoint)
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	store, closeStore, err := c.open(ctx, opts)
+	if err != nil {
+		return err
+	}
+	defer closeStore()
+
+	imp := &importer{
+		batchSize: *batchSize,
+		create:    func(accounts []model.Account) ([]bool, error) { return store.CreateAccounts(ctx, accounts) },
+		commit:    func(line int) error { return writeCheckpoint(*checkpoint, line) },
+	}
+	if done > 0 {
+		fmt.Fprintf(c.stderr, "resuming after line %d (checkpoint %s)\n", done, *checkpoint)
+	}
+	importErr := imp.run(f, done)
+
+	report := imp.report()
+	if err := c.print(opts, report, func(w *tabwriter.Writer) {
+		fmt.Fprintln(w, "ROW\tACCOUNT_ID\tSTATUS\tERROR")
+		for _, res := range report.Results {
+			fmt.Fprintf(w, "%d\t%d\t%s\t%s\n", res.Row, res.AccountID, res.Status, res.Error)
+		}
+	}); err != nil {
+		return err
+	}
+	fmt.Fprintf(c.stderr, "created %d, exists %d, invalid %d\n", report.Created, report.Exists, report.Invalid)
+
+	if importErr != nil {
+		return fmt.Errorf("import stopped after line %d; run the same command again to resume: %w", imp.committed, importErr)
+	}
+	if err := os.Remove(*checkpoint); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if report.Invalid > 0 {
+		return errInvalidRows
+	}
+	return nil
+}
+
+// importer turns CSV rows into batches of accounts and records the outcome of every row.
+type importer struct {
+	batchSize int
+	create    func([]model.Account) ([]bool, error)
+	commit    func(line int) error // called after each batch with the last line it covers
+
+	results   []model.BulkAccountResult
+	pending   []int // indexes into results of the rows in the current batch
+	batch     []model.Account
+	committed int // last line whose outcome is final
+}
+
+// run reads the CSV from r, skipping data lines up to and including skip.
+func (imp *importer) run(r io.Reader, skip int) error {
+	cr := csv.NewReader(r)
+	cr.ReuseRecord = true
+	header, err := cr.Read()
+	if err != nil {
+		return fmt.Errorf("%w: could not read CSV header: %v", errUsage, err)
+	}
+	cols := map[string]int{}
+	for i, name := range header {
+		if field, ok := importColumns[strings.TrimSpace(strings.ToLower(name))]; ok {
+			cols[field] = i
+		}
+	}
+	if _, ok := cols["account_id"]; !ok {
+		return fmt.Errorf("%w: CSV header has no account_id column", errUsage)
+	}
+	if _, ok := cols["initial_balance"]; !ok {
+		return fmt.Errorf("%w: CSV header has no initial_balance or balance column", errUsage)
+	}
+	imp.committed = max(skip, 1)
+
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil && !errors.Is(err, csv.ErrFieldCount) {
+			return err
+		}
+		line, _ := cr.FieldPos(0)
+		if line <= skip {
+			continue
+		}
+
+		res := model.BulkAccountResult{Row: line}
+		req, rowErr := parseImportRow(record, cols)
+		res.AccountID = req.AccountID
+		if rowErr == nil {
+			rowErr = model.Validate(req)
+		}
+		if rowErr != nil {
+			res.Status, res.Error = model.BulkStatusInvalid, rowErr.Error()
+			imp.results = append(imp.results, res)
+			continue
+		}
+		imp.pending = append(imp.pending, len(imp.results))
+		imp.results = append(imp.results, res)
+		imp.batch = append(imp.batch, model.Account{AccountID: req.AccountID, Balance: req.InitialBalance, Currency: req.Currency, OwnerName: req.OwnerName})
+		if len(imp.batch) == imp.batchSize {
+			if err := imp.flush(line); err != nil {
+				return err
+			}
+		}
+	}
+	last := imp.committed
+	if len(imp.results) > 0 {
+		last = imp.results[len(imp.results)-1].Row
+	}
+	return imp.flush(last)
+}
+
+// flush writes the current batch and checkpoints line, the last line it covers.
+func (imp *importer) flush(line int) error {
+	if len(imp.batch) > 0 {
+		created, err := imp.create(imp.batch)
+		if err != nil {
+			// Leave the rows of the failed batch out of the report.
+			imp.results = imp.results[:imp.pending[0]]
+			return err
+		}
+		for i, ok := range created {
+			status := model.BulkStatusExists
+			if ok {
+				status = model.BulkStatusCreated
+			}
+			imp.results[imp.pending[i]].Status = status
+		}
+		imp.batch, imp.pending = imp.batch[:0], imp.pending[:0]
+	}
+	if line > imp.committed {
+		imp.committed = line
+		return imp.commit(line)
+	}
+	return nil
+}
+
+// report returns the outcome of every row processed.
+func (imp *importer) report() *model.BulkAccountReport {
+	report := &model.BulkAccountReport{Results: make([]model.BulkAccountResult, 0, len(imp.results))}
+	for _, res := range imp.results {
+		report.Add(res)
+	}
+	return report
+}
+
+// parseImportRow converts a CSV record into a create-account request.
+func parseImportRow(record []string, cols map[string]int) (model.CreateAccountRequest, error) {
+	var req model.CreateAccountRequest
+	field := func(name string) string {
+		if i, ok := cols[name]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+	id, err := strconv.ParseInt(field("account_id"), 10, 64)
+	if err != nil {
+		return req, errors.New("account_id: must be an integer")
+	}
+	req.AccountID = id
+	if req.InitialBalance, err = decimal.NewFromString(field("initial_balance")); err != nil {
+		return req, errors.New("initial_balance: must be a decimal number")
+	}
+	req.Currency = field("currency")
+	req.OwnerName = field("owner_name")
+	return req, nil
+}
+
+// readCheckpoint returns the line recorded in a checkpoint file, or 0 if there is none.
+func readCheckpoint(path string) (int, error) {
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	line, err := strconv.Atoi(strings.TrimSpace(string(b)))
+	if err != nil || line < 0 {
+		return 0, fmt.Errorf("%w: checkpoint file %s is corrupt; delete it to start over", errUsage, path)
+	}
+	return line, nil
+}
+
+// writeCheckpoint atomically records line as done.
+func writeCheckpoint(path string, line int) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(strconv.Itoa(line)+"\n"), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,180 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"text/tabwriter"
+	"time"
+
+	"go-api-example/interest"
+	"go-api-example/metrics"
+	"go-api-example/model"
+	"go-api-example/storage"
+)
+
+// errInterestNotPosted is returned by "interest backfill" when the transfer of
+// at least one month's interest failed.
+var errInterestNotPosted = errors.New("some interest could not be posted")
+
+// Interest metrics, scraped from GET /metrics while serve runs the periodic job.
+var (
+	interestAccruals        = metrics.NewCounter("interest_accruals_total", "Daily interest accruals recorded.")
+	interestPostings        = metrics.NewCounter("interest_postings_total", "Monthly interest postings made.")
+	interestPostingFailures = metrics.NewCounter("interest_posting_failures_total", "Monthly interest postings whose transfer failed.")
+	interestRunFailures     = metrics.NewCounter("interest_run_failures_total", "Interest runs that could not complete.")
+)
+
+// interestResult is printed by "interest backfill".
+type interestResult struct {
+	From     string                  `json:"from"`
+	To       string                  `json:"to"`
+	Accruals int                     `json:"accruals"`
+	Postings []model.InterestPosting `json:"postings"`
+}
+
+// accrueAndPost accrues interest for every day from from to to, inclusive, and
+// then posts the interest of every month that ended by to, paying it from
+// expenseAccountID. Its outcome is recorded in the metrics.
+func accrueAndPost(ctx context.Context, store storage.Store, from, to time.Time, expenseAccountID int64) (*interestResult, error) {
+	from, to = interest.Day(from), interest.Day(to)
+	result := &interestResult{From: from.Format(time.DateOnly), To: to.Format(time.DateOnly)}
+	for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+		n, err := store.AccrueInterest(ctx, day)
+		if err != nil {
+			interestRunFailures.Inc()
+			return nil, fmt.Errorf("could not accrue interest for %s: %w", day.Format(time.DateOnly), err)
+		}
+		result.Accruals += n
+		interestAccruals.Add(uint64(n))
+	}
+
+	postings, err := store.PostInterest(ctx, to.AddDate(0, 0, 1), expenseAccountID)
+	for _, p := range postings {
+		if p.Error != "" {
+			interestPostingFailures.Inc()
+		} else {
+			interestPostings.Inc()
+		}
+	}
+	if err != nil {
+		interestRunFailures.Inc()
+		return nil, fmt.Errorf("could not post interest: %w", err)
+	}
+	result.Postings = postings
+	return result, nil
+}
+
+// runInterestJob accrues the interest of the previous day and posts the months
+// that ended every interval until ctx is cancelled. A day or month that is
+// already done is skipped, so running more often than daily is harmless.
+// Failures are logged; the job keeps running, and the days it misses while
+// serve is down can be accrued with "interest backfill". Days are told by now.
+func runInterestJob(ctx context.Context, store storage.Store, now func() time.Time, interval time.Duration, expenseAccountID int64) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		yesterday := interest.Day(now()).AddDate(0, 0, -1)
+		result, err := accrueAndPost(ctx, store, yesterday, yesterday, expenseAccountID)
+		if err != nil {
+			log.Printf("Interest run failed: %v", err)
+			continue
+		}
+		for _, p := range result.Postings {
+			if p.Error != "" {
+				log.Printf("Could not post interest of account %d for %s: %s", p.AccountID, p.Month, p.Error)
+			}
+		}
+	}
+}
+
+// runInterest dispatches the "interest" subcommands.
+func runInterest(ctx context.Context, c *cmdContext, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("%w: interest requires a subcommand: backfill", errUsage)
+	}
+	switch args[0] {
+	case "backfill":
+		return runInterestBackfill(ctx, c, args[1:])
+	default:
+		return fmt.Errorf("%w: unknown interest subcommand %q", errUsage, args[0])
+	}
+}
+
+// runInterestBackfill accrues the interest of the days from --from to --to,
+// yesterday by default, and posts every month that ended by --to. Days and
+// months already done are skipped, so it can be rerun after a failure.
+func runInterestBackfill(ctx context.Context, c *cmdContext, args []string) error {
+	fs, opts := c.newFlagSet("interest backfill")
+	fromFlag := fs.String("from", "", "first day to accrue, YYYY-MM-DD (required)")
+	toFlag := fs.String("to", "", "last day to accrue, YYYY-MM-DD (default yesterday)")
+	if _, err := parse(fs, opts, args); err != nil {
+		return err
+	}
+
+	cfg, err := c.loadConfig(opts)
+	if err != nil {
+		return err
+	}
+	today := interest.Day(c.now())
+	from, err := time.Parse(time.DateOnly, *fromFlag)
+	if err != nil {
+		return fmt.Errorf("%w: invalid --from %q, want YYYY-MM-DD", errUsage, *fromFlag)
+	}
+	to := today.AddDate(0, 0, -1)
+	if *toFlag != "" {
+		if to, err = time.Parse(time.DateOnly, *toFlag); err != nil {
+			return fmt.Errorf("%w: invalid --to %q, want YYYY-MM-DD", errUsage, *toFlag)
+		}
+	}
+	switch {
+	case !to.Before(today):
+		return fmt.Errorf("%w: --to must be before today, %s", errUsage, today.Format(time.DateOnly))
+	case from.After(to):
+		return fmt.Errorf("%w: --from must not be after --to", errUsage)
+	case cfg.Interest.ExpenseAccount <= 0:
+		return fmt.Errorf("%w: interest.expense_account is required (set INTEREST_EXPENSE_ACCOUNT or --interest-expense-account)", errUsage)
+	}
+
+	store, closeStore, err := c.open(ctx, opts)
+	if err != nil {
+		return err
+	}
+	defer closeStore()
+
+	result, err := accrueAndPost(ctx, store, from, to, cfg.Interest.ExpenseAccount)
+	if err != nil {
+		return err
+	}
+	err = c.print(opts, result, func(w *tabwriter.Writer) {
+		fmt.Fprintf(w, "Days:\t%s to %s\n", result.From, result.To)
+		fmt.Fprintf(w, "Accruals:\t%d\n", result.Accruals)
+		fmt.Fprintf(w, "Postings:\t%d\n", len(result.Postings))
+		if len(result.Postings) > 0 {
+			fmt.Fprintln(w)
+			fmt.Fprintln(w, "ACCOUNT_ID\tMONTH\tAMOUNT\tENTRY_ID\tERROR")
+			for _, p := range result.Postings {
+				fmt.Fprintf(w, "%d\t%s\t%s\t%d\t%s\n", p.AccountID, p.Month, p.Amount, p.EntryID, p.Error)
+			}
+		}
+	})
+	if err != nil {
+		return err
+	}
+	failed := 0
+	for _, p := range result.Postings {
+		if p.Error != "" {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%w: %d of %d months failed", errInterestNotPosted, failed, len(result.Postings))
+	}
+	return nil
+}
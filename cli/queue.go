@@ -0,0 +1,24 @@
+package cli
+
+import (
+	"go-api-example/config"
+	"go-api-example/handler"
+	"go-api-example/hashring"
+	"go-api-example/storage"
+)
+
+// transferQueue returns the store serve executes API transfers on: store
+// itself, or a TransferQueue in front of it when cfg enables one, together
+// with the routing of transfers between the replicas in cfg. Routing has a nil
+// Ring unless several replicas are listed.
+func transferQueue(store storage.Store, cfg config.TransferQueueConfig) (storage.Store, handler.TransferRouting) {
+	if !cfg.Enabled {
+		return store, handler.TransferRouting{}
+	}
+	queue := storage.NewTransferQueue(store, storage.QueueOptions{BatchSize: cfg.BatchSize, BatchMaxAmount: cfg.BatchMaxAmount})
+	routing := handler.TransferRouting{Self: cfg.Self, Secret: cfg.ForwardSecret}
+	if peers := cfg.PeerURLs(); len(peers) > 1 {
+		routing.Ring = hashring.New(peers)
+	}
+	return queue, routing
+}
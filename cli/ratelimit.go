@@ -0,0 +1,53 @@
+package cli
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"go-api-example/config"
+	"go-api-example/handler"
+	"go-api-example/ratelimit"
+	"go-api-example/storage"
+)
+
+// rateLimitPruneInterval is how often serve deletes the shared rate limit
+// buckets that have refilled.
+const rateLimitPruneInterval = time.Minute
+
+// rateLimitPolicy returns the rate limit policy of cfg. Its Backend is nil when
+// rate limiting is disabled.
+func rateLimitPolicy(store storage.Store, cfg config.RateLimitConfig) handler.RateLimitPolicy {
+	policy := handler.RateLimitPolicy{
+		Key:      cfg.Key,
+		Read:     ratelimit.Limit{Rate: cfg.ReadRate, Burst: cfg.ReadBurst},
+		Transfer: ratelimit.Limit{Rate: cfg.TransferRate, Burst: cfg.TransferBurst},
+	}
+	// Validated with the rest of the configuration.
+	policy.Gateways, _ = cfg.GatewayPrefixes()
+	switch {
+	case cfg.Key == "":
+	case cfg.Backend == "postgres":
+		policy.Backend = ratelimit.BackendFunc(store.TakeRateLimitToken)
+	default:
+		policy.Backend = ratelimit.NewMemory(nil)
+	}
+	return policy
+}
+
+// runRateLimitPruner deletes the shared rate limit buckets that have refilled
+// every interval until ctx is cancelled. Failures are logged; the job keeps running.
+func runRateLimitPruner(ctx context.Context, store storage.Store, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if _, err := store.PruneRateLimitBuckets(ctx); err != nil {
+			log.Printf("Could not prune rate limit buckets: %v", err)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"text/tabwriter"
+	"time"
+
+	"go-api-example/metrics"
+	"go-api-example/model"
+	"go-api-example/storage"
+)
+
+// errMismatch is returned by reconcile when at least one check failed.
+var errMismatch = errors.New("reconciliation found mismatches")
+
+// Reconciliation metrics, scraped from GET /metrics while serve runs the periodic job.
+var (
+	reconcileRuns       = metrics.NewCounter("reconciliation_runs_total", "Reconciliation runs completed.")
+	reconcileFailures   = metrics.NewCounter("reconciliation_failures_total", "Reconciliation runs that could not complete.")
+	reconcileChecked    = metrics.NewGauge("reconciliation_accounts_checked", "Accounts checked by the last reconciliation run.")
+	reconcileMismatches = metrics.NewGauge("reconciliation_mismatches", "Mismatches found by the last reconciliation run.")
+	reconcileReadOnly   = metrics.NewCounter("reconciliation_read_only_accounts_total", "Accounts switched to read-only by reconciliation.")
+	reconcileLastRun    = metrics.NewGauge("reconciliation_last_run_timestamp_seconds", "Unix time of the last completed reconciliation run.")
+)
+
+// reconcile runs one reconciliation and records its outcome in the metrics.
+func reconcile(ctx context.Context, store storage.Store, opts model.ReconcileOptions) (*model.ReconciliationReport, error) {
+	report, err := store.Reconcile(ctx, opts)
+	if err != nil {
+		reconcileFailures.Inc()
+		return nil, err
+	}
+	reconcileRuns.Inc()
+	reconcileChecked.Set(float64(report.AccountsChecked))
+	reconcileMismatches.Set(float64(len(report.Mismatches)))
+	reconcileReadOnly.Add(uint64(len(report.ReadOnlyAccounts)))
+	reconcileLastRun.Set(float64(report.CreatedAt.Unix()))
+	return report, nil
+}
+
+// runReconcileJob reconciles every interval until ctx is cancelled. Failures and
+// mismatches are logged; the job keeps running.
+func runReconcileJob(ctx context.Context, store storage.Store, interval time.Duration, opts model.ReconcileOptions) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		report, err := reconcile(ctx, store, opts)
+		switch {
+		case err != nil:
+			log.Printf("Reconciliation failed: %v", err)
+		case len(report.Mismatches) > 0:
+			log.Printf("Reconciliation report %d: %d mismatches in %d accounts; read-only: %v",
+				report.ReportID, len(report.Mismatches), report.AccountsChecked, report.ReadOnlyAccounts)
+		}
+	}
+}
+
+// runReconcile recomputes balances from the ledger, stores and prints the report.
+// It exits non-zero when mismatches are found.
+func runReconcile(ctx context.Context, c *cmdContext, args []string) error {
+	fs, opts := c.newFlagSet("reconcile")
+	if _, err := parse(fs, opts, args); err != nil {
+		return err
+	}
+
+	store, closeStore, err := c.open(ctx, opts)
+	if err != nil {
+		return err
+	}
+	defer closeStore()
+
+	report, err := reconcile(ctx, store, model.ReconcileOptions{ReadOnly: opts.cfg.Reconcile.ReadOnly})
+	if err != nil {
+		return err
+	}
+	err = c.print(opts, report, func(w *tabwriter.Writer) {
+		fmt.Fprintf(w, "Report:\t%d\n", report.ReportID)
+		fmt.Fprintf(w, "Accounts checked:\t%d\n", report.AccountsChecked)
+		fmt.Fprintf(w, "Total balance:\t%s\n", report.TotalBalance)
+		fmt.Fprintf(w, "Mismatches:\t%d\n", len(report.Mismatches))
+		if len(report.Mismatches) > 0 {
+			fmt.Fprintln(w)
+			fmt.Fprintln(w, "ACCOUNT_ID\tCURRENCY\tSTORED_BALANCE\tEXPECTED_BALANCE\tREASON")
+			for _, m := range report.Mismatches {
+				account := "-"
+				if m.AccountID != 0 {
+					account = fmt.Sprint(m.AccountID)
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", account, m.Currency, m.StoredBalance, m.ExpectedBalance, m.Reason)
+			}
+		}
+		if len(report.ReadOnlyAccounts) > 0 {
+			fmt.Fprintf(w, "\nSwitched to read-only:\t%v\n", report.ReadOnlyAccounts)
+		}
+	})
+	if err != nil {
+		return err
+	}
+	if len(report.Mismatches) > 0 {
+		return errMismatch
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package cli
+
+import (
+	"context"
+	"log"
+	"slices"
+	"time"
+
+	"go-api-example/config"
+	"go-api-example/model"
+	"go-api-example/rules"
+	"go-api-example/storage"
+)
+
+// rulesLoader returns a function reading the transfer rules from the source
+// cfg names, or nil when no source is configured.
+func rulesLoader(store storage.Store, cfg config.RulesConfig) func(context.Context) ([]model.Rule, error) {
+	switch cfg.Source {
+	case config.RulesSourceFile:
+		return func(context.Context) ([]model.Rule, error) { return rules.LoadFile(cfg.File) }
+	case config.RulesSourceDB:
+		return store.TransferRules
+	}
+	return nil
+}
+
+// runRulesReloader reloads the transfer rules every interval until ctx is
+// cancelled. Rules that fail to load or compile are logged and the ones in
+// effect are kept; a successful reload is logged only when the rules changed.
+func runRulesReloader(ctx context.Context, engine *rules.Engine, load func(context.Context) ([]model.Rule, error), interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		loaded, err := load(ctx)
+		if err == nil && slices.Equal(loaded, engine.Rules()) {
+			continue
+		}
+		if err == nil {
+			err = engine.Load(loaded)
+		}
+		if err != nil {
+			log.Printf("Could not reload transfer rules, keeping the current ones: %v", err)
+			continue
+		}
+		log.Printf("Reloaded %d transfer rules", len(loaded))
+	}
+}
@@ -0,0 +1,37 @@
+package cli
+
+import (
+	"context"
+	"log"
+	"slices"
+	"time"
+
+	"go-api-example/screening"
+)
+
+// runScreeningReloader reloads the blocklist from path every interval until ctx
+// is cancelled. A blocklist that fails to load is logged and the one in effect
+// is kept; a successful reload is logged only when the list changed.
+func runScreeningReloader(ctx context.Context, screener *screening.Screener, path string, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		entries, err := screening.LoadFile(path)
+		if err == nil && slices.Equal(entries, screener.Entries()) {
+			continue
+		}
+		if err == nil {
+			err = screener.Load(entries)
+		}
+		if err != nil {
+			log.Printf("Could not reload the blocklist, keeping the current one: %v", err)
+			continue
+		}
+		log.Printf("Reloaded %d blocklist entries", len(entries))
+	}
+}
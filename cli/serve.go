@@ -0,0 +1,141 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"go-api-example/audit"
+	"go-api-example/handler"
+	"go-api-example/model"
+	"go-api-example/rules"
+	"go-api-example/screening"
+)
+
+// runServe starts the HTTP API and blocks until ctx is cancelled, then shuts down gracefully.
+func runServe(ctx context.Context, c *cmdContext, args []string) error {
+	fs, opts := c.newFlagSet("serve")
+	if _, err := parse(fs, opts, args); err != nil {
+		return err
+	}
+	cfg, err := c.loadConfig(opts)
+	if err != nil {
+		return err
+	}
+	log.Printf("Effective configuration:\n%s", cfg)
+
+	// Initialize storage
+	store, closeStore, err := c.open(ctx, opts)
+	if err != nil {
+		return fmt.Errorf("failed to initialize database: %w", err)
+	}
+	defer closeStore()
+	log.Println("Database connection established and schema initialized.")
+
+	if cfg.Reconcile.Interval > 0 {
+		go runReconcileJob(audit.WithActor(ctx, "reconcile-job"), store, cfg.Reconcile.Interval, model.ReconcileOptions{ReadOnly: cfg.Reconcile.ReadOnly})
+		log.Printf("Reconciling balances every %s", cfg.Reconcile.Interval)
+	}
+	if cfg.Interest.Interval > 0 {
+		go runInterestJob(audit.WithActor(ctx, "interest-job"), store, c.now, cfg.Interest.Interval, cfg.Interest.ExpenseAccount)
+		log.Printf("Accruing interest every %s, paid from account %d", cfg.Interest.Interval, cfg.Interest.ExpenseAccount)
+	}
+	if cfg.TransferJobs.Workers > 0 {
+		go runTransferWorkers(audit.WithActor(ctx, "transfer-worker"), store, cfg.TransferJobs.Workers, cfg.TransferJobs.PollInterval)
+		log.Printf("Running %d transfer job workers", cfg.TransferJobs.Workers)
+	}
+
+	approvals := handler.ApprovalPolicy{Threshold: cfg.Approvals.Threshold, TTL: cfg.Approvals.TTL}
+	if cfg.Approvals.Threshold.IsPositive() {
+		log.Printf("Transfers above %s need approval within %s", cfg.Approvals.Threshold, cfg.Approvals.TTL)
+	}
+
+	engine := new(rules.Engine)
+	if load := rulesLoader(store, cfg.Rules); load != nil {
+		loaded, err := load(ctx)
+		if err == nil {
+			err = engine.Load(loaded)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to load transfer rules: %w", err)
+		}
+		go runRulesReloader(ctx, engine, load, cfg.Rules.ReloadInterval)
+		log.Printf("Loaded %d transfer rules from %s, reloading every %s", len(loaded), cfg.Rules.Source, cfg.Rules.ReloadInterval)
+	}
+
+	screener := screening.New(cfg.Screening.NameThreshold)
+	if cfg.Screening.File != "" {
+		entries, err := screening.LoadFile(cfg.Screening.File)
+		if err == nil {
+			err = screener.Load(entries)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to load the blocklist: %w", err)
+		}
+		go runScreeningReloader(ctx, screener, cfg.Screening.File, cfg.Screening.ReloadInterval)
+		log.Printf("Screening transfers against %d blocklist entries (%s on match), reloading every %s",
+			len(entries), cfg.Screening.Action, cfg.Screening.ReloadInterval)
+	}
+	screeningPolicy := handler.ScreeningPolicy{Screener: screener, Action: cfg.Screening.Action}
+
+	rateLimit := rateLimitPolicy(store, cfg.RateLimit)
+	if rateLimit.Backend != nil {
+		if cfg.RateLimit.Backend == "postgres" {
+			go runRateLimitPruner(ctx, store, rateLimitPruneInterval)
+		}
+		log.Printf("Rate limiting requests by %s in %s: %g reads/s (burst %d), %g transfers/s (burst %d)", cfg.RateLimit.Key,
+			cfg.RateLimit.Backend, cfg.RateLimit.ReadRate, cfg.RateLimit.ReadBurst, cfg.RateLimit.TransferRate, cfg.RateLimit.TransferBurst)
+	}
+
+	apiStore, routing := transferQueue(store, cfg.TransferQueue)
+	if cfg.TransferQueue.Enabled {
+		log.Printf("Queueing transfers per source account, up to %d per commit (amounts up to %s)",
+			cfg.TransferQueue.BatchSize, cfg.TransferQueue.BatchMaxAmount)
+	}
+	if routing.Ring != nil {
+		log.Printf("Routing transfers between replicas %s as %s", cfg.TransferQueue.Peers, cfg.TransferQueue.Self)
+	}
+
+	routerOpts := []handler.Option{handler.WithApprovalPolicy(approvals), handler.WithRules(engine), handler.WithScreening(screeningPolicy),
+		handler.WithRateLimit(rateLimit), handler.WithTransferRouting(routing)}
+	if cfg.Database.ReadURL != "" {
+		routerOpts = append(routerOpts, handler.WithReadReplica())
+		log.Println("Serving balance reads from the read replica when their consistency allows it")
+	}
+
+	// Create and start server
+	server := &http.Server{
+		Addr:    cfg.Server.Addr,
+		Handler: handler.NewRouter(apiStore, routerOpts...),
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		log.Printf("Starting server on %s", cfg.Server.Addr)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			serveErr <- err
+		}
+		close(serveErr)
+	}()
+
+	// Wait for shutdown signal
+	select {
+	case <-ctx.Done():
+	case err := <-serveErr:
+		return fmt.Errorf("listen error: %w", err)
+	}
+	log.Println("Shutting down server...")
+
+	// Create a context for shutdown with a timeout
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer cancel()
+
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("server shutdown failed: %w", err)
+	}
+
+	log.Println("Server gracefully stopped")
+	return nil
+}
@@ -0,0 +1,51 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"text/tabwriter"
+
+	"go-api-example/model"
+
+	"github.com/shopspring/decimal"
+)
+
+// runTransfer executes a transfer with the same validation as POST /transactions,
+// charging the fee of the source account's schedule.
+func runTransfer(ctx context.Context, c *cmdContext, args []string) error {
+	fs, opts := c.newFlagSet("transfer")
+	from := fs.Int64("from", 0, "source account ID (required)")
+	to := fs.Int64("to", 0, "destination account ID (required)")
+	amount := fs.String("amount", "", "amount to transfer (required, positive)")
+	if _, err := parse(fs, opts, args); err != nil {
+		return err
+	}
+
+	amt, err := decimal.NewFromString(*amount)
+	if err != nil {
+		return fmt.Errorf("%w: invalid --amount %q", errUsage, *amount)
+	}
+	req := model.TransactionRequest{SourceAccountID: *from, DestinationAccountID: *to, Amount: amt}
+	if err := model.Validate(req); err != nil {
+		return err
+	}
+
+	store, closeStore, err := c.open(ctx, opts)
+	if err != nil {
+		return err
+	}
+	defer closeStore()
+
+	t, err := store.ExecuteTransfer(ctx, req)
+	if err != nil {
+		return err
+	}
+	fee := decimal.Zero
+	if t.Fee != nil {
+		fee = t.Fee.Amount
+	}
+	return c.print(opts, t, func(w *tabwriter.Writer) {
+		fmt.Fprintln(w, "SOURCE\tDESTINATION\tAMOUNT\tFEE\tTOTAL")
+		fmt.Fprintf(w, "%d\t%d\t%s\t%s\t%s\n", t.SourceAccountID, t.DestinationAccountID, t.Amount, fee, t.Total)
+	})
+}
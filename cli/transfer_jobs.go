@@ -0,0 +1,67 @@
+package cli
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"go-api-example/metrics"
+	"go-api-example/model"
+	"go-api-example/storage"
+)
+
+// Transfer job metrics, scraped from GET /metrics while serve runs the workers.
+var (
+	transferJobLinesSucceeded = metrics.NewCounter("transfer_job_lines_succeeded_total", "Transfer job lines executed successfully.")
+	transferJobLinesFailed    = metrics.NewCounter("transfer_job_lines_failed_total", "Transfer job lines whose transfer was rejected.")
+	transferJobWorkerErrors   = metrics.NewCounter("transfer_job_worker_errors_total", "Attempts to process a transfer job line that failed and will be retried.")
+)
+
+// runTransferWorkers runs workers goroutines that execute pending transfer job
+// lines until ctx is cancelled, and waits for them to stop. A worker that finds
+// no work, or hits an error, waits pollInterval before trying again; a line that
+// hit an error stays pending and is retried.
+func runTransferWorkers(ctx context.Context, store storage.Store, workers int, pollInterval time.Duration) {
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				if !processTransferJobLine(ctx, store) {
+					select {
+					case <-ctx.Done():
+						return
+					case <-time.After(pollInterval):
+					}
+				}
+				if ctx.Err() != nil {
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// processTransferJobLine processes one pending line, recording the outcome in the
+// metrics. It reports whether a line was processed.
+func processTransferJobLine(ctx context.Context, store storage.Store) bool {
+	line, err := store.ProcessNextTransferJobLine(ctx)
+	switch {
+	case err != nil:
+		if ctx.Err() == nil {
+			transferJobWorkerErrors.Inc()
+			log.Printf("Transfer job worker: %v", err)
+		}
+		return false
+	case line == nil:
+		return false
+	case line.Status == model.LineStatusFailed:
+		transferJobLinesFailed.Inc()
+	default:
+		transferJobLinesSucceeded.Inc()
+	}
+	return true
+}
@@ -0,0 +1,381 @@
+// Package config defines the typed application configuration and loads it
+// from, in increasing order of precedence: built-in defaults, a YAML file,
+// environment variables and command-line flags.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"gopkg.in/yaml.v3"
+)
+
+// Every leaf field carries four tags:
+//   - yaml:  key in the config file
+//   - env:   environment variable that overrides the file
+//   - flag:  command-line flag that overrides the environment
+//   - usage: help text for the flag
+
+// Config is the complete application configuration.
+type Config struct {
+	Server        ServerConfig        `yaml:"server"`
+	Database      DatabaseConfig      `yaml:"database"`
+	Reconcile     ReconcileConfig     `yaml:"reconcile"`
+	TransferJobs  TransferJobsConfig  `yaml:"transfer_jobs"`
+	Approvals     ApprovalsConfig     `yaml:"approvals"`
+	Rules         RulesConfig         `yaml:"rules"`
+	Screening     ScreeningConfig     `yaml:"screening"`
+	Interest      InterestConfig      `yaml:"interest"`
+	RateLimit     RateLimitConfig     `yaml:"rate_limit"`
+	TransferQueue TransferQueueConfig `yaml:"transfer_queue"`
+}
+
+// ServerConfig configures the HTTP server.
+type ServerConfig struct {
+	Addr            string        `yaml:"addr" env:"LISTEN_ADDR" flag:"addr" usage:"address the HTTP server listens on"`
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT" flag:"shutdown-timeout" usage:"time allowed for in-flight requests on shutdown"`
+}
+
+// DatabaseConfig configures the PostgreSQL connection pool.
+type DatabaseConfig struct {
+	URL                  string        `yaml:"url" env:"DATABASE_URL" flag:"database-url" usage:"PostgreSQL connection URL" secret:"true"`
+	URLFile              string        `yaml:"url_file" env:"DATABASE_URL_FILE" flag:"database-url-file" usage:"file containing the PostgreSQL connection URL (e.g. a mounted secret); overrides url"`
+	ConnectRetries       int           `yaml:"connect_retries" env:"DB_CONNECT_RETRIES" flag:"db-connect-retries" usage:"connection attempts at startup"`
+	ConnectRetryInterval time.Duration `yaml:"connect_retry_interval" env:"DB_CONNECT_RETRY_INTERVAL" flag:"db-connect-retry-interval" usage:"wait between connection attempts"`
+	MaxConns             int32         `yaml:"max_conns" env:"DB_MAX_CONNS" flag:"db-max-conns" usage:"maximum connections in the pool"`
+	MinConns             int32         `yaml:"min_conns" env:"DB_MIN_CONNS" flag:"db-min-conns" usage:"connections kept open when idle"`
+	StatementTimeout     time.Duration `yaml:"statement_timeout" env:"DB_STATEMENT_TIMEOUT" flag:"db-statement-timeout" usage:"PostgreSQL statement_timeout; 0 disables"`
+	LockTimeout          time.Duration `yaml:"lock_timeout" env:"DB_LOCK_TIMEOUT" flag:"db-lock-timeout" usage:"PostgreSQL lock_timeout; 0 disables"`
+	TxAttempts           int           `yaml:"tx_attempts" env:"DB_TX_ATTEMPTS" flag:"db-tx-attempts" usage:"attempts of a write transaction aborted by a serialization failure or deadlock; 1 disables retries"`
+	TxRetryBaseDelay     time.Duration `yaml:"tx_retry_base_delay" env:"DB_TX_RETRY_BASE_DELAY" flag:"db-tx-retry-base-delay" usage:"backoff before the first transaction retry, doubled for each further one"`
+	TxRetryMaxDelay      time.Duration `yaml:"tx_retry_max_delay" env:"DB_TX_RETRY_MAX_DELAY" flag:"db-tx-retry-max-delay" usage:"longest backoff between transaction retries"`
+	ReadURL              string        `yaml:"read_url" env:"READ_DATABASE_URL" flag:"read-database-url" usage:"PostgreSQL connection URL of a read replica serving balance reads; empty reads from the primary" secret:"true"`
+	ReplicaCheckInterval time.Duration `yaml:"replica_check_interval" env:"DB_REPLICA_CHECK_INTERVAL" flag:"db-replica-check-interval" usage:"how often the read replica's health and lag are checked"`
+	ReplicaMaxLag        time.Duration `yaml:"replica_max_lag" env:"DB_REPLICA_MAX_LAG" flag:"db-replica-max-lag" usage:"lag behind the primary beyond which the read replica counts as unhealthy; 0 disables"`
+}
+
+// ReconcileConfig configures reconciliation of balances against the ledger.
+type ReconcileConfig struct {
+	Interval time.Duration `yaml:"interval" env:"RECONCILE_INTERVAL" flag:"reconcile-interval" usage:"how often serve reconciles balances in the background; 0 disables"`
+	ReadOnly bool          `yaml:"read_only" env:"RECONCILE_READ_ONLY" flag:"reconcile-read-only" usage:"switch accounts with a reconciliation mismatch to read-only"`
+}
+
+// TransferJobsConfig configures the background workers that execute transfer jobs.
+type TransferJobsConfig struct {
+	Workers      int           `yaml:"workers" env:"TRANSFER_JOB_WORKERS" flag:"transfer-job-workers" usage:"transfer job lines serve executes concurrently; 0 disables"`
+	PollInterval time.Duration `yaml:"poll_interval" env:"TRANSFER_JOB_POLL_INTERVAL" flag:"transfer-job-poll-interval" usage:"how long an idle transfer job worker waits before looking for work again"`
+}
+
+// ApprovalsConfig configures maker-checker approval of large transfers.
+type ApprovalsConfig struct {
+	Threshold decimal.Decimal `yaml:"threshold" env:"APPROVAL_THRESHOLD" flag:"approval-threshold" usage:"transfers above this amount wait for approval by a second principal; 0 disables"`
+	TTL       time.Duration   `yaml:"ttl" env:"APPROVAL_TTL" flag:"approval-ttl" usage:"how long a held transfer waits for a decision before it expires"`
+}
+
+// Sources of transfer rules accepted by RulesConfig.Source.
+const (
+	RulesSourceFile = "file"
+	RulesSourceDB   = "db"
+)
+
+// RulesConfig configures where serve loads the transfer rules from.
+type RulesConfig struct {
+	Source         string        `yaml:"source" env:"RULES_SOURCE" flag:"rules-source" usage:"where transfer rules are loaded from: file, db, or empty for no rules"`
+	File           string        `yaml:"file" env:"RULES_FILE" flag:"rules-file" usage:"YAML file of transfer rules, when rules.source is file"`
+	ReloadInterval time.Duration `yaml:"reload_interval" env:"RULES_RELOAD_INTERVAL" flag:"rules-reload-interval" usage:"how often serve reloads the transfer rules"`
+}
+
+// ScreeningConfig configures screening of transfers against a blocklist.
+type ScreeningConfig struct {
+	File           string        `yaml:"file" env:"SCREENING_FILE" flag:"screening-file" usage:"CSV blocklist of account IDs and owner names; empty disables screening"`
+	NameThreshold  float64       `yaml:"name_threshold" env:"SCREENING_NAME_THRESHOLD" flag:"screening-name-threshold" usage:"lowest owner name similarity, from 0 to 1, that matches a blocklist name"`
+	Action         string        `yaml:"action" env:"SCREENING_ACTION" flag:"screening-action" usage:"what a match does to a transfer: block or review"`
+	ReloadInterval time.Duration `yaml:"reload_interval" env:"SCREENING_RELOAD_INTERVAL" flag:"screening-reload-interval" usage:"how often serve reloads the blocklist"`
+}
+
+// InterestConfig configures the daily accrual and monthly posting of interest.
+type InterestConfig struct {
+	ExpenseAccount int64         `yaml:"expense_account" env:"INTEREST_EXPENSE_ACCOUNT" flag:"interest-expense-account" usage:"account interest is paid from"`
+	Interval       time.Duration `yaml:"interval" env:"INTEREST_INTERVAL" flag:"interest-interval" usage:"how often serve accrues and posts interest in the background; 0 disables"`
+}
+
+// RateLimitConfig configures token-bucket rate limiting of API requests.
+type RateLimitConfig struct {
+	Key           string  `yaml:"key" env:"RATE_LIMIT_KEY" flag:"rate-limit-key" usage:"what requests are counted against: client, ip or account; empty disables rate limiting"`
+	Backend       string  `yaml:"backend" env:"RATE_LIMIT_BACKEND" flag:"rate-limit-backend" usage:"where the buckets are kept: memory (per replica) or postgres (shared by all replicas)"`
+	ReadRate      float64 `yaml:"read_rate" env:"RATE_LIMIT_READ_RATE" flag:"rate-limit-read-rate" usage:"read requests per second each key is allowed"`
+	ReadBurst     int     `yaml:"read_burst" env:"RATE_LIMIT_READ_BURST" flag:"rate-limit-read-burst" usage:"read requests each key can make at once"`
+	TransferRate  float64 `yaml:"transfer_rate" env:"RATE_LIMIT_TRANSFER_RATE" flag:"rate-limit-transfer-rate" usage:"transfer and other write requests per second each key is allowed"`
+	TransferBurst int     `yaml:"transfer_burst" env:"RATE_LIMIT_TRANSFER_BURST" flag:"rate-limit-transfer-burst" usage:"transfer and other write requests each key can make at once"`
+	Gateways      string  `yaml:"gateways" env:"RATE_LIMIT_GATEWAYS" flag:"rate-limit-gateways" usage:"comma-separated IP addresses or CIDR prefixes of the gateways that set X-Principal; required by the client key"`
+}
+
+// GatewayPrefixes returns the gateways listed in Gateways, an address being a
+// prefix of its own.
+func (c RateLimitConfig) GatewayPrefixes() ([]netip.Prefix, error) {
+	var gateways []netip.Prefix
+	for _, g := range strings.Split(c.Gateways, ",") {
+		if g = strings.TrimSpace(g); g == "" {
+			continue
+		}
+		if addr, err := netip.ParseAddr(g); err == nil {
+			gateways = append(gateways, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(g)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not an IP address or CIDR prefix", g)
+		}
+		gateways = append(gateways, prefix.Masked())
+	}
+	return gateways, nil
+}
+
+// TransferQueueConfig configures the in-process queues transfers from the same
+// account go through, and the replicas they are routed between.
+type TransferQueueConfig struct {
+	Enabled        bool            `yaml:"enabled" env:"TRANSFER_QUEUE_ENABLED" flag:"transfer-queue-enabled" usage:"execute transfers through in-process queues, one per source account, instead of contending for row locks"`
+	BatchSize      int             `yaml:"batch_size" env:"TRANSFER_QUEUE_BATCH_SIZE" flag:"transfer-queue-batch-size" usage:"queued transfers committed together at most"`
+	BatchMaxAmount decimal.Decimal `yaml:"batch_max_amount" env:"TRANSFER_QUEUE_BATCH_MAX_AMOUNT" flag:"transfer-queue-batch-max-amount" usage:"transfers above this amount are committed alone; 0 batches every transfer"`
+	Self           string          `yaml:"self" env:"TRANSFER_QUEUE_SELF" flag:"transfer-queue-self" usage:"URL of this replica, as listed in transfer_queue.peers"`
+	Peers          string          `yaml:"peers" env:"TRANSFER_QUEUE_PEERS" flag:"transfer-queue-peers" usage:"comma-separated URLs of every replica, to route each transfer to the one owning its source account; empty for a single replica"`
+	ForwardSecret  string          `yaml:"forward_secret" env:"TRANSFER_QUEUE_FORWARD_SECRET" flag:"transfer-queue-forward-secret" usage:"secret shared by the replicas in transfer_queue.peers to sign the transfers they forward to each other; required with several peers" secret:"true"`
+}
+
+// PeerURLs returns the replicas listed in Peers.
+func (c TransferQueueConfig) PeerURLs() []string {
+	var peers []string
+	for _, p := range strings.Split(c.Peers, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			peers = append(peers, p)
+		}
+	}
+	return peers
+}
+
+// Default returns the configuration used when nothing else is set.
+// These are the values that used to be hardcoded in main.go and NewPostgresStore.
+func Default() Config {
+	return Config{
+		Server: ServerConfig{
+			Addr:            ":8080",
+			ShutdownTimeout: 5 * time.Second,
+		},
+		Database: DatabaseConfig{
+			ConnectRetries:       5,
+			ConnectRetryInterval: time.Second,
+			MaxConns:             10,
+			MinConns:             0,
+			StatementTimeout:     30 * time.Second,
+			TxAttempts:           4,
+			TxRetryBaseDelay:     10 * time.Millisecond,
+			TxRetryMaxDelay:      500 * time.Millisecond,
+			ReplicaCheckInterval: 5 * time.Second,
+		},
+		TransferJobs: TransferJobsConfig{
+			Workers:      4,
+			PollInterval: time.Second,
+		},
+		Approvals: ApprovalsConfig{
+			TTL: 24 * time.Hour,
+		},
+		Rules: RulesConfig{
+			ReloadInterval: 10 * time.Second,
+		},
+		Screening: ScreeningConfig{
+			NameThreshold:  0.9,
+			Action:         "block",
+			ReloadInterval: time.Minute,
+		},
+		RateLimit: RateLimitConfig{
+			Backend:       "memory",
+			ReadRate:      50,
+			ReadBurst:     100,
+			TransferRate:  5,
+			TransferBurst: 10,
+		},
+		TransferQueue: TransferQueueConfig{
+			BatchSize:      32,
+			BatchMaxAmount: decimal.NewFromInt(1000),
+		},
+	}
+}
+
+// Load builds the effective configuration. The file path comes from the
+// --config flag, falling back to the CONFIG_FILE environment variable.
+// The result has already been validated.
+func Load(flags *Flags, getenv func(string) string) (*Config, error) {
+	cfg := Default()
+
+	path := flags.configPath
+	if path == "" {
+		path = getenv("CONFIG_FILE")
+	}
+	if path != "" {
+		if err := loadFile(&cfg, path); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := applyEnv(&cfg, getenv); err != nil {
+		return nil, err
+	}
+	if err := flags.apply(&cfg); err != nil {
+		return nil, err
+	}
+
+	if cfg.Database.URLFile != "" {
+		b, err := os.ReadFile(cfg.Database.URLFile)
+		if err != nil {
+			return nil, fmt.Errorf("could not read database URL file: %w", err)
+		}
+		cfg.Database.URL = strings.TrimSpace(string(b))
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+func loadFile(cfg *Config, path string) error {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+	default:
+		return fmt.Errorf("config file %s: only YAML (.yaml, .yml) is supported", path)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("could not open config file: %w", err)
+	}
+	defer f.Close()
+
+	dec := yaml.NewDecoder(f)
+	dec.KnownFields(true)
+	if err := dec.Decode(cfg); err != nil {
+		return fmt.Errorf("could not parse config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// Validate reports every invalid setting at once.
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(c.Server.Addr != "", "server.addr is required")
+	check(c.Server.ShutdownTimeout > 0, "server.shutdown_timeout must be positive")
+
+	check(c.Database.URL != "", "database.url is required (set DATABASE_URL, DATABASE_URL_FILE or --database-url)")
+	check(c.Database.ConnectRetries >= 1, "database.connect_retries must be at least 1")
+	check(c.Database.ConnectRetryInterval >= 0, "database.connect_retry_interval cannot be negative")
+	check(c.Database.MaxConns >= 1, "database.max_conns must be at least 1")
+	check(c.Database.MinConns >= 0, "database.min_conns cannot be negative")
+	check(c.Database.MinConns <= c.Database.MaxConns, "database.min_conns (%d) cannot exceed database.max_conns (%d)", c.Database.MinConns, c.Database.MaxConns)
+	check(c.Database.StatementTimeout >= 0, "database.statement_timeout cannot be negative")
+	check(c.Database.LockTimeout >= 0, "database.lock_timeout cannot be negative")
+	check(c.Database.TxAttempts >= 1, "database.tx_attempts must be at least 1")
+	check(c.Database.TxRetryBaseDelay > 0, "database.tx_retry_base_delay must be positive")
+	check(c.Database.TxRetryMaxDelay >= c.Database.TxRetryBaseDelay, "database.tx_retry_max_delay cannot be less than database.tx_retry_base_delay")
+	check(c.Database.ReplicaCheckInterval > 0, "database.replica_check_interval must be positive")
+	check(c.Database.ReplicaMaxLag >= 0, "database.replica_max_lag cannot be negative")
+
+	check(c.Reconcile.Interval >= 0, "reconcile.interval cannot be negative")
+
+	check(c.TransferJobs.Workers >= 0, "transfer_jobs.workers cannot be negative")
+	check(c.TransferJobs.PollInterval > 0, "transfer_jobs.poll_interval must be positive")
+
+	check(!c.Approvals.Threshold.IsNegative(), "approvals.threshold cannot be negative")
+	check(c.Approvals.TTL > 0, "approvals.ttl must be positive")
+
+	check(c.Rules.Source == "" || c.Rules.Source == RulesSourceFile || c.Rules.Source == RulesSourceDB,
+		"rules.source must be file, db or empty, not %q", c.Rules.Source)
+	check(c.Rules.Source != RulesSourceFile || c.Rules.File != "", "rules.file is required when rules.source is file")
+	check(c.Rules.ReloadInterval > 0, "rules.reload_interval must be positive")
+
+	check(c.Screening.NameThreshold > 0 && c.Screening.NameThreshold <= 1, "screening.name_threshold must be above 0 and at most 1")
+	check(c.Screening.Action == "block" || c.Screening.Action == "review", "screening.action must be block or review, not %q", c.Screening.Action)
+	check(c.Screening.ReloadInterval > 0, "screening.reload_interval must be positive")
+
+	check(c.Interest.ExpenseAccount >= 0, "interest.expense_account cannot be negative")
+	check(c.Interest.Interval >= 0, "interest.interval cannot be negative")
+	check(c.Interest.Interval == 0 || c.Interest.ExpenseAccount > 0, "interest.expense_account is required when interest.interval is set")
+
+	check(c.RateLimit.Key == "" || c.RateLimit.Key == "client" || c.RateLimit.Key == "ip" || c.RateLimit.Key == "account",
+		"rate_limit.key must be client, ip, account or empty, not %q", c.RateLimit.Key)
+	check(c.RateLimit.Backend == "memory" || c.RateLimit.Backend == "postgres", "rate_limit.backend must be memory or postgres, not %q", c.RateLimit.Backend)
+	check(c.RateLimit.ReadRate > 0, "rate_limit.read_rate must be positive")
+	check(c.RateLimit.ReadBurst >= 1, "rate_limit.read_burst must be at least 1")
+	check(c.RateLimit.TransferRate > 0, "rate_limit.transfer_rate must be positive")
+	check(c.RateLimit.TransferBurst >= 1, "rate_limit.transfer_burst must be at least 1")
+	if gateways, err := c.RateLimit.GatewayPrefixes(); err != nil {
+		check(false, "rate_limit.gateways: %v", err)
+	} else {
+		check(c.RateLimit.Key != "client" || len(gateways) > 0,
+			"rate_limit.gateways is required when rate_limit.key is client, as X-Principal is only trusted from a gateway")
+	}
+
+	check(c.TransferQueue.BatchSize >= 1, "transfer_queue.batch_size must be at least 1")
+	check(!c.TransferQueue.BatchMaxAmount.IsNegative(), "transfer_queue.batch_max_amount cannot be negative")
+	if peers := c.TransferQueue.PeerURLs(); len(peers) > 0 {
+		check(c.TransferQueue.Enabled, "transfer_queue.peers requires transfer_queue.enabled")
+		for _, p := range peers {
+			u, err := url.Parse(p)
+			check(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "", "transfer_queue.peers: %q is not an http or https URL", p)
+		}
+		check(slices.Contains(peers, c.TransferQueue.Self), "transfer_queue.self (%q) must be one of transfer_queue.peers", c.TransferQueue.Self)
+		check(len(peers) == 1 || c.TransferQueue.ForwardSecret != "",
+			"transfer_queue.forward_secret is required with several transfer_queue.peers, as forwarded transfers are only trusted when signed with it")
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid configuration: %w", errors.Join(errs...))
+	}
+	return nil
+}
+
+// dsnPassword matches password=... in a key/value connection string.
+var dsnPassword = regexp.MustCompile(`(password\s*=\s*)('[^']*'|\S+)`)
+
+// Redacted returns a copy of c with secrets masked, safe to log.
+func (c Config) Redacted() Config {
+	c.Database.URL = redactURL(c.Database.URL)
+	c.Database.ReadURL = redactURL(c.Database.ReadURL)
+	if c.TransferQueue.ForwardSecret != "" {
+		c.TransferQueue.ForwardSecret = "xxxxx"
+	}
+	return c
+}
+
+// String renders the redacted configuration as YAML.
+func (c Config) String() string {
+	b, err := yaml.Marshal(c.Redacted())
+	if err != nil {
+		return fmt.Sprintf("<unprintable config: %v>", err)
+	}
+	return string(b)
+}
+
+func redactURL(raw string) string {
+	if raw == "" {
+		return ""
+	}
+	if u, err := url.Parse(raw); err == nil && u.Scheme != "" {
+		return u.Redacted()
+	}
+	return dsnPassword.ReplaceAllString(raw, "${1}xxxxx")
+}
@@ -0,0 +1,189 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// field is one leaf setting of Config, found through its struct tags.
+type field struct {
+	key   string // dotted YAML path, e.g. "database.max_conns"
+	env   string
+	flag  string
+	usage string
+	index []int
+	kind  reflect.Kind
+}
+
+var (
+	durationType = reflect.TypeOf(time.Duration(0))
+	decimalType  = reflect.TypeOf(decimal.Decimal{})
+)
+
+// fields lists every leaf setting of Config in declaration order.
+var fields = collectFields(reflect.TypeOf(Config{}), "", nil)
+
+func collectFields(t reflect.Type, prefix string, index []int) []field {
+	var out []field
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		key := sf.Tag.Get("yaml")
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+		idx := append(append([]int(nil), index...), i)
+		if sf.Type.Kind() == reflect.Struct && sf.Type != decimalType {
+			out = append(out, collectFields(sf.Type, key, idx)...)
+			continue
+		}
+		out = append(out, field{
+			key:   key,
+			env:   sf.Tag.Get("env"),
+			flag:  sf.Tag.Get("flag"),
+			usage: sf.Tag.Get("usage"),
+			index: idx,
+			kind:  sf.Type.Kind(),
+		})
+	}
+	return out
+}
+
+// set parses raw into the field of cfg.
+func (f field) set(cfg *Config, raw string) error {
+	v := reflect.ValueOf(cfg).Elem().FieldByIndex(f.index)
+	switch {
+	case v.Type() == durationType:
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return fmt.Errorf("%s: invalid duration %q", f.key, raw)
+		}
+		v.SetInt(int64(d))
+	case v.Type() == decimalType:
+		d, err := decimal.NewFromString(raw)
+		if err != nil {
+			return fmt.Errorf("%s: invalid decimal %q", f.key, raw)
+		}
+		v.Set(reflect.ValueOf(d))
+	case v.Kind() == reflect.String:
+		v.SetString(raw)
+	case v.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("%s: invalid boolean %q", f.key, raw)
+		}
+		v.SetBool(b)
+	case v.CanInt():
+		n, err := strconv.ParseInt(raw, 10, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("%s: invalid integer %q", f.key, raw)
+		}
+		v.SetInt(n)
+	case v.CanFloat():
+		n, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return fmt.Errorf("%s: invalid number %q", f.key, raw)
+		}
+		v.SetFloat(n)
+	default:
+		return fmt.Errorf("%s: unsupported type %s", f.key, v.Type())
+	}
+	return nil
+}
+
+// get formats the field of cfg as a string.
+func (f field) get(cfg *Config) string {
+	v := reflect.ValueOf(cfg).Elem().FieldByIndex(f.index)
+	switch v.Type() {
+	case durationType:
+		return time.Duration(v.Int()).String()
+	case decimalType:
+		return v.Interface().(decimal.Decimal).String()
+	}
+	return fmt.Sprint(v.Interface())
+}
+
+func applyEnv(cfg *Config, getenv func(string) string) error {
+	for _, f := range fields {
+		if f.env == "" {
+			continue
+		}
+		if raw := getenv(f.env); raw != "" {
+			if err := f.set(cfg, raw); err != nil {
+				return fmt.Errorf("environment variable %s: %w", f.env, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Flags holds the configuration flags registered on a FlagSet. Only flags the
+// user actually set are applied, so an unset flag never hides the file or environment.
+type Flags struct {
+	configPath string
+	values     map[string]string // flag name -> raw value, for flags that were set
+}
+
+// RegisterFlags adds --config and one flag per configuration field to fs.
+func RegisterFlags(fs *flag.FlagSet) *Flags {
+	flags := &Flags{values: map[string]string{}}
+	fs.StringVar(&flags.configPath, "config", "", "YAML configuration file (env CONFIG_FILE)")
+
+	defaults := Default()
+	for _, f := range fields {
+		if f.flag == "" {
+			continue
+		}
+		usage := f.usage
+		if f.env != "" {
+			usage += " (env " + f.env + ")"
+		}
+		fs.Var(&flagValue{name: f.flag, flags: flags, def: f.get(&defaults), isBool: f.kind == reflect.Bool}, f.flag, usage)
+	}
+	return flags
+}
+
+func (flags *Flags) apply(cfg *Config) error {
+	for _, f := range fields {
+		raw, ok := flags.values[f.flag]
+		if !ok {
+			continue
+		}
+		if err := f.set(cfg, raw); err != nil {
+			return fmt.Errorf("flag --%s: %w", f.flag, err)
+		}
+	}
+	return nil
+}
+
+// flagValue records the raw string of a configuration flag when it is set.
+// Boolean settings may be given without a value, as --name.
+type flagValue struct {
+	name   string
+	flags  *Flags
+	def    string
+	isBool bool
+}
+
+func (v *flagValue) String() string {
+	if v == nil || v.flags == nil {
+		return ""
+	}
+	if raw, ok := v.flags.values[v.name]; ok {
+		return raw
+	}
+	return v.def
+}
+
+func (v *flagValue) Set(raw string) error {
+	v.flags.values[v.name] = raw
+	return nil
+}
+
+func (v *flagValue) IsBoolFlag() bool {
+	return v.isBool
+}
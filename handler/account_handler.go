@@ -1,16 +1,17 @@
 package handler
 
 import (
-	"encoding/json"
 	"errors"
-	"log"
+	"fmt"
 	"net/http"
 	"strconv"
+	"time"
 
 	"go-api-example/model"
 	"go-api-example/storage"
 
 	"github.com/gorilla/mux"
+	"github.com/shopspring/decimal"
 )
 
 // AccountHandler holds dependencies for account-related handlers.
@@ -24,41 +25,39 @@ func NewAccountHandler(store storage.Store) *AccountHandler {
 }
 
 // CreateAccountHandler handles the creation of a new bank account.
-// It expects a JSON body with "account_id" and "initial_balance".
+// It expects a JSON body with "account_id", "initial_balance" and an optional "currency".
+// The account ID must be positive and the initial balance non-negative.
 // This endpoint is idempotent.
 //
 // Method: POST
 // Path: /accounts
 // Success: 201 Created (if new) or 200 OK (if exists)
 // Error: 400 Bad Request (for invalid JSON or validation failure)
+// Error: 413 Request Entity Too Large (body over 1 MiB)
+// Error: 415 Unsupported Media Type (Content-Type is not application/json)
 // Error: 500 Internal Server Error (for database errors)
 func (h *AccountHandler) CreateAccountHandler(w http.ResponseWriter, r *http.Request) {
 	var req model.CreateAccountRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
-		return
-	}
-
-	if req.InitialBalance.IsNegative() {
-		http.Error(w, "Initial balance cannot be negative", http.StatusBadRequest)
+	if !decodeAndValidate(w, r, &req) {
 		return
 	}
 
 	// Check if account already exists to determine status code
 	existingAcc, err := h.store.GetAccount(r.Context(), req.AccountID)
 	if err != nil && !errors.Is(err, storage.ErrNotFound) {
-		http.Error(w, "Could not check for existing account", http.StatusInternalServerError)
+		writeError(w, r, err)
 		return
 	}
 
 	acc := model.Account{
 		AccountID: req.AccountID,
 		Balance:   req.InitialBalance,
+		Currency:  req.Currency,
+		OwnerName: req.OwnerName,
 	}
 
 	if err := h.store.CreateAccount(r.Context(), acc); err != nil {
-		log.Printf("Error creating account: %v", err)
-		http.Error(w, "Failed to create account", http.StatusInternalServerError)
+		writeError(w, r, err)
 		return
 	}
 
@@ -69,51 +68,220 @@ func (h *AccountHandler) CreateAccountHandler(w http.ResponseWriter, r *http.Req
 	}
 }
 
+// ListAccountsHandler returns one page of accounts. Accounts can be filtered by
+// balance range, creation time range (created_from inclusive, created_to exclusive),
+// status and currency, and sorted by account_id (default), balance or created_at,
+// ascending (default) or descending; ties are broken by account ID.
+// The response carries opaque "next_cursor" and "prev_cursor" values; pass one back
+// as "cursor", together with the same sort and order, to fetch the neighbouring page.
+//
+// Method: GET
+// Path: /accounts?limit=<n>&cursor=<c>&sort=<key>&order=<asc|desc>&status=&currency=&min_balance=&max_balance=&created_from=&created_to=
+// Success: 200 OK
+// Error: 400 Bad Request (for invalid query parameters or a cursor issued for another sort order)
+// Error: 500 Internal Server Error (for database errors)
+func (h *AccountHandler) ListAccountsHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	filter := model.AccountFilter{
+		Limit:    100,
+		Status:   q.Get("status"),
+		Currency: q.Get("currency"),
+		Sort:     q.Get("sort"),
+	}
+	if filter.Sort == "" {
+		filter.Sort = model.SortAccountID
+	}
+
+	var limit int64 = int64(filter.Limit)
+	if !queryInt64(w, r, "limit", &limit) ||
+		!queryDecimal(w, r, "min_balance", &filter.MinBalance) ||
+		!queryDecimal(w, r, "max_balance", &filter.MaxBalance) ||
+		!queryTime(w, r, "created_from", &filter.CreatedFrom) ||
+		!queryTime(w, r, "created_to", &filter.CreatedBefore) {
+		return
+	}
+	filter.Limit = int(limit)
+
+	invalid := func(detail string) {
+		writeProblem(w, newProblem(r, http.StatusBadRequest, CodeInvalidRequest, "Invalid query parameter", detail))
+	}
+	switch filter.Sort {
+	case model.SortAccountID, model.SortBalance, model.SortCreatedAt:
+	default:
+		invalid(`Query parameter "sort" must be one of account_id, balance, created_at`)
+		return
+	}
+	switch q.Get("order") {
+	case "", "asc":
+	case "desc":
+		filter.Desc = true
+	default:
+		invalid(`Query parameter "order" must be asc or desc`)
+		return
+	}
+	switch filter.Status {
+	case "", model.AccountStatusActive, model.AccountStatusFrozen, model.AccountStatusReadOnly:
+	default:
+		invalid(`Query parameter "status" must be one of active, frozen, read_only`)
+		return
+	}
+	if filter.Currency != "" && !model.IsCurrencyCode(filter.Currency) {
+		invalid(`Query parameter "currency" must be a three-letter ISO 4217 code`)
+		return
+	}
+	if raw := q.Get("cursor"); raw != "" {
+		if err := applyCursor(raw, &filter); err != nil {
+			invalid(`Query parameter "cursor" is not valid for this sort and order`)
+			return
+		}
+	}
+
+	page, err := h.store.ListAccounts(r.Context(), filter)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	page.NextCursor = encodeCursor(filter, page.Next, false)
+	page.PrevCursor = encodeCursor(filter, page.Prev, true)
+
+	writeJSON(w, http.StatusOK, page)
+}
+
 // GetAccountHandler handles retrieving a specific account's balance.
-// It expects an "account_id" as a URL path parameter.
+// It expects an "account_id" as a URL path parameter. With the optional
+// "as_of" query parameter (RFC 3339) it returns the balance at that instant.
 //
 // Method: GET
-// Path: /accounts/{account_id}
+// Path: /accounts/{account_id}?as_of=<RFC3339>
 // Success: 200 OK
-// Error: 400 Bad Request (for invalid account ID format)
-// Error: 404 Not Found (if account does not exist)
+// Error: 400 Bad Request (for invalid account ID format or as_of timestamp)
+// Error: 404 Not Found (if account does not exist, or did not exist yet at as_of)
 // Error: 500 Internal Server Error (for database errors)
 func (h *AccountHandler) GetAccountHandler(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	idStr, ok := vars["account_id"]
+	accountID, ok := accountIDFromPath(w, r)
 	if !ok {
-		http.Error(w, "Account ID is required", http.StatusBadRequest)
 		return
 	}
 
-	accountID, err := strconv.ParseInt(idStr, 10, 64)
+	var asOf time.Time
+	if !queryTime(w, r, "as_of", &asOf) {
+		return
+	}
+
+	var account *model.Account
+	var err error
+	if asOf.IsZero() {
+		account, err = h.store.GetAccount(r.Context(), accountID)
+	} else {
+		account, err = h.store.GetAccountAsOf(r.Context(), accountID, asOf)
+	}
 	if err != nil {
-		http.Error(w, "Invalid account ID format", http.StatusBadRequest)
+		writeError(w, r, err)
 		return
 	}
 
-	account, err := h.store.GetAccount(r.Context(), accountID)
+	writeJSON(w, http.StatusOK, account)
+}
+
+// GetAccountPostingsHandler returns the postings behind an account's balance,
+// oldest first, together with the opening balance they start from.
+// Pages are requested with the "after" query parameter set to the previous
+// response's "next_after".
+//
+// Method: GET
+// Path: /accounts/{account_id}/postings?after=<posting_id>&limit=<n>
+// Success: 200 OK
+// Error: 400 Bad Request (for invalid account ID or query parameters)
+// Error: 404 Not Found (if account does not exist)
+// Error: 500 Internal Server Error (for database errors)
+func (h *AccountHandler) GetAccountPostingsHandler(w http.ResponseWriter, r *http.Request) {
+	accountID, ok := accountIDFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	filter := model.PostingFilter{Limit: 100}
+	if !queryInt64(w, r, "after", &filter.AfterID) {
+		return
+	}
+	var limit int64 = int64(filter.Limit)
+	if !queryInt64(w, r, "limit", &limit) {
+		return
+	}
+	filter.Limit = int(limit)
+
+	ledger, err := h.store.GetAccountPostings(r.Context(), accountID, filter)
 	if err != nil {
-		if errors.Is(err, storage.ErrNotFound) {
-			http.Error(w, "Account not found", http.StatusNotFound)
-		} else {
-			log.Printf("Error getting account: %v", err)
-			http.Error(w, "Failed to retrieve account", http.StatusInternalServerError)
-		}
+		writeError(w, r, err)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(account); err != nil {
-		log.Printf("Error encoding response: %v", err)
-		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+	writeJSON(w, http.StatusOK, ledger)
+}
+
+// accountIDFromPath parses the {account_id} path variable, writing a problem response on failure.
+func accountIDFromPath(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	idStr, ok := mux.Vars(r)["account_id"]
+	if !ok {
+		writeProblem(w, newProblem(r, http.StatusBadRequest, CodeInvalidAccountID, "Invalid account ID", "Account ID is required"))
+		return 0, false
+	}
+
+	accountID, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		writeProblem(w, newProblem(r, http.StatusBadRequest, CodeInvalidAccountID, "Invalid account ID", "Invalid account ID format"))
+		return 0, false
+	}
+	return accountID, true
+}
+
+// queryInt64 parses an optional integer query parameter into dst, leaving dst
+// unchanged when the parameter is absent. It writes a problem response on failure.
+func queryInt64(w http.ResponseWriter, r *http.Request, name string, dst *int64) bool {
+	raw := r.URL.Query().Get(name)
+	if raw == "" {
+		return true
 	}
+	v, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil {
+		writeProblem(w, newProblem(r, http.StatusBadRequest, CodeInvalidRequest, "Invalid query parameter",
+			fmt.Sprintf("Query parameter %q must be an integer", name)))
+		return false
+	}
+	*dst = v
+	return true
 }
 
-func writeJSON(w http.ResponseWriter, status int, v any) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(status)
-	if err := json.NewEncoder(w).Encode(v); err != nil {
-		log.Printf("Error writing JSON response: %v", err)
+// queryTime parses an optional RFC 3339 timestamp query parameter into dst, leaving
+// dst unchanged when the parameter is absent. It writes a problem response on failure.
+func queryTime(w http.ResponseWriter, r *http.Request, name string, dst *time.Time) bool {
+	raw := r.URL.Query().Get(name)
+	if raw == "" {
+		return true
+	}
+	v, err := time.Parse(time.RFC3339Nano, raw)
+	if err != nil {
+		writeProblem(w, newProblem(r, http.StatusBadRequest, CodeInvalidRequest, "Invalid query parameter",
+			fmt.Sprintf("Query parameter %q must be an RFC 3339 timestamp", name)))
+		return false
+	}
+	*dst = v
+	return true
+}
+
+// queryDecimal parses an optional decimal query parameter into dst, leaving dst
+// nil when the parameter is absent. It writes a problem response on failure.
+func queryDecimal(w http.ResponseWriter, r *http.Request, name string, dst **decimal.Decimal) bool {
+	raw := r.URL.Query().Get(name)
+	if raw == "" {
+		return true
+	}
+	v, err := decimal.NewFromString(raw)
+	if err != nil {
+		writeProblem(w, newProblem(r, http.StatusBadRequest, CodeInvalidRequest, "Invalid query parameter",
+			fmt.Sprintf("Query parameter %q must be a decimal number", name)))
+		return false
 	}
+	*dst = &v
+	return true
 }
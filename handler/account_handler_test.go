@@ -7,20 +7,61 @@ import (
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"go-api-example/model"
+	"go-api-example/ratelimit"
 	"go-api-example/storage"
 
 	"github.com/gorilla/mux"
 	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 // MockStore provides a mock implementation of the storage.Store for testing.
 type MockStore struct {
-	CreateAccountFunc   func(ctx context.Context, acc model.Account) error
-	GetAccountFunc      func(ctx context.Context, id int64) (*model.Account, error)
-	ExecuteTransferFunc func(ctx context.Context, req model.TransactionRequest) error
+	CreateAccountFunc               func(ctx context.Context, acc model.Account) error
+	GetAccountFunc                  func(ctx context.Context, id int64) (*model.Account, error)
+	ExecuteTransferFunc             func(ctx context.Context, req model.TransactionRequest) (*model.Transfer, error)
+	ListAccountsFunc                func(ctx context.Context, filter model.AccountFilter) (*model.AccountPage, error)
+	SetAccountStatusFunc            func(ctx context.Context, id int64, status string) error
+	ReconcileFunc                   func(ctx context.Context, opts model.ReconcileOptions) (*model.ReconciliationReport, error)
+	GetAccountPostingsFunc          func(ctx context.Context, id int64, filter model.PostingFilter) (*model.AccountLedger, error)
+	PostJournalEntryFunc            func(ctx context.Context, req model.JournalEntryRequest) (*model.JournalEntry, error)
+	GetAccountAsOfFunc              func(ctx context.Context, id int64, asOf time.Time) (*model.Account, error)
+	AuditLogFunc                    func(ctx context.Context, filter model.AuditFilter) ([]model.AuditRecord, error)
+	CreateAccountsFunc              func(ctx context.Context, accounts []model.Account) ([]bool, error)
+	CreateTransferJobFunc           func(ctx context.Context, lines []model.TransferJobLine) (*model.TransferJob, error)
+	GetTransferJobFunc              func(ctx context.Context, id int64) (*model.TransferJob, error)
+	TransferJobLinesFunc            func(ctx context.Context, id int64, filter model.TransferJobLineFilter) ([]model.TransferJobLine, error)
+	ProcessNextTransferJobLineFunc  func(ctx context.Context) (*model.TransferJobLine, error)
+	WriteStatementFunc              func(ctx context.Context, id int64, period model.StatementPeriod, w model.StatementWriter) error
+	RequestTransferApprovalFunc     func(ctx context.Context, req model.TransactionRequest, ttl time.Duration) (*model.TransferApproval, error)
+	GetTransferApprovalFunc         func(ctx context.Context, id int64) (*model.TransferApproval, error)
+	ListTransferApprovalsFunc       func(ctx context.Context, filter model.ApprovalFilter) ([]model.TransferApproval, error)
+	DecideTransferApprovalFunc      func(ctx context.Context, id int64, approve bool) (*model.TransferApproval, error)
+	ListTierLimitsFunc              func(ctx context.Context) ([]model.TierLimits, error)
+	SetTierLimitsFunc               func(ctx context.Context, tier model.TierLimits) error
+	GetAccountLimitsFunc            func(ctx context.Context, id int64) (*model.AccountLimits, error)
+	SetAccountLimitsFunc            func(ctx context.Context, id int64, req model.AccountLimitsRequest) (*model.AccountLimits, error)
+	TransferRulesFunc               func(ctx context.Context) ([]model.Rule, error)
+	RecordScreeningHitFunc          func(ctx context.Context, hit model.ScreeningHit) error
+	ListTierFeesFunc                func(ctx context.Context) ([]model.TierFees, error)
+	SetTierFeesFunc                 func(ctx context.Context, tier string, schedule *model.FeeSchedule) error
+	GetAccountFeesFunc              func(ctx context.Context, id int64) (*model.AccountFees, error)
+	SetAccountFeesFunc              func(ctx context.Context, id int64, schedule *model.FeeSchedule) (*model.AccountFees, error)
+	GetInterestRateFunc             func(ctx context.Context, id int64) (*model.InterestRate, error)
+	SetInterestRateFunc             func(ctx context.Context, id int64, req model.InterestRateRequest) (*model.InterestRate, error)
+	AccrueInterestFunc              func(ctx context.Context, day time.Time) (int, error)
+	PostInterestFunc                func(ctx context.Context, before time.Time, expenseAccountID int64) ([]model.InterestPosting, error)
+	TakeRateLimitTokenFunc          func(ctx context.Context, key string, limit ratelimit.Limit) (bool, float64, error)
+	PruneRateLimitBucketsFunc       func(ctx context.Context) (int64, error)
+	GetAccountShardsFunc            func(ctx context.Context, id int64) (*model.AccountShards, error)
+	SetAccountShardsFunc            func(ctx context.Context, id int64, shards int) (*model.AccountShards, error)
+	ExecuteTransferBatchFunc        func(ctx context.Context, transfers []storage.BatchedTransfer) ([]storage.BatchResult, error)
+	ConsistencyTokenFunc            func(ctx context.Context) (string, error)
+	RequestJournalEntryApprovalFunc func(ctx context.Context, req model.JournalEntryRequest, ttl time.Duration) (*model.TransferApproval, error)
 }
 
 func (m *MockStore) CreateAccount(ctx context.Context, acc model.Account) error {
@@ -31,10 +72,169 @@ func (m *MockStore) GetAccount(ctx context.Context, id int64) (*model.Account, e
 	return m.GetAccountFunc(ctx, id)
 }
 
-func (m *MockStore) ExecuteTransfer(ctx context.Context, req model.TransactionRequest) error {
+func (m *MockStore) ExecuteTransfer(ctx context.Context, req model.TransactionRequest) (*model.Transfer, error) {
 	return m.ExecuteTransferFunc(ctx, req)
 }
 
+func (m *MockStore) ListAccounts(ctx context.Context, filter model.AccountFilter) (*model.AccountPage, error) {
+	return m.ListAccountsFunc(ctx, filter)
+}
+
+func (m *MockStore) SetAccountStatus(ctx context.Context, id int64, status string) error {
+	return m.SetAccountStatusFunc(ctx, id, status)
+}
+
+func (m *MockStore) Reconcile(ctx context.Context, opts model.ReconcileOptions) (*model.ReconciliationReport, error) {
+	return m.ReconcileFunc(ctx, opts)
+}
+
+func (m *MockStore) GetAccountPostings(ctx context.Context, id int64, filter model.PostingFilter) (*model.AccountLedger, error) {
+	return m.GetAccountPostingsFunc(ctx, id, filter)
+}
+
+func (m *MockStore) PostJournalEntry(ctx context.Context, req model.JournalEntryRequest) (*model.JournalEntry, error) {
+	return m.PostJournalEntryFunc(ctx, req)
+}
+
+func (m *MockStore) GetAccountAsOf(ctx context.Context, id int64, asOf time.Time) (*model.Account, error) {
+	return m.GetAccountAsOfFunc(ctx, id, asOf)
+}
+
+func (m *MockStore) AuditLog(ctx context.Context, filter model.AuditFilter) ([]model.AuditRecord, error) {
+	return m.AuditLogFunc(ctx, filter)
+}
+
+func (m *MockStore) CreateAccounts(ctx context.Context, accounts []model.Account) ([]bool, error) {
+	return m.CreateAccountsFunc(ctx, accounts)
+}
+
+func (m *MockStore) CreateTransferJob(ctx context.Context, lines []model.TransferJobLine) (*model.TransferJob, error) {
+	return m.CreateTransferJobFunc(ctx, lines)
+}
+
+func (m *MockStore) GetTransferJob(ctx context.Context, id int64) (*model.TransferJob, error) {
+	return m.GetTransferJobFunc(ctx, id)
+}
+
+func (m *MockStore) TransferJobLines(ctx context.Context, id int64, filter model.TransferJobLineFilter) ([]model.TransferJobLine, error) {
+	return m.TransferJobLinesFunc(ctx, id, filter)
+}
+
+func (m *MockStore) ProcessNextTransferJobLine(ctx context.Context) (*model.TransferJobLine, error) {
+	return m.ProcessNextTransferJobLineFunc(ctx)
+}
+
+func (m *MockStore) WriteStatement(ctx context.Context, id int64, period model.StatementPeriod, w model.StatementWriter) error {
+	return m.WriteStatementFunc(ctx, id, period, w)
+}
+
+func (m *MockStore) RequestTransferApproval(ctx context.Context, req model.TransactionRequest, ttl time.Duration) (*model.TransferApproval, error) {
+	return m.RequestTransferApprovalFunc(ctx, req, ttl)
+}
+
+func (m *MockStore) GetTransferApproval(ctx context.Context, id int64) (*model.TransferApproval, error) {
+	return m.GetTransferApprovalFunc(ctx, id)
+}
+
+func (m *MockStore) ListTransferApprovals(ctx context.Context, filter model.ApprovalFilter) ([]model.TransferApproval, error) {
+	return m.ListTransferApprovalsFunc(ctx, filter)
+}
+
+func (m *MockStore) DecideTransferApproval(ctx context.Context, id int64, approve bool) (*model.TransferApproval, error) {
+	return m.DecideTransferApprovalFunc(ctx, id, approve)
+}
+
+func (m *MockStore) ListTierLimits(ctx context.Context) ([]model.TierLimits, error) {
+	return m.ListTierLimitsFunc(ctx)
+}
+
+func (m *MockStore) SetTierLimits(ctx context.Context, tier model.TierLimits) error {
+	return m.SetTierLimitsFunc(ctx, tier)
+}
+
+func (m *MockStore) GetAccountLimits(ctx context.Context, id int64) (*model.AccountLimits, error) {
+	return m.GetAccountLimitsFunc(ctx, id)
+}
+
+func (m *MockStore) SetAccountLimits(ctx context.Context, id int64, req model.AccountLimitsRequest) (*model.AccountLimits, error) {
+	return m.SetAccountLimitsFunc(ctx, id, req)
+}
+
+func (m *MockStore) TransferRules(ctx context.Context) ([]model.Rule, error) {
+	return m.TransferRulesFunc(ctx)
+}
+
+func (m *MockStore) RecordScreeningHit(ctx context.Context, hit model.ScreeningHit) error {
+	return m.RecordScreeningHitFunc(ctx, hit)
+}
+
+func (m *MockStore) ListTierFees(ctx context.Context) ([]model.TierFees, error) {
+	return m.ListTierFeesFunc(ctx)
+}
+
+func (m *MockStore) SetTierFees(ctx context.Context, tier string, schedule *model.FeeSchedule) error {
+	return m.SetTierFeesFunc(ctx, tier, schedule)
+}
+
+func (m *MockStore) GetAccountFees(ctx context.Context, id int64) (*model.AccountFees, error) {
+	return m.GetAccountFeesFunc(ctx, id)
+}
+
+func (m *MockStore) SetAccountFees(ctx context.Context, id int64, schedule *model.FeeSchedule) (*model.AccountFees, error) {
+	return m.SetAccountFeesFunc(ctx, id, schedule)
+}
+
+func (m *MockStore) GetInterestRate(ctx context.Context, id int64) (*model.InterestRate, error) {
+	return m.GetInterestRateFunc(ctx, id)
+}
+
+func (m *MockStore) SetInterestRate(ctx context.Context, id int64, req model.InterestRateRequest) (*model.InterestRate, error) {
+	return m.SetInterestRateFunc(ctx, id, req)
+}
+
+func (m *MockStore) AccrueInterest(ctx context.Context, day time.Time) (int, error) {
+	return m.AccrueInterestFunc(ctx, day)
+}
+
+func (m *MockStore) PostInterest(ctx context.Context, before time.Time, expenseAccountID int64) ([]model.InterestPosting, error) {
+	return m.PostInterestFunc(ctx, before, expenseAccountID)
+}
+
+func (m *MockStore) TakeRateLimitToken(ctx context.Context, key string, limit ratelimit.Limit) (bool, float64, error) {
+	return m.TakeRateLimitTokenFunc(ctx, key, limit)
+}
+
+func (m *MockStore) PruneRateLimitBuckets(ctx context.Context) (int64, error) {
+	return m.PruneRateLimitBucketsFunc(ctx)
+}
+
+func (m *MockStore) GetAccountShards(ctx context.Context, id int64) (*model.AccountShards, error) {
+	return m.GetAccountShardsFunc(ctx, id)
+}
+
+func (m *MockStore) SetAccountShards(ctx context.Context, id int64, shards int) (*model.AccountShards, error) {
+	return m.SetAccountShardsFunc(ctx, id, shards)
+}
+
+func (m *MockStore) ExecuteTransferBatch(ctx context.Context, transfers []storage.BatchedTransfer) ([]storage.BatchResult, error) {
+	return m.ExecuteTransferBatchFunc(ctx, transfers)
+}
+
+func (m *MockStore) ConsistencyToken(ctx context.Context) (string, error) {
+	return m.ConsistencyTokenFunc(ctx)
+}
+
+func (m *MockStore) RequestJournalEntryApproval(ctx context.Context, req model.JournalEntryRequest, ttl time.Duration) (*model.TransferApproval, error) {
+	return m.RequestJournalEntryApprovalFunc(ctx, req, ttl)
+}
+
+// newJSONRequest builds a request with a JSON body and the matching Content-Type.
+func newJSONRequest(method, target, body string) *http.Request {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	return req
+}
+
 func TestCreateAccountHandler(t *testing.T) {
 	t.Run("success - new account", func(t *testing.T) {
 		mockStore := &MockStore{
@@ -47,7 +247,7 @@ func TestCreateAccountHandler(t *testing.T) {
 		}
 		handler := NewAccountHandler(mockStore)
 		body := `{"account_id": 123, "initial_balance": "100.50"}`
-		req := httptest.NewRequest("POST", "/accounts", strings.NewReader(body))
+		req := newJSONRequest("POST", "/accounts", body)
 		rr := httptest.NewRecorder()
 
 		handler.CreateAccountHandler(rr, req)
@@ -66,7 +266,7 @@ func TestCreateAccountHandler(t *testing.T) {
 		}
 		handler := NewAccountHandler(mockStore)
 		body := `{"account_id": 123, "initial_balance": "100.50"}`
-		req := httptest.NewRequest("POST", "/accounts", strings.NewReader(body))
+		req := newJSONRequest("POST", "/accounts", body)
 		rr := httptest.NewRecorder()
 
 		handler.CreateAccountHandler(rr, req)
@@ -77,10 +277,30 @@ func TestCreateAccountHandler(t *testing.T) {
 	t.Run("invalid json", func(t *testing.T) {
 		handler := NewAccountHandler(&MockStore{})
 		body := `{"account_id": 123, "initial_balance": "100.50"` // Malformed
-		req := httptest.NewRequest("POST", "/accounts", strings.NewReader(body))
+		req := newJSONRequest("POST", "/accounts", body)
+		rr := httptest.NewRecorder()
+		handler.CreateAccountHandler(rr, req)
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+
+	t.Run("zero account id", func(t *testing.T) {
+		handler := NewAccountHandler(&MockStore{})
+		body := `{"account_id": 0, "initial_balance": "100.50"}`
+		req := newJSONRequest("POST", "/accounts", body)
 		rr := httptest.NewRecorder()
 		handler.CreateAccountHandler(rr, req)
 		assert.Equal(t, http.StatusBadRequest, rr.Code)
+		assert.Contains(t, rr.Body.String(), `"field":"account_id"`)
+	})
+
+	t.Run("misspelled field is rejected", func(t *testing.T) {
+		handler := NewAccountHandler(&MockStore{})
+		body := `{"account_id": 1, "initial_balanse": "100.50"}`
+		req := newJSONRequest("POST", "/accounts", body)
+		rr := httptest.NewRecorder()
+		handler.CreateAccountHandler(rr, req)
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+		assert.Contains(t, rr.Body.String(), "initial_balanse")
 	})
 }
 
@@ -128,4 +348,195 @@ func TestGetAccountHandler(t *testing.T) {
 
 		assert.Equal(t, http.StatusNotFound, rr.Code)
 	})
+
+	t.Run("balance as of an instant", func(t *testing.T) {
+		mockStore := &MockStore{
+			GetAccountAsOfFunc: func(ctx context.Context, id int64, asOf time.Time) (*model.Account, error) {
+				assert.Equal(t, int64(123), id)
+				assert.True(t, asOf.Equal(time.Date(2025, 3, 31, 23, 59, 59, 0, time.UTC)), "as_of = %s", asOf)
+				return &model.Account{AccountID: id, Balance: decimal.NewFromInt(42)}, nil
+			},
+		}
+		router := NewRouter(mockStore)
+		req := httptest.NewRequest("GET", "/accounts/123?as_of=2025-04-01T01:59:59%2B02:00", nil)
+		rr := httptest.NewRecorder()
+
+		router.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Contains(t, rr.Body.String(), `"balance":"42"`)
+	})
+
+	t.Run("invalid as_of", func(t *testing.T) {
+		router := NewRouter(&MockStore{})
+		req := httptest.NewRequest("GET", "/accounts/123?as_of=last-quarter", nil)
+		rr := httptest.NewRecorder()
+
+		router.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+		assert.Contains(t, rr.Body.String(), "as_of")
+	})
+}
+
+func TestListAccountsHandler(t *testing.T) {
+	t.Run("filters and sort are passed to the store", func(t *testing.T) {
+		created := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+		mockStore := &MockStore{
+			ListAccountsFunc: func(ctx context.Context, filter model.AccountFilter) (*model.AccountPage, error) {
+				assert.Equal(t, 2, filter.Limit)
+				assert.Equal(t, model.AccountStatusActive, filter.Status)
+				assert.Equal(t, "EUR", filter.Currency)
+				assert.Equal(t, model.SortBalance, filter.Sort)
+				assert.True(t, filter.Desc)
+				require.NotNil(t, filter.MinBalance)
+				assert.Equal(t, "10", filter.MinBalance.String())
+				assert.Nil(t, filter.MaxBalance)
+				assert.True(t, filter.CreatedFrom.Equal(created))
+				assert.True(t, filter.CreatedBefore.IsZero())
+				assert.Nil(t, filter.After)
+				assert.Nil(t, filter.Before)
+				return &model.AccountPage{
+					Accounts: []model.Account{
+						{AccountID: 7, Balance: decimal.NewFromInt(90), Currency: "EUR", CreatedAt: created},
+						{AccountID: 3, Balance: decimal.NewFromInt(50), Currency: "EUR", CreatedAt: created},
+					},
+					Next: &model.AccountCursor{AccountID: 3, Balance: decimal.NewFromInt(50)},
+				}, nil
+			},
+		}
+		router := NewRouter(mockStore)
+		req := httptest.NewRequest("GET", "/accounts?limit=2&status=active&currency=EUR&sort=balance&order=desc&min_balance=10&created_from=2025-01-02T03:04:05Z", nil)
+		rr := httptest.NewRecorder()
+
+		router.ServeHTTP(rr, req)
+
+		require.Equal(t, http.StatusOK, rr.Code)
+		var page model.AccountPage
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &page))
+		assert.Len(t, page.Accounts, 2)
+		assert.True(t, page.Accounts[0].CreatedAt.Equal(created))
+		assert.NotEmpty(t, page.NextCursor)
+		assert.Empty(t, page.PrevCursor)
+	})
+
+	t.Run("cursors round-trip", func(t *testing.T) {
+		var got model.AccountFilter
+		mockStore := &MockStore{
+			ListAccountsFunc: func(ctx context.Context, filter model.AccountFilter) (*model.AccountPage, error) {
+				got = filter
+				return &model.AccountPage{
+					Accounts: []model.Account{{AccountID: 5}},
+					Next:     &model.AccountCursor{AccountID: 5, Balance: decimal.RequireFromString("12.5")},
+					Prev:     &model.AccountCursor{AccountID: 5, Balance: decimal.RequireFromString("12.5")},
+				}, nil
+			},
+		}
+		router := NewRouter(mockStore)
+		list := func(query string) model.AccountPage {
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, httptest.NewRequest("GET", "/accounts?sort=balance&"+query, nil))
+			require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+			var page model.AccountPage
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &page))
+			return page
+		}
+
+		page := list("")
+		list("cursor=" + page.NextCursor)
+		assert.Equal(t, &model.AccountCursor{AccountID: 5, Balance: decimal.RequireFromString("12.5")}, got.After)
+		assert.Nil(t, got.Before)
+
+		list("cursor=" + page.PrevCursor)
+		assert.Nil(t, got.After)
+		require.NotNil(t, got.Before)
+		assert.Equal(t, int64(5), got.Before.AccountID)
+	})
+
+	t.Run("cursor from another sort order is rejected", func(t *testing.T) {
+		mockStore := &MockStore{
+			ListAccountsFunc: func(ctx context.Context, filter model.AccountFilter) (*model.AccountPage, error) {
+				return &model.AccountPage{Accounts: []model.Account{{AccountID: 1}}, Next: &model.AccountCursor{AccountID: 1}}, nil
+			},
+		}
+		router := NewRouter(mockStore)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest("GET", "/accounts", nil))
+		var page model.AccountPage
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &page))
+
+		rr = httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest("GET", "/accounts?order=desc&cursor="+page.NextCursor, nil))
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+		assert.Equal(t, CodeInvalidRequest, readProblem(t, rr).Code)
+	})
+
+	t.Run("invalid query parameters", func(t *testing.T) {
+		router := NewRouter(&MockStore{})
+		for _, query := range []string{"sort=name", "order=up", "status=closed", "currency=euro", "min_balance=ten", "created_to=yesterday", "cursor=%21%21"} {
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, httptest.NewRequest("GET", "/accounts?"+query, nil))
+			assert.Equal(t, http.StatusBadRequest, rr.Code, query)
+		}
+	})
+}
+
+func TestGetAccountPostingsHandler(t *testing.T) {
+	t.Run("success with paging parameters", func(t *testing.T) {
+		next := int64(12)
+		mockStore := &MockStore{
+			GetAccountPostingsFunc: func(ctx context.Context, id int64, filter model.PostingFilter) (*model.AccountLedger, error) {
+				assert.Equal(t, int64(123), id)
+				assert.Equal(t, model.PostingFilter{AfterID: 10, Limit: 2}, filter)
+				return &model.AccountLedger{
+					AccountID:      123,
+					OpeningBalance: decimal.NewFromInt(100),
+					Balance:        decimal.NewFromInt(75),
+					Postings: []model.Posting{
+						{PostingID: 11, EntryID: 6, AccountID: 123, Amount: decimal.NewFromInt(-50), Direction: model.DirectionDebit},
+						{PostingID: 12, EntryID: 7, AccountID: 123, Amount: decimal.NewFromInt(25), Direction: model.DirectionCredit},
+					},
+					NextAfter: &next,
+				}, nil
+			},
+		}
+		router := NewRouter(mockStore)
+		req := httptest.NewRequest("GET", "/accounts/123/postings?after=10&limit=2", nil)
+		rr := httptest.NewRecorder()
+
+		router.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		var ledger model.AccountLedger
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &ledger))
+		assert.Len(t, ledger.Postings, 2)
+		assert.Equal(t, &next, ledger.NextAfter)
+		assert.Equal(t, model.DirectionDebit, ledger.Postings[0].Direction)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		mockStore := &MockStore{
+			GetAccountPostingsFunc: func(ctx context.Context, id int64, filter model.PostingFilter) (*model.AccountLedger, error) {
+				return nil, &storage.AccountError{AccountID: id, Err: storage.ErrNotFound}
+			},
+		}
+		router := NewRouter(mockStore)
+		req := httptest.NewRequest("GET", "/accounts/404/postings", nil)
+		rr := httptest.NewRecorder()
+
+		router.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+	})
+
+	t.Run("invalid limit", func(t *testing.T) {
+		router := NewRouter(&MockStore{})
+		req := httptest.NewRequest("GET", "/accounts/1/postings?limit=lots", nil)
+		rr := httptest.NewRecorder()
+
+		router.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	})
 }
@@ -0,0 +1,220 @@
+package handler
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go-api-example/model"
+	"go-api-example/storage"
+
+	"github.com/gorilla/mux"
+	"github.com/shopspring/decimal"
+)
+
+// ApprovalPolicy decides which transfers need maker-checker approval.
+type ApprovalPolicy struct {
+	Threshold decimal.Decimal // transfers above this are held; zero disables approvals
+	TTL       time.Duration   // how long a held transfer waits for a decision; 0 is defaultApprovalTTL
+}
+
+// defaultApprovalTTL is how long a held transfer waits when the policy sets no
+// TTL, as when only the transfer rules send transfers to review.
+const defaultApprovalTTL = 24 * time.Hour
+
+// Requires reports whether a transfer of amount must be approved before it runs.
+func (p ApprovalPolicy) Requires(amount decimal.Decimal) bool {
+	return p.Threshold.IsPositive() && amount.GreaterThan(p.Threshold)
+}
+
+// RequiresEntry reports whether a journal entry must be approved before it is
+// posted: whether it debits any account by more than a transfer could move
+// without approval, in total over the account's postings.
+func (p ApprovalPolicy) RequiresEntry(req model.JournalEntryRequest) bool {
+	debits := map[int64]decimal.Decimal{}
+	for _, posting := range req.Postings {
+		if posting.Amount.IsNegative() {
+			debits[posting.AccountID] = debits[posting.AccountID].Sub(posting.Amount)
+		}
+	}
+	for _, total := range debits {
+		if p.Requires(total) {
+			return true
+		}
+	}
+	return false
+}
+
+// ApprovalHandler holds dependencies for the transfer approval handlers.
+type ApprovalHandler struct {
+	store storage.Store
+}
+
+// NewApprovalHandler creates a new ApprovalHandler.
+func NewApprovalHandler(store storage.Store) *ApprovalHandler {
+	return &ApprovalHandler{store: store}
+}
+
+// holdTransfer records a transfer above the approval threshold, or sent to
+// review by a transfer rule, as pending approval instead of executing it. The
+// requesting principal is required, as only a different one may approve.
+func holdTransfer(w http.ResponseWriter, r *http.Request, store storage.Store, policy ApprovalPolicy, req model.TransactionRequest) {
+	hold(w, r, policy, func(ttl time.Duration) (*model.TransferApproval, error) {
+		return store.RequestTransferApproval(r.Context(), req, ttl)
+	}, fmt.Sprintf("Transfer of %s from account %d to %d", req.Amount, req.SourceAccountID, req.DestinationAccountID))
+}
+
+// holdJournalEntry holds a journal entry for approval as holdTransfer holds a
+// transfer.
+func holdJournalEntry(w http.ResponseWriter, r *http.Request, store storage.Store, policy ApprovalPolicy, req model.JournalEntryRequest) {
+	hold(w, r, policy, func(ttl time.Duration) (*model.TransferApproval, error) {
+		return store.RequestJournalEntryApproval(r.Context(), req, ttl)
+	}, fmt.Sprintf("Journal entry with %d postings", len(req.Postings)))
+}
+
+// hold records what is described as pending approval with request, and
+// responds with the approval.
+func hold(w http.ResponseWriter, r *http.Request, policy ApprovalPolicy, request func(ttl time.Duration) (*model.TransferApproval, error), what string) {
+	if !requirePrincipal(w, r) {
+		return
+	}
+	ttl := policy.TTL
+	if ttl <= 0 {
+		ttl = defaultApprovalTTL
+	}
+	approval, err := request(ttl)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	log.Printf("%s held for approval %d", what, approval.ApprovalID)
+
+	w.Header().Set("Location", fmt.Sprintf("/approvals/%d", approval.ApprovalID))
+	writeJSON(w, http.StatusAccepted, approval)
+}
+
+// ListApprovalsHandler lists transfer approvals, oldest first, optionally only
+// those with a given status. Pages are requested with the "after" query
+// parameter set to the previous response's "next_after".
+//
+// Method: GET
+// Path: /approvals?status=<status>&after=<approval_id>&limit=<n>
+// Success: 200 OK
+// Error: 400 Bad Request (for invalid query parameters)
+// Error: 500 Internal Server Error (for database errors)
+func (h *ApprovalHandler) ListApprovalsHandler(w http.ResponseWriter, r *http.Request) {
+	filter := model.ApprovalFilter{Status: r.URL.Query().Get("status"), Limit: 100}
+	var limit int64 = int64(filter.Limit)
+	if !queryInt64(w, r, "after", &filter.AfterID) || !queryInt64(w, r, "limit", &limit) {
+		return
+	}
+	filter.Limit = int(limit)
+	switch filter.Status {
+	case "", model.ApprovalStatusPending, model.ApprovalStatusApproved, model.ApprovalStatusRejected, model.ApprovalStatusExpired:
+	default:
+		writeProblem(w, newProblem(r, http.StatusBadRequest, CodeInvalidRequest, "Invalid query parameter",
+			`Query parameter "status" must be one of pending_approval, approved, rejected, expired`))
+		return
+	}
+
+	approvals, err := h.store.ListTransferApprovals(r.Context(), filter)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	page := model.ApprovalPage{Approvals: approvals}
+	if len(approvals) == filter.Limit {
+		next := approvals[len(approvals)-1].ApprovalID
+		page.NextAfter = &next
+	}
+	writeJSON(w, http.StatusOK, page)
+}
+
+// GetApprovalHandler returns a transfer approval.
+//
+// Method: GET
+// Path: /approvals/{approval_id}
+// Success: 200 OK
+// Error: 400 Bad Request (for an invalid approval ID)
+// Error: 404 Not Found (if the approval does not exist)
+// Error: 500 Internal Server Error (for database errors)
+func (h *ApprovalHandler) GetApprovalHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := approvalIDFromPath(w, r)
+	if !ok {
+		return
+	}
+	approval, err := h.store.GetTransferApproval(r.Context(), id)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, approval)
+}
+
+// ApproveHandler approves a held transfer and executes it, atomically. The
+// approving principal must differ from the one who requested the transfer. If
+// the transfer fails, the approval stays pending.
+//
+// Method: POST
+// Path: /approvals/{approval_id}/approve
+// Success: 200 OK (with the decided approval)
+// Error: 400 Bad Request (for an invalid approval ID or a missing X-Principal)
+// Error: 403 Forbidden (if the principal requested the transfer)
+// Error: 404 Not Found (if the approval does not exist)
+// Error: 409 Conflict (if the approval was already decided or has expired)
+// Error: 422 Unprocessable Entity (if the transfer fails, e.g. insufficient funds)
+// Error: 500 Internal Server Error (for database errors)
+func (h *ApprovalHandler) ApproveHandler(w http.ResponseWriter, r *http.Request) {
+	h.decide(w, r, true)
+}
+
+// RejectHandler rejects a held transfer, which is then never executed. The
+// rejecting principal must differ from the one who requested the transfer.
+//
+// Method: POST
+// Path: /approvals/{approval_id}/reject
+// Success: 200 OK (with the decided approval)
+// Error: 400 Bad Request (for an invalid approval ID or a missing X-Principal)
+// Error: 403 Forbidden (if the principal requested the transfer)
+// Error: 404 Not Found (if the approval does not exist)
+// Error: 409 Conflict (if the approval was already decided or has expired)
+// Error: 500 Internal Server Error (for database errors)
+func (h *ApprovalHandler) RejectHandler(w http.ResponseWriter, r *http.Request) {
+	h.decide(w, r, false)
+}
+
+func (h *ApprovalHandler) decide(w http.ResponseWriter, r *http.Request, approve bool) {
+	id, ok := approvalIDFromPath(w, r)
+	if !ok || !requirePrincipal(w, r) {
+		return
+	}
+	approval, err := h.store.DecideTransferApproval(r.Context(), id, approve)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	log.Printf("Approval %d %s by %s", approval.ApprovalID, approval.Status, approval.DecidedBy)
+	writeJSON(w, http.StatusOK, approval)
+}
+
+// requirePrincipal writes a problem response unless the request names a principal.
+func requirePrincipal(w http.ResponseWriter, r *http.Request) bool {
+	if _, ok := principalFrom(r.Context()); ok {
+		return true
+	}
+	writeProblem(w, newProblem(r, http.StatusBadRequest, CodePrincipalRequired, "Principal required",
+		"The "+PrincipalHeader+" header must name the principal making the request"))
+	return false
+}
+
+// approvalIDFromPath parses the {approval_id} path variable, writing a problem response on failure.
+func approvalIDFromPath(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	id, err := strconv.ParseInt(mux.Vars(r)["approval_id"], 10, 64)
+	if err != nil {
+		writeProblem(w, newProblem(r, http.StatusBadRequest, CodeInvalidRequest, "Invalid approval ID", "Invalid approval ID format"))
+		return 0, false
+	}
+	return id, true
+}
@@ -0,0 +1,273 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go-api-example/audit"
+	"go-api-example/model"
+	"go-api-example/storage"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testApprovals = WithApprovalPolicy(ApprovalPolicy{Threshold: decimal.NewFromInt(1000), TTL: time.Hour})
+
+func TestApprovalPolicyRequires(t *testing.T) {
+	p := ApprovalPolicy{Threshold: decimal.NewFromInt(1000)}
+	assert.False(t, p.Requires(decimal.NewFromInt(1000)))
+	assert.True(t, p.Requires(decimal.RequireFromString("1000.00001")))
+	assert.False(t, ApprovalPolicy{}.Requires(decimal.NewFromInt(1_000_000)))
+}
+
+func TestCreateTransactionHandlerHoldsLargeTransfers(t *testing.T) {
+	var requested model.TransactionRequest
+	var actor string
+	store := &MockStore{
+		ExecuteTransferFunc: func(ctx context.Context, req model.TransactionRequest) (*model.Transfer, error) {
+			t.Fatal("a transfer above the threshold must not be executed")
+			return &model.Transfer{TransactionRequest: req, Total: req.Amount}, nil
+		},
+		RequestTransferApprovalFunc: func(ctx context.Context, req model.TransactionRequest, ttl time.Duration) (*model.TransferApproval, error) {
+			requested, actor = req, audit.ActorFrom(ctx)
+			assert.Equal(t, time.Hour, ttl)
+			return &model.TransferApproval{ApprovalID: 5, TransactionRequest: req, Status: model.ApprovalStatusPending, RequestedBy: actor}, nil
+		},
+	}
+	body := `{"source_account_id": 1, "destination_account_id": 2, "amount": "1000.01"}`
+
+	t.Run("held for approval", func(t *testing.T) {
+		req := newJSONRequest("POST", "/transactions", body)
+		req.Header.Set(PrincipalHeader, "alice@example.com")
+		rr := httptest.NewRecorder()
+		NewRouter(store, testApprovals).ServeHTTP(rr, req)
+
+		require.Equal(t, http.StatusAccepted, rr.Code, rr.Body.String())
+		assert.Equal(t, "/approvals/5", rr.Header().Get("Location"))
+		var approval model.TransferApproval
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &approval))
+		assert.Equal(t, model.ApprovalStatusPending, approval.Status)
+		assert.Equal(t, int64(2), requested.DestinationAccountID)
+		assert.Equal(t, "api:alice@example.com", actor)
+	})
+
+	t.Run("principal required", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		NewRouter(store, testApprovals).ServeHTTP(rr, newJSONRequest("POST", "/transactions", body))
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+		assert.Equal(t, CodePrincipalRequired, readProblem(t, rr).Code)
+	})
+
+	t.Run("at the threshold runs immediately", func(t *testing.T) {
+		executed := false
+		store := &MockStore{
+			ExecuteTransferFunc: func(ctx context.Context, req model.TransactionRequest) (*model.Transfer, error) {
+				executed = true
+				return &model.Transfer{TransactionRequest: req, Total: req.Amount}, nil
+			},
+		}
+		rr := httptest.NewRecorder()
+		req := newJSONRequest("POST", "/transactions", `{"source_account_id": 1, "destination_account_id": 2, "amount": "1000"}`)
+		NewRouter(store, testApprovals).ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.True(t, executed)
+	})
+}
+
+func TestApprovalPolicyRequiresEntry(t *testing.T) {
+	p := ApprovalPolicy{Threshold: decimal.NewFromInt(1000)}
+	entry := func(amounts ...string) model.JournalEntryRequest {
+		var req model.JournalEntryRequest
+		for i, a := range amounts {
+			req.Postings = append(req.Postings, model.PostingRequest{AccountID: int64(i%2 + 1), Amount: decimal.RequireFromString(a), Currency: "EUR"})
+		}
+		return req
+	}
+	assert.False(t, p.RequiresEntry(entry("-1000", "1000")))
+	assert.True(t, p.RequiresEntry(entry("-1000.01", "1000.01")))
+	assert.True(t, p.RequiresEntry(entry("-600", "600", "-600", "600")), "the debits of an account are summed")
+	assert.False(t, ApprovalPolicy{}.RequiresEntry(entry("-5000", "5000")))
+}
+
+func TestCreateJournalEntryHandlerHoldsLargeEntries(t *testing.T) {
+	var requested model.JournalEntryRequest
+	store := &MockStore{
+		PostJournalEntryFunc: func(ctx context.Context, req model.JournalEntryRequest) (*model.JournalEntry, error) {
+			t.Fatal("an entry above the threshold must not be posted")
+			return nil, nil
+		},
+		RequestJournalEntryApprovalFunc: func(ctx context.Context, req model.JournalEntryRequest, ttl time.Duration) (*model.TransferApproval, error) {
+			requested = req
+			return &model.TransferApproval{ApprovalID: 6, Postings: req.Postings, Status: model.ApprovalStatusPending, RequestedBy: audit.ActorFrom(ctx)}, nil
+		},
+	}
+	// Two debits of 600 from account 1 move more than the threshold of 1000.
+	body := `{"postings": [
+		{"account_id": 1, "amount": "-600", "currency": "EUR"},
+		{"account_id": 2, "amount": "600", "currency": "EUR"},
+		{"account_id": 1, "amount": "-600", "currency": "EUR"},
+		{"account_id": 3, "amount": "600", "currency": "EUR"}
+	]}`
+
+	t.Run("held for approval", func(t *testing.T) {
+		req := newJSONRequest("POST", "/journal-entries", body)
+		req.Header.Set(PrincipalHeader, "alice")
+		rr := httptest.NewRecorder()
+		NewRouter(store, testApprovals).ServeHTTP(rr, req)
+
+		require.Equal(t, http.StatusAccepted, rr.Code, rr.Body.String())
+		assert.Equal(t, "/approvals/6", rr.Header().Get("Location"))
+		var approval model.TransferApproval
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &approval))
+		assert.Len(t, approval.Postings, 4)
+		assert.Len(t, requested.Postings, 4)
+	})
+
+	t.Run("principal required", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		NewRouter(store, testApprovals).ServeHTTP(rr, newJSONRequest("POST", "/journal-entries", body))
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+		assert.Equal(t, CodePrincipalRequired, readProblem(t, rr).Code)
+	})
+}
+
+func TestCreateTransferJobHandlerFailsLinesNeedingApproval(t *testing.T) {
+	store, lines := jobStore()
+	req := httptest.NewRequest("POST", "/transfer-jobs", strings.NewReader("source_account_id,destination_account_id,amount\n1,2,10\n1,2,5000\n"))
+	req.Header.Set("Content-Type", "text/csv")
+	rr := httptest.NewRecorder()
+	NewRouter(store, testApprovals).ServeHTTP(rr, req)
+
+	require.Equal(t, http.StatusAccepted, rr.Code, rr.Body.String())
+	require.Len(t, *lines, 2)
+	assert.Equal(t, "", (*lines)[0].Status)
+	assert.Equal(t, model.LineStatusFailed, (*lines)[1].Status)
+	assert.Contains(t, (*lines)[1].Error, "approval threshold")
+}
+
+func TestListApprovalsHandler(t *testing.T) {
+	store := &MockStore{
+		ListTransferApprovalsFunc: func(ctx context.Context, filter model.ApprovalFilter) ([]model.TransferApproval, error) {
+			assert.Equal(t, model.ApprovalStatusPending, filter.Status)
+			assert.Equal(t, int64(3), filter.AfterID)
+			approvals := make([]model.TransferApproval, filter.Limit)
+			for i := range approvals {
+				approvals[i] = model.TransferApproval{ApprovalID: filter.AfterID + int64(i) + 1, Status: model.ApprovalStatusPending}
+			}
+			return approvals, nil
+		},
+	}
+
+	t.Run("full page has a next position", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		NewRouter(store).ServeHTTP(rr, httptest.NewRequest("GET", "/approvals?status=pending_approval&after=3&limit=2", nil))
+
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+		var page model.ApprovalPage
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &page))
+		require.Len(t, page.Approvals, 2)
+		require.NotNil(t, page.NextAfter)
+		assert.Equal(t, int64(5), *page.NextAfter)
+	})
+
+	t.Run("unknown status", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		NewRouter(store).ServeHTTP(rr, httptest.NewRequest("GET", "/approvals?status=done", nil))
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+}
+
+func TestGetApprovalHandler(t *testing.T) {
+	store := &MockStore{
+		GetTransferApprovalFunc: func(ctx context.Context, id int64) (*model.TransferApproval, error) {
+			return nil, storage.ErrApprovalNotFound
+		},
+	}
+	rr := httptest.NewRecorder()
+	NewRouter(store).ServeHTTP(rr, httptest.NewRequest("GET", "/approvals/9", nil))
+
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	assert.Equal(t, CodeApprovalNotFound, readProblem(t, rr).Code)
+}
+
+func TestDecideApprovalHandlers(t *testing.T) {
+	decide := func(path, principal string, err error) (*httptest.ResponseRecorder, *bool) {
+		var approved *bool
+		store := &MockStore{
+			DecideTransferApprovalFunc: func(ctx context.Context, id int64, approve bool) (*model.TransferApproval, error) {
+				approved = &approve
+				if err != nil {
+					return nil, err
+				}
+				status := model.ApprovalStatusRejected
+				if approve {
+					status = model.ApprovalStatusApproved
+				}
+				return &model.TransferApproval{ApprovalID: id, Status: status, DecidedBy: audit.ActorFrom(ctx)}, nil
+			},
+		}
+		req := httptest.NewRequest("POST", path, nil)
+		if principal != "" {
+			req.Header.Set(PrincipalHeader, principal)
+		}
+		rr := httptest.NewRecorder()
+		NewRouter(store).ServeHTTP(rr, req)
+		return rr, approved
+	}
+
+	t.Run("approve", func(t *testing.T) {
+		rr, approved := decide("/approvals/5/approve", "bob", nil)
+
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+		require.NotNil(t, approved)
+		assert.True(t, *approved)
+		var approval model.TransferApproval
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &approval))
+		assert.Equal(t, model.ApprovalStatusApproved, approval.Status)
+		assert.Equal(t, "api:bob", approval.DecidedBy)
+	})
+
+	t.Run("reject", func(t *testing.T) {
+		rr, approved := decide("/approvals/5/reject", "bob", nil)
+
+		require.Equal(t, http.StatusOK, rr.Code)
+		require.NotNil(t, approved)
+		assert.False(t, *approved)
+	})
+
+	t.Run("principal required", func(t *testing.T) {
+		rr, approved := decide("/approvals/5/approve", "", nil)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+		assert.Equal(t, CodePrincipalRequired, readProblem(t, rr).Code)
+		assert.Nil(t, approved)
+	})
+
+	for _, tc := range []struct {
+		err    error
+		status int
+		code   string
+	}{
+		{storage.ErrSelfApproval, http.StatusForbidden, CodeSelfApproval},
+		{storage.ErrApprovalNotPending, http.StatusConflict, CodeApprovalDecided},
+		{storage.ErrApprovalExpired, http.StatusConflict, CodeApprovalExpired},
+		{&storage.AccountError{AccountID: 1, Err: storage.ErrInsufficientFunds}, http.StatusUnprocessableEntity, CodeInsufficientFunds},
+	} {
+		t.Run(tc.code, func(t *testing.T) {
+			rr, _ := decide("/approvals/5/approve", "bob", tc.err)
+
+			assert.Equal(t, tc.status, rr.Code)
+			assert.Equal(t, tc.code, readProblem(t, rr).Code)
+		})
+	}
+}
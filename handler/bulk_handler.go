@@ -0,0 +1,168 @@
+package handler
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+
+	"go-api-example/model"
+)
+
+// maxBulkBodyBytes caps the body of a bulk request: room for well over
+// a hundred thousand accounts.
+const maxBulkBodyBytes = 32 << 20
+
+// bulkBatchSize is the number of accounts written per storage call and transaction.
+const bulkBatchSize = 1000
+
+// ndjsonContentType is the media type of a newline-delimited JSON stream.
+const ndjsonContentType = "application/x-ndjson"
+
+// CreateAccountsBulkHandler creates many accounts in one request. The body is
+// either a JSON array of create-account objects (Content-Type application/json)
+// or one object per line (application/x-ndjson). Each row has the semantics of
+// POST /accounts: an existing account ID is reported as "exists" and left
+// untouched, and a row failing validation is reported as "invalid" without
+// affecting the others. Valid rows are written in batches of 1000, each in its
+// own transaction, so after a failure the same body can simply be sent again.
+//
+// Method: POST
+// Path: /accounts/bulk
+// Success: 200 OK (with a per-row report)
+// Error: 400 Bad Request (for a malformed JSON array)
+// Error: 413 Request Entity Too Large (body over 32 MiB)
+// Error: 415 Unsupported Media Type (Content-Type is neither JSON nor NDJSON)
+// Error: 500 Internal Server Error (for database errors; earlier batches stay committed)
+func (h *AccountHandler) CreateAccountsBulkHandler(w http.ResponseWriter, r *http.Request) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	body := http.MaxBytesReader(w, r.Body, maxBulkBodyBytes)
+
+	var results []model.BulkAccountResult
+	var reqs []model.CreateAccountRequest
+	add := func(row int, req model.CreateAccountRequest, err error) {
+		if err == nil {
+			err = model.Validate(req)
+		}
+		res := model.BulkAccountResult{Row: row, AccountID: req.AccountID}
+		if err != nil {
+			res.Status, res.Error = model.BulkStatusInvalid, err.Error()
+		} else {
+			reqs = append(reqs, req)
+		}
+		results = append(results, res)
+	}
+
+	var p *Problem
+	switch mediaType {
+	case "application/json":
+		p = decodeBulkArray(r, body, add)
+	case ndjsonContentType:
+		p = decodeNDJSON(r, body, add)
+	default:
+		p = newProblem(r, http.StatusUnsupportedMediaType, CodeUnsupportedMediaType,
+			"Unsupported media type", "Content-Type must be application/json or "+ndjsonContentType)
+	}
+	if p != nil {
+		writeProblem(w, p)
+		return
+	}
+
+	// Fill in the outcome of the valid rows, which are the results without a status, in order.
+	pending := make([]int, 0, len(reqs))
+	for i, res := range results {
+		if res.Status == "" {
+			pending = append(pending, i)
+		}
+	}
+	for start := 0; start < len(reqs); start += bulkBatchSize {
+		end := min(start+bulkBatchSize, len(reqs))
+		accounts := make([]model.Account, 0, end-start)
+		for _, req := range reqs[start:end] {
+			accounts = append(accounts, model.Account{AccountID: req.AccountID, Balance: req.InitialBalance, Currency: req.Currency, OwnerName: req.OwnerName})
+		}
+		created, err := h.store.CreateAccounts(r.Context(), accounts)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		for i, ok := range created {
+			status := model.BulkStatusExists
+			if ok {
+				status = model.BulkStatusCreated
+			}
+			results[pending[start+i]].Status = status
+		}
+	}
+
+	report := model.BulkAccountReport{Results: make([]model.BulkAccountResult, 0, len(results))}
+	for _, res := range results {
+		report.Add(res)
+	}
+	writeJSON(w, http.StatusOK, report)
+}
+
+// decodeBulkArray decodes a JSON array of create-account objects, calling add for
+// each element. An element that is valid JSON but not a valid request is passed to
+// add with its error; a malformed array ends decoding with a problem.
+func decodeBulkArray(r *http.Request, body io.Reader, add func(int, model.CreateAccountRequest, error)) *Problem {
+	dec := json.NewDecoder(body)
+	dec.DisallowUnknownFields()
+	notArray := newProblem(r, http.StatusBadRequest, CodeInvalidRequest,
+		"Invalid request body", "Request body must be a JSON array of accounts")
+
+	if tok, err := dec.Token(); err != nil {
+		return decodeProblem(r, err)
+	} else if tok != json.Delim('[') {
+		return notArray
+	}
+	for row := 1; dec.More(); row++ {
+		var req model.CreateAccountRequest
+		err := dec.Decode(&req)
+		var syntaxErr *json.SyntaxError
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &syntaxErr) || errors.As(err, &maxErr) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return decodeProblem(r, err)
+		}
+		add(row, req, err)
+	}
+	if _, err := dec.Token(); err != nil {
+		return decodeProblem(r, err)
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return notArray
+	}
+	return nil
+}
+
+// decodeNDJSON decodes one JSON object per line into a T, calling add for each
+// non-blank line with its line number. A line that fails to decode is passed to
+// add with its error.
+func decodeNDJSON[T any](r *http.Request, body io.Reader, add func(int, T, error)) *Problem {
+	sc := bufio.NewScanner(body)
+	sc.Buffer(nil, maxBodyBytes)
+	for line := 1; sc.Scan(); line++ {
+		if len(bytes.TrimSpace(sc.Bytes())) == 0 {
+			continue
+		}
+		var v T
+		dec := json.NewDecoder(bytes.NewReader(sc.Bytes()))
+		dec.DisallowUnknownFields()
+		err := dec.Decode(&v)
+		if err == nil && dec.More() {
+			err = errors.New("line must contain a single JSON object")
+		}
+		add(line, v, err)
+	}
+	if err := sc.Err(); err != nil {
+		if errors.Is(err, bufio.ErrTooLong) {
+			return newProblem(r, http.StatusBadRequest, CodeInvalidRequest,
+				"Invalid request body", "NDJSON lines must not exceed 1 MiB")
+		}
+		return decodeProblem(r, err)
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"go-api-example/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// bulkStore is a MockStore whose CreateAccounts treats the IDs in existing as taken.
+func bulkStore(existing ...int64) (*MockStore, *[][]model.Account) {
+	var batches [][]model.Account
+	taken := map[int64]bool{}
+	for _, id := range existing {
+		taken[id] = true
+	}
+	return &MockStore{
+		CreateAccountsFunc: func(ctx context.Context, accounts []model.Account) ([]bool, error) {
+			batches = append(batches, accounts)
+			created := make([]bool, len(accounts))
+			for i, acc := range accounts {
+				created[i] = !taken[acc.AccountID]
+				taken[acc.AccountID] = true
+			}
+			return created, nil
+		},
+	}, &batches
+}
+
+func postBulk(t *testing.T, store *MockStore, contentType, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest("POST", "/accounts/bulk", strings.NewReader(body))
+	req.Header.Set("Content-Type", contentType)
+	rr := httptest.NewRecorder()
+	NewRouter(store).ServeHTTP(rr, req)
+	return rr
+}
+
+func TestCreateAccountsBulkHandler(t *testing.T) {
+	t.Run("JSON array reports every row", func(t *testing.T) {
+		store, batches := bulkStore(2)
+		body := `[
+			{"account_id": 1, "initial_balance": "10"},
+			{"account_id": 2, "initial_balance": "20"},
+			{"account_id": 3, "initial_balance": "-1"},
+			{"account_id": "four", "initial_balance": "0"},
+			{"account_id": 5, "initial_balance": "0", "currency": "EUR"}
+		]`
+
+		rr := postBulk(t, store, "application/json", body)
+
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+		var report model.BulkAccountReport
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &report))
+		assert.Equal(t, 2, report.Created)
+		assert.Equal(t, 1, report.Exists)
+		assert.Equal(t, 2, report.Invalid)
+		require.Len(t, report.Results, 5)
+		statuses := make([]string, len(report.Results))
+		for i, res := range report.Results {
+			assert.Equal(t, i+1, res.Row)
+			statuses[i] = res.Status
+		}
+		assert.Equal(t, []string{"created", "exists", "invalid", "invalid", "created"}, statuses)
+		assert.Equal(t, "initial_balance: cannot be negative", report.Results[2].Error)
+
+		require.Len(t, *batches, 1)
+		assert.Len(t, (*batches)[0], 3, "only valid rows reach the store")
+		assert.Equal(t, "EUR", (*batches)[0][2].Currency)
+	})
+
+	t.Run("NDJSON rows are numbered by line", func(t *testing.T) {
+		store, _ := bulkStore()
+		body := "{\"account_id\": 1, \"initial_balance\": \"1\"}\n\n{not json}\n{\"account_id\": 2, \"initial_balance\": \"2\", \"extra\": 1}\n{\"account_id\": 3, \"initial_balance\": \"3\"}\n"
+
+		rr := postBulk(t, store, "application/x-ndjson", body)
+
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+		var report model.BulkAccountReport
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &report))
+		require.Len(t, report.Results, 4)
+		assert.Equal(t, model.BulkAccountResult{Row: 1, AccountID: 1, Status: model.BulkStatusCreated}, report.Results[0])
+		assert.Equal(t, 3, report.Results[1].Row)
+		assert.Equal(t, model.BulkStatusInvalid, report.Results[1].Status)
+		assert.Equal(t, 4, report.Results[2].Row)
+		assert.Contains(t, report.Results[2].Error, "extra")
+		assert.Equal(t, model.BulkAccountResult{Row: 5, AccountID: 3, Status: model.BulkStatusCreated}, report.Results[3])
+	})
+
+	t.Run("rows are written in batches", func(t *testing.T) {
+		store, batches := bulkStore()
+		var sb strings.Builder
+		for id := 1; id <= bulkBatchSize+1; id++ {
+			sb.WriteString(`{"account_id": ` + strconv.Itoa(id) + `, "initial_balance": "0"}` + "\n")
+		}
+
+		rr := postBulk(t, store, "application/x-ndjson", sb.String())
+
+		require.Equal(t, http.StatusOK, rr.Code)
+		require.Len(t, *batches, 2)
+		assert.Len(t, (*batches)[0], bulkBatchSize)
+		assert.Len(t, (*batches)[1], 1)
+	})
+
+	t.Run("malformed array", func(t *testing.T) {
+		rr := postBulk(t, &MockStore{}, "application/json", `{"account_id": 1}`)
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+
+	t.Run("unsupported content type", func(t *testing.T) {
+		rr := postBulk(t, &MockStore{}, "text/csv", "account_id,initial_balance\n1,0\n")
+		assert.Equal(t, http.StatusUnsupportedMediaType, rr.Code)
+		assert.Equal(t, "Content-Type must be application/json or application/x-ndjson", readProblem(t, rr).Detail)
+	})
+
+	t.Run("store failure", func(t *testing.T) {
+		store := &MockStore{
+			CreateAccountsFunc: func(ctx context.Context, accounts []model.Account) ([]bool, error) {
+				return nil, errors.New("connection reset")
+			},
+		}
+		rr := postBulk(t, store, "application/json", `[{"account_id": 1, "initial_balance": "0"}]`)
+		assert.Equal(t, http.StatusInternalServerError, rr.Code)
+	})
+}
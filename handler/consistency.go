@@ -0,0 +1,108 @@
+package handler
+
+import (
+	"log"
+	"net/http"
+	"strings"
+
+	"go-api-example/model"
+	"go-api-example/storage"
+
+	"github.com/gorilla/mux"
+)
+
+// ReadConsistencyHeader asks for the consistency of a read: "strong", the
+// default, reads from the primary, "eventual" from the read replica and
+// "read-your-writes" from the replica once it has replayed the write named in
+// ConsistencyTokenHeader.
+const ReadConsistencyHeader = "X-Read-Consistency"
+
+// ConsistencyTokenHeader carries the consistency token of a successful write
+// in the response, and the token a read-your-writes read must see in the
+// request.
+const ConsistencyTokenHeader = "X-Consistency-Token"
+
+// consistencyMiddleware lets GET requests choose where they read from, with
+// ReadConsistencyHeader and ConsistencyTokenHeader, and returns a consistency
+// token with every successful write. A read that asks for nothing is strong,
+// or read-your-writes if it carries a token, so that only reads that opt in
+// can see stale data. It is only installed when the store has a read replica.
+func consistencyMiddleware(store storage.Store, enabled bool) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		if !enabled {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodGet && r.Method != http.MethodHead {
+				next.ServeHTTP(&tokenWriter{ResponseWriter: w, r: r, store: store}, r)
+				return
+			}
+			c, errs := readConsistency(r)
+			if len(errs) > 0 {
+				p := newProblem(r, http.StatusBadRequest, CodeInvalidRequest, "Invalid request", errs.Error())
+				p.Errors = errs
+				writeProblem(w, p)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(storage.WithReadConsistency(r.Context(), c)))
+		})
+	}
+}
+
+// readConsistency returns the consistency r asks for.
+func readConsistency(r *http.Request) (storage.ReadConsistency, model.ValidationErrors) {
+	var errs model.ValidationErrors
+	c := storage.ReadConsistency{
+		Level: strings.ToLower(strings.TrimSpace(r.Header.Get(ReadConsistencyHeader))),
+		Token: strings.TrimSpace(r.Header.Get(ConsistencyTokenHeader)),
+	}
+	switch c.Level {
+	case "":
+		c.Level = storage.ConsistencyStrong
+		if c.Token != "" {
+			c.Level = storage.ConsistencyReadYourWrites
+		}
+	case storage.ConsistencyStrong, storage.ConsistencyEventual, storage.ConsistencyReadYourWrites:
+	default:
+		errs = append(errs, fieldError("header."+ReadConsistencyHeader, "enum",
+			`must be one of "strong", "eventual" or "read-your-writes"`))
+	}
+	if c.Level == storage.ConsistencyReadYourWrites {
+		if c.Token == "" {
+			errs = append(errs, fieldError("header."+ConsistencyTokenHeader, "required", "is required for read-your-writes"))
+		} else if _, err := storage.ParseLSN(c.Token); err != nil {
+			errs = append(errs, fieldError("header."+ConsistencyTokenHeader, "format", "must be a token returned by a write"))
+		}
+	}
+	return c, errs
+}
+
+// tokenWriter sets ConsistencyTokenHeader on a successful response to a write,
+// once the write has committed.
+type tokenWriter struct {
+	http.ResponseWriter
+	r           *http.Request
+	store       storage.Store
+	wroteHeader bool
+}
+
+func (w *tokenWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		if status < http.StatusBadRequest {
+			if token, err := w.store.ConsistencyToken(w.r.Context()); err != nil {
+				log.Printf("Could not get a consistency token for request %s: %v", RequestIDFromContext(w.r.Context()), err)
+			} else if token != "" {
+				w.Header().Set(ConsistencyTokenHeader, token)
+			}
+		}
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *tokenWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
@@ -0,0 +1,139 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go-api-example/model"
+	"go-api-example/storage"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConsistencyMiddleware(t *testing.T) {
+	var read storage.ReadConsistency
+	var hasConsistency bool
+	tokens := 0
+	store := &MockStore{
+		GetAccountFunc: func(ctx context.Context, id int64) (*model.Account, error) {
+			read, hasConsistency = storage.ReadConsistencyFrom(ctx)
+			return &model.Account{AccountID: id, Balance: decimal.NewFromInt(100)}, nil
+		},
+		ExecuteTransferFunc: func(ctx context.Context, req model.TransactionRequest) (*model.Transfer, error) {
+			if req.Amount.GreaterThan(decimal.NewFromInt(100)) {
+				return nil, &storage.AccountError{AccountID: req.SourceAccountID, Err: storage.ErrInsufficientFunds}
+			}
+			return &model.Transfer{EntryID: 1, TransactionRequest: req, Total: req.Amount}, nil
+		},
+		ConsistencyTokenFunc: func(ctx context.Context) (string, error) {
+			tokens++
+			return "0/16B3748", nil
+		},
+	}
+	get := func(router http.Handler, headers map[string]string) *httptest.ResponseRecorder {
+		hasConsistency = false
+		req := httptest.NewRequest("GET", "/accounts/1", nil)
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+	transfer := func(router http.Handler, amount string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/transactions", strings.NewReader(
+			`{"source_account_id": 1, "destination_account_id": 2, "amount": "`+amount+`"}`))
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+	router := NewRouter(store, WithReadReplica())
+
+	t.Run("reads are strong by default", func(t *testing.T) {
+		rr := get(router, nil)
+
+		require.Equal(t, http.StatusOK, rr.Code)
+		assert.True(t, hasConsistency)
+		assert.Equal(t, storage.ReadConsistency{Level: storage.ConsistencyStrong}, read)
+	})
+
+	t.Run("eventual", func(t *testing.T) {
+		rr := get(router, map[string]string{ReadConsistencyHeader: "Eventual"})
+
+		require.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, storage.ConsistencyEventual, read.Level)
+	})
+
+	t.Run("a token implies read-your-writes", func(t *testing.T) {
+		rr := get(router, map[string]string{ConsistencyTokenHeader: "0/16B3748"})
+
+		require.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, storage.ReadConsistency{Level: storage.ConsistencyReadYourWrites, Token: "0/16B3748"}, read)
+	})
+
+	t.Run("unknown consistency", func(t *testing.T) {
+		rr := get(router, map[string]string{ReadConsistencyHeader: "linearizable"})
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+		assert.False(t, hasConsistency, "the store was not called")
+		assert.Equal(t, "header.X-Read-Consistency", readProblem(t, rr).Errors[0].Field)
+	})
+
+	t.Run("read-your-writes needs a valid token", func(t *testing.T) {
+		rr := get(router, map[string]string{ReadConsistencyHeader: "read-your-writes"})
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+		assert.Contains(t, readProblem(t, rr).Detail, "is required for read-your-writes")
+
+		rr = get(router, map[string]string{ConsistencyTokenHeader: "latest"})
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+		assert.Contains(t, readProblem(t, rr).Detail, "must be a token returned by a write")
+	})
+
+	t.Run("writes return a token", func(t *testing.T) {
+		tokens = 0
+		rr := transfer(router, "10")
+
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+		assert.Equal(t, "0/16B3748", rr.Header().Get(ConsistencyTokenHeader))
+		assert.Equal(t, 1, tokens)
+	})
+
+	t.Run("failed writes return no token", func(t *testing.T) {
+		tokens = 0
+		rr := transfer(router, "1000")
+
+		require.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+		assert.Empty(t, rr.Header().Get(ConsistencyTokenHeader))
+		assert.Zero(t, tokens)
+	})
+
+	t.Run("token errors do not fail the write", func(t *testing.T) {
+		failing := *store
+		failing.ConsistencyTokenFunc = func(ctx context.Context) (string, error) { return "", errors.New("connection reset") }
+		rr := transfer(NewRouter(&failing, WithReadReplica()), "10")
+
+		require.Equal(t, http.StatusOK, rr.Code)
+		assert.Empty(t, rr.Header().Get(ConsistencyTokenHeader))
+	})
+
+	t.Run("without a read replica", func(t *testing.T) {
+		tokens = 0
+		plain := NewRouter(store)
+
+		rr := get(plain, map[string]string{ReadConsistencyHeader: "linearizable"})
+		require.Equal(t, http.StatusOK, rr.Code, "the headers are ignored")
+		assert.False(t, hasConsistency)
+
+		rr = transfer(plain, "10")
+		require.Equal(t, http.StatusOK, rr.Code)
+		assert.Empty(t, rr.Header().Get(ConsistencyTokenHeader))
+		assert.Zero(t, tokens)
+	})
+}
@@ -0,0 +1,80 @@
+package handler
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"go-api-example/model"
+
+	"github.com/shopspring/decimal"
+)
+
+// errInvalidCursor is returned for a cursor that cannot be decoded or was issued
+// for a different sort order than the request it is used with.
+var errInvalidCursor = errors.New("invalid cursor")
+
+// accountCursor is the payload of the opaque cursors returned by GET /accounts.
+// It records the sort order it was issued for, so that a cursor cannot be replayed
+// against another ordering, and whether it points forwards or backwards.
+type accountCursor struct {
+	Sort      string           `json:"s"`
+	Desc      bool             `json:"d,omitempty"`
+	Prev      bool             `json:"p,omitempty"`
+	AccountID int64            `json:"id"`
+	Balance   *decimal.Decimal `json:"b,omitempty"`
+	CreatedAt *time.Time       `json:"c,omitempty"`
+}
+
+// encodeCursor returns the opaque cursor for position c of a listing sorted by
+// filter, or "" if c is nil. prev marks a cursor for the preceding page.
+func encodeCursor(filter model.AccountFilter, c *model.AccountCursor, prev bool) string {
+	if c == nil {
+		return ""
+	}
+	ac := accountCursor{Sort: filter.Sort, Desc: filter.Desc, Prev: prev, AccountID: c.AccountID}
+	switch filter.Sort {
+	case model.SortBalance:
+		ac.Balance = &c.Balance
+	case model.SortCreatedAt:
+		ac.CreatedAt = &c.CreatedAt
+	}
+	b, _ := json.Marshal(ac) // cannot fail: plain struct
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// applyCursor decodes raw and sets filter.After or filter.Before from it.
+func applyCursor(raw string, filter *model.AccountFilter) error {
+	b, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return errInvalidCursor
+	}
+	var ac accountCursor
+	if err := json.Unmarshal(b, &ac); err != nil {
+		return errInvalidCursor
+	}
+	if ac.Sort != filter.Sort || ac.Desc != filter.Desc {
+		return errInvalidCursor
+	}
+
+	c := &model.AccountCursor{AccountID: ac.AccountID}
+	switch filter.Sort {
+	case model.SortBalance:
+		if ac.Balance == nil {
+			return errInvalidCursor
+		}
+		c.Balance = *ac.Balance
+	case model.SortCreatedAt:
+		if ac.CreatedAt == nil {
+			return errInvalidCursor
+		}
+		c.CreatedAt = *ac.CreatedAt
+	}
+	if ac.Prev {
+		filter.Before = c
+	} else {
+		filter.After = c
+	}
+	return nil
+}
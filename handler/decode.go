@@ -0,0 +1,113 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+
+	"go-api-example/model"
+)
+
+// maxBodyBytes caps every JSON request body. Bodies are small fixed-shape objects,
+// so anything larger is a client bug or abuse.
+const maxBodyBytes = 1 << 20
+
+// ruleCodes maps a failed validation rule to the problem code reported for it.
+var ruleCodes = map[string]string{
+	"nefield":     CodeSameAccount,
+	"positive":    CodeInvalidAmount,
+	"nonnegative": CodeInvalidAmount,
+	"maxdp":       CodeInvalidAmount,
+	"nonzero":     CodeInvalidAmount,
+	"balanced":    CodeUnbalancedEntry,
+}
+
+// decodeAndValidate is the shared decode-and-validate layer for JSON bodies.
+// It requires a JSON Content-Type, limits the body size, rejects unknown fields
+// and trailing data, and then applies the `validate` rules declared on dst.
+// On failure it writes a problem response and returns false.
+func decodeAndValidate(w http.ResponseWriter, r *http.Request, dst any) bool {
+	if p := checkJSONContentType(r); p != nil {
+		writeProblem(w, p)
+		return false
+	}
+
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(dst); err != nil {
+		writeProblem(w, decodeProblem(r, err))
+		return false
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		writeProblem(w, newProblem(r, http.StatusBadRequest, CodeInvalidRequest,
+			"Invalid request body", "Request body must contain a single JSON object"))
+		return false
+	}
+
+	if err := model.Validate(dst); err != nil {
+		var verrs model.ValidationErrors
+		errors.As(err, &verrs)
+		p := newProblem(r, http.StatusBadRequest, validationCode(verrs), "Validation failed", verrs.Error())
+		p.Errors = verrs
+		writeProblem(w, p)
+		return false
+	}
+	return true
+}
+
+// checkJSONContentType returns a 415 problem unless the request declares a JSON body.
+func checkJSONContentType(r *http.Request) *Problem {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != "application/json" {
+		return newProblem(r, http.StatusUnsupportedMediaType, CodeUnsupportedMediaType,
+			"Unsupported media type", "Content-Type must be application/json")
+	}
+	return nil
+}
+
+// decodeProblem turns a json.Decoder error into a problem with a useful detail.
+func decodeProblem(r *http.Request, err error) *Problem {
+	var maxErr *http.MaxBytesError
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+
+	switch {
+	case errors.As(err, &maxErr):
+		return newProblem(r, http.StatusRequestEntityTooLarge, CodePayloadTooLarge,
+			"Payload too large", fmt.Sprintf("Request body must not exceed %d bytes", maxErr.Limit))
+	case errors.As(err, &syntaxErr):
+		return newProblem(r, http.StatusBadRequest, CodeInvalidRequest,
+			"Invalid request body", fmt.Sprintf("Malformed JSON at offset %d", syntaxErr.Offset))
+	case errors.As(err, &typeErr):
+		p := newProblem(r, http.StatusBadRequest, CodeInvalidRequest,
+			"Invalid request body", fmt.Sprintf("Field %q has the wrong type", typeErr.Field))
+		p.Errors = model.ValidationErrors{{Field: typeErr.Field, Rule: "type", Message: "must be " + typeErr.Type.String()}}
+		return p
+	case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return newProblem(r, http.StatusBadRequest, CodeInvalidRequest,
+			"Invalid request body", "Request body is empty or truncated")
+	default:
+		// Unknown fields and decimal parse errors end up here; their messages name the field.
+		return newProblem(r, http.StatusBadRequest, CodeInvalidRequest, "Invalid request body", err.Error())
+	}
+}
+
+// validationCode picks a specific code when every failed rule agrees on one,
+// and falls back to INVALID_REQUEST otherwise.
+func validationCode(errs model.ValidationErrors) string {
+	code := ""
+	for _, fe := range errs {
+		c, ok := ruleCodes[fe.Rule]
+		if !ok || (code != "" && c != code) {
+			return CodeInvalidRequest
+		}
+		code = c
+	}
+	if code == "" {
+		return CodeInvalidRequest
+	}
+	return code
+}
@@ -0,0 +1,78 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go-api-example/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodeAndValidate(t *testing.T) {
+	t.Run("valid body", func(t *testing.T) {
+		var req model.TransactionRequest
+		rr := httptest.NewRecorder()
+		ok := decodeAndValidate(rr, newJSONRequest("POST", "/transactions",
+			`{"source_account_id": 1, "destination_account_id": 2, "amount": "10.5"}`), &req)
+
+		require.True(t, ok)
+		assert.Equal(t, int64(2), req.DestinationAccountID)
+	})
+
+	t.Run("content type with charset is accepted", func(t *testing.T) {
+		var req model.CreateAccountRequest
+		r := httptest.NewRequest("POST", "/accounts", strings.NewReader(`{"account_id": 1, "initial_balance": "0"}`))
+		r.Header.Set("Content-Type", "application/json; charset=utf-8")
+		assert.True(t, decodeAndValidate(httptest.NewRecorder(), r, &req))
+	})
+
+	tests := []struct {
+		name   string
+		body   string
+		status int
+		code   string
+	}{
+		{"unknown field", `{"source_account_id": 1, "destination_account_id": 2, "ammount": "10"}`, http.StatusBadRequest, CodeInvalidRequest},
+		{"trailing data", `{"source_account_id": 1, "destination_account_id": 2, "amount": "10"} {}`, http.StatusBadRequest, CodeInvalidRequest},
+		{"empty body", ``, http.StatusBadRequest, CodeInvalidRequest},
+		{"wrong type", `{"source_account_id": "one", "destination_account_id": 2, "amount": "10"}`, http.StatusBadRequest, CodeInvalidRequest},
+		{"same account", `{"source_account_id": 1, "destination_account_id": 1, "amount": "10"}`, http.StatusBadRequest, CodeSameAccount},
+		{"too many decimal places", `{"source_account_id": 1, "destination_account_id": 2, "amount": "0.000001"}`, http.StatusBadRequest, CodeInvalidAmount},
+		{"oversized body", `{"source_account_id": 1, "pad": "` + strings.Repeat("x", maxBodyBytes) + `"}`, http.StatusRequestEntityTooLarge, CodePayloadTooLarge},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var req model.TransactionRequest
+			rr := httptest.NewRecorder()
+			ok := decodeAndValidate(rr, newJSONRequest("POST", "/transactions", tc.body), &req)
+
+			assert.False(t, ok)
+			assert.Equal(t, tc.status, rr.Code)
+			assert.Equal(t, tc.code, readProblem(t, rr).Code)
+		})
+	}
+
+	t.Run("all field errors are returned at once", func(t *testing.T) {
+		var req model.TransactionRequest
+		rr := httptest.NewRecorder()
+		ok := decodeAndValidate(rr, newJSONRequest("POST", "/transactions",
+			`{"source_account_id": 0, "destination_account_id": -4, "amount": "-1"}`), &req)
+
+		assert.False(t, ok)
+		p := readProblem(t, rr)
+		assert.Equal(t, CodeInvalidRequest, p.Code)
+		assert.Len(t, p.Errors, 3)
+	})
+
+	t.Run("missing content type", func(t *testing.T) {
+		var req model.TransactionRequest
+		rr := httptest.NewRecorder()
+		r := httptest.NewRequest("POST", "/transactions", strings.NewReader(`{}`))
+		assert.False(t, decodeAndValidate(rr, r, &req))
+		assert.Equal(t, http.StatusUnsupportedMediaType, rr.Code)
+	})
+}
@@ -0,0 +1,176 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"go-api-example/model"
+	"go-api-example/storage"
+
+	"github.com/shopspring/decimal"
+)
+
+// Stable, machine-readable error codes returned in the "code" member of every
+// problem+json response. Clients should branch on these, never on title or detail.
+const (
+	CodeInvalidRequest    = "INVALID_REQUEST"
+	CodeInvalidAccountID  = "INVALID_ACCOUNT_ID"
+	CodeInvalidAmount     = "INVALID_AMOUNT"
+	CodeSameAccount       = "SAME_ACCOUNT"
+	CodeAccountNotFound   = "ACCOUNT_NOT_FOUND"
+	CodeInsufficientFunds = "INSUFFICIENT_FUNDS"
+	CodeAccountFrozen     = "ACCOUNT_FROZEN"
+	CodeAccountReadOnly   = "ACCOUNT_READ_ONLY"
+	CodeUnbalancedEntry   = "UNBALANCED_ENTRY"
+	CodeCurrencyMismatch  = "CURRENCY_MISMATCH"
+	CodeJobNotFound       = "JOB_NOT_FOUND"
+	CodePrincipalRequired = "PRINCIPAL_REQUIRED"
+	CodeApprovalNotFound  = "APPROVAL_NOT_FOUND"
+	CodeApprovalDecided   = "APPROVAL_ALREADY_DECIDED"
+	CodeApprovalExpired   = "APPROVAL_EXPIRED"
+	CodeSelfApproval      = "SELF_APPROVAL"
+	CodeLimitExceeded     = "LIMIT_EXCEEDED"
+	CodeTransferDenied    = "TRANSFER_DENIED"
+	CodeTransferBlocked   = "TRANSFER_BLOCKED"
+	CodeInternal          = "INTERNAL_ERROR"
+
+	CodeUnsupportedMediaType = "UNSUPPORTED_MEDIA_TYPE"
+	CodePayloadTooLarge      = "PAYLOAD_TOO_LARGE"
+	CodeRateLimited          = "RATE_LIMITED"
+	CodeReplicaUnavailable   = "REPLICA_UNAVAILABLE"
+)
+
+// problemContentType is the media type defined by RFC 7807.
+const problemContentType = "application/problem+json"
+
+// Problem is an RFC 7807 problem details body extended with a stable error code,
+// the request ID and, where relevant, the account that caused the failure, the
+// transfer limit it would exceed, with what remains of it, and the code of the
+// transfer rule that denied it.
+type Problem struct {
+	Type      string                 `json:"type"`
+	Title     string                 `json:"title"`
+	Status    int                    `json:"status"`
+	Detail    string                 `json:"detail,omitempty"`
+	Instance  string                 `json:"instance,omitempty"`
+	Code      string                 `json:"code"`
+	RequestID string                 `json:"request_id,omitempty"`
+	AccountID *int64                 `json:"account_id,omitempty"`
+	Limit     string                 `json:"limit,omitempty"`
+	Remaining *decimal.Decimal       `json:"remaining,omitempty"`
+	RuleCode  string                 `json:"rule_code,omitempty"`
+	Errors    model.ValidationErrors `json:"errors,omitempty"`
+}
+
+// newProblem builds a Problem for the given request. The type URI is derived from the code.
+func newProblem(r *http.Request, status int, code, title, detail string) *Problem {
+	return &Problem{
+		Type:      "/problems/" + strings.ToLower(strings.ReplaceAll(code, "_", "-")),
+		Title:     title,
+		Status:    status,
+		Detail:    detail,
+		Instance:  r.URL.Path,
+		Code:      code,
+		RequestID: RequestIDFromContext(r.Context()),
+	}
+}
+
+// writeProblem writes p as an application/problem+json response.
+func writeProblem(w http.ResponseWriter, p *Problem) {
+	w.Header().Set("Content-Type", problemContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(p.Status)
+	if err := json.NewEncoder(w).Encode(p); err != nil {
+		log.Printf("Error writing problem response: %v", err)
+	}
+}
+
+// writeError is the central mapper from storage errors to HTTP responses.
+// Every handler funnels store failures through it so that the same error
+// always produces the same status and code.
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+	writeProblem(w, problemFromError(r, err))
+}
+
+func problemFromError(r *http.Request, err error) *Problem {
+	var p *Problem
+	switch {
+	case errors.Is(err, storage.ErrInsufficientFunds):
+		p = newProblem(r, http.StatusUnprocessableEntity, CodeInsufficientFunds,
+			"Insufficient funds", "The source account balance does not cover the amount")
+	case errors.Is(err, storage.ErrNotFound):
+		p = newProblem(r, http.StatusNotFound, CodeAccountNotFound,
+			"Account not found", "One or both accounts not found")
+	case errors.Is(err, storage.ErrAccountFrozen):
+		p = newProblem(r, http.StatusUnprocessableEntity, CodeAccountFrozen,
+			"Account frozen", "A frozen account cannot send or receive transfers")
+	case errors.Is(err, storage.ErrAccountReadOnly):
+		p = newProblem(r, http.StatusUnprocessableEntity, CodeAccountReadOnly,
+			"Account read-only", "An account with a reconciliation mismatch cannot send or receive transfers")
+	case errors.Is(err, storage.ErrLimitExceeded):
+		p = newProblem(r, http.StatusUnprocessableEntity, CodeLimitExceeded,
+			"Transfer limit exceeded", "The transfer exceeds a limit of the source account")
+	case errors.Is(err, storage.ErrCurrencyMismatch):
+		p = newProblem(r, http.StatusUnprocessableEntity, CodeCurrencyMismatch,
+			"Currency mismatch", "A posting's currency differs from its account's currency")
+	case errors.Is(err, storage.ErrJobNotFound):
+		p = newProblem(r, http.StatusNotFound, CodeJobNotFound,
+			"Transfer job not found", "No transfer job has this ID")
+	case errors.Is(err, storage.ErrApprovalNotFound):
+		p = newProblem(r, http.StatusNotFound, CodeApprovalNotFound,
+			"Approval not found", "No transfer approval has this ID")
+	case errors.Is(err, storage.ErrApprovalNotPending):
+		p = newProblem(r, http.StatusConflict, CodeApprovalDecided,
+			"Approval already decided", "The transfer has already been approved or rejected")
+	case errors.Is(err, storage.ErrApprovalExpired):
+		p = newProblem(r, http.StatusConflict, CodeApprovalExpired,
+			"Approval expired", "The transfer was not decided in time; submit it again")
+	case errors.Is(err, storage.ErrSelfApproval):
+		p = newProblem(r, http.StatusForbidden, CodeSelfApproval,
+			"Self-approval not allowed", "A transfer must be decided by a principal other than the one who requested it")
+	case errors.Is(err, storage.ErrUnbalancedEntry):
+		p = newProblem(r, http.StatusBadRequest, CodeUnbalancedEntry,
+			"Unbalanced entry", "The postings do not sum to zero in each currency")
+	default:
+		log.Printf("Internal error [request_id=%s]: %v", RequestIDFromContext(r.Context()), err)
+		return newProblem(r, http.StatusInternalServerError, CodeInternal,
+			"Internal server error", "The request could not be processed")
+	}
+
+	var accErr *storage.AccountError
+	if errors.As(err, &accErr) {
+		id := accErr.AccountID
+		p.AccountID = &id
+		switch p.Code {
+		case CodeAccountNotFound:
+			p.Detail = fmt.Sprintf("Account %d does not exist", id)
+		case CodeInsufficientFunds:
+			p.Detail = fmt.Sprintf("Account %d balance does not cover the amount", id)
+		case CodeAccountFrozen:
+			p.Detail = fmt.Sprintf("Account %d is frozen", id)
+		case CodeAccountReadOnly:
+			p.Detail = fmt.Sprintf("Account %d is read-only pending reconciliation", id)
+		case CodeCurrencyMismatch:
+			p.Detail = fmt.Sprintf("Account %d is held in a different currency", id)
+		}
+	}
+	var limitErr *storage.LimitError
+	if errors.As(err, &limitErr) {
+		p.Limit, p.Remaining = limitErr.Limit, &limitErr.Remaining
+		p.Detail = fmt.Sprintf("The transfer exceeds the %s limit of the source account; %s remains", limitErr.Limit, limitErr.Remaining)
+	}
+	return p
+}
+
+// writeJSON writes v as a JSON response with the given status code.
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("Error writing JSON response: %v", err)
+	}
+}
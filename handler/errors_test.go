@@ -0,0 +1,103 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go-api-example/model"
+	"go-api-example/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func readProblem(t *testing.T, rr *httptest.ResponseRecorder) Problem {
+	t.Helper()
+	assert.Equal(t, "application/problem+json", rr.Header().Get("Content-Type"))
+	var p Problem
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &p))
+	return p
+}
+
+func TestProblemFromError(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		status    int
+		code      string
+		accountID *int64
+	}{
+		{"insufficient funds with account", &storage.AccountError{AccountID: 7, Err: storage.ErrInsufficientFunds}, http.StatusUnprocessableEntity, CodeInsufficientFunds, ptr(int64(7))},
+		{"not found with account", &storage.AccountError{AccountID: 9, Err: storage.ErrNotFound}, http.StatusNotFound, CodeAccountNotFound, ptr(int64(9))},
+		{"frozen with account", &storage.AccountError{AccountID: 3, Err: storage.ErrAccountFrozen}, http.StatusUnprocessableEntity, CodeAccountFrozen, ptr(int64(3))},
+		{"bare not found", storage.ErrNotFound, http.StatusNotFound, CodeAccountNotFound, nil},
+		{"unknown error", errors.New("connection reset"), http.StatusInternalServerError, CodeInternal, nil},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/transactions", nil)
+			p := problemFromError(req, tc.err)
+
+			assert.Equal(t, tc.status, p.Status)
+			assert.Equal(t, tc.code, p.Code)
+			assert.Equal(t, tc.accountID, p.AccountID)
+			assert.NotContains(t, p.Detail, "connection reset", "internal errors must not leak")
+		})
+	}
+}
+
+func TestProblemResponseThroughRouter(t *testing.T) {
+	mockStore := &MockStore{
+		ExecuteTransferFunc: func(ctx context.Context, req model.TransactionRequest) (*model.Transfer, error) {
+			return nil, &storage.AccountError{AccountID: req.SourceAccountID, Err: storage.ErrInsufficientFunds}
+		},
+	}
+	router := NewRouter(mockStore)
+	body := `{"source_account_id": 1, "destination_account_id": 2, "amount": "1000"}`
+	req := newJSONRequest("POST", "/transactions", body)
+	req.Header.Set(RequestIDHeader, "req-123")
+	rr := httptest.NewRecorder()
+
+	router.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+	assert.Equal(t, "req-123", rr.Header().Get(RequestIDHeader))
+	p := readProblem(t, rr)
+	assert.Equal(t, CodeInsufficientFunds, p.Code)
+	assert.Equal(t, "req-123", p.RequestID)
+	assert.Equal(t, "/problems/insufficient-funds", p.Type)
+	require.NotNil(t, p.AccountID)
+	assert.Equal(t, int64(1), *p.AccountID)
+}
+
+func TestRequestIDMiddleware(t *testing.T) {
+	var seen string
+	h := RequestIDMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = RequestIDFromContext(r.Context())
+	}))
+
+	t.Run("generates an ID when absent", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, httptest.NewRequest("GET", "/", nil))
+		assert.Len(t, seen, 32)
+		assert.Equal(t, seen, rr.Header().Get(RequestIDHeader))
+	})
+
+	t.Run("replaces a malformed client ID", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set(RequestIDHeader, "bad id\n")
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		assert.NotEqual(t, "bad id\n", seen)
+		assert.Len(t, seen, 32)
+	})
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
@@ -0,0 +1,122 @@
+package handler
+
+import (
+	"log"
+	"net/http"
+
+	"go-api-example/model"
+	"go-api-example/storage"
+
+	"github.com/gorilla/mux"
+)
+
+// FeesHandler holds dependencies for the fee schedule admin handlers.
+// The gateway in front of the API must restrict /admin/ to operators.
+type FeesHandler struct {
+	store storage.Store
+}
+
+// NewFeesHandler creates a new FeesHandler.
+func NewFeesHandler(store storage.Store) *FeesHandler {
+	return &FeesHandler{store: store}
+}
+
+// ListTierFeesHandler returns the fee schedule of every tier that has one.
+//
+// Method: GET
+// Path: /admin/fees/tiers
+// Success: 200 OK
+// Error: 500 Internal Server Error (for database errors)
+func (h *FeesHandler) ListTierFeesHandler(w http.ResponseWriter, r *http.Request) {
+	tiers, err := h.store.ListTierFees(r.Context())
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string][]model.TierFees{"tiers": tiers})
+}
+
+// SetTierFeesHandler replaces the fee schedule of a tier, or removes it when
+// the body has no "schedule". The change applies to the next transfer of every
+// account in the tier without a schedule of its own.
+//
+// Method: PUT
+// Path: /admin/fees/tiers/{tier}
+// Success: 200 OK (with the tier's schedule)
+// Success: 204 No Content (schedule removed)
+// Error: 400 Bad Request (for an invalid tier name, invalid JSON or validation failure)
+// Error: 404 Not Found (if the revenue account does not exist)
+// Error: 422 Unprocessable Entity (if the revenue account is held in another currency than the tier's accounts)
+// Error: 500 Internal Server Error (for database errors)
+func (h *FeesHandler) SetTierFeesHandler(w http.ResponseWriter, r *http.Request) {
+	tier := mux.Vars(r)["tier"]
+	if !model.IsTierName(tier) {
+		writeProblem(w, newProblem(r, http.StatusBadRequest, CodeInvalidRequest, "Invalid tier",
+			"Tier names are 1 to 32 lower-case letters, digits, '-' or '_'"))
+		return
+	}
+	var req model.FeeScheduleRequest
+	if !decodeAndValidate(w, r, &req) {
+		return
+	}
+	if err := h.store.SetTierFees(r.Context(), tier, req.Schedule); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if req.Schedule == nil {
+		log.Printf("Fee schedule of tier %s removed", tier)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	log.Printf("Fee schedule of tier %s set (%s)", tier, req.Schedule.Type)
+	writeJSON(w, http.StatusOK, model.TierFees{Tier: tier, Schedule: *req.Schedule})
+}
+
+// GetAccountFeesHandler returns an account's tier, own and effective fee schedules.
+//
+// Method: GET
+// Path: /admin/fees/accounts/{account_id}
+// Success: 200 OK
+// Error: 400 Bad Request (for an invalid account ID)
+// Error: 404 Not Found (if the account does not exist)
+// Error: 500 Internal Server Error (for database errors)
+func (h *FeesHandler) GetAccountFeesHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := accountIDFromPath(w, r)
+	if !ok {
+		return
+	}
+	fees, err := h.store.GetAccountFees(r.Context(), id)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, fees)
+}
+
+// SetAccountFeesHandler replaces an account's own fee schedule, or removes it
+// when the body has no "schedule", so that its tier's schedule applies.
+//
+// Method: PUT
+// Path: /admin/fees/accounts/{account_id}
+// Success: 200 OK (with the account's schedules)
+// Error: 400 Bad Request (for an invalid account ID, invalid JSON or validation failure)
+// Error: 404 Not Found (if the account or the revenue account does not exist)
+// Error: 422 Unprocessable Entity (if the revenue account is held in another currency than the account)
+// Error: 500 Internal Server Error (for database errors)
+func (h *FeesHandler) SetAccountFeesHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := accountIDFromPath(w, r)
+	if !ok {
+		return
+	}
+	var req model.FeeScheduleRequest
+	if !decodeAndValidate(w, r, &req) {
+		return
+	}
+	fees, err := h.store.SetAccountFees(r.Context(), id, req.Schedule)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	log.Printf("Fee schedule of account %d set (tier %s)", id, fees.Tier)
+	writeJSON(w, http.StatusOK, fees)
+}
@@ -0,0 +1,105 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go-api-example/model"
+	"go-api-example/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetTierFeesHandler(t *testing.T) {
+	var stored *model.FeeSchedule
+	store := &MockStore{
+		SetTierFeesFunc: func(ctx context.Context, tier string, schedule *model.FeeSchedule) error {
+			stored = schedule
+			if schedule != nil && schedule.RevenueAccountID != 9 {
+				return &storage.AccountError{AccountID: schedule.RevenueAccountID, Err: storage.ErrNotFound}
+			}
+			return nil
+		},
+	}
+
+	t.Run("tiered schedule", func(t *testing.T) {
+		rr := putJSON(store, "/admin/fees/tiers/gold", `{"schedule": {"type": "tiered", "revenue_account_id": 9, "max": "25",
+			"bands": [{"up_to": "1000", "flat": "1"}, {"percent": "0.2"}]}}`)
+
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+		require.NotNil(t, stored)
+		assert.Equal(t, model.FeeTiered, stored.Type)
+		require.Len(t, stored.Bands, 2)
+		assert.Equal(t, "0.2", stored.Bands[1].Percent.String())
+		var fees model.TierFees
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &fees))
+		assert.Equal(t, "gold", fees.Tier)
+	})
+
+	t.Run("remove", func(t *testing.T) {
+		rr := putJSON(store, "/admin/fees/tiers/gold", `{}`)
+
+		assert.Equal(t, http.StatusNoContent, rr.Code)
+		assert.Nil(t, stored)
+	})
+
+	t.Run("unknown type", func(t *testing.T) {
+		rr := putJSON(store, "/admin/fees/tiers/gold", `{"schedule": {"type": "hourly", "revenue_account_id": 9}}`)
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+
+	t.Run("fields of another type", func(t *testing.T) {
+		rr := putJSON(store, "/admin/fees/tiers/gold", `{"schedule": {"type": "flat", "revenue_account_id": 9, "flat": "1", "percent": "2"}}`)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+		assert.Equal(t, "schedule.percent: is not used by a flat fee", readProblem(t, rr).Detail)
+	})
+
+	t.Run("unknown revenue account", func(t *testing.T) {
+		rr := putJSON(store, "/admin/fees/tiers/gold", `{"schedule": {"type": "flat", "revenue_account_id": 8, "flat": "1"}}`)
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+	})
+}
+
+func TestAccountFeesHandlers(t *testing.T) {
+	store := &MockStore{
+		GetAccountFeesFunc: func(ctx context.Context, id int64) (*model.AccountFees, error) {
+			if id != 1 {
+				return nil, &storage.AccountError{AccountID: id, Err: storage.ErrNotFound}
+			}
+			return &model.AccountFees{AccountID: id, Tier: model.DefaultTier}, nil
+		},
+		SetAccountFeesFunc: func(ctx context.Context, id int64, schedule *model.FeeSchedule) (*model.AccountFees, error) {
+			return &model.AccountFees{AccountID: id, Tier: model.DefaultTier, Override: schedule, Effective: schedule}, nil
+		},
+	}
+
+	t.Run("get", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		NewRouter(store).ServeHTTP(rr, httptest.NewRequest("GET", "/admin/fees/accounts/1", nil))
+
+		require.Equal(t, http.StatusOK, rr.Code)
+		assert.JSONEq(t, `{"account_id": 1, "tier": "standard"}`, rr.Body.String())
+	})
+
+	t.Run("get unknown account", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		NewRouter(store).ServeHTTP(rr, httptest.NewRequest("GET", "/admin/fees/accounts/9", nil))
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+	})
+
+	t.Run("set", func(t *testing.T) {
+		rr := putJSON(store, "/admin/fees/accounts/1", `{"schedule": {"type": "percentage", "revenue_account_id": 9, "percent": "0.5", "min": "1"}}`)
+
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+		var fees model.AccountFees
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &fees))
+		require.NotNil(t, fees.Effective)
+		assert.Equal(t, model.FeePercentage, fees.Effective.Type)
+		assert.Equal(t, "1", fees.Effective.Min.String())
+	})
+}
@@ -0,0 +1,69 @@
+package handler
+
+import (
+	"log"
+	"net/http"
+
+	"go-api-example/model"
+	"go-api-example/storage"
+)
+
+// InterestHandler holds dependencies for the interest rate admin handlers.
+// The gateway in front of the API must restrict /admin/ to operators.
+type InterestHandler struct {
+	store storage.Store
+}
+
+// NewInterestHandler creates a new InterestHandler.
+func NewInterestHandler(store storage.Store) *InterestHandler {
+	return &InterestHandler{store: store}
+}
+
+// GetInterestRateHandler returns an account's interest rate and the interest it
+// has accrued and not posted yet.
+//
+// Method: GET
+// Path: /admin/interest/accounts/{account_id}
+// Success: 200 OK
+// Error: 400 Bad Request (for an invalid account ID)
+// Error: 404 Not Found (if the account does not exist)
+// Error: 500 Internal Server Error (for database errors)
+func (h *InterestHandler) GetInterestRateHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := accountIDFromPath(w, r)
+	if !ok {
+		return
+	}
+	rate, err := h.store.GetInterestRate(r.Context(), id)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, rate)
+}
+
+// SetInterestRateHandler replaces an account's interest rate. It applies from
+// the next day accrued; a zero rate stops accrual.
+//
+// Method: PUT
+// Path: /admin/interest/accounts/{account_id}
+// Success: 200 OK (with the account's rate)
+// Error: 400 Bad Request (for an invalid account ID, invalid JSON or validation failure)
+// Error: 404 Not Found (if the account does not exist)
+// Error: 500 Internal Server Error (for database errors)
+func (h *InterestHandler) SetInterestRateHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := accountIDFromPath(w, r)
+	if !ok {
+		return
+	}
+	var req model.InterestRateRequest
+	if !decodeAndValidate(w, r, &req) {
+		return
+	}
+	rate, err := h.store.SetInterestRate(r.Context(), id, req)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	log.Printf("Interest rate of account %d set to %s%% (%s)", id, rate.AnnualRate, rate.DayCount)
+	writeJSON(w, http.StatusOK, rate)
+}
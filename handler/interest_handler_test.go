@@ -0,0 +1,66 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go-api-example/model"
+	"go-api-example/storage"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInterestRateHandlers(t *testing.T) {
+	var stored model.InterestRateRequest
+	store := &MockStore{
+		GetInterestRateFunc: func(ctx context.Context, id int64) (*model.InterestRate, error) {
+			if id != 1 {
+				return nil, &storage.AccountError{AccountID: id, Err: storage.ErrNotFound}
+			}
+			return &model.InterestRate{AccountID: id, AnnualRate: decimal.NewFromInt(2), DayCount: model.DayCountACT365,
+				Accrued: decimal.RequireFromString("0.054794520547945205")}, nil
+		},
+		SetInterestRateFunc: func(ctx context.Context, id int64, req model.InterestRateRequest) (*model.InterestRate, error) {
+			stored = req
+			return &model.InterestRate{AccountID: id, AnnualRate: req.AnnualRate, DayCount: req.DayCount}, nil
+		},
+	}
+
+	t.Run("get", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		NewRouter(store).ServeHTTP(rr, httptest.NewRequest("GET", "/admin/interest/accounts/1", nil))
+
+		require.Equal(t, http.StatusOK, rr.Code)
+		assert.JSONEq(t, `{"account_id": 1, "annual_rate": "2", "day_count": "ACT/365", "accrued": "0.054794520547945205", "carry": "0"}`, rr.Body.String())
+	})
+
+	t.Run("get unknown account", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		NewRouter(store).ServeHTTP(rr, httptest.NewRequest("GET", "/admin/interest/accounts/9", nil))
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+	})
+
+	t.Run("set", func(t *testing.T) {
+		rr := putJSON(store, "/admin/interest/accounts/1", `{"annual_rate": "3.25", "day_count": "30/360"}`)
+
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+		assert.Equal(t, "3.25", stored.AnnualRate.String())
+		assert.Equal(t, model.DayCount30360, stored.DayCount)
+	})
+
+	t.Run("unknown day count", func(t *testing.T) {
+		rr := putJSON(store, "/admin/interest/accounts/1", `{"annual_rate": "1", "day_count": "ACT/360"}`)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+		assert.Contains(t, readProblem(t, rr).Detail, "day_count: must be one of")
+	})
+
+	t.Run("negative rate", func(t *testing.T) {
+		rr := putJSON(store, "/admin/interest/accounts/1", `{"annual_rate": "-1", "day_count": "ACT/365"}`)
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+}
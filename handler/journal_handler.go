@@ -0,0 +1,88 @@
+package handler
+
+import (
+	"log"
+	"net/http"
+
+	"go-api-example/model"
+	"go-api-example/rules"
+	"go-api-example/storage"
+)
+
+// JournalHandler holds dependencies for journal entry handlers.
+type JournalHandler struct {
+	store     storage.Store
+	approvals ApprovalPolicy
+	rules     *rules.Engine
+	screening ScreeningPolicy
+}
+
+// NewJournalHandler creates a new JournalHandler that holds entries requiring
+// approval under approvals, evaluates engine's transfer rules on every leg of an
+// entry and screens entries under screening.
+func NewJournalHandler(store storage.Store, approvals ApprovalPolicy, engine *rules.Engine, screening ScreeningPolicy) *JournalHandler {
+	return &JournalHandler{store: store, approvals: approvals, rules: engine, screening: screening}
+}
+
+// CreateJournalEntryHandler posts a journal entry with any number of postings.
+// Negative amounts debit an account and positive amounts credit it; the postings
+// must sum to zero in each currency. The entry is applied atomically. Every
+// account the entry posts to is first screened against the blocklist, as for a
+// transfer. The transfer rules are evaluated next on every leg of the entry,
+// each debited account to each credited one: an entry a rule denies on any leg
+// is rejected. An entry sent to review by screening or a rule is held for
+// approval, as is one that debits an account by more than the approval
+// threshold over all its postings; the request must then carry X-Principal.
+//
+// Method: POST
+// Path: /journal-entries
+// Success: 201 Created (with the recorded entry)
+// Success: 202 Accepted (held for approval; Location points at the approval)
+// Error: 400 Bad Request (for invalid JSON, validation failure, an unbalanced entry or a held entry without X-Principal)
+// Error: 404 Not Found (if an account does not exist)
+// Error: 413 Request Entity Too Large (body over 1 MiB)
+// Error: 415 Unsupported Media Type (Content-Type is not application/json)
+// Error: 422 Unprocessable Entity (insufficient funds, frozen account, currency mismatch, a blocklist match or a rule denying a leg)
+// Error: 500 Internal Server Error (for database errors)
+func (h *JournalHandler) CreateJournalEntryHandler(w http.ResponseWriter, r *http.Request) {
+	var req model.JournalEntryRequest
+	if !decodeAndValidate(w, r, &req) {
+		return
+	}
+
+	getAccount := cachedAccounts(h.store.GetAccount)
+	screened, err := screenEntry(r.Context(), h.store, h.screening, getAccount, req)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if screened == model.ScreeningBlock {
+		blockTransfer(w, r)
+		return
+	}
+
+	decision, err := decideEntryRules(r.Context(), h.rules, getAccount, req)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if decision.Action == model.RuleDeny {
+		log.Printf("Journal entry with %d postings denied by rule %s", len(req.Postings), decision.Rule)
+		denyTransfer(w, r, decision)
+		return
+	}
+
+	if screened == model.ScreeningReview || decision.Action == model.RuleReview || h.approvals.RequiresEntry(req) {
+		holdJournalEntry(w, r, h.store, h.approvals, req)
+		return
+	}
+
+	entry, err := h.store.PostJournalEntry(r.Context(), req)
+	if err != nil {
+		log.Printf("Error posting journal entry: %v", err)
+		writeError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, entry)
+}
@@ -0,0 +1,98 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go-api-example/model"
+	"go-api-example/storage"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateJournalEntryHandler(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		mockStore := &MockStore{
+			PostJournalEntryFunc: func(ctx context.Context, req model.JournalEntryRequest) (*model.JournalEntry, error) {
+				require.Len(t, req.Postings, 3)
+				assert.True(t, decimal.NewFromInt(-100).Equal(req.Postings[0].Amount))
+				return &model.JournalEntry{EntryID: 7, Kind: model.EntryKindJournal, Postings: []model.Posting{}}, nil
+			},
+		}
+		body := `{"postings": [
+			{"account_id": 1, "amount": "-100", "currency": "EUR"},
+			{"account_id": 2, "amount": "99.5", "currency": "EUR"},
+			{"account_id": 3, "amount": "0.5", "currency": "EUR"}
+		]}`
+		req := newJSONRequest("POST", "/journal-entries", body)
+		rr := httptest.NewRecorder()
+
+		NewRouter(mockStore).ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusCreated, rr.Code)
+		var entry model.JournalEntry
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &entry))
+		assert.Equal(t, int64(7), entry.EntryID)
+	})
+
+	t.Run("unbalanced entry", func(t *testing.T) {
+		body := `{"postings": [
+			{"account_id": 1, "amount": "-100", "currency": "EUR"},
+			{"account_id": 2, "amount": "100", "currency": "USD"}
+		]}`
+		req := newJSONRequest("POST", "/journal-entries", body)
+		rr := httptest.NewRecorder()
+
+		NewRouter(&MockStore{}).ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+		p := readProblem(t, rr)
+		assert.Equal(t, CodeUnbalancedEntry, p.Code)
+		require.Len(t, p.Errors, 2)
+		assert.Equal(t, "postings", p.Errors[0].Field)
+	})
+
+	t.Run("single posting", func(t *testing.T) {
+		body := `{"postings": [{"account_id": 1, "amount": "-100", "currency": "EUR"}]}`
+		req := newJSONRequest("POST", "/journal-entries", body)
+		rr := httptest.NewRecorder()
+
+		NewRouter(&MockStore{}).ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+		assert.Contains(t, rr.Body.String(), "at least 2 items")
+	})
+
+	t.Run("store errors are mapped", func(t *testing.T) {
+		tests := []struct {
+			err    error
+			status int
+			code   string
+		}{
+			{&storage.AccountError{AccountID: 2, Err: storage.ErrInsufficientFunds}, http.StatusUnprocessableEntity, CodeInsufficientFunds},
+			{&storage.AccountError{AccountID: 2, Err: storage.ErrCurrencyMismatch}, http.StatusUnprocessableEntity, CodeCurrencyMismatch},
+			{&storage.AccountError{AccountID: 2, Err: storage.ErrNotFound}, http.StatusNotFound, CodeAccountNotFound},
+			{storage.ErrUnbalancedEntry, http.StatusBadRequest, CodeUnbalancedEntry},
+		}
+		for _, tt := range tests {
+			mockStore := &MockStore{
+				PostJournalEntryFunc: func(ctx context.Context, req model.JournalEntryRequest) (*model.JournalEntry, error) {
+					return nil, tt.err
+				},
+			}
+			body := `{"postings": [{"account_id": 1, "amount": "-1", "currency": "EUR"}, {"account_id": 2, "amount": "1", "currency": "EUR"}]}`
+			req := newJSONRequest("POST", "/journal-entries", body)
+			rr := httptest.NewRecorder()
+
+			NewRouter(mockStore).ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.status, rr.Code, tt.code)
+			assert.Equal(t, tt.code, readProblem(t, rr).Code)
+		}
+	})
+}
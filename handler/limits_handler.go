@@ -0,0 +1,113 @@
+package handler
+
+import (
+	"log"
+	"net/http"
+
+	"go-api-example/model"
+	"go-api-example/storage"
+
+	"github.com/gorilla/mux"
+)
+
+// LimitsHandler holds dependencies for the transfer limit admin handlers.
+// The gateway in front of the API must restrict /admin/ to operators.
+type LimitsHandler struct {
+	store storage.Store
+}
+
+// NewLimitsHandler creates a new LimitsHandler.
+func NewLimitsHandler(store storage.Store) *LimitsHandler {
+	return &LimitsHandler{store: store}
+}
+
+// ListTierLimitsHandler returns the limits of every tier that has any.
+//
+// Method: GET
+// Path: /admin/limits/tiers
+// Success: 200 OK
+// Error: 500 Internal Server Error (for database errors)
+func (h *LimitsHandler) ListTierLimitsHandler(w http.ResponseWriter, r *http.Request) {
+	tiers, err := h.store.ListTierLimits(r.Context())
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string][]model.TierLimits{"tiers": tiers})
+}
+
+// SetTierLimitsHandler replaces the limits of a tier. Limits omitted from the
+// body are removed. The change applies to the next transfer of every account in
+// the tier that does not override the limit.
+//
+// Method: PUT
+// Path: /admin/limits/tiers/{tier}
+// Success: 200 OK (with the tier's limits)
+// Error: 400 Bad Request (for an invalid tier name, invalid JSON or validation failure)
+// Error: 500 Internal Server Error (for database errors)
+func (h *LimitsHandler) SetTierLimitsHandler(w http.ResponseWriter, r *http.Request) {
+	tier := mux.Vars(r)["tier"]
+	if !model.IsTierName(tier) {
+		writeProblem(w, newProblem(r, http.StatusBadRequest, CodeInvalidRequest, "Invalid tier",
+			"Tier names are 1 to 32 lower-case letters, digits, '-' or '_'"))
+		return
+	}
+	t := model.TierLimits{Tier: tier}
+	if !decodeAndValidate(w, r, &t.Limits) {
+		return
+	}
+	if err := h.store.SetTierLimits(r.Context(), t); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	log.Printf("Transfer limits of tier %s set", tier)
+	writeJSON(w, http.StatusOK, t)
+}
+
+// GetAccountLimitsHandler returns an account's tier, overrides and effective limits.
+//
+// Method: GET
+// Path: /admin/limits/accounts/{account_id}
+// Success: 200 OK
+// Error: 400 Bad Request (for an invalid account ID)
+// Error: 404 Not Found (if the account does not exist)
+// Error: 500 Internal Server Error (for database errors)
+func (h *LimitsHandler) GetAccountLimitsHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := accountIDFromPath(w, r)
+	if !ok {
+		return
+	}
+	limits, err := h.store.GetAccountLimits(r.Context(), id)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, limits)
+}
+
+// SetAccountLimitsHandler assigns an account its tier and replaces its
+// overrides. Limits omitted from "overrides" fall back to the tier's.
+//
+// Method: PUT
+// Path: /admin/limits/accounts/{account_id}
+// Success: 200 OK (with the account's limits)
+// Error: 400 Bad Request (for an invalid account ID, invalid JSON or validation failure)
+// Error: 404 Not Found (if the account does not exist)
+// Error: 500 Internal Server Error (for database errors)
+func (h *LimitsHandler) SetAccountLimitsHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := accountIDFromPath(w, r)
+	if !ok {
+		return
+	}
+	var req model.AccountLimitsRequest
+	if !decodeAndValidate(w, r, &req) {
+		return
+	}
+	limits, err := h.store.SetAccountLimits(r.Context(), id, req)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	log.Printf("Transfer limits of account %d set (tier %s)", id, limits.Tier)
+	writeJSON(w, http.StatusOK, limits)
+}
@@ -0,0 +1,127 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go-api-example/model"
+	"go-api-example/storage"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func putJSON(store *MockStore, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("PUT", path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	NewRouter(store).ServeHTTP(rr, req)
+	return rr
+}
+
+func TestTransferLimitExceeded(t *testing.T) {
+	store := &MockStore{
+		ExecuteTransferFunc: func(ctx context.Context, req model.TransactionRequest) (*model.Transfer, error) {
+			return nil, &storage.AccountError{AccountID: 1, Err: &storage.LimitError{Limit: model.LimitDailyAmount, Remaining: decimal.RequireFromString("40.5")}}
+		},
+	}
+	rr := httptest.NewRecorder()
+	NewRouter(store).ServeHTTP(rr, newJSONRequest("POST", "/transactions", `{"source_account_id": 1, "destination_account_id": 2, "amount": "100"}`))
+
+	assert.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+	p := readProblem(t, rr)
+	assert.Equal(t, CodeLimitExceeded, p.Code)
+	assert.Equal(t, model.LimitDailyAmount, p.Limit)
+	require.NotNil(t, p.Remaining)
+	assert.Equal(t, "40.5", p.Remaining.String())
+	require.NotNil(t, p.AccountID)
+	assert.Equal(t, int64(1), *p.AccountID)
+}
+
+func TestSetTierLimitsHandler(t *testing.T) {
+	var stored model.TierLimits
+	store := &MockStore{
+		SetTierLimitsFunc: func(ctx context.Context, tier model.TierLimits) error {
+			stored = tier
+			return nil
+		},
+	}
+
+	t.Run("success", func(t *testing.T) {
+		rr := putJSON(store, "/admin/limits/tiers/gold", `{"daily_amount": "5000", "hourly_count": 10}`)
+
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+		assert.Equal(t, "gold", stored.Tier)
+		require.NotNil(t, stored.Limits.DailyAmount)
+		assert.Equal(t, "5000", stored.Limits.DailyAmount.String())
+		require.NotNil(t, stored.Limits.HourlyCount)
+		assert.Equal(t, int64(10), *stored.Limits.HourlyCount)
+		assert.Nil(t, stored.Limits.MaxAmount)
+	})
+
+	t.Run("invalid tier name", func(t *testing.T) {
+		rr := putJSON(store, "/admin/limits/tiers/Gold", `{}`)
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+
+	t.Run("negative limit", func(t *testing.T) {
+		rr := putJSON(store, "/admin/limits/tiers/gold", `{"max_amount": "-1"}`)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+		assert.Equal(t, "max_amount: cannot be negative", readProblem(t, rr).Detail)
+	})
+}
+
+func TestAccountLimitsHandlers(t *testing.T) {
+	limit := decimal.NewFromInt(100)
+	store := &MockStore{
+		GetAccountLimitsFunc: func(ctx context.Context, id int64) (*model.AccountLimits, error) {
+			if id != 1 {
+				return nil, &storage.AccountError{AccountID: id, Err: storage.ErrNotFound}
+			}
+			return &model.AccountLimits{AccountID: id, Tier: model.DefaultTier, Effective: model.TransferLimits{MaxAmount: &limit}}, nil
+		},
+		SetAccountLimitsFunc: func(ctx context.Context, id int64, req model.AccountLimitsRequest) (*model.AccountLimits, error) {
+			return &model.AccountLimits{AccountID: id, Tier: req.Tier, Overrides: req.Overrides, Effective: req.Overrides}, nil
+		},
+	}
+
+	t.Run("get", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		NewRouter(store).ServeHTTP(rr, httptest.NewRequest("GET", "/admin/limits/accounts/1", nil))
+
+		require.Equal(t, http.StatusOK, rr.Code)
+		var limits model.AccountLimits
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &limits))
+		assert.Equal(t, model.DefaultTier, limits.Tier)
+		require.NotNil(t, limits.Effective.MaxAmount)
+		assert.True(t, limit.Equal(*limits.Effective.MaxAmount))
+	})
+
+	t.Run("get unknown account", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		NewRouter(store).ServeHTTP(rr, httptest.NewRequest("GET", "/admin/limits/accounts/9", nil))
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+	})
+
+	t.Run("set", func(t *testing.T) {
+		rr := putJSON(store, "/admin/limits/accounts/1", `{"tier": "gold", "overrides": {"monthly_amount": "20000"}}`)
+
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+		var limits model.AccountLimits
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &limits))
+		assert.Equal(t, "gold", limits.Tier)
+		require.NotNil(t, limits.Overrides.MonthlyAmount)
+		assert.Equal(t, "20000", limits.Overrides.MonthlyAmount.String())
+	})
+
+	t.Run("unknown limit", func(t *testing.T) {
+		rr := putJSON(store, "/admin/limits/accounts/1", `{"overrides": {"weekly_amount": "1"}}`)
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+}
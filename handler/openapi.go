@@ -0,0 +1,401 @@
+package handler
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"go-api-example/model"
+
+	"github.com/gorilla/mux"
+)
+
+// openAPIDocument is the embedded OpenAPI 3.1 description of this API.
+// It is the single source of truth for the HTTP contract: it is served at
+// /openapi.json and used by ValidationMiddleware to check incoming requests.
+//
+//go:embed openapi.json
+var openAPIDocument []byte
+
+// apiSpec is the parsed form of openAPIDocument.
+var apiSpec = mustLoadSpec(openAPIDocument)
+
+// openAPISpec is the subset of an OpenAPI document that the validator understands.
+type openAPISpec struct {
+	Paths      map[string]map[string]*operation `json:"paths"`
+	Components struct {
+		Schemas map[string]*schema `json:"schemas"`
+	} `json:"components"`
+}
+
+type operation struct {
+	OperationID string       `json:"operationId"`
+	Parameters  []parameter  `json:"parameters"`
+	RequestBody *requestBody `json:"requestBody"`
+}
+
+type parameter struct {
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required"`
+	Schema   *schema `json:"schema"`
+}
+
+type requestBody struct {
+	Required bool `json:"required"`
+	Content  map[string]struct {
+		Schema *schema `json:"schema"`
+	} `json:"content"`
+	MaxBytes int64 `json:"x-max-body-bytes"` // overrides maxBodyBytes for this operation
+}
+
+// bodyLimit returns the maximum accepted body size for the request body.
+func (rb *requestBody) bodyLimit() int64 {
+	if rb.MaxBytes > 0 {
+		return rb.MaxBytes
+	}
+	return maxBodyBytes
+}
+
+// checkContentType returns a 415 problem unless the request declares one of
+// the media types documented for the body.
+func (rb *requestBody) checkContentType(r *http.Request) *Problem {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if _, ok := rb.Content[mediaType]; err == nil && ok {
+		return nil
+	}
+	types := make([]string, 0, len(rb.Content))
+	for t := range rb.Content {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	return newProblem(r, http.StatusUnsupportedMediaType, CodeUnsupportedMediaType,
+		"Unsupported media type", "Content-Type must be "+strings.Join(types, " or "))
+}
+
+// schema is the subset of JSON Schema used by openapi.json.
+type schema struct {
+	Ref                  string             `json:"$ref"`
+	Type                 string             `json:"type"`
+	Format               string             `json:"format"`
+	Properties           map[string]*schema `json:"properties"`
+	Required             []string           `json:"required"`
+	AdditionalProperties *bool              `json:"additionalProperties"`
+	Items                *schema            `json:"items"`
+	Enum                 []any              `json:"enum"`
+	Pattern              string             `json:"pattern"`
+	Minimum              *float64           `json:"minimum"`
+	Maximum              *float64           `json:"maximum"`
+	MinItems             *int               `json:"minItems"`
+
+	pattern *regexp.Regexp
+}
+
+func mustLoadSpec(doc []byte) *openAPISpec {
+	var spec openAPISpec
+	if err := json.Unmarshal(doc, &spec); err != nil {
+		panic(fmt.Sprintf("invalid embedded OpenAPI document: %v", err))
+	}
+	for _, s := range spec.Components.Schemas {
+		compilePatterns(s)
+	}
+	for _, item := range spec.Paths {
+		for _, op := range item {
+			for _, p := range op.Parameters {
+				compilePatterns(p.Schema)
+			}
+			if op.RequestBody != nil {
+				for _, c := range op.RequestBody.Content {
+					compilePatterns(c.Schema)
+				}
+			}
+		}
+	}
+	return &spec
+}
+
+func compilePatterns(s *schema) {
+	if s == nil {
+		return
+	}
+	if s.Pattern != "" {
+		s.pattern = regexp.MustCompile(s.Pattern)
+	}
+	for _, p := range s.Properties {
+		compilePatterns(p)
+	}
+	compilePatterns(s.Items)
+}
+
+// operation returns the operation documented for a path template and HTTP method.
+func (spec *openAPISpec) operation(pathTemplate, method string) (*operation, bool) {
+	item, ok := spec.Paths[pathTemplate]
+	if !ok {
+		return nil, false
+	}
+	op, ok := item[strings.ToLower(method)]
+	return op, ok
+}
+
+// resolve follows a local "#/components/schemas/..." reference.
+func (spec *openAPISpec) resolve(s *schema) *schema {
+	for s != nil && s.Ref != "" {
+		s = spec.Components.Schemas[strings.TrimPrefix(s.Ref, "#/components/schemas/")]
+	}
+	return s
+}
+
+// validateValue checks a decoded JSON value against a schema and appends
+// one message per violation to errs.
+func (spec *openAPISpec) validateValue(field string, v any, s *schema, errs model.ValidationErrors) model.ValidationErrors {
+	s = spec.resolve(s)
+	if s == nil {
+		return errs
+	}
+
+	if len(s.Enum) > 0 {
+		found := false
+		for _, e := range s.Enum {
+			if fmt.Sprint(e) == fmt.Sprint(v) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return append(errs, fieldError(field, "enum", fmt.Sprintf("must be one of %v", s.Enum)))
+		}
+	}
+
+	switch s.Type {
+	case "object":
+		obj, ok := v.(map[string]any)
+		if !ok {
+			return append(errs, fieldError(field, "type", "must be an object"))
+		}
+		for _, name := range s.Required {
+			if _, ok := obj[name]; !ok {
+				errs = append(errs, fieldError(joinField(field, name), "required", "is required"))
+			}
+		}
+		names := make([]string, 0, len(obj))
+		for name := range obj {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			prop, ok := s.Properties[name]
+			if !ok {
+				if s.AdditionalProperties != nil && !*s.AdditionalProperties {
+					errs = append(errs, fieldError(joinField(field, name), "additionalProperties", "unknown field"))
+				}
+				continue
+			}
+			errs = spec.validateValue(joinField(field, name), obj[name], prop, errs)
+		}
+	case "array":
+		arr, ok := v.([]any)
+		if !ok {
+			return append(errs, fieldError(field, "type", "must be an array"))
+		}
+		if s.MinItems != nil && len(arr) < *s.MinItems {
+			errs = append(errs, fieldError(field, "minItems", fmt.Sprintf("must contain at least %d items", *s.MinItems)))
+		}
+		for i, item := range arr {
+			errs = spec.validateValue(fmt.Sprintf("%s[%d]", field, i), item, s.Items, errs)
+		}
+	case "string":
+		str, ok := v.(string)
+		if !ok {
+			return append(errs, fieldError(field, "type", "must be a string"))
+		}
+		if s.pattern != nil && !s.pattern.MatchString(str) {
+			errs = append(errs, fieldError(field, "pattern", fmt.Sprintf("does not match pattern %s", s.Pattern)))
+		}
+	case "integer", "number":
+		num, ok := v.(json.Number)
+		if !ok {
+			return append(errs, fieldError(field, "type", fmt.Sprintf("must be %s %s", article(s.Type), s.Type)))
+		}
+		if s.Type == "integer" {
+			if _, err := strconv.ParseInt(num.String(), 10, 64); err != nil {
+				return append(errs, fieldError(field, "type", "must be a 64-bit integer"))
+			}
+		}
+		f, err := num.Float64()
+		if err != nil {
+			return append(errs, fieldError(field, "type", "must be a number"))
+		}
+		if s.Minimum != nil && f < *s.Minimum {
+			errs = append(errs, fieldError(field, "minimum", fmt.Sprintf("must be >= %v", *s.Minimum)))
+		}
+		if s.Maximum != nil && f > *s.Maximum {
+			errs = append(errs, fieldError(field, "maximum", fmt.Sprintf("must be <= %v", *s.Maximum)))
+		}
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			errs = append(errs, fieldError(field, "type", "must be a boolean"))
+		}
+	}
+	return errs
+}
+
+// validateParam checks a raw path or query parameter against its schema.
+func (spec *openAPISpec) validateParam(field, raw string, s *schema, errs model.ValidationErrors) model.ValidationErrors {
+	var v any = raw
+	if resolved := spec.resolve(s); resolved != nil && (resolved.Type == "integer" || resolved.Type == "number") {
+		v = json.Number(raw)
+		if _, err := json.Number(raw).Float64(); err != nil {
+			return append(errs, fieldError(field, "type", fmt.Sprintf("must be %s %s", article(resolved.Type), resolved.Type)))
+		}
+	}
+	return spec.validateValue(field, v, s, errs)
+}
+
+// validateRequest checks the parameters and body of r against op. On success
+// the body is left readable for the next handler. A non-nil error means the
+// body itself could not be read (for example it exceeded the body limit).
+func (spec *openAPISpec) validateRequest(r *http.Request, op *operation) (model.ValidationErrors, error) {
+	var errs model.ValidationErrors
+
+	vars := mux.Vars(r)
+	query := r.URL.Query()
+	for _, p := range op.Parameters {
+		var raw string
+		var present bool
+		switch p.In {
+		case "path":
+			raw, present = vars[p.Name]
+		case "query":
+			present = query.Has(p.Name)
+			raw = query.Get(p.Name)
+		default:
+			continue
+		}
+		field := p.In + "." + p.Name
+		if !present {
+			if p.Required {
+				errs = append(errs, fieldError(field, "required", "is required"))
+			}
+			continue
+		}
+		errs = spec.validateParam(field, raw, p.Schema, errs)
+	}
+
+	if op.RequestBody == nil {
+		return errs, nil
+	}
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	media, ok := op.RequestBody.Content[mediaType]
+	if !ok || mediaType != "application/json" {
+		return errs, nil
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return errs, err
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	if len(bytes.TrimSpace(body)) == 0 {
+		if op.RequestBody.Required {
+			errs = append(errs, fieldError("body", "required", "is required"))
+		}
+		return errs, nil
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return append(errs, fieldError("body", "type", "is not valid JSON")), nil
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return append(errs, fieldError("body", "type", "must contain a single JSON value")), nil
+	}
+	return spec.validateValue("body", v, media.Schema, errs), nil
+}
+
+// ValidationMiddleware rejects requests whose parameters or body do not match
+// the OpenAPI document before they reach AccountHandler or TransactionHandler.
+// Only application/json bodies are checked against a schema; other documented
+// media types, such as NDJSON, are left to the handler.
+// It must be installed with (*mux.Router).Use so that the matched route is known.
+func ValidationMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := mux.CurrentRoute(r)
+		if route == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		tmpl, err := route.GetPathTemplate()
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		op, ok := apiSpec.operation(tmpl, r.Method)
+		if !ok {
+			writeProblem(w, newProblem(r, http.StatusInternalServerError, CodeInternal,
+				"Internal server error", "Route is not described by the API specification"))
+			return
+		}
+		if op.RequestBody != nil {
+			if p := op.RequestBody.checkContentType(r); p != nil {
+				writeProblem(w, p)
+				return
+			}
+			r.Body = http.MaxBytesReader(w, r.Body, op.RequestBody.bodyLimit())
+		}
+		errs, err := apiSpec.validateRequest(r, op)
+		if err != nil {
+			writeProblem(w, decodeProblem(r, err))
+			return
+		}
+		if len(errs) > 0 {
+			p := newProblem(r, http.StatusBadRequest, CodeInvalidRequest,
+				"Invalid request", errs.Error())
+			p.Errors = errs
+			writeProblem(w, p)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// OpenAPIHandler serves the embedded OpenAPI document.
+//
+// Method: GET
+// Path: /openapi.json
+// Success: 200 OK
+func OpenAPIHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(openAPIDocument)
+}
+
+// fieldError reports a spec violation. Body fields are named relative to the body
+// ("amount", "postings[0].amount") to match the errors from decodeAndValidate.
+func fieldError(field, rule, message string) model.FieldError {
+	if rest, ok := strings.CutPrefix(field, "body."); ok {
+		field = rest
+	}
+	return model.FieldError{Field: field, Rule: rule, Message: message}
+}
+
+func joinField(parent, name string) string {
+	return parent + "." + name
+}
+
+func article(word string) string {
+	if strings.ContainsRune("aeiou", rune(word[0])) {
+		return "an"
+	}
+	return "a"
+}
@@ -0,0 +1,121 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go-api-example/model"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestRoutesAreDocumented fails when a route registered on the router is missing from openapi.json.
+func TestRoutesAreDocumented(t *testing.T) {
+	router := NewRouter(&MockStore{})
+
+	err := router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		tmpl, err := route.GetPathTemplate()
+		require.NoError(t, err)
+		methods, err := route.GetMethods()
+		require.NoError(t, err, "route %s must declare its methods", tmpl)
+
+		for _, method := range methods {
+			_, ok := apiSpec.operation(tmpl, method)
+			assert.True(t, ok, "route %s %s is not described in openapi.json", method, tmpl)
+		}
+		return nil
+	})
+	require.NoError(t, err)
+}
+
+func TestOpenAPIHandler(t *testing.T) {
+	router := NewRouter(&MockStore{})
+	req := httptest.NewRequest("GET", "/openapi.json", nil)
+	rr := httptest.NewRecorder()
+
+	router.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "application/json", rr.Header().Get("Content-Type"))
+	var doc map[string]any
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &doc))
+	assert.Equal(t, "3.1.0", doc["openapi"])
+}
+
+func TestValidationMiddleware(t *testing.T) {
+	t.Run("valid transaction reaches the handler", func(t *testing.T) {
+		called := false
+		mockStore := &MockStore{
+			ExecuteTransferFunc: func(ctx context.Context, req model.TransactionRequest) (*model.Transfer, error) {
+				called = true
+				assert.Equal(t, "250.25", req.Amount.String())
+				return &model.Transfer{TransactionRequest: req, Total: req.Amount}, nil
+			},
+		}
+		router := NewRouter(mockStore)
+		body := `{"source_account_id": 1, "destination_account_id": 2, "amount": "250.25"}`
+		req := newJSONRequest("POST", "/transactions", body)
+		rr := httptest.NewRecorder()
+
+		router.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.True(t, called)
+	})
+
+	t.Run("amount must be a decimal string", func(t *testing.T) {
+		// The store has no funcs set, so reaching it would panic.
+		router := NewRouter(&MockStore{})
+		body := `{"source_account_id": 1, "destination_account_id": 2, "amount": 250.25}`
+		req := newJSONRequest("POST", "/transactions", body)
+		rr := httptest.NewRecorder()
+
+		router.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+		assert.Contains(t, rr.Body.String(), "amount: must be a string")
+	})
+
+	t.Run("missing and unknown fields are reported together", func(t *testing.T) {
+		router := NewRouter(&MockStore{})
+		body := `{"account_id": 1, "ammount": "10"}`
+		req := newJSONRequest("POST", "/accounts", body)
+		rr := httptest.NewRecorder()
+
+		router.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+		assert.Contains(t, rr.Body.String(), "initial_balance: is required")
+		assert.Contains(t, rr.Body.String(), "ammount: unknown field")
+	})
+
+	t.Run("path parameter must be an integer", func(t *testing.T) {
+		router := NewRouter(&MockStore{})
+		req := httptest.NewRequest("GET", "/accounts/abc", nil)
+		rr := httptest.NewRecorder()
+
+		router.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+		assert.Contains(t, rr.Body.String(), "path.account_id: must be an integer")
+	})
+
+	t.Run("wrong content type", func(t *testing.T) {
+		router := NewRouter(&MockStore{})
+		body := `{"account_id": 1, "initial_balance": "10"}`
+		req := httptest.NewRequest("POST", "/accounts", strings.NewReader(body))
+		req.Header.Set("Content-Type", "text/plain")
+		rr := httptest.NewRecorder()
+
+		router.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusUnsupportedMediaType, rr.Code)
+		assert.Contains(t, rr.Body.String(), CodeUnsupportedMediaType)
+	})
+}
@@ -0,0 +1,164 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"net/netip"
+	"slices"
+	"strconv"
+	"time"
+
+	"go-api-example/metrics"
+	"go-api-example/ratelimit"
+
+	"github.com/gorilla/mux"
+)
+
+// What requests are counted against: the principal in X-Principal, the remote
+// IP address, or the account a request is about. Requests without a principal
+// or an account are counted against their IP address, and so are those whose
+// X-Principal was not set by one of the policy's gateways.
+const (
+	RateLimitByClient  = "client"
+	RateLimitByIP      = "ip"
+	RateLimitByAccount = "account"
+)
+
+var (
+	rateLimitedReads     = metrics.NewCounter("rate_limited_reads_total", "Read requests rejected by rate limiting.")
+	rateLimitedTransfers = metrics.NewCounter("rate_limited_transfers_total", "Transfer and other write requests rejected by rate limiting.")
+	rateLimitErrors      = metrics.NewCounter("rate_limit_errors_total", "Requests let through because the rate limit backend failed.")
+)
+
+// RateLimitPolicy limits how fast each key can make requests, with a bucket
+// for reads (GET) and one for transfers and every other write, which each take
+// a database transaction. A nil Backend disables rate limiting. Gateways are
+// the addresses X-Principal is trusted from: a client that could set it itself
+// would get a fresh bucket with every principal it made up.
+type RateLimitPolicy struct {
+	Backend  ratelimit.Backend
+	Key      string
+	Read     ratelimit.Limit
+	Transfer ratelimit.Limit
+	Gateways []netip.Prefix
+}
+
+// rateLimitMiddleware takes a token from the request's bucket, and rejects the
+// request with 429 Too Many Requests and Retry-After when there is none. Every
+// limited response carries the RateLimit-Limit, RateLimit-Remaining and
+// RateLimit-Reset headers. /metrics is not limited, and a request is let
+// through when the backend fails, so that the limiter cannot take the API down.
+// It runs after ValidationMiddleware, which leaves the body readable again.
+func rateLimitMiddleware(policy RateLimitPolicy) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		if policy.Backend == nil {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/metrics" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			class, limit, throttled := "transfer", policy.Transfer, rateLimitedTransfers
+			if r.Method == http.MethodGet || r.Method == http.MethodHead {
+				class, limit, throttled = "read", policy.Read, rateLimitedReads
+			}
+			ok, tokens, err := policy.Backend.Take(r.Context(), class+":"+policy.key(r), limit)
+			if err != nil {
+				rateLimitErrors.Inc()
+				log.Printf("Rate limit check failed, letting the request through: %v", err)
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			d := ratelimit.Decide(limit, ok, tokens)
+			w.Header().Set("RateLimit-Limit", strconv.Itoa(d.Limit))
+			w.Header().Set("RateLimit-Remaining", strconv.Itoa(d.Remaining))
+			w.Header().Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(d.Reset)))
+			if !d.Allowed {
+				throttled.Inc()
+				retry := max(ceilSeconds(d.RetryAfter), 1)
+				w.Header().Set("Retry-After", strconv.Itoa(retry))
+				writeProblem(w, newProblem(r, http.StatusTooManyRequests, CodeRateLimited, "Too many requests",
+					fmt.Sprintf("Rate limit of %d %s requests exceeded; retry in %d seconds", d.Limit, class, retry)))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// key returns what the request is counted against.
+func (p RateLimitPolicy) key(r *http.Request) string {
+	switch p.Key {
+	case RateLimitByAccount:
+		if id, ok := rateLimitAccount(r); ok {
+			return "account:" + id
+		}
+	case RateLimitByIP:
+		return "ip:" + remoteIP(r)
+	}
+	if principal, ok := principalFrom(r.Context()); ok && p.fromGateway(r) {
+		return "client:" + principal
+	}
+	return "ip:" + remoteIP(r)
+}
+
+// fromGateway reports whether r was sent by one of the policy's gateways.
+func (p RateLimitPolicy) fromGateway(r *http.Request) bool {
+	addr, err := netip.ParseAddr(remoteIP(r))
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	return slices.ContainsFunc(p.Gateways, func(g netip.Prefix) bool { return g.Contains(addr) })
+}
+
+// rateLimitAccount returns the account in the path, or the source account of
+// a transfer.
+func rateLimitAccount(r *http.Request) (string, bool) {
+	if id, ok := mux.Vars(r)["account_id"]; ok {
+		return id, true
+	}
+	return transferSource(r)
+}
+
+// transferSource returns the source account of a POST /transactions request,
+// leaving the body readable again.
+func transferSource(r *http.Request) (string, bool) {
+	if r.Method != http.MethodPost || r.URL.Path != "/transactions" {
+		return "", false
+	}
+	body, err := io.ReadAll(r.Body)
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return "", false
+	}
+	var req struct {
+		SourceAccountID json.Number `json:"source_account_id"`
+	}
+	if json.Unmarshal(body, &req) != nil || req.SourceAccountID == "" {
+		return "", false
+	}
+	return req.SourceAccountID.String(), true
+}
+
+// remoteIP returns the IP address of the client, or of the gateway in front of
+// the API.
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,122 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+
+	"go-api-example/model"
+	"go-api-example/ratelimit"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimitMiddleware(t *testing.T) {
+	store := &MockStore{
+		GetAccountFunc: func(ctx context.Context, id int64) (*model.Account, error) {
+			return &model.Account{AccountID: id, Balance: decimal.NewFromInt(100)}, nil
+		},
+		ExecuteTransferFunc: func(ctx context.Context, req model.TransactionRequest) (*model.Transfer, error) {
+			return &model.Transfer{TransactionRequest: req, Total: req.Amount}, nil
+		},
+	}
+	at := time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC)
+	// httptest requests come from 192.0.2.1.
+	gateway := netip.MustParsePrefix("192.0.2.0/24")
+	newRouter := func(key string, gateways ...netip.Prefix) http.Handler {
+		return NewRouter(store, WithRateLimit(RateLimitPolicy{
+			Backend:  ratelimit.NewMemory(func() time.Time { return at }),
+			Key:      key,
+			Read:     ratelimit.Limit{Rate: 0.5, Burst: 2},
+			Transfer: ratelimit.Limit{Rate: 0.1, Burst: 1},
+			Gateways: gateways,
+		}))
+	}
+	serve := func(router http.Handler, req *http.Request) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+	get := func(principal string) *http.Request {
+		req := httptest.NewRequest("GET", "/accounts/1", nil)
+		if principal != "" {
+			req.Header.Set(PrincipalHeader, principal)
+		}
+		return req
+	}
+	transfer := func(from int) *http.Request {
+		return newJSONRequest("POST", "/transactions", fmt.Sprintf(`{"source_account_id": %d, "destination_account_id": 9, "amount": "1"}`, from))
+	}
+
+	t.Run("reads by client", func(t *testing.T) {
+		router := newRouter(RateLimitByClient, gateway)
+		throttled := rateLimitedReads.Value()
+
+		rr := serve(router, get("alice"))
+		require.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, "2", rr.Header().Get("RateLimit-Limit"))
+		assert.Equal(t, "1", rr.Header().Get("RateLimit-Remaining"))
+		assert.Equal(t, "2", rr.Header().Get("RateLimit-Reset"))
+		require.Equal(t, http.StatusOK, serve(router, get("alice")).Code)
+
+		rr = serve(router, get("alice"))
+		require.Equal(t, http.StatusTooManyRequests, rr.Code)
+		assert.Equal(t, CodeRateLimited, readProblem(t, rr).Code)
+		assert.Equal(t, "2", rr.Header().Get("Retry-After"))
+		assert.Equal(t, "0", rr.Header().Get("RateLimit-Remaining"))
+		assert.Equal(t, "4", rr.Header().Get("RateLimit-Reset"))
+		assert.Equal(t, throttled+1, rateLimitedReads.Value())
+
+		assert.Equal(t, http.StatusOK, serve(router, get("bob")).Code, "clients have buckets of their own")
+		assert.Equal(t, http.StatusOK, serve(router, get("")).Code, "requests without a principal are counted by IP")
+		assert.Equal(t, http.StatusOK, serve(router, transfer(1)).Code, "transfers have a bucket of their own")
+	})
+
+	t.Run("principals not set by a gateway are counted by IP", func(t *testing.T) {
+		router := newRouter(RateLimitByClient, netip.MustParsePrefix("10.0.0.0/8"))
+
+		require.Equal(t, http.StatusOK, serve(router, get("alice")).Code)
+		require.Equal(t, http.StatusOK, serve(router, get("bob")).Code)
+		assert.Equal(t, http.StatusTooManyRequests, serve(router, get("carol")).Code, "a made-up principal gets no fresh bucket")
+	})
+
+	t.Run("transfers by source account", func(t *testing.T) {
+		router := newRouter(RateLimitByAccount)
+		throttled := rateLimitedTransfers.Value()
+
+		require.Equal(t, http.StatusOK, serve(router, transfer(1)).Code)
+		rr := serve(router, transfer(1))
+		require.Equal(t, http.StatusTooManyRequests, rr.Code)
+		assert.Equal(t, "10", rr.Header().Get("Retry-After"))
+		assert.Equal(t, throttled+1, rateLimitedTransfers.Value())
+
+		assert.Equal(t, http.StatusOK, serve(router, transfer(2)).Code)
+	})
+
+	t.Run("metrics are not limited", func(t *testing.T) {
+		router := newRouter(RateLimitByIP)
+		for i := 0; i < 3; i++ {
+			rr := serve(router, httptest.NewRequest("GET", "/metrics", nil))
+			require.Equal(t, http.StatusOK, rr.Code)
+			assert.Empty(t, rr.Header().Get("RateLimit-Limit"))
+		}
+	})
+
+	t.Run("a failing backend lets requests through", func(t *testing.T) {
+		failing := ratelimit.BackendFunc(func(ctx context.Context, key string, limit ratelimit.Limit) (bool, float64, error) {
+			return false, 0, errors.New("connection refused")
+		})
+		router := NewRouter(store, WithRateLimit(RateLimitPolicy{Backend: failing, Key: RateLimitByIP}))
+		errs := rateLimitErrors.Value()
+
+		assert.Equal(t, http.StatusOK, serve(router, get("")).Code)
+		assert.Equal(t, errs+1, rateLimitErrors.Value())
+	})
+}
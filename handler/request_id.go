@@ -0,0 +1,85 @@
+package handler
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strings"
+
+	"go-api-example/audit"
+)
+
+// RequestIDHeader carries the request ID in both directions.
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength bounds client-supplied IDs so they are safe to log.
+const maxRequestIDLength = 128
+
+// PrincipalHeader names the authenticated principal making the request. The API
+// does no authentication itself: the header is expected to be set by the gateway
+// in front of it, which must strip any value sent by the client.
+const PrincipalHeader = "X-Principal"
+
+// APIActor is the actor recorded in the audit log for changes made through the HTTP API.
+// A request with a principal is recorded as APIActor + ":" + principal.
+const APIActor = "api"
+
+// RequestIDMiddleware assigns every request an ID, reusing a well-formed
+// X-Request-ID from the client when present. The ID is echoed in the response
+// header, included in every problem+json body and recorded in the audit log
+// together with the actor: APIActor, qualified by a well-formed X-Principal.
+func RequestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		w.Header().Set(RequestIDHeader, id)
+		actor := APIActor
+		if p := r.Header.Get(PrincipalHeader); validPrincipal(p) {
+			actor += ":" + p
+		}
+		ctx := audit.WithActor(audit.WithRequestID(r.Context(), id), actor)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// principalFrom returns the principal named in the request, if any.
+func principalFrom(ctx context.Context) (string, bool) {
+	return strings.CutPrefix(audit.ActorFrom(ctx), APIActor+":")
+}
+
+// RequestIDFromContext returns the request ID set by RequestIDMiddleware, or "".
+func RequestIDFromContext(ctx context.Context) string {
+	return audit.RequestIDFrom(ctx)
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func validRequestID(id string) bool {
+	return validToken(id, "-_.")
+}
+
+// validPrincipal also accepts '@', so that an email address can name a principal.
+func validPrincipal(p string) bool {
+	return validToken(p, "-_.@")
+}
+
+// validToken reports whether s is short and made only of ASCII letters, digits
+// and the characters in extra, so it is safe to log.
+func validToken(s, extra string) bool {
+	if s == "" || len(s) > maxRequestIDLength {
+		return false
+	}
+	for _, c := range s {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || strings.ContainsRune(extra, c)) {
+			return false
+		}
+	}
+	return true
+}
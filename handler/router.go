@@ -0,0 +1,122 @@
+package handler
+
+import (
+	"go-api-example/metrics"
+	"go-api-example/rules"
+	"go-api-example/storage"
+
+	"github.com/gorilla/mux"
+)
+
+// Option configures the router built by NewRouter.
+type Option func(*options)
+
+type options struct {
+	approvals ApprovalPolicy
+	rules     *rules.Engine
+	screening ScreeningPolicy
+	rateLimit RateLimitPolicy
+	routing   TransferRouting
+	replica   bool
+}
+
+// WithApprovalPolicy holds transfers and journal entries that policy requires
+// Without it nothing needs approval.
+// Without it no transfer needs approval.
+func WithApprovalPolicy(policy ApprovalPolicy) Option {
+	return func(o *options) { o.approvals = policy }
+}
+
+// WithRules evaluates engine's transfer rules on every transfer, and every leg
+// of a journal entry, submitted through the API. Without it every transfer is
+// allowed.
+func WithRules(engine *rules.Engine) Option {
+	return func(o *options) { o.rules = engine }
+}
+
+// WithScreening screens every transfer and journal entry submitted through the
+// API against policy's blocklist. Without it nothing is screened.
+func WithScreening(policy ScreeningPolicy) Option {
+	return func(o *options) { o.screening = policy }
+}
+
+// WithRateLimit limits how fast clients can make requests according to
+// policy. Without it requests are not limited.
+func WithRateLimit(policy RateLimitPolicy) Option {
+	return func(o *options) { o.rateLimit = policy }
+}
+
+// WithTransferRouting forwards every transfer to the replica that owns its
+// source account according to routing. Without it transfers are executed by
+// the replica that receives them.
+func WithTransferRouting(routing TransferRouting) Option {
+	return func(o *options) { o.routing = routing }
+}
+
+// WithReadReplica lets reads choose their consistency, for a store with a read
+// replica, and returns a consistency token with every write. Without it every
+// read goes to the primary.
+func WithReadReplica() Option {
+	return func(o *options) { o.replica = true }
+}
+
+// NewRouter wires every HTTP endpoint of the API onto a mux.Router.
+// Every route registered here must also be described in openapi.json;
+// requests are validated against that document before reaching a handler.
+func NewRouter(store storage.Store, opts ...Option) *mux.Router {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.rules == nil {
+		o.rules = new(rules.Engine)
+	}
+
+	accountHandler := NewAccountHandler(store)
+	transactionHandler := NewTransactionHandler(store, o.approvals, o.rules, o.screening)
+	journalHandler := NewJournalHandler(store, o.approvals, o.rules, o.screening)
+	transferJobHandler := NewTransferJobHandler(store, o.approvals, o.rules, o.screening)
+	approvalHandler := NewApprovalHandler(store)
+	limitsHandler := NewLimitsHandler(store)
+	feesHandler := NewFeesHandler(store)
+	interestHandler := NewInterestHandler(store)
+	shardsHandler := NewShardsHandler(store)
+	rulesHandler := NewRulesHandler(store, o.rules)
+
+	r := mux.NewRouter()
+	r.Use(RequestIDMiddleware, ValidationMiddleware, transferRoutingMiddleware(o.routing), rateLimitMiddleware(o.rateLimit), consistencyMiddleware(store, o.replica))
+
+	r.HandleFunc("/openapi.json", OpenAPIHandler).Methods("GET")
+	r.HandleFunc("/metrics", metrics.Handler).Methods("GET")
+	r.HandleFunc("/accounts", accountHandler.CreateAccountHandler).Methods("POST")
+	r.HandleFunc("/accounts", accountHandler.ListAccountsHandler).Methods("GET")
+	r.HandleFunc("/accounts/bulk", accountHandler.CreateAccountsBulkHandler).Methods("POST")
+	r.HandleFunc("/accounts/{account_id}", accountHandler.GetAccountHandler).Methods("GET")
+	r.HandleFunc("/accounts/{account_id}/postings", accountHandler.GetAccountPostingsHandler).Methods("GET")
+	r.HandleFunc("/accounts/{account_id}/statement", accountHandler.GetStatementHandler).Methods("GET")
+	r.HandleFunc("/transactions", transactionHandler.CreateTransactionHandler).Methods("POST")
+	r.HandleFunc("/journal-entries", journalHandler.CreateJournalEntryHandler).Methods("POST")
+	r.HandleFunc("/transfer-jobs", transferJobHandler.CreateTransferJobHandler).Methods("POST")
+	r.HandleFunc("/transfer-jobs/{job_id}", transferJobHandler.GetTransferJobHandler).Methods("GET")
+	r.HandleFunc("/transfer-jobs/{job_id}/results", transferJobHandler.GetTransferJobResultsHandler).Methods("GET")
+	r.HandleFunc("/approvals", approvalHandler.ListApprovalsHandler).Methods("GET")
+	r.HandleFunc("/approvals/{approval_id}", approvalHandler.GetApprovalHandler).Methods("GET")
+	r.HandleFunc("/approvals/{approval_id}/approve", approvalHandler.ApproveHandler).Methods("POST")
+	r.HandleFunc("/approvals/{approval_id}/reject", approvalHandler.RejectHandler).Methods("POST")
+	r.HandleFunc("/admin/limits/tiers", limitsHandler.ListTierLimitsHandler).Methods("GET")
+	r.HandleFunc("/admin/limits/tiers/{tier}", limitsHandler.SetTierLimitsHandler).Methods("PUT")
+	r.HandleFunc("/admin/limits/accounts/{account_id}", limitsHandler.GetAccountLimitsHandler).Methods("GET")
+	r.HandleFunc("/admin/limits/accounts/{account_id}", limitsHandler.SetAccountLimitsHandler).Methods("PUT")
+	r.HandleFunc("/admin/fees/tiers", feesHandler.ListTierFeesHandler).Methods("GET")
+	r.HandleFunc("/admin/fees/tiers/{tier}", feesHandler.SetTierFeesHandler).Methods("PUT")
+	r.HandleFunc("/admin/fees/accounts/{account_id}", feesHandler.GetAccountFeesHandler).Methods("GET")
+	r.HandleFunc("/admin/fees/accounts/{account_id}", feesHandler.SetAccountFeesHandler).Methods("PUT")
+	r.HandleFunc("/admin/interest/accounts/{account_id}", interestHandler.GetInterestRateHandler).Methods("GET")
+	r.HandleFunc("/admin/interest/accounts/{account_id}", interestHandler.SetInterestRateHandler).Methods("PUT")
+	r.HandleFunc("/admin/shards/accounts/{account_id}", shardsHandler.GetAccountShardsHandler).Methods("GET")
+	r.HandleFunc("/admin/shards/accounts/{account_id}", shardsHandler.SetAccountShardsHandler).Methods("PUT")
+	r.HandleFunc("/admin/rules", rulesHandler.ListRulesHandler).Methods("GET")
+	r.HandleFunc("/admin/rules/dry-run", rulesHandler.DryRunRulesHandler).Methods("POST")
+
+	return r
+}
@@ -0,0 +1,153 @@
+package handler
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+
+	"go-api-example/hashring"
+	"go-api-example/metrics"
+
+	"github.com/gorilla/mux"
+)
+
+// ForwardedTransferHeader marks a transfer forwarded by another replica, which
+// names itself in it. ForwardedClientHeader carries the address of the client
+// the transfer came from, and ForwardedSignatureHeader signs both, and the
+// request ID, with the secret the replicas share. A replica executes a transfer
+// with a valid signature rather than forward it again, as coming from that
+// client; it ignores the headers of any other request.
+const (
+	ForwardedTransferHeader  = "X-Forwarded-Transfer"
+	ForwardedClientHeader    = "X-Forwarded-Transfer-Client"
+	ForwardedSignatureHeader = "X-Forwarded-Transfer-Signature"
+)
+
+var (
+	transfersForwarded       = metrics.NewCounter("transfers_forwarded_total", "Transfers forwarded to the replica that owns their source account.")
+	transferForwardFallbacks = metrics.NewCounter("transfer_forward_fallbacks_total", "Transfers executed here because their owner could not be reached.")
+	transferForwardErrors    = metrics.NewCounter("transfer_forward_errors_total", "Transfers whose forwarding failed after the owner was reached.")
+)
+
+// TransferRouting sends each transfer to the replica that owns its source
+// account on Ring, so that the transfers of an account queue up in a single
+// process. Self is this replica as named on the ring, and Secret is shared by
+// every replica on it to sign the transfers they forward. A nil Ring disables
+// routing.
+type TransferRouting struct {
+	Ring      *hashring.Ring
+	Self      string
+	Secret    string
+	Transport http.RoundTripper // nil means http.DefaultTransport
+}
+
+// sign returns the signature of a transfer forwarded by from for client.
+func (t TransferRouting) sign(from, client, requestID string) string {
+	mac := hmac.New(sha256.New, []byte(t.Secret))
+	fmt.Fprintf(mac, "%s\n%s\n%s", from, client, requestID)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// forwardedClient returns the client r was forwarded for, if r is a transfer
+// forwarded by a replica that shares the secret.
+func (t TransferRouting) forwardedClient(r *http.Request) (string, bool) {
+	from, client := r.Header.Get(ForwardedTransferHeader), r.Header.Get(ForwardedClientHeader)
+	signature, err := hex.DecodeString(r.Header.Get(ForwardedSignatureHeader))
+	if t.Secret == "" || from == "" || client == "" || err != nil {
+		return "", false
+	}
+	want, _ := hex.DecodeString(t.sign(from, client, RequestIDFromContext(r.Context())))
+	return client, hmac.Equal(signature, want)
+}
+
+// transferRoutingMiddleware forwards POST /transactions to the owner of the
+// source account when that is another replica, and relays its response. A
+// transfer whose owner cannot be connected to is executed here instead: the
+// database locks keep it correct, only slower. Once the owner has been
+// reached, a failure is a 502 Bad Gateway, since the transfer may have been
+// executed. A body that cannot be read in full is rejected rather than
+// forwarded. It runs before rate limiting, so that the owner counts the request
+// against the client it was forwarded for.
+func transferRoutingMiddleware(routing TransferRouting) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		if routing.Ring == nil {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if client, ok := routing.forwardedClient(r); ok {
+				r = r.WithContext(r.Context())
+				r.RemoteAddr = client
+				next.ServeHTTP(w, r)
+				return
+			}
+			r.Header.Del(ForwardedTransferHeader)
+			r.Header.Del(ForwardedClientHeader)
+			r.Header.Del(ForwardedSignatureHeader)
+			if r.Method != http.MethodPost || r.URL.Path != "/transactions" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			body, err := io.ReadAll(r.Body)
+			if err != nil {
+				writeProblem(w, decodeProblem(r, err))
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+			source, ok := transferSource(r)
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+			owner := routing.Ring.Owner(source)
+			target, err := url.Parse(owner)
+			if owner == "" || owner == routing.Self || err != nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			proxy := &httputil.ReverseProxy{
+				Transport: routing.Transport,
+				Rewrite: func(pr *httputil.ProxyRequest) {
+					id := RequestIDFromContext(r.Context())
+					pr.SetURL(target)
+					pr.Out.Header.Set(ForwardedTransferHeader, routing.Self)
+					pr.Out.Header.Set(ForwardedClientHeader, r.RemoteAddr)
+					pr.Out.Header.Set(ForwardedSignatureHeader, routing.sign(routing.Self, r.RemoteAddr, id))
+					pr.Out.Header.Set(RequestIDHeader, id)
+				},
+				ModifyResponse: func(resp *http.Response) error {
+					transfersForwarded.Inc()
+					// The owner reused the request ID, which is already set.
+					resp.Header.Del(RequestIDHeader)
+					return nil
+				},
+				ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+					var opErr *net.OpError
+					if errors.As(err, &opErr) && opErr.Op == "dial" {
+						transferForwardFallbacks.Inc()
+						log.Printf("Could not reach %s, the owner of account %s, executing the transfer here: %v", owner, source, err)
+						r.Body = io.NopCloser(bytes.NewReader(body))
+						next.ServeHTTP(w, r)
+						return
+					}
+					transfersForwarded.Inc()
+					transferForwardErrors.Inc()
+					log.Printf("Forwarding a transfer from account %s to %s failed: %v", source, owner, err)
+					writeProblem(w, newProblem(r, http.StatusBadGateway, CodeReplicaUnavailable, "Replica unavailable",
+						fmt.Sprintf("The replica that executes transfers from account %s failed; check whether the transfer was executed before retrying", source)))
+				},
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+			proxy.ServeHTTP(w, r)
+		})
+	}
+}
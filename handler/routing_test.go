@@ -0,0 +1,207 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"go-api-example/hashring"
+	"go-api-example/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// roundTripperFunc adapts a function to an http.RoundTripper.
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
+
+func TestTransferRoutingMiddleware(t *testing.T) {
+	// replica returns a store that counts the transfers it executes and the
+	// X-Forwarded-Transfer header the last one arrived with.
+	type replica struct {
+		store     *MockStore
+		executed  atomic.Int64
+		forwarded atomic.Value
+	}
+	newReplica := func() *replica {
+		rep := &replica{}
+		rep.store = &MockStore{
+			ExecuteTransferFunc: func(ctx context.Context, req model.TransactionRequest) (*model.Transfer, error) {
+				rep.executed.Add(1)
+				return &model.Transfer{EntryID: 7, TransactionRequest: req, Total: req.Amount}, nil
+			},
+		}
+		return rep
+	}
+
+	const secret = "s3cret"
+	a, b := newReplica(), newReplica()
+	var serverA, serverB *httptest.Server
+	var ring *hashring.Ring
+	serverA = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		NewRouter(a.store, WithTransferRouting(TransferRouting{Ring: ring, Self: serverA.URL, Secret: secret})).ServeHTTP(w, r)
+	}))
+	defer serverA.Close()
+	serverB = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b.forwarded.Store(r.Header.Get(ForwardedTransferHeader))
+		NewRouter(b.store, WithTransferRouting(TransferRouting{Ring: ring, Self: serverB.URL, Secret: secret})).ServeHTTP(w, r)
+	}))
+	defer serverB.Close()
+	ring = hashring.New([]string{serverA.URL, serverB.URL})
+
+	// ownedBy returns an account whose transfers belong to node.
+	ownedBy := func(node string) int {
+		for id := 1; ; id++ {
+			if ring.Owner(fmt.Sprint(id)) == node {
+				return id
+			}
+		}
+	}
+	transfer := func(from int) *http.Request {
+		req := newJSONRequest("POST", "/transactions", fmt.Sprintf(`{"source_account_id": %d, "destination_account_id": 999999, "amount": "1"}`, from))
+		req.Header.Set(RequestIDHeader, "req-1")
+		return req
+	}
+	routerA := func(transport http.RoundTripper) http.Handler {
+		return NewRouter(a.store, WithTransferRouting(TransferRouting{Ring: ring, Self: serverA.URL, Secret: secret, Transport: transport}))
+	}
+
+	t.Run("own account", func(t *testing.T) {
+		before := a.executed.Load()
+		rr := httptest.NewRecorder()
+		routerA(nil).ServeHTTP(rr, transfer(ownedBy(serverA.URL)))
+
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+		assert.Equal(t, before+1, a.executed.Load())
+	})
+
+	t.Run("forwarded to the owner", func(t *testing.T) {
+		before, forwarded := b.executed.Load(), transfersForwarded.Value()
+		rr := httptest.NewRecorder()
+		routerA(nil).ServeHTTP(rr, transfer(ownedBy(serverB.URL)))
+
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+		assert.Contains(t, rr.Body.String(), `"entry_id":7`)
+		assert.Equal(t, []string{"req-1"}, rr.Header().Values(RequestIDHeader))
+		assert.Equal(t, before+1, b.executed.Load())
+		assert.Equal(t, serverA.URL, b.forwarded.Load())
+		assert.Equal(t, forwarded+1, transfersForwarded.Value())
+	})
+
+	t.Run("forwarded transfers are not forwarded again", func(t *testing.T) {
+		before := a.executed.Load()
+		req := transfer(ownedBy(serverB.URL))
+		req.Header.Set(ForwardedTransferHeader, serverB.URL)
+		req.Header.Set(ForwardedClientHeader, "203.0.113.9:4000")
+		req.Header.Set(ForwardedSignatureHeader, TransferRouting{Secret: secret}.sign(serverB.URL, "203.0.113.9:4000", "req-1"))
+		rr := httptest.NewRecorder()
+		routerA(nil).ServeHTTP(rr, req)
+
+		require.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, before+1, a.executed.Load())
+	})
+
+	t.Run("forged forwards are routed", func(t *testing.T) {
+		for name, signature := range map[string]string{
+			"unsigned":     "",
+			"wrong secret": TransferRouting{Secret: "guess"}.sign(serverB.URL, "203.0.113.9:4000", "req-1"),
+		} {
+			t.Run(name, func(t *testing.T) {
+				beforeA, beforeB := a.executed.Load(), b.executed.Load()
+				req := transfer(ownedBy(serverB.URL))
+				req.Header.Set(ForwardedTransferHeader, serverB.URL)
+				req.Header.Set(ForwardedClientHeader, "203.0.113.9:4000")
+				req.Header.Set(ForwardedSignatureHeader, signature)
+				rr := httptest.NewRecorder()
+				routerA(nil).ServeHTTP(rr, req)
+
+				require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+				assert.Equal(t, beforeA, a.executed.Load())
+				assert.Equal(t, beforeB+1, b.executed.Load())
+				assert.Equal(t, serverA.URL, b.forwarded.Load())
+			})
+		}
+	})
+
+	t.Run("forwarded transfers come from the client", func(t *testing.T) {
+		var seen []string
+		next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			seen = append(seen, r.RemoteAddr+" "+r.Header.Get(ForwardedTransferHeader))
+		})
+		routed := RequestIDMiddleware(transferRoutingMiddleware(TransferRouting{Ring: ring, Self: serverA.URL, Secret: secret})(next))
+
+		req := httptest.NewRequest("GET", "/accounts/1", nil)
+		req.Header.Set(RequestIDHeader, "req-1")
+		req.Header.Set(ForwardedTransferHeader, serverB.URL)
+		req.Header.Set(ForwardedClientHeader, "203.0.113.9:4000")
+		req.Header.Set(ForwardedSignatureHeader, TransferRouting{Secret: secret}.sign(serverB.URL, "203.0.113.9:4000", "req-1"))
+		routed.ServeHTTP(httptest.NewRecorder(), req)
+
+		forged := req.Clone(req.Context())
+		forged.Header.Set(ForwardedClientHeader, "198.51.100.1:4000")
+		routed.ServeHTTP(httptest.NewRecorder(), forged)
+
+		assert.Equal(t, []string{"203.0.113.9:4000 " + serverB.URL, "192.0.2.1:1234 "}, seen,
+			"a forged client is neither trusted nor passed on")
+	})
+
+	t.Run("unreachable owner", func(t *testing.T) {
+		before, fallbacks := a.executed.Load(), transferForwardFallbacks.Value()
+		down := httptest.NewServer(http.NotFoundHandler())
+		down.Close()
+		ring := hashring.New([]string{serverA.URL, down.URL})
+		id := 1
+		for ring.Owner(fmt.Sprint(id)) != down.URL {
+			id++
+		}
+		rr := httptest.NewRecorder()
+		NewRouter(a.store, WithTransferRouting(TransferRouting{Ring: ring, Self: serverA.URL, Secret: secret})).ServeHTTP(rr, transfer(id))
+
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+		assert.Equal(t, before+1, a.executed.Load(), "executed here instead")
+		assert.Equal(t, fallbacks+1, transferForwardFallbacks.Value())
+	})
+
+	t.Run("owner fails after receiving the transfer", func(t *testing.T) {
+		before := a.executed.Load()
+		failing := roundTripperFunc(func(*http.Request) (*http.Response, error) {
+			return nil, errors.New("connection reset by peer")
+		})
+		rr := httptest.NewRecorder()
+		routerA(failing).ServeHTTP(rr, transfer(ownedBy(serverB.URL)))
+
+		require.Equal(t, http.StatusBadGateway, rr.Code)
+		assert.Equal(t, CodeReplicaUnavailable, readProblem(t, rr).Code)
+		assert.Equal(t, before, a.executed.Load(), "the transfer may have been executed by the owner")
+	})
+
+	t.Run("unreadable body", func(t *testing.T) {
+		forwarded, executed := transfersForwarded.Value(), a.executed.Load()
+		next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { t.Error("the transfer reached the handler") })
+		routed := transferRoutingMiddleware(TransferRouting{Ring: ring, Self: serverA.URL, Secret: secret})(next)
+		req := transfer(ownedBy(serverB.URL))
+		rr := httptest.NewRecorder()
+		req.Body = http.MaxBytesReader(rr, req.Body, 10)
+		routed.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusRequestEntityTooLarge, rr.Code)
+		assert.Equal(t, CodePayloadTooLarge, readProblem(t, rr).Code)
+		assert.Equal(t, forwarded, transfersForwarded.Value(), "a truncated transfer is not forwarded")
+		assert.Equal(t, executed, a.executed.Load())
+	})
+
+	t.Run("other requests are not routed", func(t *testing.T) {
+		a.store.GetAccountFunc = func(ctx context.Context, id int64) (*model.Account, error) {
+			return &model.Account{AccountID: id}, nil
+		}
+		rr := httptest.NewRecorder()
+		routerA(nil).ServeHTTP(rr, httptest.NewRequest("GET", fmt.Sprintf("/accounts/%d", ownedBy(serverB.URL)), nil))
+		assert.Equal(t, http.StatusOK, rr.Code)
+	})
+}
@@ -0,0 +1,127 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"go-api-example/model"
+	"go-api-example/rules"
+	"go-api-example/storage"
+)
+
+// RulesHandler holds dependencies for the transfer rule admin handlers.
+// The gateway in front of the API must restrict /admin/ to operators.
+type RulesHandler struct {
+	store storage.Store
+	rules *rules.Engine
+}
+
+// NewRulesHandler creates a new RulesHandler.
+func NewRulesHandler(store storage.Store, engine *rules.Engine) *RulesHandler {
+	return &RulesHandler{store: store, rules: engine}
+}
+
+// accountGetter looks up an account as storage.Store.GetAccount does.
+type accountGetter func(ctx context.Context, id int64) (*model.Account, error)
+
+// cachedAccounts wraps get so each account is looked up at most once.
+func cachedAccounts(get accountGetter) accountGetter {
+	cache := map[int64]*model.Account{}
+	return func(ctx context.Context, id int64) (*model.Account, error) {
+		if acc, ok := cache[id]; ok {
+			return acc, nil
+		}
+		acc, err := get(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		cache[id] = acc
+		return acc, nil
+	}
+}
+
+// decideRules evaluates the transfer rules on req, with its accounts looked up
+// through get. A dry run reports every rule that would fire. An account that
+// does not exist fails the evaluation with storage.ErrNotFound.
+func decideRules(ctx context.Context, engine *rules.Engine, get accountGetter, req model.TransactionRequest, dryRun bool) (model.RuleDecision, error) {
+	if !dryRun && engine.Len() == 0 {
+		return model.RuleDecision{Action: model.RuleAllow}, nil
+	}
+	src, err := get(ctx, req.SourceAccountID)
+	if err != nil {
+		return model.RuleDecision{}, err
+	}
+	dst, err := get(ctx, req.DestinationAccountID)
+	if err != nil {
+		return model.RuleDecision{}, err
+	}
+	in := rules.Input{Request: req, Source: *src, Destination: *dst, Now: time.Now()}
+	if dryRun {
+		return engine.DryRun(in), nil
+	}
+	return engine.Decide(in), nil
+}
+
+// decideEntryRules evaluates the transfer rules on every leg of a journal
+// entry, as returned by model.JournalEntryRequest.Legs. The entry is denied if
+// any leg is, and sent to review if any leg is; the decision returned is the
+// first leg's that does so.
+func decideEntryRules(ctx context.Context, engine *rules.Engine, get accountGetter, req model.JournalEntryRequest) (model.RuleDecision, error) {
+	entry := model.RuleDecision{Action: model.RuleAllow}
+	for _, leg := range req.Legs() {
+		decision, err := decideRules(ctx, engine, get, leg, false)
+		if err != nil {
+			return model.RuleDecision{}, err
+		}
+		switch {
+		case decision.Action == model.RuleDeny:
+			return decision, nil
+		case decision.Action == model.RuleReview && entry.Action == model.RuleAllow:
+			entry = decision
+		}
+	}
+	return entry, nil
+}
+
+// denyTransfer writes the response to a transfer a rule denied. The rule's code
+// is reported; its name, which may reveal how risk is assessed, is not.
+func denyTransfer(w http.ResponseWriter, r *http.Request, decision model.RuleDecision) {
+	p := newProblem(r, http.StatusUnprocessableEntity, CodeTransferDenied,
+		"Transfer denied", "The transfer is not allowed: "+decision.Code)
+	p.RuleCode = decision.Code
+	writeProblem(w, p)
+}
+
+// ListRulesHandler returns the transfer rules in effect, in evaluation order.
+//
+// Method: GET
+// Path: /admin/rules
+// Success: 200 OK
+func (h *RulesHandler) ListRulesHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string][]model.Rule{"rules": h.rules.Rules()})
+}
+
+// DryRunRulesHandler evaluates the transfer rules on a transfer without
+// executing it, and reports the decision, every rule that would fire and every
+// rule that failed to evaluate.
+//
+// Method: POST
+// Path: /admin/rules/dry-run
+// Success: 200 OK
+// Error: 400 Bad Request (for invalid JSON or validation failure)
+// Error: 404 Not Found (if either account does not exist)
+// Error: 415 Unsupported Media Type (Content-Type is not application/json)
+// Error: 500 Internal Server Error (for database errors)
+func (h *RulesHandler) DryRunRulesHandler(w http.ResponseWriter, r *http.Request) {
+	var req model.TransactionRequest
+	if !decodeAndValidate(w, r, &req) {
+		return
+	}
+	decision, err := decideRules(r.Context(), h.rules, h.store.GetAccount, req, true)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, decision)
+}
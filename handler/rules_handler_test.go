@@ -0,0 +1,203 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go-api-example/model"
+	"go-api-example/rules"
+	"go-api-example/storage"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testRules(t *testing.T) Option {
+	t.Helper()
+	engine := new(rules.Engine)
+	require.NoError(t, engine.Load([]model.Rule{
+		{Name: "usd-only", When: `source.currency != "USD"`, Action: model.RuleDeny, Code: "CURRENCY_NOT_ALLOWED"},
+		{Name: "large", When: "amount > 500", Action: model.RuleReview},
+	}))
+	return WithRules(engine)
+}
+
+// rulesStore returns a store with accounts 1 and 2 in USD and 3 in EUR.
+func rulesStore(executed *bool) *MockStore {
+	return &MockStore{
+		GetAccountFunc: func(ctx context.Context, id int64) (*model.Account, error) {
+			switch id {
+			case 1, 2:
+				return &model.Account{AccountID: id, Balance: decimal.NewFromInt(1000), Currency: "USD", Status: model.AccountStatusActive}, nil
+			case 3:
+				return &model.Account{AccountID: id, Currency: "EUR", Status: model.AccountStatusActive}, nil
+			}
+			return nil, &storage.AccountError{AccountID: id, Err: storage.ErrNotFound}
+		},
+		ExecuteTransferFunc: func(ctx context.Context, req model.TransactionRequest) (*model.Transfer, error) {
+			*executed = true
+			return &model.Transfer{TransactionRequest: req, Total: req.Amount}, nil
+		},
+		RequestTransferApprovalFunc: func(ctx context.Context, req model.TransactionRequest, ttl time.Duration) (*model.TransferApproval, error) {
+			return &model.TransferApproval{ApprovalID: 9, TransactionRequest: req, Status: model.ApprovalStatusPending, ExpiresAt: time.Now().Add(ttl)}, nil
+		},
+		PostJournalEntryFunc: func(ctx context.Context, req model.JournalEntryRequest) (*model.JournalEntry, error) {
+			*executed = true
+			return &model.JournalEntry{EntryID: 1, Kind: model.EntryKindJournal, Postings: []model.Posting{}}, nil
+		},
+		RequestJournalEntryApprovalFunc: func(ctx context.Context, req model.JournalEntryRequest, ttl time.Duration) (*model.TransferApproval, error) {
+			return &model.TransferApproval{ApprovalID: 10, Postings: req.Postings, Status: model.ApprovalStatusPending, ExpiresAt: time.Now().Add(ttl)}, nil
+		},
+	}
+}
+
+func TestCreateTransactionHandlerAppliesRules(t *testing.T) {
+	t.Run("allowed", func(t *testing.T) {
+		executed := false
+		rr := httptest.NewRecorder()
+		NewRouter(rulesStore(&executed), testRules(t)).ServeHTTP(rr, newJSONRequest("POST", "/transactions", `{"source_account_id": 1, "destination_account_id": 2, "amount": "100"}`))
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.True(t, executed)
+	})
+
+	t.Run("denied", func(t *testing.T) {
+		executed := false
+		rr := httptest.NewRecorder()
+		NewRouter(rulesStore(&executed), testRules(t)).ServeHTTP(rr, newJSONRequest("POST", "/transactions", `{"source_account_id": 3, "destination_account_id": 2, "amount": "100"}`))
+
+		assert.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+		p := readProblem(t, rr)
+		assert.Equal(t, CodeTransferDenied, p.Code)
+		assert.Equal(t, "CURRENCY_NOT_ALLOWED", p.RuleCode)
+		assert.NotContains(t, rr.Body.String(), "usd-only", "rule names are not exposed")
+		assert.False(t, executed)
+	})
+
+	t.Run("sent to review", func(t *testing.T) {
+		executed := false
+		req := newJSONRequest("POST", "/transactions", `{"source_account_id": 1, "destination_account_id": 2, "amount": "600"}`)
+		req.Header.Set(PrincipalHeader, "alice@example.com")
+		rr := httptest.NewRecorder()
+		NewRouter(rulesStore(&executed), testRules(t)).ServeHTTP(rr, req)
+
+		require.Equal(t, http.StatusAccepted, rr.Code, rr.Body.String())
+		assert.Equal(t, "/approvals/9", rr.Header().Get("Location"))
+		var approval model.TransferApproval
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &approval))
+		assert.WithinDuration(t, time.Now().Add(defaultApprovalTTL), approval.ExpiresAt, time.Minute)
+		assert.False(t, executed)
+	})
+
+	t.Run("unknown account", func(t *testing.T) {
+		executed := false
+		rr := httptest.NewRecorder()
+		NewRouter(rulesStore(&executed), testRules(t)).ServeHTTP(rr, newJSONRequest("POST", "/transactions", `{"source_account_id": 1, "destination_account_id": 4, "amount": "100"}`))
+
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+		assert.False(t, executed)
+	})
+}
+
+func TestCreateJournalEntryHandlerAppliesRules(t *testing.T) {
+	post := func(t *testing.T, executed *bool, body string) *httptest.ResponseRecorder {
+		req := newJSONRequest("POST", "/journal-entries", body)
+		req.Header.Set(PrincipalHeader, "alice")
+		rr := httptest.NewRecorder()
+		NewRouter(rulesStore(executed), testRules(t)).ServeHTTP(rr, req)
+		return rr
+	}
+
+	t.Run("allowed", func(t *testing.T) {
+		executed := false
+		rr := post(t, &executed, `{"postings": [{"account_id": 1, "amount": "-100", "currency": "USD"}, {"account_id": 2, "amount": "100", "currency": "USD"}]}`)
+
+		assert.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+		assert.True(t, executed)
+	})
+
+	t.Run("sent to review", func(t *testing.T) {
+		executed := false
+		rr := post(t, &executed, `{"postings": [{"account_id": 1, "amount": "-600", "currency": "USD"}, {"account_id": 2, "amount": "600", "currency": "USD"}]}`)
+
+		require.Equal(t, http.StatusAccepted, rr.Code, rr.Body.String())
+		assert.Equal(t, "/approvals/10", rr.Header().Get("Location"))
+		assert.False(t, executed)
+	})
+
+	t.Run("a leg denied outranks a leg sent to review", func(t *testing.T) {
+		executed := false
+		rr := post(t, &executed, `{"postings": [
+			{"account_id": 1, "amount": "-600", "currency": "USD"},
+			{"account_id": 3, "amount": "-10", "currency": "USD"},
+			{"account_id": 2, "amount": "610", "currency": "USD"}
+		]}`)
+
+		require.Equal(t, http.StatusUnprocessableEntity, rr.Code, rr.Body.String())
+		p := readProblem(t, rr)
+		assert.Equal(t, CodeTransferDenied, p.Code)
+		assert.Equal(t, "CURRENCY_NOT_ALLOWED", p.RuleCode)
+		assert.False(t, executed)
+	})
+}
+
+func TestCreateTransferJobHandlerAppliesRules(t *testing.T) {
+	store, lines := jobStore()
+	executed := false
+	store.GetAccountFunc = rulesStore(&executed).GetAccountFunc
+	req := httptest.NewRequest("POST", "/transfer-jobs", strings.NewReader("source_account_id,destination_account_id,amount\n1,2,10\n3,2,10\n1,2,600\n1,4,10\n"))
+	req.Header.Set("Content-Type", "text/csv")
+	rr := httptest.NewRecorder()
+	NewRouter(store, testRules(t)).ServeHTTP(rr, req)
+
+	require.Equal(t, http.StatusAccepted, rr.Code, rr.Body.String())
+	require.Len(t, *lines, 4)
+	assert.Equal(t, "", (*lines)[0].Status)
+	assert.Equal(t, "denied by transfer rules: CURRENCY_NOT_ALLOWED", (*lines)[1].Error)
+	assert.Contains(t, (*lines)[2].Error, "held for review")
+	assert.Equal(t, "", (*lines)[3].Status, "a missing account fails when the line is executed")
+}
+
+func TestRulesHandlers(t *testing.T) {
+	executed := false
+	store := rulesStore(&executed)
+
+	t.Run("list", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		NewRouter(store, testRules(t)).ServeHTTP(rr, httptest.NewRequest("GET", "/admin/rules", nil))
+
+		require.Equal(t, http.StatusOK, rr.Code)
+		var body struct{ Rules []model.Rule }
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &body))
+		require.Len(t, body.Rules, 2)
+		assert.Equal(t, "usd-only", body.Rules[0].Name)
+	})
+
+	t.Run("dry run", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		NewRouter(store, testRules(t)).ServeHTTP(rr, newJSONRequest("POST", "/admin/rules/dry-run", `{"source_account_id": 3, "destination_account_id": 2, "amount": "600"}`))
+
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+		var d model.RuleDecision
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &d))
+		assert.Equal(t, model.RuleDeny, d.Action)
+		assert.Equal(t, "usd-only", d.Rule)
+		require.Len(t, d.Fired, 2)
+		assert.Equal(t, "large", d.Fired[1].Name)
+		assert.False(t, executed)
+	})
+
+	t.Run("dry run without rules", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		NewRouter(store).ServeHTTP(rr, newJSONRequest("POST", "/admin/rules/dry-run", `{"source_account_id": 1, "destination_account_id": 2, "amount": "600"}`))
+
+		require.Equal(t, http.StatusOK, rr.Code)
+		assert.JSONEq(t, `{"action": "allow"}`, rr.Body.String())
+	})
+}
@@ -0,0 +1,152 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go-api-example/model"
+	"go-api-example/screening"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testScreening(t *testing.T, action string) Option {
+	t.Helper()
+	s := screening.New(0.9)
+	require.NoError(t, s.Load([]model.BlocklistEntry{{AccountID: 3, List: "internal"}}))
+	return WithScreening(ScreeningPolicy{Screener: s, Action: action})
+}
+
+func TestCreateTransactionHandlerScreensAccounts(t *testing.T) {
+	var hits []model.ScreeningHit
+	newStore := func(executed *bool) *MockStore {
+		store := rulesStore(executed)
+		store.RecordScreeningHitFunc = func(ctx context.Context, hit model.ScreeningHit) error {
+			hits = append(hits, hit)
+			return nil
+		}
+		return store
+	}
+
+	t.Run("no match", func(t *testing.T) {
+		hits = nil
+		executed := false
+		rr := httptest.NewRecorder()
+		NewRouter(newStore(&executed), testScreening(t, model.ScreeningBlock)).ServeHTTP(rr, newJSONRequest("POST", "/transactions", `{"source_account_id": 1, "destination_account_id": 2, "amount": "10"}`))
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.True(t, executed)
+		assert.Empty(t, hits)
+	})
+
+	t.Run("blocked", func(t *testing.T) {
+		hits = nil
+		executed := false
+		rr := httptest.NewRecorder()
+		NewRouter(newStore(&executed), testScreening(t, model.ScreeningBlock)).ServeHTTP(rr, newJSONRequest("POST", "/transactions", `{"source_account_id": 1, "destination_account_id": 3, "amount": "10"}`))
+
+		assert.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+		p := readProblem(t, rr)
+		assert.Equal(t, CodeTransferBlocked, p.Code)
+		assert.Nil(t, p.AccountID, "the matching account is not disclosed")
+		assert.False(t, executed)
+		require.Len(t, hits, 1)
+		assert.Equal(t, model.ScreeningBlock, hits[0].Action)
+		assert.Equal(t, int64(3), hits[0].Matches[0].AccountID)
+	})
+
+	t.Run("sent to review", func(t *testing.T) {
+		hits = nil
+		executed := false
+		req := newJSONRequest("POST", "/transactions", `{"source_account_id": 3, "destination_account_id": 1, "amount": "10"}`)
+		req.Header.Set(PrincipalHeader, "alice@example.com")
+		rr := httptest.NewRecorder()
+		NewRouter(newStore(&executed), testScreening(t, model.ScreeningReview)).ServeHTTP(rr, req)
+
+		require.Equal(t, http.StatusAccepted, rr.Code, rr.Body.String())
+		assert.False(t, executed)
+		require.Len(t, hits, 1)
+		assert.Equal(t, model.ScreeningReview, hits[0].Action)
+	})
+}
+
+func TestCreateJournalEntryHandlerScreensAccounts(t *testing.T) {
+	var hits []model.ScreeningHit
+	newStore := func(executed *bool) *MockStore {
+		store := rulesStore(executed)
+		store.RecordScreeningHitFunc = func(ctx context.Context, hit model.ScreeningHit) error {
+			hits = append(hits, hit)
+			return nil
+		}
+		return store
+	}
+	entry := func(credited int64) *http.Request {
+		return newJSONRequest("POST", "/journal-entries", fmt.Sprintf(`{"postings": [
+			{"account_id": 1, "amount": "-10", "currency": "USD"},
+			{"account_id": 2, "amount": "5", "currency": "USD"},
+			{"account_id": %d, "amount": "5", "currency": "USD"}
+		]}`, credited))
+	}
+
+	t.Run("no match", func(t *testing.T) {
+		hits = nil
+		executed := false
+		rr := httptest.NewRecorder()
+		NewRouter(newStore(&executed), testScreening(t, model.ScreeningBlock)).ServeHTTP(rr, entry(2))
+
+		assert.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+		assert.True(t, executed)
+		assert.Empty(t, hits)
+	})
+
+	t.Run("blocked", func(t *testing.T) {
+		hits = nil
+		executed := false
+		rr := httptest.NewRecorder()
+		NewRouter(newStore(&executed), testScreening(t, model.ScreeningBlock)).ServeHTTP(rr, entry(3))
+
+		assert.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+		assert.Equal(t, CodeTransferBlocked, readProblem(t, rr).Code)
+		assert.False(t, executed)
+		require.Len(t, hits, 1)
+		assert.Len(t, hits[0].Postings, 3)
+		assert.Equal(t, int64(3), hits[0].Matches[0].AccountID)
+	})
+
+	t.Run("sent to review", func(t *testing.T) {
+		hits = nil
+		executed := false
+		req := entry(3)
+		req.Header.Set(PrincipalHeader, "alice@example.com")
+		rr := httptest.NewRecorder()
+		NewRouter(newStore(&executed), testScreening(t, model.ScreeningReview)).ServeHTTP(rr, req)
+
+		require.Equal(t, http.StatusAccepted, rr.Code, rr.Body.String())
+		assert.Equal(t, "/approvals/10", rr.Header().Get("Location"))
+		assert.False(t, executed)
+		require.Len(t, hits, 1)
+		assert.Equal(t, model.ScreeningReview, hits[0].Action)
+	})
+}
+
+func TestCreateTransferJobHandlerScreensLines(t *testing.T) {
+	store, lines := jobStore()
+	executed := false
+	store.GetAccountFunc = rulesStore(&executed).GetAccountFunc
+	store.RecordScreeningHitFunc = func(ctx context.Context, hit model.ScreeningHit) error { return nil }
+	req := httptest.NewRequest("POST", "/transfer-jobs", strings.NewReader("source_account_id,destination_account_id,amount\n1,2,10\n1,3,10\n"))
+	req.Header.Set("Content-Type", "text/csv")
+	rr := httptest.NewRecorder()
+	NewRouter(store, testScreening(t, model.ScreeningBlock)).ServeHTTP(rr, req)
+
+	require.Equal(t, http.StatusAccepted, rr.Code, rr.Body.String())
+	require.Len(t, *lines, 2)
+	assert.Equal(t, "", (*lines)[0].Status)
+	assert.Equal(t, model.LineStatusFailed, (*lines)[1].Status)
+	assert.Equal(t, "the transfer cannot be processed", (*lines)[1].Error)
+}
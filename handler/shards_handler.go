@@ -0,0 +1,70 @@
+package handler
+
+import (
+	"log"
+	"net/http"
+
+	"go-api-example/model"
+	"go-api-example/storage"
+)
+
+// ShardsHandler holds dependencies for the hot account admin handlers. The
+// gateway in front of the API must restrict /admin/ to operators.
+type ShardsHandler struct {
+	store storage.Store
+}
+
+// NewShardsHandler creates a new ShardsHandler.
+func NewShardsHandler(store storage.Store) *ShardsHandler {
+	return &ShardsHandler{store: store}
+}
+
+// GetAccountShardsHandler returns how an account's balance is spread over its
+// shards. An account that is not hot has none.
+//
+// Method: GET
+// Path: /admin/shards/accounts/{account_id}
+// Success: 200 OK
+// Error: 400 Bad Request (for an invalid account ID)
+// Error: 404 Not Found (if the account does not exist)
+// Error: 500 Internal Server Error (for database errors)
+func (h *ShardsHandler) GetAccountShardsHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := accountIDFromPath(w, r)
+	if !ok {
+		return
+	}
+	shards, err := h.store.GetAccountShards(r.Context(), id)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, shards)
+}
+
+// SetAccountShardsHandler makes an account hot by spreading its balance over
+// shards rows, so that concurrent transfers to it do not queue on one row
+// lock, or, with zero shards, makes it an ordinary account again.
+//
+// Method: PUT
+// Path: /admin/shards/accounts/{account_id}
+// Success: 200 OK (with the account's shards)
+// Error: 400 Bad Request (for an invalid account ID, invalid JSON or validation failure)
+// Error: 404 Not Found (if the account does not exist)
+// Error: 500 Internal Server Error (for database errors)
+func (h *ShardsHandler) SetAccountShardsHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := accountIDFromPath(w, r)
+	if !ok {
+		return
+	}
+	var req model.AccountShardsRequest
+	if !decodeAndValidate(w, r, &req) {
+		return
+	}
+	shards, err := h.store.SetAccountShards(r.Context(), id, req.Shards)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	log.Printf("Account %d set to %d shards", id, shards.Shards)
+	writeJSON(w, http.StatusOK, shards)
+}
@@ -0,0 +1,72 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go-api-example/model"
+	"go-api-example/storage"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAccountShardsHandlers(t *testing.T) {
+	stored := -1
+	store := &MockStore{
+		GetAccountShardsFunc: func(ctx context.Context, id int64) (*model.AccountShards, error) {
+			if id != 1 {
+				return nil, &storage.AccountError{AccountID: id, Err: storage.ErrNotFound}
+			}
+			return &model.AccountShards{AccountID: id, Shards: 2, Balance: decimal.NewFromInt(150),
+				ShardBalances: []decimal.Decimal{decimal.NewFromInt(100), decimal.NewFromInt(50)}}, nil
+		},
+		SetAccountShardsFunc: func(ctx context.Context, id int64, shards int) (*model.AccountShards, error) {
+			stored = shards
+			return &model.AccountShards{AccountID: id, Shards: shards, Balance: decimal.Zero, ShardBalances: []decimal.Decimal{}}, nil
+		},
+	}
+
+	t.Run("get", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		NewRouter(store).ServeHTTP(rr, httptest.NewRequest("GET", "/admin/shards/accounts/1", nil))
+
+		require.Equal(t, http.StatusOK, rr.Code)
+		assert.JSONEq(t, `{"account_id": 1, "shards": 2, "balance": "150", "shard_balances": ["100", "50"]}`, rr.Body.String())
+	})
+
+	t.Run("get unknown account", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		NewRouter(store).ServeHTTP(rr, httptest.NewRequest("GET", "/admin/shards/accounts/9", nil))
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+	})
+
+	t.Run("set", func(t *testing.T) {
+		rr := putJSON(store, "/admin/shards/accounts/1", `{"shards": 8}`)
+
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+		assert.Equal(t, 8, stored)
+	})
+
+	t.Run("unshard", func(t *testing.T) {
+		rr := putJSON(store, "/admin/shards/accounts/1", `{"shards": 0}`)
+
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+		assert.Equal(t, 0, stored)
+	})
+
+	t.Run("one shard", func(t *testing.T) {
+		rr := putJSON(store, "/admin/shards/accounts/1", `{"shards": 1}`)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+		assert.Contains(t, readProblem(t, rr).Detail, "shards: must be 0, or from 2 to 64")
+	})
+
+	t.Run("too many shards", func(t *testing.T) {
+		rr := putJSON(store, "/admin/shards/accounts/1", `{"shards": 65}`)
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+}
@@ -0,0 +1,80 @@
+package handler
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"go-api-example/model"
+	"go-api-example/statement"
+)
+
+// GetStatementHandler downloads an account statement: the opening balance, every
+// posting in the period and the closing balance. The period runs from "from"
+// (inclusive, default the account's creation) to "to" (exclusive, default now);
+// without "to" the closing balance is the account's current balance. The format
+// is csv (the default), ofx or camt053. The statement is streamed as it is read.
+//
+// Method: GET
+// Path: /accounts/{account_id}/statement?from=<RFC3339>&to=<RFC3339>&format=<csv|ofx|camt053>
+// Success: 200 OK
+// Error: 400 Bad Request (for invalid account ID, timestamps, period or format)
+// Error: 404 Not Found (if account does not exist)
+// Error: 500 Internal Server Error (for database errors)
+func (h *AccountHandler) GetStatementHandler(w http.ResponseWriter, r *http.Request) {
+	accountID, ok := accountIDFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	var period model.StatementPeriod
+	if !queryTime(w, r, "from", &period.From) || !queryTime(w, r, "to", &period.To) {
+		return
+	}
+	invalid := func(detail string) {
+		writeProblem(w, newProblem(r, http.StatusBadRequest, CodeInvalidRequest, "Invalid query parameter", detail))
+	}
+	if !period.From.IsZero() && !period.To.IsZero() && !period.From.Before(period.To) {
+		invalid(`Query parameter "from" must be before "to"`)
+		return
+	}
+	format, ok := statement.Lookup(r.URL.Query().Get("format"))
+	if !ok {
+		invalid(`Query parameter "format" must be one of ` + strings.Join(statement.Names(), ", "))
+		return
+	}
+
+	out := &statementResponse{
+		w:           w,
+		contentType: format.ContentType,
+		filename:    fmt.Sprintf("statement-%d.%s", accountID, format.Extension),
+	}
+	if err := h.store.WriteStatement(r.Context(), accountID, period, format.NewWriter(out)); err != nil {
+		if !out.started {
+			writeError(w, r, err)
+			return
+		}
+		// The status line is gone; all that can be done is to cut the download short.
+		log.Printf("Error writing statement of account %d: %v", accountID, err)
+	}
+}
+
+// statementResponse sends the response headers with the first byte of the
+// statement, so that an error before any output can still become a problem response.
+type statementResponse struct {
+	w           http.ResponseWriter
+	contentType string
+	filename    string
+	started     bool
+}
+
+func (s *statementResponse) Write(p []byte) (int, error) {
+	if !s.started {
+		s.started = true
+		s.w.Header().Set("Content-Type", s.contentType)
+		s.w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", s.filename))
+		s.w.WriteHeader(http.StatusOK)
+	}
+	return s.w.Write(p)
+}
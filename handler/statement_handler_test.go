@@ -0,0 +1,123 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go-api-example/model"
+	"go-api-example/storage"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// statementStore is a MockStore whose WriteStatement writes the given number of
+// debits of 3, then fails with failAfter if it is set. It records the period asked for.
+func statementStore(movements int, failAfter error) (*MockStore, *model.StatementPeriod) {
+	var got model.StatementPeriod
+	return &MockStore{
+		WriteStatementFunc: func(ctx context.Context, id int64, period model.StatementPeriod, w model.StatementWriter) error {
+			got = period
+			if id != 1001 {
+				return &storage.AccountError{AccountID: id, Err: storage.ErrNotFound}
+			}
+			at := time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC)
+			stmt := &model.Statement{AccountID: id, Currency: "EUR", From: at, To: at.Add(time.Hour),
+				OpeningBalance: decimal.NewFromInt(10), ClosingBalance: decimal.NewFromInt(int64(10 - 3*movements)), CreatedAt: at}
+			if err := w.Begin(stmt); err != nil {
+				return err
+			}
+			p := model.Posting{PostingID: 1, EntryID: 1, Kind: model.EntryKindTransfer, AccountID: id,
+				Amount: decimal.NewFromInt(-3), Currency: "EUR", Direction: model.DirectionDebit, CreatedAt: at}
+			for range movements {
+				if err := w.Movement(p); err != nil {
+					return err
+				}
+			}
+			if failAfter != nil {
+				return failAfter
+			}
+			return w.End()
+		},
+	}, &got
+}
+
+func getStatement(store *MockStore, path string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", path, nil)
+	rr := httptest.NewRecorder()
+	NewRouter(store).ServeHTTP(rr, req)
+	return rr
+}
+
+func TestGetStatementHandler(t *testing.T) {
+	t.Run("CSV by default", func(t *testing.T) {
+		store, period := statementStore(1, nil)
+
+		rr := getStatement(store, "/accounts/1001/statement?from=2025-01-01T00:00:00Z&to=2025-02-01T00:00:00Z")
+
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+		assert.Equal(t, "text/csv", rr.Header().Get("Content-Type"))
+		assert.Equal(t, `attachment; filename="statement-1001.csv"`, rr.Header().Get("Content-Disposition"))
+		assert.Equal(t, time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), period.From)
+		assert.Equal(t, time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC), period.To)
+		rows := strings.Split(strings.TrimSpace(rr.Body.String()), "\n")
+		require.Len(t, rows, 4)
+		assert.Equal(t, "opening,2025-01-02T00:00:00Z,,,,,EUR,10.00", rows[1])
+		assert.Equal(t, "movement,2025-01-02T00:00:00Z,1,1,transfer,-3.00,EUR,7.00", rows[2])
+		assert.Equal(t, "closing,2025-01-02T01:00:00Z,,,,,EUR,7.00", rows[3])
+	})
+
+	t.Run("CAMT.053", func(t *testing.T) {
+		store, period := statementStore(1, nil)
+
+		rr := getStatement(store, "/accounts/1001/statement?format=camt053")
+
+		require.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, "application/xml", rr.Header().Get("Content-Type"))
+		assert.Equal(t, `attachment; filename="statement-1001.xml"`, rr.Header().Get("Content-Disposition"))
+		assert.Contains(t, rr.Body.String(), `<Amt Ccy="EUR">3.00</Amt>`)
+		assert.True(t, period.From.IsZero() && period.To.IsZero(), "an open period is passed on as zero times")
+	})
+
+	t.Run("account not found", func(t *testing.T) {
+		store, _ := statementStore(1, nil)
+		rr := getStatement(store, "/accounts/42/statement?format=ofx")
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+		assert.Equal(t, CodeAccountNotFound, readProblem(t, rr).Code)
+	})
+
+	t.Run("empty period", func(t *testing.T) {
+		rr := getStatement(&MockStore{}, "/accounts/1001/statement?from=2025-02-01T00:00:00Z&to=2025-02-01T00:00:00Z")
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+		assert.Equal(t, `Query parameter "from" must be before "to"`, readProblem(t, rr).Detail)
+	})
+
+	t.Run("unknown format", func(t *testing.T) {
+		rr := getStatement(&MockStore{}, "/accounts/1001/statement?format=pdf")
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+
+	t.Run("failure before any output is flushed", func(t *testing.T) {
+		store, _ := statementStore(1, errors.New("connection reset"))
+
+		rr := getStatement(store, "/accounts/1001/statement")
+
+		assert.Equal(t, http.StatusInternalServerError, rr.Code)
+	})
+
+	t.Run("failure after output started", func(t *testing.T) {
+		store, _ := statementStore(1000, errors.New("connection reset"))
+
+		rr := getStatement(store, "/accounts/1001/statement")
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, "text/csv", rr.Header().Get("Content-Type"))
+		assert.NotContains(t, rr.Body.String(), "closing", "a failed statement must not look complete")
+	})
+}
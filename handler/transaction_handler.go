@@ -1,64 +1,89 @@
 package handler
 
 import (
-	"encoding/json"
-	"errors"
 	"log"
 	"net/http"
 
 	"go-api-example/model"
+	"go-api-example/rules"
 	"go-api-example/storage"
 )
 
 // TransactionHandler holds dependencies for transaction-related handlers.
 type TransactionHandler struct {
-	store storage.Store
+	store     storage.Store
+	approvals ApprovalPolicy
+	rules     *rules.Engine
+	screening ScreeningPolicy
 }
 
-// NewTransactionHandler creates a new TransactionHandler.
-func NewTransactionHandler(store storage.Store) *TransactionHandler {
-	return &TransactionHandler{store: store}
+// NewTransactionHandler creates a new TransactionHandler that holds transfers
+// requiring approval under approvals, evaluates engine's transfer rules and
+// screens transfers under screening.
+func NewTransactionHandler(store storage.Store, approvals ApprovalPolicy, engine *rules.Engine, screening ScreeningPolicy) *TransactionHandler {
+	return &TransactionHandler{store: store, approvals: approvals, rules: engine, screening: screening}
 }
 
 // CreateTransactionHandler handles the submission of a new financial transaction.
-// It processes the transfer atomically and ensures data consistency.
+// It processes the transfer atomically and ensures data consistency, and
+// responds with the transfer and the fee charged on top of it, if any. Both
+// accounts are first screened against the blocklist: a match blocks the
+// transfer or sends it to review, and is recorded in the audit log. The
+// transfer rules are evaluated next: a transfer a rule denies is rejected. A
+// transfer sent to review by screening or a rule is held for approval by
+// another principal, as is any transfer above the approval threshold; the
+// request must then carry X-Principal.
 //
 // Method: POST
 // Path: /transactions
-// Success: 200 OK
-// Error: 400 Bad Request (for invalid JSON or validation failure)
-// Error: 422 Unprocessable Entity (for business logic errors like insufficient funds)
+// Success: 200 OK (with the transfer and its fee)
+// Success: 202 Accepted (held for approval; Location points at the approval)
+// Error: 400 Bad Request (for invalid JSON, validation failure or a held transfer without X-Principal)
+// Error: 404 Not Found (if either account does not exist)
+// Error: 413 Request Entity Too Large (body over 1 MiB)
+// Error: 415 Unsupported Media Type (Content-Type is not application/json)
+// Error: 422 Unprocessable Entity (for business logic errors like insufficient funds for the amount plus the fee, a blocklist match or a rule denying the transfer)
 // Error: 500 Internal Server Error (for database errors)
 func (h *TransactionHandler) CreateTransactionHandler(w http.ResponseWriter, r *http.Request) {
 	var req model.TransactionRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	if !decodeAndValidate(w, r, &req) {
 		return
 	}
 
-	// Validation
-	if req.SourceAccountID == req.DestinationAccountID {
-		http.Error(w, "Source and destination accounts cannot be the same", http.StatusBadRequest)
+	getAccount := cachedAccounts(h.store.GetAccount)
+	screened, err := screenTransfer(r.Context(), h.store, h.screening, getAccount, req)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if screened == model.ScreeningBlock {
+		blockTransfer(w, r)
+		return
+	}
+
+	decision, err := decideRules(r.Context(), h.rules, getAccount, req, false)
+	if err != nil {
+		writeError(w, r, err)
 		return
 	}
-	if !req.Amount.IsPositive() {
-		http.Error(w, "Transaction amount must be positive", http.StatusBadRequest)
+	if decision.Action == model.RuleDeny {
+		log.Printf("Transfer of %s from account %d to %d denied by rule %s",
+			req.Amount, req.SourceAccountID, req.DestinationAccountID, decision.Rule)
+		denyTransfer(w, r, decision)
+		return
+	}
+
+	if screened == model.ScreeningReview || decision.Action == model.RuleReview || h.approvals.Requires(req.Amount) {
+		holdTransfer(w, r, h.store, h.approvals, req)
 		return
 	}
 
-	err := h.store.ExecuteTransfer(r.Context(), req)
+	transfer, err := h.store.ExecuteTransfer(r.Context(), req)
 	if err != nil {
 		log.Printf("Error executing transfer: %v", err)
-		switch {
-		case errors.Is(err, storage.ErrInsufficientFunds):
-			http.Error(w, "Insufficient funds", http.StatusUnprocessableEntity)
-		case errors.Is(err, storage.ErrNotFound):
-			http.Error(w, "One or both accounts not found", http.StatusNotFound)
-		default:
-			http.Error(w, "Failed to process transaction", http.StatusInternalServerError)
-		}
+		writeError(w, r, err)
 		return
 	}
 
-	w.WriteHeader(http.StatusOK)
+	writeJSON(w, http.StatusOK, transfer)
 }
@@ -2,27 +2,30 @@ package handler
 
 import (
 	"context"
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
-	"strings"
 	"testing"
 
 	"go-api-example/model"
+	"go-api-example/rules"
 	"go-api-example/storage"
 
+	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestCreateTransactionHandler(t *testing.T) {
 	t.Run("success", func(t *testing.T) {
 		mockStore := &MockStore{
-			ExecuteTransferFunc: func(ctx context.Context, req model.TransactionRequest) error {
-				return nil
+			ExecuteTransferFunc: func(ctx context.Context, req model.TransactionRequest) (*model.Transfer, error) {
+				return &model.Transfer{TransactionRequest: req, Total: req.Amount}, nil
 			},
 		}
-		handler := NewTransactionHandler(mockStore)
+		handler := NewTransactionHandler(mockStore, ApprovalPolicy{}, new(rules.Engine), ScreeningPolicy{})
 		body := `{"source_account_id": 1, "destination_account_id": 2, "amount": "100"}`
-		req := httptest.NewRequest("POST", "/transactions", strings.NewReader(body))
+		req := newJSONRequest("POST", "/transactions", body)
 		rr := httptest.NewRecorder()
 
 		handler.CreateTransactionHandler(rr, req)
@@ -30,59 +33,87 @@ func TestCreateTransactionHandler(t *testing.T) {
 		assert.Equal(t, http.StatusOK, rr.Code)
 	})
 
+	t.Run("success with fee", func(t *testing.T) {
+		fee := model.FeeBreakdown{Source: model.FeeSourceTier, Tier: model.DefaultTier, Type: model.FeeFlat,
+			Flat: decimal.NewFromInt(2), Amount: decimal.NewFromInt(2), RevenueAccountID: 9, EntryID: 8}
+		mockStore := &MockStore{
+			ExecuteTransferFunc: func(ctx context.Context, req model.TransactionRequest) (*model.Transfer, error) {
+				return &model.Transfer{EntryID: 7, TransactionRequest: req, Fee: &fee, Total: req.Amount.Add(fee.Amount)}, nil
+			},
+		}
+		handler := NewTransactionHandler(mockStore, ApprovalPolicy{}, new(rules.Engine), ScreeningPolicy{})
+		rr := httptest.NewRecorder()
+
+		handler.CreateTransactionHandler(rr, newJSONRequest("POST", "/transactions", `{"source_account_id": 1, "destination_account_id": 2, "amount": "100"}`))
+
+		require.Equal(t, http.StatusOK, rr.Code)
+		var transfer model.Transfer
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &transfer))
+		assert.Equal(t, int64(7), transfer.EntryID)
+		assert.Equal(t, "102", transfer.Total.String())
+		require.NotNil(t, transfer.Fee)
+		assert.Equal(t, "2", transfer.Fee.Amount.String())
+		assert.Equal(t, model.DefaultTier, transfer.Fee.Tier)
+	})
+
 	t.Run("insufficient funds", func(t *testing.T) {
 		mockStore := &MockStore{
-			ExecuteTransferFunc: func(ctx context.Context, req model.TransactionRequest) error {
-				return storage.ErrInsufficientFunds
+			ExecuteTransferFunc: func(ctx context.Context, req model.TransactionRequest) (*model.Transfer, error) {
+				return nil, storage.ErrInsufficientFunds
 			},
 		}
-		handler := NewTransactionHandler(mockStore)
+		handler := NewTransactionHandler(mockStore, ApprovalPolicy{}, new(rules.Engine), ScreeningPolicy{})
 		body := `{"source_account_id": 1, "destination_account_id": 2, "amount": "1000"}`
-		req := httptest.NewRequest("POST", "/transactions", strings.NewReader(body))
+		req := newJSONRequest("POST", "/transactions", body)
 		rr := httptest.NewRecorder()
 
 		handler.CreateTransactionHandler(rr, req)
 
 		assert.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+		assert.Equal(t, "application/problem+json", rr.Header().Get("Content-Type"))
 		assert.Contains(t, rr.Body.String(), "Insufficient funds")
+		assert.Contains(t, rr.Body.String(), CodeInsufficientFunds)
 	})
 
 	t.Run("account not found", func(t *testing.T) {
 		mockStore := &MockStore{
-			ExecuteTransferFunc: func(ctx context.Context, req model.TransactionRequest) error {
-				return storage.ErrNotFound
+			ExecuteTransferFunc: func(ctx context.Context, req model.TransactionRequest) (*model.Transfer, error) {
+				return nil, storage.ErrNotFound
 			},
 		}
-		handler := NewTransactionHandler(mockStore)
+		handler := NewTransactionHandler(mockStore, ApprovalPolicy{}, new(rules.Engine), ScreeningPolicy{})
 		body := `{"source_account_id": 99, "destination_account_id": 2, "amount": "100"}`
-		req := httptest.NewRequest("POST", "/transactions", strings.NewReader(body))
+		req := newJSONRequest("POST", "/transactions", body)
 		rr := httptest.NewRecorder()
 
 		handler.CreateTransactionHandler(rr, req)
 
 		assert.Equal(t, http.StatusNotFound, rr.Code)
 		assert.Contains(t, rr.Body.String(), "One or both accounts not found")
+		assert.Contains(t, rr.Body.String(), CodeAccountNotFound)
 	})
 
 	t.Run("same account", func(t *testing.T) {
-		handler := NewTransactionHandler(&MockStore{})
+		handler := NewTransactionHandler(&MockStore{}, ApprovalPolicy{}, new(rules.Engine), ScreeningPolicy{})
 		body := `{"source_account_id": 1, "destination_account_id": 1, "amount": "100"}`
-		req := httptest.NewRequest("POST", "/transactions", strings.NewReader(body))
+		req := newJSONRequest("POST", "/transactions", body)
 		rr := httptest.NewRecorder()
 
 		handler.CreateTransactionHandler(rr, req)
 
 		assert.Equal(t, http.StatusBadRequest, rr.Code)
+		assert.Contains(t, rr.Body.String(), CodeSameAccount)
 	})
 
 	t.Run("negative amount", func(t *testing.T) {
-		handler := NewTransactionHandler(&MockStore{})
+		handler := NewTransactionHandler(&MockStore{}, ApprovalPolicy{}, new(rules.Engine), ScreeningPolicy{})
 		body := `{"source_account_id": 1, "destination_account_id": 2, "amount": "-100"}`
-		req := httptest.NewRequest("POST", "/transactions", strings.NewReader(body))
+		req := newJSONRequest("POST", "/transactions", body)
 		rr := httptest.NewRecorder()
 
 		handler.CreateTransactionHandler(rr, req)
 
 		assert.Equal(t, http.StatusBadRequest, rr.Code)
+		assert.Contains(t, rr.Body.String(), CodeInvalidAmount)
 	})
 }
@@ -0,0 +1,341 @@
+package handler
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"go-api-example/model"
+	"go-api-example/rules"
+	"go-api-example/storage"
+
+	"github.com/gorilla/mux"
+	"github.com/shopspring/decimal"
+)
+
+// csvContentType is the media type of an uploaded or downloaded CSV file.
+const csvContentType = "text/csv"
+
+// TransferJobHandler holds dependencies for the transfer job handlers.
+type TransferJobHandler struct {
+	store     storage.Store
+	approvals ApprovalPolicy
+	rules     *rules.Engine
+	screening ScreeningPolicy
+}
+
+// NewTransferJobHandler creates a new TransferJobHandler. Lines that would need
+// approval under approvals, that engine's transfer rules deny or send to
+// review, or that match the blocklist under screening, are failed rather than
+// executed.
+func NewTransferJobHandler(store storage.Store, approvals ApprovalPolicy, engine *rules.Engine, screening ScreeningPolicy) *TransferJobHandler {
+	return &TransferJobHandler{store: store, approvals: approvals, rules: engine, screening: screening}
+}
+
+// CreateTransferJobHandler accepts a transfer file and queues its lines for the
+// background workers. The file is CSV (text/csv) with a header row naming the
+// source_account_id, destination_account_id and amount columns, or NDJSON
+// (application/x-ndjson) with one transaction object per line. Lines that fail
+// validation are recorded as failed straight away; the others are executed later,
+// each exactly once, as POST /transactions would. A job cannot be used to get
+// around maker-checker approval: a line above the approval threshold fails. So
+// does a line that matches the blocklist, or that the transfer rules deny or
+// send to review; screening and rules apply when the file is uploaded, not when
+// the line is executed.
+//
+// Method: POST
+// Path: /transfer-jobs
+// Success: 202 Accepted (with the job; Location points at it)
+// Error: 400 Bad Request (for a file without transfers or a malformed CSV file)
+// Error: 413 Request Entity Too Large (body over 64 MiB)
+// Error: 415 Unsupported Media Type (Content-Type is neither CSV nor NDJSON)
+// Error: 500 Internal Server Error (for database errors)
+func (h *TransferJobHandler) CreateTransferJobHandler(w http.ResponseWriter, r *http.Request) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	body := http.MaxBytesReader(w, r.Body, maxTransferFileBytes)
+
+	var lines []model.TransferJobLine
+	var checkErr error
+	getAccount := cachedAccounts(h.store.GetAccount)
+	add := func(line int, req model.TransactionRequest, err error) {
+		if err == nil {
+			err = model.Validate(req)
+		}
+		if err == nil && h.approvals.Requires(req.Amount) {
+			err = errors.New("amount: exceeds the approval threshold; submit it through POST /transactions")
+		}
+		if err == nil && checkErr == nil {
+			err, checkErr = h.checkLine(r.Context(), getAccount, req)
+		}
+		l := model.TransferJobLine{Line: line, TransactionRequest: req}
+		if err != nil {
+			l = model.TransferJobLine{Line: line, Status: model.LineStatusFailed, Error: err.Error()}
+		}
+		lines = append(lines, l)
+	}
+
+	var p *Problem
+	switch mediaType {
+	case csvContentType:
+		p = decodeTransferCSV(r, body, add)
+	case ndjsonContentType:
+		p = decodeNDJSON(r, body, add)
+	default:
+		p = newProblem(r, http.StatusUnsupportedMediaType, CodeUnsupportedMediaType,
+			"Unsupported media type", "Content-Type must be "+csvContentType+" or "+ndjsonContentType)
+	}
+	if p == nil && len(lines) == 0 {
+		p = newProblem(r, http.StatusBadRequest, CodeInvalidRequest, "Invalid request body", "The file contains no transfers")
+	}
+	if p != nil {
+		writeProblem(w, p)
+		return
+	}
+	if checkErr != nil {
+		writeError(w, r, checkErr)
+		return
+	}
+
+	job, err := h.store.CreateTransferJob(r.Context(), lines)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	log.Printf("Transfer job %d queued with %d lines (%d invalid)", job.JobID, job.TotalLines, job.Failed)
+
+	w.Header().Set("Location", fmt.Sprintf("/transfer-jobs/%d", job.JobID))
+	writeJSON(w, http.StatusAccepted, job)
+}
+
+// checkLine screens a line and evaluates the transfer rules on it. It returns
+// why the line fails, if it does, and separately an error that fails the whole
+// upload. A missing account is left for the line to fail on when executed.
+func (h *TransferJobHandler) checkLine(ctx context.Context, get accountGetter, req model.TransactionRequest) (reason, err error) {
+	screened, err := screenTransfer(ctx, h.store, h.screening, get, req)
+	if err == nil && screened == "" {
+		var decision model.RuleDecision
+		decision, err = decideRules(ctx, h.rules, get, req, false)
+		switch decision.Action {
+		case model.RuleDeny:
+			return fmt.Errorf("denied by transfer rules: %s", decision.Code), nil
+		case model.RuleReview:
+			return errors.New("held for review by transfer rules; submit it through POST /transactions"), nil
+		}
+	}
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		return nil, nil
+	case err != nil:
+		return nil, err
+	case screened == model.ScreeningBlock:
+		return errors.New("the transfer cannot be processed"), nil
+	case screened == model.ScreeningReview:
+		return errors.New("held for review; submit it through POST /transactions"), nil
+	}
+	return nil, nil
+}
+
+// GetTransferJobHandler reports the status and progress of a transfer job.
+//
+// Method: GET
+// Path: /transfer-jobs/{job_id}
+// Success: 200 OK
+// Error: 400 Bad Request (for an invalid job ID)
+// Error: 404 Not Found (if the job does not exist)
+// Error: 500 Internal Server Error (for database errors)
+func (h *TransferJobHandler) GetTransferJobHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := jobIDFromPath(w, r)
+	if !ok {
+		return
+	}
+	job, err := h.store.GetTransferJob(r.Context(), id)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, job)
+}
+
+// GetTransferJobResultsHandler downloads the outcome of every line of a job, as
+// CSV (the default) or NDJSON with ?format=ndjson. Lines not processed yet are
+// reported as pending.
+//
+// Method: GET
+// Path: /transfer-jobs/{job_id}/results?format=<csv|ndjson>
+// Success: 200 OK
+// Error: 400 Bad Request (for an invalid job ID or format)
+// Error: 404 Not Found (if the job does not exist)
+// Error: 500 Internal Server Error (for database errors)
+func (h *TransferJobHandler) GetTransferJobResultsHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := jobIDFromPath(w, r)
+	if !ok {
+		return
+	}
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "csv"
+	}
+	if format != "csv" && format != "ndjson" {
+		writeProblem(w, newProblem(r, http.StatusBadRequest, CodeInvalidRequest, "Invalid query parameter",
+			`Query parameter "format" must be csv or ndjson`))
+		return
+	}
+	if _, err := h.store.GetTransferJob(r.Context(), id); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	// Results are streamed page by page; once the first byte is written an error
+	// can only be logged and the download is cut short.
+	filter := model.TransferJobLineFilter{Limit: model.MaxPageSize}
+	lines, err := h.store.TransferJobLines(r.Context(), id, filter)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	contentType := csvContentType
+	if format == "ndjson" {
+		contentType = ndjsonContentType
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="transfer-job-%d-results.%s"`, id, format))
+	w.WriteHeader(http.StatusOK)
+
+	write := newResultWriter(format, w)
+	for {
+		for _, l := range lines {
+			if err := write(l); err != nil {
+				log.Printf("Error writing transfer job %d results: %v", id, err)
+				return
+			}
+		}
+		if len(lines) < filter.Limit {
+			break
+		}
+		filter.AfterLine = lines[len(lines)-1].Line
+		if lines, err = h.store.TransferJobLines(r.Context(), id, filter); err != nil {
+			log.Printf("Error reading transfer job %d results: %v", id, err)
+			return
+		}
+	}
+	if err := write(model.TransferJobLine{}); err != nil {
+		log.Printf("Error writing transfer job %d results: %v", id, err)
+	}
+}
+
+// maxTransferFileBytes caps an uploaded transfer file: room for about a million lines.
+const maxTransferFileBytes = 64 << 20
+
+// transferCSVColumns are the columns a transfer CSV file must name in its header.
+var transferCSVColumns = []string{"source_account_id", "destination_account_id", "amount"}
+
+// decodeTransferCSV reads a CSV transfer file, calling add for each data line
+// with its line number. Unparseable values are passed to add as errors.
+func decodeTransferCSV(r *http.Request, body io.Reader, add func(int, model.TransactionRequest, error)) *Problem {
+	cr := csv.NewReader(body)
+	cr.ReuseRecord = true
+	invalid := func(detail string) *Problem {
+		return newProblem(r, http.StatusBadRequest, CodeInvalidRequest, "Invalid request body", detail)
+	}
+
+	header, err := cr.Read()
+	if err != nil {
+		return invalid("Could not read the CSV header")
+	}
+	cols := map[string]int{}
+	for i, name := range header {
+		cols[strings.TrimSpace(strings.ToLower(name))] = i
+	}
+	for _, name := range transferCSVColumns {
+		if _, ok := cols[name]; !ok {
+			return invalid(fmt.Sprintf("CSV header has no %s column", name))
+		}
+	}
+
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			return nil
+		}
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			return decodeProblem(r, err)
+		}
+		if err != nil && !errors.Is(err, csv.ErrFieldCount) {
+			return invalid(err.Error())
+		}
+		line, _ := cr.FieldPos(0)
+		field := func(name string) string {
+			if i := cols[name]; i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+
+		var req model.TransactionRequest
+		var rowErr error
+		if req.SourceAccountID, err = strconv.ParseInt(field("source_account_id"), 10, 64); err != nil {
+			rowErr = errors.New("source_account_id: must be an integer")
+		} else if req.DestinationAccountID, err = strconv.ParseInt(field("destination_account_id"), 10, 64); err != nil {
+			rowErr = errors.New("destination_account_id: must be an integer")
+		} else if req.Amount, err = decimal.NewFromString(field("amount")); err != nil {
+			rowErr = errors.New("amount: must be a decimal number")
+		}
+		add(line, req, rowErr)
+	}
+}
+
+// newResultWriter returns a function that writes one job line per call in the
+// given format. Calling it with a zero line flushes buffered output.
+func newResultWriter(format string, w io.Writer) func(model.TransferJobLine) error {
+	if format == "ndjson" {
+		enc := json.NewEncoder(w)
+		return func(l model.TransferJobLine) error {
+			if l.Line == 0 {
+				return nil
+			}
+			return enc.Encode(l)
+		}
+	}
+	cw := csv.NewWriter(w)
+	header := false
+	return func(l model.TransferJobLine) error {
+		if !header {
+			header = true
+			if err := cw.Write([]string{"line", "source_account_id", "destination_account_id", "amount", "status", "error"}); err != nil {
+				return err
+			}
+		}
+		if l.Line == 0 {
+			cw.Flush()
+			return cw.Error()
+		}
+		return cw.Write([]string{
+			strconv.Itoa(l.Line),
+			strconv.FormatInt(l.SourceAccountID, 10),
+			strconv.FormatInt(l.DestinationAccountID, 10),
+			l.Amount.String(),
+			l.Status,
+			l.Error,
+		})
+	}
+}
+
+// jobIDFromPath parses the {job_id} path variable, writing a problem response on failure.
+func jobIDFromPath(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	id, err := strconv.ParseInt(mux.Vars(r)["job_id"], 10, 64)
+	if err != nil {
+		writeProblem(w, newProblem(r, http.StatusBadRequest, CodeInvalidRequest, "Invalid job ID", "Invalid job ID format"))
+		return 0, false
+	}
+	return id, true
+}
@@ -0,0 +1,190 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go-api-example/model"
+	"go-api-example/storage"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func postTransferJob(t *testing.T, store *MockStore, contentType, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest("POST", "/transfer-jobs", strings.NewReader(body))
+	req.Header.Set("Content-Type", contentType)
+	rr := httptest.NewRecorder()
+	NewRouter(store).ServeHTTP(rr, req)
+	return rr
+}
+
+// jobStore is a MockStore whose CreateTransferJob records the lines it is given.
+func jobStore() (*MockStore, *[]model.TransferJobLine) {
+	var stored []model.TransferJobLine
+	return &MockStore{
+		CreateTransferJobFunc: func(ctx context.Context, lines []model.TransferJobLine) (*model.TransferJob, error) {
+			stored = lines
+			job := &model.TransferJob{JobID: 7, Status: model.JobStatusPending, CreatedAt: time.Now(), TotalLines: int64(len(lines))}
+			for _, l := range lines {
+				if l.Status == model.LineStatusFailed {
+					job.Failed++
+				} else {
+					job.Pending++
+				}
+			}
+			return job, nil
+		},
+	}, &stored
+}
+
+func TestCreateTransferJobHandler(t *testing.T) {
+	t.Run("CSV lines are queued and invalid ones failed", func(t *testing.T) {
+		store, lines := jobStore()
+		body := "Source_Account_ID,destination_account_id,amount\n1,2,10.50\n1,x,1\n2,1,-3\n"
+
+		rr := postTransferJob(t, store, "text/csv", body)
+
+		require.Equal(t, http.StatusAccepted, rr.Code, rr.Body.String())
+		assert.Equal(t, "/transfer-jobs/7", rr.Header().Get("Location"))
+		var job model.TransferJob
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &job))
+		assert.Equal(t, int64(3), job.TotalLines)
+		assert.Equal(t, int64(1), job.Pending)
+		assert.Equal(t, int64(2), job.Failed)
+
+		require.Len(t, *lines, 3)
+		assert.Equal(t, 2, (*lines)[0].Line)
+		assert.Equal(t, int64(1), (*lines)[0].SourceAccountID)
+		assert.True(t, decimal.RequireFromString("10.50").Equal((*lines)[0].Amount))
+		assert.Equal(t, "", (*lines)[0].Status)
+		assert.Equal(t, model.LineStatusFailed, (*lines)[1].Status)
+		assert.Equal(t, "destination_account_id: must be an integer", (*lines)[1].Error)
+		assert.Equal(t, model.LineStatusFailed, (*lines)[2].Status)
+	})
+
+	t.Run("NDJSON lines are numbered by line", func(t *testing.T) {
+		store, lines := jobStore()
+		body := "{\"source_account_id\": 1, \"destination_account_id\": 2, \"amount\": \"5\"}\n\n{bad}\n"
+
+		rr := postTransferJob(t, store, "application/x-ndjson", body)
+
+		require.Equal(t, http.StatusAccepted, rr.Code, rr.Body.String())
+		require.Len(t, *lines, 2)
+		assert.Equal(t, 1, (*lines)[0].Line)
+		assert.Equal(t, 3, (*lines)[1].Line)
+		assert.Equal(t, model.LineStatusFailed, (*lines)[1].Status)
+	})
+
+	t.Run("CSV without a required column", func(t *testing.T) {
+		rr := postTransferJob(t, &MockStore{}, "text/csv", "source_account_id,amount\n1,2\n")
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+		assert.Equal(t, "CSV header has no destination_account_id column", readProblem(t, rr).Detail)
+	})
+
+	t.Run("file without transfers", func(t *testing.T) {
+		rr := postTransferJob(t, &MockStore{}, "text/csv", "source_account_id,destination_account_id,amount\n")
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+
+	t.Run("unsupported content type", func(t *testing.T) {
+		rr := postTransferJob(t, &MockStore{}, "application/json", "[]")
+		assert.Equal(t, http.StatusUnsupportedMediaType, rr.Code)
+	})
+}
+
+func TestGetTransferJobHandler(t *testing.T) {
+	t.Run("found", func(t *testing.T) {
+		store := &MockStore{
+			GetTransferJobFunc: func(ctx context.Context, id int64) (*model.TransferJob, error) {
+				return &model.TransferJob{JobID: id, Status: model.JobStatusRunning, TotalLines: 3, Pending: 1, Succeeded: 2}, nil
+			},
+		}
+		req := httptest.NewRequest("GET", "/transfer-jobs/7", nil)
+		rr := httptest.NewRecorder()
+		NewRouter(store).ServeHTTP(rr, req)
+
+		require.Equal(t, http.StatusOK, rr.Code)
+		var job model.TransferJob
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &job))
+		assert.Equal(t, int64(7), job.JobID)
+		assert.Equal(t, model.JobStatusRunning, job.Status)
+		assert.Equal(t, int64(2), job.Succeeded)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		store := &MockStore{
+			GetTransferJobFunc: func(ctx context.Context, id int64) (*model.TransferJob, error) {
+				return nil, storage.ErrJobNotFound
+			},
+		}
+		req := httptest.NewRequest("GET", "/transfer-jobs/9", nil)
+		rr := httptest.NewRecorder()
+		NewRouter(store).ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+		assert.Equal(t, CodeJobNotFound, readProblem(t, rr).Code)
+	})
+}
+
+func TestGetTransferJobResultsHandler(t *testing.T) {
+	// Two full pages and a partial one.
+	total := 2*model.MaxPageSize + 1
+	store := &MockStore{
+		GetTransferJobFunc: func(ctx context.Context, id int64) (*model.TransferJob, error) {
+			return &model.TransferJob{JobID: id}, nil
+		},
+		TransferJobLinesFunc: func(ctx context.Context, id int64, filter model.TransferJobLineFilter) ([]model.TransferJobLine, error) {
+			var lines []model.TransferJobLine
+			for l := filter.AfterLine + 1; l <= total && len(lines) < filter.Limit; l++ {
+				line := model.TransferJobLine{Line: l, Status: model.LineStatusSucceeded,
+					TransactionRequest: model.TransactionRequest{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromInt(1)}}
+				if l == 2 {
+					line.Status, line.Error = model.LineStatusFailed, "insufficient funds"
+				}
+				lines = append(lines, line)
+			}
+			return lines, nil
+		},
+	}
+	get := func(query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/transfer-jobs/7/results"+query, nil)
+		rr := httptest.NewRecorder()
+		NewRouter(store).ServeHTTP(rr, req)
+		return rr
+	}
+
+	t.Run("CSV by default", func(t *testing.T) {
+		rr := get("")
+
+		require.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, "text/csv", rr.Header().Get("Content-Type"))
+		assert.Equal(t, `attachment; filename="transfer-job-7-results.csv"`, rr.Header().Get("Content-Disposition"))
+		rows := strings.Split(strings.TrimSpace(rr.Body.String()), "\n")
+		require.Len(t, rows, total+1)
+		assert.Equal(t, "line,source_account_id,destination_account_id,amount,status,error", rows[0])
+		assert.Equal(t, "2,1,2,1,failed,insufficient funds", rows[2])
+	})
+
+	t.Run("NDJSON", func(t *testing.T) {
+		rr := get("?format=ndjson")
+
+		require.Equal(t, http.StatusOK, rr.Code)
+		rows := strings.Split(strings.TrimSpace(rr.Body.String()), "\n")
+		require.Len(t, rows, total)
+		var line model.TransferJobLine
+		require.NoError(t, json.Unmarshal([]byte(rows[total-1]), &line))
+		assert.Equal(t, total, line.Line)
+	})
+
+	t.Run("invalid format", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, get("?format=xml").Code)
+	})
+}
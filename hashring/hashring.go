@@ -0,0 +1,72 @@
+// Package hashring assigns keys to nodes by consistent hashing. Every node is
+// placed on a ring of 64-bit hashes at a number of points, and a key belongs to
+// the node of the first point at or after the key's hash. Adding or removing a
+// node therefore only moves the keys of that node, and every replica that
+// knows the same nodes agrees on the owner of every key.
+package hashring
+
+import (
+	"cmp"
+	"crypto/sha256"
+	"encoding/binary"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// DefaultPoints is the number of points per node used by New: enough for the
+// keys to spread evenly over a handful of nodes.
+const DefaultPoints = 128
+
+// Ring is a consistent hash ring. It is immutable and safe for concurrent use.
+type Ring struct {
+	points []point
+}
+
+type point struct {
+	hash uint64
+	node string
+}
+
+// New returns a ring of nodes with DefaultPoints points each. Duplicate nodes
+// are placed once.
+func New(nodes []string) *Ring {
+	return NewWithPoints(nodes, DefaultPoints)
+}
+
+// NewWithPoints returns a ring of nodes with the given number of points each.
+func NewWithPoints(nodes []string, points int) *Ring {
+	r := &Ring{}
+	seen := map[string]bool{}
+	for _, node := range nodes {
+		if seen[node] {
+			continue
+		}
+		seen[node] = true
+		for i := 0; i < points; i++ {
+			r.points = append(r.points, point{hash: hash(node + "#" + strconv.Itoa(i)), node: node})
+		}
+	}
+	// Ties, however unlikely, are broken by node, so that every replica agrees.
+	slices.SortFunc(r.points, func(a, b point) int {
+		return cmp.Or(cmp.Compare(a.hash, b.hash), strings.Compare(a.node, b.node))
+	})
+	return r
+}
+
+// Owner returns the node that key belongs to, or "" if the ring is empty.
+func (r *Ring) Owner(key string) string {
+	if len(r.points) == 0 {
+		return ""
+	}
+	i, _ := slices.BinarySearchFunc(r.points, hash(key), func(p point, h uint64) int { return cmp.Compare(p.hash, h) })
+	if i == len(r.points) {
+		i = 0
+	}
+	return r.points[i].node
+}
+
+func hash(s string) uint64 {
+	sum := sha256.Sum256([]byte(s))
+	return binary.BigEndian.Uint64(sum[:8])
+}
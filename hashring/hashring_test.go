@@ -0,0 +1,50 @@
+package hashring
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOwner(t *testing.T) {
+	nodes := []string{"http://api-1:8080", "http://api-2:8080", "http://api-3:8080"}
+	ring := New(nodes)
+
+	t.Run("every replica agrees", func(t *testing.T) {
+		other := New([]string{nodes[2], nodes[0], nodes[1], nodes[0]})
+		for i := 0; i < 1000; i++ {
+			key := fmt.Sprint(i)
+			assert.Equal(t, ring.Owner(key), other.Owner(key), key)
+		}
+	})
+
+	t.Run("keys spread over the nodes", func(t *testing.T) {
+		counts := map[string]int{}
+		for i := 0; i < 30000; i++ {
+			counts[ring.Owner(fmt.Sprint(i))]++
+		}
+		assert.Len(t, counts, 3)
+		for node, n := range counts {
+			assert.InDelta(t, 10000, n, 2500, "keys owned by %s", node)
+		}
+	})
+
+	t.Run("removing a node only moves its keys", func(t *testing.T) {
+		smaller := New(nodes[:2])
+		for i := 0; i < 1000; i++ {
+			key := fmt.Sprint(i)
+			if owner := ring.Owner(key); owner != nodes[2] {
+				assert.Equal(t, owner, smaller.Owner(key), key)
+			}
+		}
+	})
+
+	t.Run("empty ring", func(t *testing.T) {
+		assert.Equal(t, "", New(nil).Owner("1"))
+	})
+
+	t.Run("single node", func(t *testing.T) {
+		assert.Equal(t, nodes[0], New(nodes[:1]).Owner("1"))
+	})
+}
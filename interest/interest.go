@@ -0,0 +1,89 @@
+// Package interest computes the interest an account accrues in a day under the
+// supported day-count conventions. Amounts are exact decimals, divided and
+// rounded only once when accruals are summed, so that the daily accruals of a
+// month add up to exactly the interest of the month.
+package interest
+
+import (
+	"fmt"
+	"time"
+
+	"go-api-example/model"
+
+	"github.com/shopspring/decimal"
+)
+
+// Places is the number of decimal places interest is rounded to. Interest is
+// posted rounded down to model.MaxDecimalPlaces, and the rest is carried over
+// to the next posting.
+const Places = 18
+
+// Days returns how many days the calendar day of t accrues under dayCount, out
+// of the days of a year. Under ACT/365 that is 1 of 365 for every day. Under
+// 30/360 it is 1 of 360, except that the day before a 31st accrues nothing and
+// the last day of February accrues the days up to the 30th.
+func Days(dayCount string, t time.Time) (days, year int64, err error) {
+	switch dayCount {
+	case model.DayCountACT365:
+		return 1, 365, nil
+	case model.DayCount30360:
+		// The 30/360 day count from t to the next day, with both 31sts counted as 30ths.
+		y1, m1, d1 := t.Date()
+		y2, m2, d2 := t.AddDate(0, 0, 1).Date()
+		d1, d2 = min(d1, 30), min(d2, 30)
+		return int64(360*(y2-y1) + 30*(int(m2)-int(m1)) + (d2 - d1)), 360, nil
+	}
+	return 0, 0, fmt.Errorf("unknown day-count convention %q", dayCount)
+}
+
+// Accrual is the interest of a balance for one day: Balance times AnnualRate
+// percent times Days divided by Year. It is kept in these parts, so that a sum
+// of accruals is divided only once.
+type Accrual struct {
+	Balance    decimal.Decimal
+	AnnualRate decimal.Decimal
+	Days       int64
+	Year       int64
+}
+
+// Accrue returns the accrual of balance for the calendar day of t at
+// annualRate percent a year under dayCount.
+func Accrue(balance, annualRate decimal.Decimal, dayCount string, t time.Time) (Accrual, error) {
+	days, year, err := Days(dayCount, t)
+	if err != nil {
+		return Accrual{}, err
+	}
+	return Accrual{Balance: balance, AnnualRate: annualRate, Days: days, Year: year}, nil
+}
+
+// Amount returns the interest of the accrual, rounded to Places.
+func (a Accrual) Amount() decimal.Decimal {
+	return Sum([]Accrual{a})
+}
+
+// Sum returns the interest of accruals, rounded to Places. The exact products
+// are added up for each length of year and divided once, so the sum does not
+// depend on how the interest was split into days.
+func Sum(accruals []Accrual) decimal.Decimal {
+	products := map[int64]decimal.Decimal{}
+	for _, a := range accruals {
+		products[a.Year] = products[a.Year].Add(a.Balance.Mul(a.AnnualRate).Mul(decimal.NewFromInt(a.Days)))
+	}
+	sum := decimal.Zero
+	for year, p := range products {
+		sum = sum.Add(p.DivRound(decimal.NewFromInt(100*year), Places+2))
+	}
+	return sum.Round(Places)
+}
+
+// Day returns the UTC calendar day of t, at midnight.
+func Day(t time.Time) time.Time {
+	y, m, d := t.UTC().Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+// Month returns the first day of the UTC calendar month of t, at midnight.
+func Month(t time.Time) time.Time {
+	y, m, _ := t.UTC().Date()
+	return time.Date(y, m, 1, 0, 0, 0, 0, time.UTC)
+}
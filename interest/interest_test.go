@@ -0,0 +1,103 @@
+package interest
+
+import (
+	"testing"
+	"time"
+
+	"go-api-example/model"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func date(y int, m time.Month, d int) time.Time {
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+// accrue sums the daily accruals of the days from from up to, not including, to.
+func accrue(t *testing.T, balance, rate string, dayCount string, from, to time.Time) decimal.Decimal {
+	t.Helper()
+	var accruals []Accrual
+	for day := from; day.Before(to); day = day.AddDate(0, 0, 1) {
+		a, err := Accrue(decimal.RequireFromString(balance), decimal.RequireFromString(rate), dayCount, day)
+		require.NoError(t, err)
+		accruals = append(accruals, a)
+	}
+	return Sum(accruals)
+}
+
+func TestDays30360(t *testing.T) {
+	tests := []struct {
+		day  time.Time
+		days int64
+	}{
+		{date(2023, time.January, 15), 1},
+		{date(2023, time.January, 30), 0}, // the 31st counts as the 30th
+		{date(2023, time.January, 31), 1},
+		{date(2023, time.February, 28), 3}, // up to the 30th
+		{date(2024, time.February, 28), 1}, // leap year
+		{date(2024, time.February, 29), 2},
+		{date(2023, time.April, 30), 1}, // 30-day month
+		{date(2023, time.December, 31), 1},
+	}
+	for _, tt := range tests {
+		days, year, err := Days(model.DayCount30360, tt.day)
+		require.NoError(t, err)
+		assert.Equal(t, tt.days, days, tt.day.Format(time.DateOnly))
+		assert.Equal(t, int64(360), year)
+	}
+
+	_, _, err := Days("ACT/ACT", date(2024, time.January, 1))
+	assert.Error(t, err)
+}
+
+func TestAccrual(t *testing.T) {
+	t.Run("30/360 accrues a twelfth of the rate every month", func(t *testing.T) {
+		for _, month := range []time.Time{date(2023, time.January, 1), date(2023, time.February, 1), date(2024, time.February, 1), date(2023, time.April, 1)} {
+			sum := accrue(t, "1200", "3", model.DayCount30360, month, month.AddDate(0, 1, 0))
+			assert.Equal(t, "3", sum.String(), month.Format("2006-01"))
+		}
+	})
+
+	t.Run("30/360 accrues the rate in a year", func(t *testing.T) {
+		sum := accrue(t, "1000", "5", model.DayCount30360, date(2024, time.January, 1), date(2025, time.January, 1))
+		assert.Equal(t, "50", sum.String())
+	})
+
+	t.Run("ACT/365 accrues by calendar day", func(t *testing.T) {
+		sum := accrue(t, "365000", "1", model.DayCountACT365, date(2023, time.February, 1), date(2023, time.March, 1))
+		assert.Equal(t, "280", sum.String())
+
+		// A leap year has one day more to accrue
+		sum = accrue(t, "365000", "1", model.DayCountACT365, date(2024, time.January, 1), date(2025, time.January, 1))
+		assert.Equal(t, "3660", sum.String())
+	})
+
+	t.Run("one day rounds to Places", func(t *testing.T) {
+		a, err := Accrue(decimal.RequireFromString("100.00001"), decimal.RequireFromString("2.5"), model.DayCountACT365, date(2024, time.March, 31))
+		require.NoError(t, err)
+		assert.Equal(t, "0.006849315753424658", a.Amount().String())
+	})
+
+	t.Run("a rate change mid-month accrues each part at its rate", func(t *testing.T) {
+		var accruals []Accrual
+		for day := date(2023, time.June, 1); day.Month() == time.June; day = day.AddDate(0, 0, 1) {
+			rate, dayCount := "2", model.DayCount30360
+			if day.Day() > 15 {
+				rate, dayCount = "4", model.DayCountACT365
+			}
+			a, err := Accrue(decimal.NewFromInt(3650), decimal.RequireFromString(rate), dayCount, day)
+			require.NoError(t, err)
+			accruals = append(accruals, a)
+		}
+		// 3650 × 2% × 15/360 + 3650 × 4% × 15/365
+		assert.Equal(t, "9.041666666666666667", Sum(accruals).String())
+	})
+}
+
+func TestDayAndMonth(t *testing.T) {
+	at := time.Date(2024, time.March, 1, 0, 30, 0, 0, time.FixedZone("CET", 3600))
+	assert.Equal(t, date(2024, time.February, 29), Day(at))
+	assert.Equal(t, date(2024, time.February, 1), Month(at))
+}
@@ -0,0 +1,110 @@
+// Package metrics is a minimal registry of counters and gauges exposed in the
+// Prometheus text format, so the service can be scraped without extra dependencies.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// metric is one registered counter or gauge.
+type metric interface {
+	describe() (name, help, kind string)
+	value() float64
+}
+
+var (
+	mu       sync.Mutex
+	registry = map[string]metric{}
+)
+
+func register(m metric) {
+	name, _, _ := m.describe()
+	mu.Lock()
+	defer mu.Unlock()
+	if _, ok := registry[name]; ok {
+		panic(fmt.Sprintf("metrics: %s registered twice", name))
+	}
+	registry[name] = m
+}
+
+// Counter is a monotonically increasing count.
+type Counter struct {
+	name, help string
+	n          atomic.Uint64
+}
+
+// NewCounter registers a counter. Names must be unique.
+func NewCounter(name, help string) *Counter {
+	c := &Counter{name: name, help: help}
+	register(c)
+	return c
+}
+
+// Inc adds one to the counter.
+func (c *Counter) Inc() { c.n.Add(1) }
+
+// Add adds n to the counter.
+func (c *Counter) Add(n uint64) { c.n.Add(n) }
+
+// Value returns the current count.
+func (c *Counter) Value() uint64 { return c.n.Load() }
+
+func (c *Counter) describe() (string, string, string) { return c.name, c.help, "counter" }
+func (c *Counter) value() float64                     { return float64(c.n.Load()) }
+
+// Gauge is a value that can go up and down.
+type Gauge struct {
+	name, help string
+	bits       atomic.Uint64
+}
+
+// NewGauge registers a gauge. Names must be unique.
+func NewGauge(name, help string) *Gauge {
+	g := &Gauge{name: name, help: help}
+	register(g)
+	return g
+}
+
+// Set replaces the gauge's value.
+func (g *Gauge) Set(v float64) { g.bits.Store(math.Float64bits(v)) }
+
+// Value returns the current value.
+func (g *Gauge) Value() float64 { return math.Float64frombits(g.bits.Load()) }
+
+func (g *Gauge) describe() (string, string, string) { return g.name, g.help, "gauge" }
+func (g *Gauge) value() float64                     { return g.Value() }
+
+// Write writes every registered metric in the Prometheus text exposition format, sorted by name.
+func Write(w io.Writer) error {
+	mu.Lock()
+	metrics := make([]metric, 0, len(registry))
+	for _, m := range registry {
+		metrics = append(metrics, m)
+	}
+	mu.Unlock()
+	sort.Slice(metrics, func(i, j int) bool {
+		a, _, _ := metrics[i].describe()
+		b, _, _ := metrics[j].describe()
+		return a < b
+	})
+
+	for _, m := range metrics {
+		name, help, kind := m.describe()
+		if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n%s %g\n", name, help, name, kind, name, m.value()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Handler serves the registered metrics for scraping.
+func Handler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	Write(w)
+}
@@ -0,0 +1,26 @@
+package metrics
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWrite(t *testing.T) {
+	c := NewCounter("test_runs_total", "Runs so far.")
+	g := NewGauge("test_last_mismatches", "Mismatches in the last run.")
+	c.Inc()
+	c.Add(2)
+	g.Set(4)
+
+	var buf bytes.Buffer
+	require.NoError(t, Write(&buf))
+
+	assert.Contains(t, buf.String(), "# HELP test_last_mismatches Mismatches in the last run.\n# TYPE test_last_mismatches gauge\ntest_last_mismatches 4\n")
+	assert.Contains(t, buf.String(), "# TYPE test_runs_total counter\ntest_runs_total 3\n")
+	assert.Less(t, bytes.Index(buf.Bytes(), []byte("test_last_mismatches")), bytes.Index(buf.Bytes(), []byte("test_runs_total")))
+
+	assert.Panics(t, func() { NewCounter("test_runs_total", "again") })
+}
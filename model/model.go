@@ -1,6 +1,12 @@
 package model
 
-import "github.com/shopspring/decimal"
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
 
 // Package model defines the data structures used in the banking application.
 
@@ -26,21 +32,821 @@ import "github.com/shopspring/decimal"
 // Hence we use the "github.com/shopspring/decimal" package instead of float64 to ensure that all monetary values are
 // handled with the necessary precision and accuracy.
 
+// Account statuses. Frozen accounts can neither send nor receive transfers.
+// Read-only accounts are blocked the same way, but by reconciliation after a
+// mismatch, pending investigation; reactivating them is a manual decision.
+const (
+	AccountStatusActive   = "active"
+	AccountStatusFrozen   = "frozen"
+	AccountStatusReadOnly = "read_only"
+)
+
+// MaxPageSize caps the number of accounts returned by a single list call.
+const MaxPageSize = 1000
+
+// DefaultCurrency is the ISO 4217 code for "no currency", assigned to accounts
+// created without one. It keeps the original single-currency behaviour.
+const DefaultCurrency = "XXX"
+
+// IsCurrencyCode reports whether c looks like an ISO 4217 code: three upper-case letters.
+func IsCurrencyCode(c string) bool {
+	if len(c) != 3 {
+		return false
+	}
+	for _, r := range c {
+		if r < 'A' || r > 'Z' {
+			return false
+		}
+	}
+	return true
+}
+
 // Account represents a bank account with its ID and balance.
 type Account struct {
 	AccountID int64           `json:"account_id"`
 	Balance   decimal.Decimal `json:"balance"`
+	Currency  string          `json:"currency,omitempty"`
+	OwnerName string          `json:"owner_name,omitempty"`
+	Status    string          `json:"status,omitempty"`
+	CreatedAt time.Time       `json:"created_at,omitzero"`
 }
 
+// Sort orders accepted by AccountFilter.Sort. Ties are always broken by account ID.
+const (
+	SortAccountID = "account_id"
+	SortBalance   = "balance"
+	SortCreatedAt = "created_at"
+)
+
+// AccountFilter selects a page of accounts. Zero-valued fields do not filter.
+type AccountFilter struct {
+	AfterID int64     // return accounts with an ID greater than this
+	Limit   int       // page size; 0 or more than MaxPageSize means MaxPageSize
+	AsOf    time.Time // if set, report balances as of this instant and skip accounts created later
+
+	Status        string
+	Currency      string
+	MinBalance    *decimal.Decimal // inclusive
+	MaxBalance    *decimal.Decimal // inclusive
+	CreatedFrom   time.Time        // inclusive
+	CreatedBefore time.Time        // exclusive
+
+	Sort string // SortAccountID (default), SortBalance or SortCreatedAt
+	Desc bool
+
+	// Keyset pagination: at most one of After and Before is set. After returns the
+	// page following the cursor position in sort order, Before the page preceding it.
+	// Either way the accounts come back in sort order.
+	After  *AccountCursor
+	Before *AccountCursor
+}
+
+// AccountCursor is a position in a sorted account listing: the sort key of a row and
+// its account ID as the tie-breaker. Only the key of the filter's Sort is used.
+type AccountCursor struct {
+	AccountID int64
+	Balance   decimal.Decimal
+	CreatedAt time.Time
+}
+
+// CursorOf returns the position of acc in a listing.
+func CursorOf(acc Account) *AccountCursor {
+	return &AccountCursor{AccountID: acc.AccountID, Balance: acc.Balance, CreatedAt: acc.CreatedAt}
+}
+
+// AccountPage is one page of accounts. Next and Prev are the positions to pass as
+// AccountFilter.After and AccountFilter.Before for the neighbouring pages; they are
+// nil when there is no such page. The HTTP API exposes them as opaque cursors.
+type AccountPage struct {
+	Accounts   []Account      `json:"accounts"`
+	Next       *AccountCursor `json:"-"`
+	Prev       *AccountCursor `json:"-"`
+	NextCursor string         `json:"next_cursor,omitempty"`
+	PrevCursor string         `json:"prev_cursor,omitempty"`
+}
+
+// ReconciliationReport is the outcome of checking stored balances against the ledger.
+type ReconciliationReport struct {
+	ReportID         int64                    `json:"report_id"`
+	CreatedAt        time.Time                `json:"created_at"`
+	AccountsChecked  int64                    `json:"accounts_checked"`
+	TotalBalance     decimal.Decimal          `json:"total_balance"`
+	Totals           []CurrencyTotal          `json:"totals"`
+	Mismatches       []ReconciliationMismatch `json:"mismatches"`
+	ReadOnlyAccounts []int64                  `json:"read_only_accounts,omitempty"` // accounts switched to read-only by this run
+}
+
+// CurrencyTotal is the money held in one currency. Transfers only move money, so
+// Balance must equal OpeningBalance, the sum of every account's opening balance.
+type CurrencyTotal struct {
+	Currency       string          `json:"currency"`
+	OpeningBalance decimal.Decimal `json:"opening_balance"`
+	Balance        decimal.Decimal `json:"balance"`
+}
+
+// ReconciliationMismatch describes one failed check. AccountID is 0 for a check
+// on the total of a currency rather than on a single account.
+type ReconciliationMismatch struct {
+	AccountID       int64           `json:"account_id,omitempty"`
+	Currency        string          `json:"currency,omitempty"`
+	StoredBalance   decimal.Decimal `json:"stored_balance"`
+	ExpectedBalance decimal.Decimal `json:"expected_balance"`
+	Reason          string          `json:"reason"`
+}
+
+// Reasons reported in a ReconciliationMismatch.
+const (
+	ReasonLedgerDrift     = "balance does not match ledger"
+	ReasonNegativeBalance = "negative balance"
+	ReasonTotalChanged    = "total balance differs from total opening balance"
+)
+
+// ReconcileOptions control what a reconciliation run does besides reporting.
+type ReconcileOptions struct {
+	ReadOnly bool // switch active accounts with a mismatch to AccountStatusReadOnly
+}
+
+// MaxDecimalPlaces matches the scale of the NUMERIC(19, 5) balance column.
+// Amounts with more places would be silently rounded by the database.
+const MaxDecimalPlaces = 5
+
 // CreateAccountRequest defines the expected JSON body for creating an account.
 type CreateAccountRequest struct {
-	AccountID      int64           `json:"account_id"`
-	InitialBalance decimal.Decimal `json:"initial_balance"`
+	AccountID      int64           `json:"account_id" validate:"min=1"`
+	InitialBalance decimal.Decimal `json:"initial_balance" validate:"nonnegative,maxdp=5"`
+	Currency       string          `json:"currency,omitempty" validate:"currency"` // defaults to DefaultCurrency
+	OwnerName      string          `json:"owner_name,omitempty" validate:"maxlen=200"`
+}
+
+// Outcomes of one row of a bulk account import.
+const (
+	BulkStatusCreated = "created"
+	BulkStatusExists  = "exists"
+	BulkStatusInvalid = "invalid"
+)
+
+// BulkAccountResult is the outcome of one row of a bulk account import. Row is the
+// 1-based position of the row in the input: the array index plus one for a JSON
+// array, the line number for NDJSON and CSV.
+type BulkAccountResult struct {
+	Row       int    `json:"row"`
+	AccountID int64  `json:"account_id,omitempty"`
+	Status    string `json:"status"`
+	Error     string `json:"error,omitempty"`
+}
+
+// BulkAccountReport is the per-row report of a bulk account import.
+type BulkAccountReport struct {
+	Created int                 `json:"created"`
+	Exists  int                 `json:"exists"`
+	Invalid int                 `json:"invalid"`
+	Results []BulkAccountResult `json:"results"`
+}
+
+// Add records a row's result and counts its outcome.
+func (r *BulkAccountReport) Add(res BulkAccountResult) {
+	switch res.Status {
+	case BulkStatusCreated:
+		r.Created++
+	case BulkStatusExists:
+		r.Exists++
+	case BulkStatusInvalid:
+		r.Invalid++
+	}
+	r.Results = append(r.Results, res)
 }
 
 // TransactionRequest defines the expected JSON body for submitting a transaction.
 type TransactionRequest struct {
-	SourceAccountID      int64           `json:"source_account_id"`
-	DestinationAccountID int64           `json:"destination_account_id"`
-	Amount               decimal.Decimal `json:"amount"`
+	SourceAccountID      int64           `json:"source_account_id" validate:"min=1"`
+	DestinationAccountID int64           `json:"destination_account_id" validate:"min=1,nefield=SourceAccountID"`
+	Amount               decimal.Decimal `json:"amount" validate:"positive,maxdp=5"`
+}
+
+// Journal entry kinds.
+const (
+	EntryKindTransfer = "transfer"
+	EntryKindJournal  = "journal"
+	EntryKindFee      = "fee"
+	EntryKindInterest = "interest"
+)
+
+// Posting directions. A debit takes money out of an account, a credit puts money in.
+const (
+	DirectionDebit  = "debit"
+	DirectionCredit = "credit"
+)
+
+// Posting is one leg of a journal entry. Amount is signed: negative for a debit,
+// positive for a credit. The postings of an entry always sum to zero.
+type Posting struct {
+	PostingID int64           `json:"posting_id"`
+	EntryID   int64           `json:"entry_id"`
+	Kind      string          `json:"kind"`
+	AccountID int64           `json:"account_id"`
+	Amount    decimal.Decimal `json:"amount"`
+	Currency  string          `json:"currency"`
+	Direction string          `json:"direction"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// DirectionOf returns the direction of a signed posting amount.
+func DirectionOf(amount decimal.Decimal) string {
+	if amount.IsNegative() {
+		return DirectionDebit
+	}
+	return DirectionCredit
+}
+
+// PostingFilter selects a page of an account's postings ordered by posting ID.
+type PostingFilter struct {
+	AfterID int64 // return postings with an ID greater than this
+	Limit   int   // page size; 0 or more than MaxPageSize means MaxPageSize
+}
+
+// AccountLedger is an account's balance together with the postings behind it.
+// OpeningBalance plus the amounts of all postings equals Balance.
+type AccountLedger struct {
+	AccountID      int64           `json:"account_id"`
+	OpeningBalance decimal.Decimal `json:"opening_balance"`
+	Balance        decimal.Decimal `json:"balance"`
+	Postings       []Posting       `json:"postings"`
+	NextAfter      *int64          `json:"next_after,omitempty"`
+}
+
+// StatementPeriod selects the postings on a statement by creation time. A zero
+// From starts at the account's creation, a zero To runs up to now.
+type StatementPeriod struct {
+	From time.Time // inclusive
+	To   time.Time // exclusive
+}
+
+// Statement describes an account statement: the period it covers and the
+// balances at either end. OpeningBalance plus the amounts of the postings in the
+// period equals ClosingBalance; for a period running up to now, ClosingBalance
+// is the account's stored balance.
+type Statement struct {
+	AccountID      int64
+	Currency       string
+	From           time.Time // inclusive
+	To             time.Time // exclusive
+	OpeningBalance decimal.Decimal
+	ClosingBalance decimal.Decimal
+	CreatedAt      time.Time
+}
+
+// StatementWriter renders a statement as it is read: Begin is called once,
+// then Movement for each posting in the period in order, then End.
+type StatementWriter interface {
+	Begin(s *Statement) error
+	Movement(p Posting) error
+	End() error
+}
+
+// PostingRequest is one leg of a JournalEntryRequest. A negative amount debits
+// the account, a positive amount credits it. Currency must match the account's currency.
+type PostingRequest struct {
+	AccountID int64           `json:"account_id" validate:"min=1"`
+	Amount    decimal.Decimal `json:"amount" validate:"nonzero,maxdp=5"`
+	Currency  string          `json:"currency" validate:"required,currency"`
+}
+
+// JournalEntryRequest defines the expected JSON body for posting an N-leg journal entry.
+// It generalises TransactionRequest: fees, splits and FX are entries with more than two legs.
+type JournalEntryRequest struct {
+	Postings []PostingRequest `json:"postings" validate:"minlen=2"`
+}
+
+// validateSelf checks that the postings sum to zero in every currency.
+func (r JournalEntryRequest) validateSelf() ValidationErrors {
+	sums := map[string]decimal.Decimal{}
+	var currencies []string
+	for _, p := range r.Postings {
+		if _, ok := sums[p.Currency]; !ok {
+			currencies = append(currencies, p.Currency)
+		}
+		sums[p.Currency] = sums[p.Currency].Add(p.Amount)
+	}
+
+	var errs ValidationErrors
+	for _, c := range currencies {
+		if !sums[c].IsZero() {
+			errs = append(errs, FieldError{
+				Field:   "postings",
+				Rule:    "balanced",
+				Message: fmt.Sprintf("%s postings sum to %s instead of zero", c, sums[c]),
+			})
+		}
+	}
+	return errs
+}
+
+// Legs returns the transfers the entry amounts to: for every account it debits
+// and every other account it credits in the same currency, a transfer of the
+// smaller of the two totals, in the order the accounts first appear. An entry
+// with one debit and one credit is a single transfer of its amount.
+func (r JournalEntryRequest) Legs() []TransactionRequest {
+	type total struct {
+		accountID int64
+		currency  string
+		amount    decimal.Decimal
+	}
+	var debits, credits []total
+	add := func(totals []total, p PostingRequest, amount decimal.Decimal) []total {
+		for i, t := range totals {
+			if t.accountID == p.AccountID && t.currency == p.Currency {
+				totals[i].amount = t.amount.Add(amount)
+				return totals
+			}
+		}
+		return append(totals, total{p.AccountID, p.Currency, amount})
+	}
+	for _, p := range r.Postings {
+		if p.Amount.IsNegative() {
+			debits = add(debits, p, p.Amount.Neg())
+		} else {
+			credits = add(credits, p, p.Amount)
+		}
+	}
+
+	var legs []TransactionRequest
+	for _, d := range debits {
+		for _, c := range credits {
+			if c.currency == d.currency && c.accountID != d.accountID {
+				legs = append(legs, TransactionRequest{SourceAccountID: d.accountID, DestinationAccountID: c.accountID, Amount: decimal.Min(d.amount, c.amount)})
+			}
+		}
+	}
+	return legs
+}
+
+// JournalEntry is a recorded journal entry with its postings.
+type JournalEntry struct {
+	EntryID   int64     `json:"entry_id"`
+	Kind      string    `json:"kind"`
+	CreatedAt time.Time `json:"created_at"`
+	Postings  []Posting `json:"postings"`
+}
+
+// Transfer job statuses. A job is pending until a worker picks up its first line
+// and completed once no line is pending.
+const (
+	JobStatusPending   = "pending"
+	JobStatusRunning   = "running"
+	JobStatusCompleted = "completed"
+)
+
+// Transfer job line statuses.
+const (
+	LineStatusPending   = "pending"
+	LineStatusSucceeded = "succeeded"
+	LineStatusFailed    = "failed"
+)
+
+// TransferJob is an uploaded transfer file and the progress of its lines.
+type TransferJob struct {
+	JobID      int64      `json:"job_id"`
+	Status     string     `json:"status"`
+	CreatedAt  time.Time  `json:"created_at"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	TotalLines int64      `json:"total_lines"`
+	Pending    int64      `json:"pending"`
+	Succeeded  int64      `json:"succeeded"`
+	Failed     int64      `json:"failed"`
+}
+
+// TransferJobLine is one transfer of a job and its outcome. Line is the line
+// number in the uploaded file. Lines that fail validation on upload are stored
+// as failed straight away and never executed.
+type TransferJobLine struct {
+	Line int `json:"line"`
+	TransactionRequest
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// TransferJobLineFilter selects a page of a job's lines in line order.
+type TransferJobLineFilter struct {
+	AfterLine int // return lines after this one
+	Limit     int // page size; 0 or more than MaxPageSize means MaxPageSize
+}
+
+// Transfer approval statuses. A held transfer is pending_approval until a second
+// principal approves (it is then executed) or rejects it, or until it expires.
+const (
+	ApprovalStatusPending  = "pending_approval"
+	ApprovalStatusApproved = "approved"
+	ApprovalStatusRejected = "rejected"
+	ApprovalStatusExpired  = "expired"
+)
+
+// TransferApproval is a transfer above the approval threshold, held until a
+// principal other than the one who requested it decides on it. A held journal
+// entry has its Postings instead, and a zero TransactionRequest.
+type TransferApproval struct {
+	ApprovalID int64 `json:"approval_id"`
+	TransactionRequest
+	Postings    []PostingRequest `json:"postings,omitempty"`
+	Status      string           `json:"status"`
+	RequestedBy string           `json:"requested_by"`
+	RequestedAt time.Time        `json:"requested_at"`
+	ExpiresAt   time.Time        `json:"expires_at"`
+	DecidedBy   string           `json:"decided_by,omitempty"`
+	DecidedAt   *time.Time       `json:"decided_at,omitempty"`
+}
+
+// ApprovalPage is a page of transfer approvals. NextAfter is set when more may
+// follow; pass it as the "after" query parameter to fetch them.
+type ApprovalPage struct {
+	Approvals []TransferApproval `json:"approvals"`
+	NextAfter *int64             `json:"next_after,omitempty"`
+}
+
+// ApprovalFilter selects a page of transfer approvals ordered by ID.
+type ApprovalFilter struct {
+	Status  string // only approvals with this status; "" for all
+	AfterID int64  // return approvals with an ID greater than this
+	Limit   int    // page size; 0 or more than MaxPageSize means MaxPageSize
+}
+
+// DefaultTier is the limit tier of accounts that were never assigned one.
+const DefaultTier = "standard"
+
+// IsTierName reports whether t is a valid tier name: 1 to 32 lower-case letters,
+// digits, '-' or '_'.
+func IsTierName(t string) bool {
+	if len(t) == 0 || len(t) > 32 {
+		return false
+	}
+	for _, r := range t {
+		if !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-' || r == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+// Names of the transfer limits, as reported when one is exceeded.
+const (
+	LimitMaxAmount     = "max_amount"
+	LimitDailyAmount   = "daily_amount"
+	LimitMonthlyAmount = "monthly_amount"
+	LimitHourlyCount   = "hourly_count"
+)
+
+// TransferLimits cap the outgoing transfers of an account. A nil field is no limit.
+// Daily and monthly totals cover the current UTC calendar day and month; the
+// hourly count covers the last 60 minutes.
+type TransferLimits struct {
+	MaxAmount     *decimal.Decimal `json:"max_amount,omitempty" validate:"nonnegative,maxdp=5"`
+	DailyAmount   *decimal.Decimal `json:"daily_amount,omitempty" validate:"nonnegative,maxdp=5"`
+	MonthlyAmount *decimal.Decimal `json:"monthly_amount,omitempty" validate:"nonnegative,maxdp=5"`
+	HourlyCount   *int64           `json:"hourly_count,omitempty" validate:"min=0"`
+}
+
+// IsZero reports whether l sets no limit at all.
+func (l TransferLimits) IsZero() bool {
+	return l.MaxAmount == nil && l.DailyAmount == nil && l.MonthlyAmount == nil && l.HourlyCount == nil
+}
+
+// TierLimits are the transfer limits shared by every account of a tier.
+type TierLimits struct {
+	Tier   string         `json:"tier"`
+	Limits TransferLimits `json:"limits"`
+}
+
+// AccountLimits are the transfer limits of one account: those of its tier, with
+// each limit the account overrides replaced. Effective is what is enforced.
+type AccountLimits struct {
+	AccountID int64          `json:"account_id"`
+	Tier      string         `json:"tier"`
+	Overrides TransferLimits `json:"overrides"`
+	Effective TransferLimits `json:"effective"`
+}
+
+// AccountLimitsRequest assigns an account its tier and per-account overrides.
+type AccountLimitsRequest struct {
+	Tier      string         `json:"tier,omitempty" validate:"tier"` // defaults to DefaultTier
+	Overrides TransferLimits `json:"overrides"`
+}
+
+// Fee schedule types.
+const (
+	FeeFlat       = "flat"
+	FeePercentage = "percentage"
+	FeeTiered     = "tiered"
+)
+
+// Where the fee schedule of a transfer comes from: the source account's own
+// schedule, or that of its tier.
+const (
+	FeeSourceAccount = "account"
+	FeeSourceTier    = "tier"
+)
+
+// FeeSchedule prices the transfers of an account, on top of their amount. A
+// flat fee is Flat, a percentage fee is Percent percent of the amount, and a
+// tiered fee is the Flat plus Percent of the first band the amount falls in.
+// The fee is then raised to Min and capped at Max. It is credited to
+// RevenueAccountID, which must hold the currency of the accounts it charges.
+type FeeSchedule struct {
+	Type             string           `json:"type" validate:"required"`
+	RevenueAccountID int64            `json:"revenue_account_id" validate:"min=1"`
+	Flat             decimal.Decimal  `json:"flat,omitzero" validate:"nonnegative,maxdp=5"`
+	Percent          decimal.Decimal  `json:"percent,omitzero" validate:"nonnegative"`
+	Bands            []FeeBand        `json:"bands,omitempty"`
+	Min              *decimal.Decimal `json:"min,omitempty" validate:"nonnegative,maxdp=5"`
+	Max              *decimal.Decimal `json:"max,omitempty" validate:"nonnegative,maxdp=5"`
+}
+
+// FeeBand is one band of a tiered fee schedule. It covers amounts up to and
+// including UpTo, and above the band before it; the last band has no UpTo.
+type FeeBand struct {
+	UpTo    *decimal.Decimal `json:"up_to,omitempty" validate:"positive,maxdp=5"`
+	Flat    decimal.Decimal  `json:"flat,omitzero" validate:"nonnegative,maxdp=5"`
+	Percent decimal.Decimal  `json:"percent,omitzero" validate:"nonnegative"`
+}
+
+// validateSelf checks that the schedule sets only the fields of its type, that
+// the bands of a tiered schedule ascend, and that Min does not exceed Max.
+func (s FeeSchedule) validateSelf() ValidationErrors {
+	var errs ValidationErrors
+	fail := func(field, rule, msg string) {
+		errs = append(errs, FieldError{Field: field, Rule: rule, Message: msg})
+	}
+	hundred := decimal.NewFromInt(100)
+	switch s.Type {
+	case FeeFlat:
+		if !s.Percent.IsZero() {
+			fail("percent", "type", "is not used by a flat fee")
+		}
+	case FeePercentage:
+		if !s.Flat.IsZero() {
+			fail("flat", "type", "is not used by a percentage fee")
+		}
+	case FeeTiered:
+		if !s.Flat.IsZero() || !s.Percent.IsZero() {
+			fail("bands", "type", "a tiered fee sets flat and percent per band")
+		}
+		if len(s.Bands) == 0 {
+			fail("bands", "minlen", "a tiered fee needs at least one band")
+		}
+		for i, b := range s.Bands {
+			field := fmt.Sprintf("bands[%d].up_to", i)
+			switch {
+			case i == len(s.Bands)-1 && b.UpTo != nil:
+				fail(field, "bands", "must be omitted on the last band")
+			case i < len(s.Bands)-1 && b.UpTo == nil:
+				fail(field, "bands", "is required on every band but the last")
+			case i > 0 && b.UpTo != nil && !b.UpTo.GreaterThan(*s.Bands[i-1].UpTo):
+				fail(field, "bands", "must be greater than the up_to of the band before")
+			}
+			if b.Percent.GreaterThan(hundred) {
+				fail(fmt.Sprintf("bands[%d].percent", i), "percent", "must be at most 100")
+			}
+		}
+	default:
+		fail("type", "type", "must be one of flat, percentage, tiered")
+	}
+	if s.Type != FeeTiered && len(s.Bands) > 0 {
+		fail("bands", "type", "are only used by a tiered fee")
+	}
+	if s.Percent.GreaterThan(hundred) {
+		fail("percent", "percent", "must be at most 100")
+	}
+	if s.Min != nil && s.Max != nil && s.Min.GreaterThan(*s.Max) {
+		fail("min", "min", "cannot exceed max")
+	}
+	return errs
+}
+
+// Fee computes the fee the schedule charges on amount, rounded to
+// MaxDecimalPlaces. The breakdown's Source and Tier are left to the caller.
+func (s FeeSchedule) Fee(amount decimal.Decimal) FeeBreakdown {
+	flat, percent := s.Flat, s.Percent
+	if s.Type == FeeTiered {
+		for _, b := range s.Bands {
+			flat, percent = b.Flat, b.Percent
+			if b.UpTo != nil && amount.LessThanOrEqual(*b.UpTo) {
+				break
+			}
+		}
+	}
+	f := FeeBreakdown{
+		Type:             s.Type,
+		RevenueAccountID: s.RevenueAccountID,
+		Flat:             flat,
+		Percentage:       amount.Mul(percent).Div(decimal.NewFromInt(100)).Round(MaxDecimalPlaces),
+	}
+	fee := f.Flat.Add(f.Percentage)
+	if s.Min != nil && fee.LessThan(*s.Min) {
+		f.Adjustment = s.Min.Sub(fee)
+	}
+	if s.Max != nil && fee.GreaterThan(*s.Max) {
+		f.Adjustment = s.Max.Sub(fee)
+	}
+	f.Amount = fee.Add(f.Adjustment)
+	return f
+}
+
+// FeeBreakdown is how the fee of a transfer was computed: Flat plus
+// Percentage, the percentage part, plus Adjustment, which is positive when the
+// schedule's minimum raised the fee and negative when its cap lowered it.
+// Amount is the fee charged. EntryID is the journal entry of the fee.
+type FeeBreakdown struct {
+	Source           string          `json:"source"`
+	Tier             string          `json:"tier,omitempty"`
+	Type             string          `json:"type"`
+	Flat             decimal.Decimal `json:"flat"`
+	Percentage       decimal.Decimal `json:"percentage"`
+	Adjustment       decimal.Decimal `json:"adjustment"`
+	Amount           decimal.Decimal `json:"amount"`
+	RevenueAccountID int64           `json:"revenue_account_id"`
+	EntryID          int64           `json:"entry_id"`
+}
+
+// Transfer is an executed transfer. Fee is nil when no fee was charged. Total
+// is what was debited from the source account: the amount plus the fee.
+type Transfer struct {
+	EntryID int64 `json:"entry_id"`
+	TransactionRequest
+	Fee       *FeeBreakdown   `json:"fee,omitempty"`
+	Total     decimal.Decimal `json:"total"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// TierFees is the fee schedule shared by every account of a tier.
+type TierFees struct {
+	Tier     string      `json:"tier"`
+	Schedule FeeSchedule `json:"schedule"`
+}
+
+// AccountFees is the fee schedule of one account: its own, Override, if it has
+// one, or else its tier's. Effective is what is charged; nil charges no fee.
+type AccountFees struct {
+	AccountID int64        `json:"account_id"`
+	Tier      string       `json:"tier"`
+	Override  *FeeSchedule `json:"override,omitempty"`
+	Effective *FeeSchedule `json:"effective,omitempty"`
+}
+
+// FeeScheduleRequest sets a fee schedule, or removes it when Schedule is omitted.
+type FeeScheduleRequest struct {
+	Schedule *FeeSchedule `json:"schedule,omitempty"`
+}
+
+// MaxShards is the most shard rows the balance of a hot account can be spread over.
+const MaxShards = 64
+
+// AccountShards is how the balance of an account is spread over shard rows. A
+// hot account keeps its balance in Shards rows, so that concurrent credits lock
+// different rows; Balance is their sum. An account that is not hot has no
+// shards and keeps its balance in the account row.
+type AccountShards struct {
+	AccountID     int64             `json:"account_id"`
+	Shards        int               `json:"shards"`
+	Balance       decimal.Decimal   `json:"balance"`
+	ShardBalances []decimal.Decimal `json:"shard_balances"`
+}
+
+// AccountShardsRequest makes an account hot with Shards shard rows, or, with
+// zero, moves its balance back into the account row.
+type AccountShardsRequest struct {
+	Shards int `json:"shards"`
+}
+
+// validateSelf checks the number of shards: one shard would only move the lock.
+func (r AccountShardsRequest) validateSelf() ValidationErrors {
+	if r.Shards != 0 && (r.Shards < 2 || r.Shards > MaxShards) {
+		return ValidationErrors{{Field: "shards", Rule: "shards", Message: fmt.Sprintf("must be 0, or from 2 to %d", MaxShards)}}
+	}
+	return nil
+}
+
+// Day-count conventions of interest rates. Under ACT/365 every calendar day
+// accrues 1/365 of the annual rate, leap days included. Under 30/360 every month
+// accrues 30/360 of it, whatever its length.
+const (
+	DayCountACT365 = "ACT/365"
+	DayCount30360  = "30/360"
+)
+
+// InterestRate is the annual interest rate of an account, in percent, and the
+// day-count convention it accrues under. Accrued is the interest accrued but
+// not posted yet; Carry is the fraction of the smallest amount a balance can
+// hold that was left over by the last posting.
+type InterestRate struct {
+	AccountID  int64           `json:"account_id"`
+	AnnualRate decimal.Decimal `json:"annual_rate"`
+	DayCount   string          `json:"day_count"`
+	Accrued    decimal.Decimal `json:"accrued"`
+	Carry      decimal.Decimal `json:"carry"`
+}
+
+// InterestRateRequest sets the interest rate of an account. A zero rate stops
+// accrual; interest accrued before is still posted.
+type InterestRateRequest struct {
+	AnnualRate decimal.Decimal `json:"annual_rate" validate:"nonnegative"`
+	DayCount   string          `json:"day_count" validate:"required"`
+}
+
+// validateSelf checks the day-count convention.
+func (r InterestRateRequest) validateSelf() ValidationErrors {
+	if r.DayCount != DayCountACT365 && r.DayCount != DayCount30360 {
+		return ValidationErrors{{Field: "day_count", Rule: "daycount", Message: "must be one of ACT/365, 30/360"}}
+	}
+	return nil
+}
+
+// InterestPosting is the interest of one account for one month, posted as a
+// transfer from the interest-expense account. EntryID is 0 when the interest
+// rounded down to nothing, and Error is set when the transfer failed; the
+// interest is then posted by a later run.
+type InterestPosting struct {
+	AccountID int64           `json:"account_id"`
+	Month     string          `json:"month"` // YYYY-MM
+	Amount    decimal.Decimal `json:"amount"`
+	EntryID   int64           `json:"entry_id,omitempty"`
+	Error     string          `json:"error,omitempty"`
+}
+
+// Transfer rule actions.
+const (
+	RuleAllow  = "allow"
+	RuleDeny   = "deny"
+	RuleReview = "review"
+)
+
+// Rule is a transfer rule: when its condition holds for a transfer, Action
+// decides it. Deny rules carry the Code reported to the client.
+type Rule struct {
+	Name   string `json:"name" yaml:"name"`
+	When   string `json:"when" yaml:"when"`
+	Action string `json:"action" yaml:"action"`
+	Code   string `json:"code,omitempty" yaml:"code,omitempty"`
+}
+
+// RuleDecision is the outcome of evaluating the transfer rules: the action and
+// code of the first rule that matched, or allow when none did. A dry run also
+// lists every rule that would fire and the rules that failed to evaluate.
+type RuleDecision struct {
+	Action string   `json:"action"`
+	Rule   string   `json:"rule,omitempty"`
+	Code   string   `json:"code,omitempty"`
+	Fired  []Rule   `json:"fired,omitempty"`
+	Errors []string `json:"errors,omitempty"`
+}
+
+// What happens to a transfer whose account or owner is on the blocklist.
+const (
+	ScreeningBlock  = "block"
+	ScreeningReview = "review"
+)
+
+// BlocklistEntry is one blocked party: an account, an owner name, or both.
+// List names the sanctions or internal list the entry comes from.
+type BlocklistEntry struct {
+	AccountID int64  `json:"account_id,omitempty"`
+	Name      string `json:"name,omitempty"`
+	List      string `json:"list,omitempty"`
+}
+
+// ScreeningMatch is an account of a transfer that matched a blocklist entry.
+// Score is the similarity of the owner names, from 0 to 1; it is 1 for a match
+// on the account ID.
+type ScreeningMatch struct {
+	AccountID int64          `json:"account_id"`
+	Entry     BlocklistEntry `json:"entry"`
+	Score     float64        `json:"score"`
+}
+
+// ScreeningHit is a transfer stopped by screening, as recorded in the audit log.
+// A journal entry stopped by screening has its Postings instead, and a zero
+// TransactionRequest.
+type ScreeningHit struct {
+	TransactionRequest
+	Postings []PostingRequest `json:"postings,omitempty"`
+	Action   string           `json:"action"`
+	Matches  []ScreeningMatch `json:"matches"`
+}
+
+// AuditRecord is one link of the tamper-evident audit log. Hash is the SHA-256 of
+// the other fields, including PrevHash, the hash of the record before it.
+type AuditRecord struct {
+	Seq       int64           `json:"seq"`
+	CreatedAt time.Time       `json:"created_at"`
+	Actor     string          `json:"actor"`
+	RequestID string          `json:"request_id,omitempty"`
+	Action    string          `json:"action"`
+	Before    json.RawMessage `json:"before"`
+	After     json.RawMessage `json:"after"`
+	PrevHash  string          `json:"prev_hash"`
+	Hash      string          `json:"hash"`
+}
+
+// AuditFilter selects a page of audit records in sequence order.
+type AuditFilter struct {
+	AfterSeq int64 // return records with a sequence number greater than this
+	Limit    int   // page size; 0 or more than MaxPageSize means MaxPageSize
 }
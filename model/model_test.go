@@ -115,3 +115,77 @@ func TestTransactionRequestJSON(t *testing.T) {
 		assert.Contains(t, err.Error(), "can't convert true to decimal")
 	})
 }
+
+// TestFeeScheduleFee tests fee computation for each schedule type, with a minimum and a cap.
+func TestFeeScheduleFee(t *testing.T) {
+	d := decimal.RequireFromString
+	ptr := func(s string) *decimal.Decimal { v := d(s); return &v }
+
+	tests := []struct {
+		name       string
+		schedule   FeeSchedule
+		amount     string
+		percentage string
+		adjustment string
+		fee        string
+	}{
+		{"flat", FeeSchedule{Type: FeeFlat, Flat: d("1.5")}, "100", "0", "0", "1.5"},
+		{"percentage", FeeSchedule{Type: FeePercentage, Percent: d("0.25")}, "1234.5", "3.08625", "0", "3.08625"},
+		{"percentage rounded", FeeSchedule{Type: FeePercentage, Percent: d("0.333")}, "0.01", "0.00003", "0", "0.00003"},
+		{"percentage raised to minimum", FeeSchedule{Type: FeePercentage, Percent: d("1"), Min: ptr("2")}, "50", "0.5", "1.5", "2"},
+		{"percentage lowered to cap", FeeSchedule{Type: FeePercentage, Percent: d("1"), Max: ptr("20")}, "5000", "50", "-30", "20"},
+		{"tiered first band", tieredFees(), "100", "0", "0", "1"},
+		{"tiered middle band", tieredFees(), "100.01", "0.50005", "0", "2.50005"},
+		{"tiered last band", tieredFees(), "10000", "10", "0", "10"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := tt.schedule.Fee(d(tt.amount))
+
+			assert.Equal(t, tt.schedule.Type, f.Type)
+			assert.Equal(t, tt.percentage, f.Percentage.String(), "percentage")
+			assert.Equal(t, tt.adjustment, f.Adjustment.String(), "adjustment")
+			assert.Equal(t, tt.fee, f.Amount.String(), "fee")
+			assert.True(t, f.Amount.Equal(f.Flat.Add(f.Percentage).Add(f.Adjustment)))
+		})
+	}
+}
+
+// tieredFees charges 1 up to 100, 2 plus 0.5% up to 1000 and 0.1% above.
+func tieredFees() FeeSchedule {
+	upTo100, upTo1000 := decimal.NewFromInt(100), decimal.NewFromInt(1000)
+	return FeeSchedule{Type: FeeTiered, Bands: []FeeBand{
+		{UpTo: &upTo100, Flat: decimal.NewFromInt(1)},
+		{UpTo: &upTo1000, Flat: decimal.NewFromInt(2), Percent: decimal.RequireFromString("0.5")},
+		{Percent: decimal.RequireFromString("0.1")},
+	}}
+}
+
+func TestJournalEntryRequestLegs(t *testing.T) {
+	posting := func(id int64, amount, currency string) PostingRequest {
+		return PostingRequest{AccountID: id, Amount: decimal.RequireFromString(amount), Currency: currency}
+	}
+	leg := func(from, to int64, amount string) TransactionRequest {
+		return TransactionRequest{SourceAccountID: from, DestinationAccountID: to, Amount: decimal.RequireFromString(amount)}
+	}
+
+	t.Run("two postings are one transfer", func(t *testing.T) {
+		req := JournalEntryRequest{Postings: []PostingRequest{posting(1, "-10", "EUR"), posting(2, "10", "EUR")}}
+		assert.Equal(t, []TransactionRequest{leg(1, 2, "10")}, req.Legs())
+	})
+
+	t.Run("split and FX", func(t *testing.T) {
+		req := JournalEntryRequest{Postings: []PostingRequest{
+			posting(1, "-100", "EUR"), posting(2, "99.5", "EUR"), posting(3, "0.5", "EUR"),
+			posting(4, "-30", "USD"), posting(4, "-20", "USD"), posting(5, "50", "USD"),
+		}}
+		legs := req.Legs()
+		require.Len(t, legs, 3)
+		assert.Equal(t, int64(2), legs[0].DestinationAccountID)
+		assert.Equal(t, "99.5", legs[0].Amount.String())
+		assert.Equal(t, int64(3), legs[1].DestinationAccountID)
+		assert.Equal(t, "0.5", legs[1].Amount.String())
+		assert.Equal(t, int64(4), legs[2].SourceAccountID)
+		assert.Equal(t, "50", legs[2].Amount.String(), "the debits of an account are summed")
+	})
+}
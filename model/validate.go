@@ -0,0 +1,220 @@
+package model
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/shopspring/decimal"
+)
+
+// Validation rules are declared on model fields with a `validate` struct tag,
+// for example `validate:"min=1"`. Several rules are separated by commas.
+//
+// Supported rules:
+//   - required:    the field must not be its zero value
+//   - min=N:       integer field must be >= N
+//   - positive:    decimal field must be > 0
+//   - nonnegative: decimal field must be >= 0
+//   - maxdp=N:     decimal field must have at most N decimal places
+//   - nefield=F:   field must differ from sibling field F
+//   - nonzero:     decimal field must not be 0
+//   - minlen=N:    slice field must have at least N elements
+//   - maxlen=N:    string field must have at most N characters
+//   - currency:    string field must be empty or an ISO 4217 code such as "EUR"
+//   - tier:        string field must be empty or a tier name (see IsTierName)
+//
+// Pointer fields are checked only when set. Nested structs are validated field by
+// field ("overrides.max_amount"; embedded structs add no prefix), and slices of structs element by element
+// ("postings[1].amount").
+// Rules that span several fields are implemented by a validateSelf method.
+// The JSON name of the field (from its `json` tag) is used in error messages.
+
+// FieldError describes one rule that a field failed.
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// ValidationErrors collects every FieldError found in a value.
+type ValidationErrors []FieldError
+
+func (v ValidationErrors) Error() string {
+	msgs := make([]string, len(v))
+	for i, fe := range v {
+		msgs[i] = fe.Field + ": " + fe.Message
+	}
+	return strings.Join(msgs, "; ")
+}
+
+var (
+	decimalType = reflect.TypeOf(decimal.Decimal{})
+	timeType    = reflect.TypeOf(time.Time{})
+)
+
+// selfValidator is implemented by types with rules that span several fields.
+// It runs only when the field-level rules pass.
+type selfValidator interface {
+	validateSelf() ValidationErrors
+}
+
+// Validate checks v (a struct or pointer to struct) against its `validate` tags.
+// It returns nil or a ValidationErrors listing every failing rule, not just the first.
+func Validate(v any) error {
+	errs := validateStruct(reflect.Indirect(reflect.ValueOf(v)), "")
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+func validateStruct(rv reflect.Value, prefix string) ValidationErrors {
+	if rv.Kind() != reflect.Struct {
+		return nil
+	}
+	rt := rv.Type()
+
+	var errs ValidationErrors
+	for i := 0; i < rt.NumField(); i++ {
+		sf := rt.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		fv := rv.Field(i)
+		field := prefix + jsonName(sf)
+		if fv.Kind() == reflect.Pointer {
+			if fv.IsNil() {
+				continue
+			}
+			fv = fv.Elem()
+		}
+		if tag := sf.Tag.Get("validate"); tag != "" {
+			for _, rule := range strings.Split(tag, ",") {
+				name, arg, _ := strings.Cut(strings.TrimSpace(rule), "=")
+				if msg := checkRule(rv, fv, name, arg); msg != "" {
+					errs = append(errs, FieldError{Field: field, Rule: name, Message: msg})
+				}
+			}
+		}
+		if fv.Kind() == reflect.Struct && fv.Type() != decimalType && fv.Type() != timeType {
+			nested := field + "."
+			if sf.Anonymous {
+				nested = prefix
+			}
+			errs = append(errs, validateStruct(fv, nested)...)
+		}
+		if fv.Kind() == reflect.Slice && fv.Type().Elem().Kind() == reflect.Struct && fv.Type().Elem() != decimalType {
+			for j := 0; j < fv.Len(); j++ {
+				errs = append(errs, validateStruct(fv.Index(j), fmt.Sprintf("%s[%d].", field, j))...)
+			}
+		}
+	}
+
+	if len(errs) == 0 {
+		if sv, ok := rv.Interface().(selfValidator); ok {
+			for _, fe := range sv.validateSelf() {
+				fe.Field = prefix + fe.Field
+				errs = append(errs, fe)
+			}
+		}
+	}
+	return errs
+}
+
+// checkRule returns a failure message, or "" if the rule holds.
+func checkRule(parent, fv reflect.Value, name, arg string) string {
+	switch name {
+	case "required":
+		if fv.IsZero() {
+			return "is required"
+		}
+	case "min":
+		n, err := strconv.ParseInt(arg, 10, 64)
+		if err != nil {
+			panic(fmt.Sprintf("validate: bad min argument %q", arg))
+		}
+		if fv.Int() < n {
+			return fmt.Sprintf("must be at least %d", n)
+		}
+	case "positive":
+		if !fv.Interface().(decimal.Decimal).IsPositive() {
+			return "must be positive"
+		}
+	case "nonnegative":
+		if fv.Interface().(decimal.Decimal).IsNegative() {
+			return "cannot be negative"
+		}
+	case "maxdp":
+		n, err := strconv.ParseInt(arg, 10, 32)
+		if err != nil {
+			panic(fmt.Sprintf("validate: bad maxdp argument %q", arg))
+		}
+		d := fv.Interface().(decimal.Decimal)
+		if !d.Equal(d.Truncate(int32(n))) {
+			return fmt.Sprintf("must have at most %d decimal places", n)
+		}
+	case "nefield":
+		other := parent.FieldByName(arg)
+		if !other.IsValid() {
+			panic(fmt.Sprintf("validate: unknown nefield %q", arg))
+		}
+		if fv.Type() == decimalType {
+			if fv.Interface().(decimal.Decimal).Equal(other.Interface().(decimal.Decimal)) {
+				return "must differ from " + jsonNameOf(parent.Type(), arg)
+			}
+		} else if fv.Interface() == other.Interface() {
+			return "must differ from " + jsonNameOf(parent.Type(), arg)
+		}
+	case "nonzero":
+		if fv.Interface().(decimal.Decimal).IsZero() {
+			return "must not be zero"
+		}
+	case "minlen":
+		n, err := strconv.Atoi(arg)
+		if err != nil {
+			panic(fmt.Sprintf("validate: bad minlen argument %q", arg))
+		}
+		if fv.Len() < n {
+			return fmt.Sprintf("must contain at least %d items", n)
+		}
+	case "maxlen":
+		n, err := strconv.Atoi(arg)
+		if err != nil {
+			panic(fmt.Sprintf("validate: bad maxlen argument %q", arg))
+		}
+		if utf8.RuneCountInString(fv.String()) > n {
+			return fmt.Sprintf("must be at most %d characters", n)
+		}
+	case "currency":
+		if c := fv.String(); c != "" && !IsCurrencyCode(c) {
+			return "must be a three-letter ISO 4217 code"
+		}
+	case "tier":
+		if t := fv.String(); t != "" && !IsTierName(t) {
+			return "must be 1 to 32 lower-case letters, digits, '-' or '_'"
+		}
+	default:
+		panic(fmt.Sprintf("validate: unknown rule %q", name))
+	}
+	return ""
+}
+
+func jsonName(sf reflect.StructField) string {
+	name, _, _ := strings.Cut(sf.Tag.Get("json"), ",")
+	if name == "" || name == "-" {
+		return sf.Name
+	}
+	return name
+}
+
+func jsonNameOf(t reflect.Type, field string) string {
+	sf, ok := t.FieldByName(field)
+	if !ok {
+		return field
+	}
+	return jsonName(sf)
+}
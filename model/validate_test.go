@@ -0,0 +1,143 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestValidate tests the declarative validation rules on the request types.
+func TestValidate(t *testing.T) {
+	t.Run("valid transaction request", func(t *testing.T) {
+		req := TransactionRequest{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.RequireFromString("0.00001")}
+		assert.NoError(t, Validate(req))
+	})
+
+	t.Run("valid create account request with zero balance", func(t *testing.T) {
+		req := &CreateAccountRequest{AccountID: 1, InitialBalance: decimal.Zero}
+		assert.NoError(t, Validate(req))
+	})
+
+	t.Run("every failing rule is reported", func(t *testing.T) {
+		// Arrange
+		req := TransactionRequest{SourceAccountID: 0, DestinationAccountID: 0, Amount: decimal.RequireFromString("1.000001")}
+
+		// Act
+		err := Validate(req)
+
+		// Assert
+		var verrs ValidationErrors
+		require.ErrorAs(t, err, &verrs)
+		assert.Equal(t, ValidationErrors{
+			{Field: "source_account_id", Rule: "min", Message: "must be at least 1"},
+			{Field: "destination_account_id", Rule: "min", Message: "must be at least 1"},
+			{Field: "destination_account_id", Rule: "nefield", Message: "must differ from source_account_id"},
+			{Field: "amount", Rule: "maxdp", Message: "must have at most 5 decimal places"},
+		}, verrs)
+	})
+
+	t.Run("negative initial balance", func(t *testing.T) {
+		err := Validate(CreateAccountRequest{AccountID: 5, InitialBalance: decimal.NewFromInt(-1)})
+		require.Error(t, err)
+		assert.Equal(t, "initial_balance: cannot be negative", err.Error())
+	})
+
+	t.Run("non-positive amount", func(t *testing.T) {
+		err := Validate(TransactionRequest{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.Zero})
+		require.Error(t, err)
+		assert.Equal(t, "amount: must be positive", err.Error())
+	})
+
+	t.Run("journal entry postings are validated element by element", func(t *testing.T) {
+		req := JournalEntryRequest{Postings: []PostingRequest{
+			{AccountID: 1, Amount: decimal.NewFromInt(-10), Currency: "EUR"},
+			{AccountID: 0, Amount: decimal.Zero, Currency: "eur"},
+		}}
+
+		err := Validate(req)
+
+		var verrs ValidationErrors
+		require.ErrorAs(t, err, &verrs)
+		assert.Equal(t, ValidationErrors{
+			{Field: "postings[1].account_id", Rule: "min", Message: "must be at least 1"},
+			{Field: "postings[1].amount", Rule: "nonzero", Message: "must not be zero"},
+			{Field: "postings[1].currency", Rule: "currency", Message: "must be a three-letter ISO 4217 code"},
+		}, verrs)
+	})
+
+	t.Run("nested limits are checked only when set", func(t *testing.T) {
+		negative := decimal.NewFromInt(-1)
+		count := int64(-2)
+		req := AccountLimitsRequest{Overrides: TransferLimits{DailyAmount: &negative, HourlyCount: &count}}
+
+		err := Validate(req)
+
+		var verrs ValidationErrors
+		require.ErrorAs(t, err, &verrs)
+		assert.Equal(t, ValidationErrors{
+			{Field: "overrides.daily_amount", Rule: "nonnegative", Message: "cannot be negative"},
+			{Field: "overrides.hourly_count", Rule: "min", Message: "must be at least 0"},
+		}, verrs)
+		assert.NoError(t, Validate(AccountLimitsRequest{}))
+	})
+
+	t.Run("journal entry needs two postings", func(t *testing.T) {
+		err := Validate(JournalEntryRequest{Postings: []PostingRequest{{AccountID: 1, Amount: decimal.NewFromInt(1), Currency: "EUR"}}})
+		require.Error(t, err)
+		assert.Equal(t, "postings: must contain at least 2 items", err.Error())
+	})
+
+	t.Run("journal entry must balance per currency", func(t *testing.T) {
+		req := JournalEntryRequest{Postings: []PostingRequest{
+			{AccountID: 1, Amount: decimal.NewFromInt(-10), Currency: "EUR"},
+			{AccountID: 2, Amount: decimal.NewFromInt(10), Currency: "USD"},
+		}}
+
+		err := Validate(req)
+
+		var verrs ValidationErrors
+		require.ErrorAs(t, err, &verrs)
+		require.Len(t, verrs, 2)
+		assert.Equal(t, "balanced", verrs[0].Rule)
+		assert.Equal(t, "EUR postings sum to -10 instead of zero", verrs[0].Message)
+	})
+
+	t.Run("balanced multi-currency journal entry", func(t *testing.T) {
+		req := JournalEntryRequest{Postings: []PostingRequest{
+			{AccountID: 1, Amount: decimal.NewFromInt(-100), Currency: "EUR"},
+			{AccountID: 2, Amount: decimal.NewFromInt(99), Currency: "EUR"},
+			{AccountID: 3, Amount: decimal.NewFromInt(1), Currency: "EUR"},
+			{AccountID: 4, Amount: decimal.NewFromInt(-108), Currency: "USD"},
+			{AccountID: 5, Amount: decimal.NewFromInt(108), Currency: "USD"},
+		}}
+		assert.NoError(t, Validate(req))
+	})
+	t.Run("fee schedule fields must match its type", func(t *testing.T) {
+		upTo := decimal.NewFromInt(100)
+		req := FeeScheduleRequest{Schedule: &FeeSchedule{
+			Type:             FeeTiered,
+			RevenueAccountID: 9,
+			Percent:          decimal.NewFromInt(1),
+			Bands:            []FeeBand{{UpTo: &upTo}, {UpTo: &upTo}},
+		}}
+
+		err := Validate(req)
+
+		var verrs ValidationErrors
+		require.ErrorAs(t, err, &verrs)
+		assert.Equal(t, ValidationErrors{
+			{Field: "schedule.bands", Rule: "type", Message: "a tiered fee sets flat and percent per band"},
+			{Field: "schedule.bands[1].up_to", Rule: "bands", Message: "must be omitted on the last band"},
+		}, verrs)
+		assert.NoError(t, Validate(FeeScheduleRequest{}))
+	})
+
+	t.Run("fee schedule minimum cannot exceed its cap", func(t *testing.T) {
+		minFee, maxFee := decimal.NewFromInt(5), decimal.NewFromInt(2)
+		err := Validate(FeeSchedule{Type: FeeFlat, RevenueAccountID: 9, Flat: decimal.NewFromInt(1), Min: &minFee, Max: &maxFee})
+		require.Error(t, err)
+		assert.Equal(t, "min: cannot exceed max", err.Error())
+	})
+}
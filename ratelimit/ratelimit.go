@@ -0,0 +1,139 @@
+// Package ratelimit implements token buckets: a bucket holds up to Burst
+// tokens, refills at Rate tokens a second, and every request takes one. The
+// buckets live in a Backend: Memory keeps them in the process, and a shared
+// backend (see storage.PostgresStore.TakeRateLimitToken) lets every replica
+// draw on the same buckets.
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// Limit is the size and refill rate of a bucket.
+type Limit struct {
+	Rate  float64 // tokens added per second
+	Burst int     // tokens the bucket holds when full
+}
+
+// Backend takes a token from the bucket named key, creating it full if it does
+// not exist yet. It reports whether a token was taken and how many are left.
+type Backend interface {
+	Take(ctx context.Context, key string, limit Limit) (ok bool, tokens float64, err error)
+}
+
+// BackendFunc adapts a function to a Backend.
+type BackendFunc func(ctx context.Context, key string, limit Limit) (bool, float64, error)
+
+// Take calls f.
+func (f BackendFunc) Take(ctx context.Context, key string, limit Limit) (bool, float64, error) {
+	return f(ctx, key, limit)
+}
+
+// Decision is the outcome of a take, in the terms of the RateLimit-* and
+// Retry-After response headers.
+type Decision struct {
+	Allowed    bool
+	Limit      int           // tokens in a full bucket
+	Remaining  int           // whole tokens left
+	Reset      time.Duration // until the bucket is full again
+	RetryAfter time.Duration // until the next token, when not allowed
+}
+
+// Decide returns the decision for a take from a bucket with limit that
+// reported ok and left tokens.
+func Decide(limit Limit, ok bool, tokens float64) Decision {
+	tokens = max(tokens, 0)
+	d := Decision{
+		Allowed:   ok,
+		Limit:     limit.Burst,
+		Remaining: int(math.Floor(tokens)),
+		Reset:     seconds((float64(limit.Burst) - tokens) / limit.Rate),
+	}
+	if !ok {
+		d.RetryAfter = seconds((1 - tokens) / limit.Rate)
+	}
+	return d
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(max(s, 0) * float64(time.Second))
+}
+
+// sweepInterval is how often Memory drops the buckets that have refilled.
+const sweepInterval = time.Minute
+
+// Memory is a Backend that keeps the buckets of one process. It is safe for
+// concurrent use.
+type Memory struct {
+	now       func() time.Time
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+type bucket struct {
+	limit   Limit
+	tokens  float64
+	updated time.Time
+}
+
+// NewMemory returns an empty Memory that reads the time from now, or from
+// time.Now if now is nil.
+func NewMemory(now func() time.Time) *Memory {
+	if now == nil {
+		now = time.Now
+	}
+	return &Memory{now: now, buckets: map[string]*bucket{}}
+}
+
+// Take implements Backend.
+func (m *Memory) Take(ctx context.Context, key string, limit Limit) (bool, float64, error) {
+	now := m.now()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if now.Sub(m.lastSweep) >= sweepInterval {
+		m.sweep(now)
+	}
+	b, ok := m.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(limit.Burst), updated: now}
+		m.buckets[key] = b
+	}
+	b.limit = limit
+	b.refill(now)
+	if b.tokens < 1 {
+		return false, b.tokens, nil
+	}
+	b.tokens--
+	return true, b.tokens, nil
+}
+
+// Len returns the number of buckets kept.
+func (m *Memory) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.buckets)
+}
+
+// sweep drops the buckets that are full by now: a new bucket starts full, so
+// forgetting them changes nothing.
+func (m *Memory) sweep(now time.Time) {
+	for key, b := range m.buckets {
+		if b.refill(now); b.tokens >= float64(b.limit.Burst) {
+			delete(m.buckets, key)
+		}
+	}
+	m.lastSweep = now
+}
+
+// refill adds the tokens earned since the bucket was last updated.
+func (b *bucket) refill(now time.Time) {
+	if elapsed := now.Sub(b.updated); elapsed > 0 {
+		b.tokens = min(float64(b.limit.Burst), b.tokens+elapsed.Seconds()*b.limit.Rate)
+		b.updated = now
+	}
+}
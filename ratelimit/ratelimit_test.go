@@ -0,0 +1,77 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// clock is a settable time source.
+type clock struct{ t time.Time }
+
+func (c *clock) now() time.Time { return c.t }
+
+func TestMemory(t *testing.T) {
+	ctx := context.Background()
+	c := &clock{t: time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC)}
+	limit := Limit{Rate: 2, Burst: 3}
+
+	t.Run("a full bucket allows a burst and then refills at the rate", func(t *testing.T) {
+		m := NewMemory(c.now)
+		for want := 2.0; want >= 0; want-- {
+			ok, tokens, err := m.Take(ctx, "a", limit)
+			require.NoError(t, err)
+			assert.True(t, ok)
+			assert.Equal(t, want, tokens)
+		}
+		ok, tokens, _ := m.Take(ctx, "a", limit)
+		assert.False(t, ok)
+		assert.Equal(t, 0.0, tokens)
+
+		ok, _, _ = m.Take(ctx, "b", limit)
+		assert.True(t, ok, "keys have buckets of their own")
+
+		c.t = c.t.Add(250 * time.Millisecond)
+		ok, tokens, _ = m.Take(ctx, "a", limit)
+		assert.False(t, ok)
+		assert.Equal(t, 0.5, tokens)
+
+		c.t = c.t.Add(250 * time.Millisecond)
+		ok, tokens, _ = m.Take(ctx, "a", limit)
+		assert.True(t, ok)
+		assert.Equal(t, 0.0, tokens)
+
+		c.t = c.t.Add(time.Hour)
+		ok, tokens, _ = m.Take(ctx, "a", limit)
+		assert.True(t, ok)
+		assert.Equal(t, 2.0, tokens, "a bucket holds at most Burst tokens")
+	})
+
+	t.Run("full buckets are swept", func(t *testing.T) {
+		m := NewMemory(c.now)
+		m.Take(ctx, "a", limit)
+		m.Take(ctx, "b", Limit{Rate: 0.001, Burst: 3})
+		require.Equal(t, 2, m.Len())
+
+		c.t = c.t.Add(sweepInterval)
+		m.Take(ctx, "c", limit)
+
+		assert.Equal(t, 2, m.Len(), "a has refilled, b has not")
+	})
+}
+
+func TestDecide(t *testing.T) {
+	limit := Limit{Rate: 0.5, Burst: 10}
+
+	d := Decide(limit, true, 7.5)
+	assert.Equal(t, Decision{Allowed: true, Limit: 10, Remaining: 7, Reset: 5 * time.Second}, d)
+
+	d = Decide(limit, false, 0.25)
+	assert.False(t, d.Allowed)
+	assert.Equal(t, 0, d.Remaining)
+	assert.Equal(t, 1500*time.Millisecond, d.RetryAfter)
+	assert.Equal(t, 19500*time.Millisecond, d.Reset)
+}
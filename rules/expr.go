@@ -0,0 +1,484 @@
+package rules
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/shopspring/decimal"
+)
+
+// The expression language of a rule's "when" condition:
+//
+//	expr    = or
+//	or      = and { "||" and }
+//	and     = not { "&&" not }
+//	not     = "!" not | compare
+//	compare = sum [ ( "==" | "!=" | "<" | "<=" | ">" | ">=" ) sum | "in" list ]
+//	sum     = product { ( "+" | "-" ) product }
+//	product = unary { ( "*" | "/" ) unary }
+//	unary   = "-" unary | primary
+//	primary = number | string | "true" | "false" | variable | "(" expr ")"
+//	list    = "[" [ expr { "," expr } ] "]"
+//
+// Numbers are decimals, never floats. Strings are quoted with " or '.
+// Expressions are type-checked when compiled: the condition must be a boolean,
+// arithmetic and ordering need numbers, == and != need operands of one type.
+
+// kind is the static type of an expression.
+type kind int
+
+const (
+	kindNumber kind = iota
+	kindString
+	kindBool
+)
+
+func (k kind) String() string {
+	return [...]string{"number", "string", "boolean"}[k]
+}
+
+// errDivisionByZero is the only error an expression can fail with once compiled.
+var errDivisionByZero = errors.New("division by zero")
+
+// env resolves variables while an expression is evaluated.
+type env map[string]any
+
+// node is a compiled expression.
+type node interface {
+	kind() kind
+	eval(env) (any, error)
+}
+
+type literal struct {
+	k kind
+	v any
+}
+
+func (n literal) kind() kind            { return n.k }
+func (n literal) eval(env) (any, error) { return n.v, nil }
+
+type variable struct {
+	name string
+	k    kind
+}
+
+func (n variable) kind() kind { return n.k }
+func (n variable) eval(e env) (any, error) {
+	return e[n.name], nil
+}
+
+type not struct{ x node }
+
+func (n not) kind() kind { return kindBool }
+func (n not) eval(e env) (any, error) {
+	v, err := n.x.eval(e)
+	if err != nil {
+		return nil, err
+	}
+	return !v.(bool), nil
+}
+
+type neg struct{ x node }
+
+func (n neg) kind() kind { return kindNumber }
+func (n neg) eval(e env) (any, error) {
+	v, err := n.x.eval(e)
+	if err != nil {
+		return nil, err
+	}
+	return v.(decimal.Decimal).Neg(), nil
+}
+
+// logical is && or ||; the right operand is only evaluated when needed.
+type logical struct {
+	and  bool
+	l, r node
+}
+
+func (n logical) kind() kind { return kindBool }
+func (n logical) eval(e env) (any, error) {
+	l, err := n.l.eval(e)
+	if err != nil {
+		return nil, err
+	}
+	if l.(bool) != n.and {
+		return l, nil
+	}
+	return n.r.eval(e)
+}
+
+type binary struct {
+	op   string
+	k    kind
+	l, r node
+}
+
+func (n binary) kind() kind { return n.k }
+func (n binary) eval(e env) (any, error) {
+	l, err := n.l.eval(e)
+	if err != nil {
+		return nil, err
+	}
+	r, err := n.r.eval(e)
+	if err != nil {
+		return nil, err
+	}
+	switch n.op {
+	case "==":
+		return equal(l, r), nil
+	case "!=":
+		return !equal(l, r), nil
+	}
+	a, b := l.(decimal.Decimal), r.(decimal.Decimal)
+	switch n.op {
+	case "<":
+		return a.LessThan(b), nil
+	case "<=":
+		return a.LessThanOrEqual(b), nil
+	case ">":
+		return a.GreaterThan(b), nil
+	case ">=":
+		return a.GreaterThanOrEqual(b), nil
+	case "+":
+		return a.Add(b), nil
+	case "-":
+		return a.Sub(b), nil
+	case "*":
+		return a.Mul(b), nil
+	case "/":
+		if b.IsZero() {
+			return nil, errDivisionByZero
+		}
+		return a.Div(b), nil
+	}
+	panic("rules: unknown operator " + n.op)
+}
+
+type in struct {
+	x    node
+	list []node
+}
+
+func (n in) kind() kind { return kindBool }
+func (n in) eval(e env) (any, error) {
+	v, err := n.x.eval(e)
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range n.list {
+		w, err := item.eval(e)
+		if err != nil {
+			return nil, err
+		}
+		if equal(v, w) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func equal(a, b any) bool {
+	if d, ok := a.(decimal.Decimal); ok {
+		return d.Equal(b.(decimal.Decimal))
+	}
+	return a == b
+}
+
+// compile parses and type-checks a condition against the variables in vars.
+func compile(src string, vars map[string]kind) (node, error) {
+	toks, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{toks: toks, vars: vars}
+	n, err := p.expr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.typ != tokEOF {
+		return nil, fmt.Errorf("unexpected %q at offset %d", t.text, t.pos)
+	}
+	if n.kind() != kindBool {
+		return nil, fmt.Errorf("condition is a %s, not a boolean", n.kind())
+	}
+	return n, nil
+}
+
+type tokenType int
+
+const (
+	tokEOF tokenType = iota
+	tokNumber
+	tokString
+	tokIdent
+	tokOp
+)
+
+type token struct {
+	typ  tokenType
+	text string // for strings, the unquoted value
+	pos  int
+}
+
+// operators lists the operator tokens, two-character ones first.
+var operators = []string{"&&", "||", "==", "!=", "<=", ">=", "<", ">", "!", "+", "-", "*", "/", "(", ")", "[", "]", ","}
+
+func lex(src string) ([]token, error) {
+	var toks []token
+	for i := 0; i < len(src); {
+		c := rune(src[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c >= '0' && c <= '9':
+			j := i
+			for j < len(src) && (src[j] >= '0' && src[j] <= '9' || src[j] == '.') {
+				j++
+			}
+			toks = append(toks, token{tokNumber, src[i:j], i})
+			i = j
+		case c == '"' || c == '\'':
+			j := strings.IndexByte(src[i+1:], src[i])
+			if j < 0 {
+				return nil, fmt.Errorf("unterminated string at offset %d", i)
+			}
+			toks = append(toks, token{tokString, src[i+1 : i+1+j], i})
+			i += j + 2
+		case c == '_' || unicode.IsLetter(c):
+			j := i
+			for j < len(src) && (src[j] == '_' || src[j] == '.' || unicode.IsLetter(rune(src[j])) || src[j] >= '0' && src[j] <= '9') {
+				j++
+			}
+			toks = append(toks, token{tokIdent, src[i:j], i})
+			i = j
+		default:
+			op := ""
+			for _, o := range operators {
+				if strings.HasPrefix(src[i:], o) {
+					op = o
+					break
+				}
+			}
+			if op == "" {
+				return nil, fmt.Errorf("unexpected %q at offset %d", c, i)
+			}
+			toks = append(toks, token{tokOp, op, i})
+			i += len(op)
+		}
+	}
+	return append(toks, token{tokEOF, "", len(src)}), nil
+}
+
+type parser struct {
+	toks []token
+	vars map[string]kind
+}
+
+func (p *parser) peek() token {
+	return p.toks[0]
+}
+
+func (p *parser) next() token {
+	t := p.toks[0]
+	if t.typ != tokEOF {
+		p.toks = p.toks[1:]
+	}
+	return t
+}
+
+// accept consumes the next token if it is the operator or keyword op.
+func (p *parser) accept(op string) bool {
+	if t := p.peek(); (t.typ == tokOp || t.typ == tokIdent) && t.text == op {
+		p.next()
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(op string) error {
+	if !p.accept(op) {
+		t := p.peek()
+		if t.typ == tokEOF {
+			return fmt.Errorf("expected %q at end of condition", op)
+		}
+		return fmt.Errorf("expected %q at offset %d, found %q", op, t.pos, t.text)
+	}
+	return nil
+}
+
+func (p *parser) expr() (node, error) {
+	return p.logical(true)
+}
+
+// logical parses a chain of || operands if or is set, else a chain of && operands.
+func (p *parser) logical(or bool) (node, error) {
+	op, operand := "&&", p.not
+	if or {
+		op, operand = "||", func() (node, error) { return p.logical(false) }
+	}
+	l, err := operand()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept(op) {
+		r, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		if l.kind() != kindBool || r.kind() != kindBool {
+			return nil, fmt.Errorf("%s needs booleans, not %s and %s", op, l.kind(), r.kind())
+		}
+		l = logical{and: !or, l: l, r: r}
+	}
+	return l, nil
+}
+
+func (p *parser) not() (node, error) {
+	if p.accept("!") {
+		x, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		if x.kind() != kindBool {
+			return nil, fmt.Errorf("! needs a boolean, not a %s", x.kind())
+		}
+		return not{x}, nil
+	}
+	return p.compare()
+}
+
+func (p *parser) compare() (node, error) {
+	l, err := p.sum()
+	if err != nil {
+		return nil, err
+	}
+	if p.accept("in") {
+		return p.in(l)
+	}
+	for _, op := range []string{"==", "!=", "<=", ">=", "<", ">"} {
+		if !p.accept(op) {
+			continue
+		}
+		r, err := p.sum()
+		if err != nil {
+			return nil, err
+		}
+		if l.kind() != r.kind() {
+			return nil, fmt.Errorf("cannot compare a %s with a %s", l.kind(), r.kind())
+		}
+		if op != "==" && op != "!=" && l.kind() != kindNumber {
+			return nil, fmt.Errorf("%s needs numbers, not %ss", op, l.kind())
+		}
+		return binary{op: op, k: kindBool, l: l, r: r}, nil
+	}
+	return l, nil
+}
+
+func (p *parser) in(x node) (node, error) {
+	if err := p.expect("["); err != nil {
+		return nil, err
+	}
+	n := in{x: x}
+	for !p.accept("]") {
+		if len(n.list) > 0 {
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+		}
+		item, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		if item.kind() != x.kind() {
+			return nil, fmt.Errorf("list of %s holds a %s", x.kind(), item.kind())
+		}
+		n.list = append(n.list, item)
+	}
+	return n, nil
+}
+
+func (p *parser) sum() (node, error) {
+	return p.arith([]string{"+", "-"}, p.product)
+}
+
+func (p *parser) product() (node, error) {
+	return p.arith([]string{"*", "/"}, p.unary)
+}
+
+func (p *parser) arith(ops []string, operand func() (node, error)) (node, error) {
+	l, err := operand()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op := ""
+		for _, o := range ops {
+			if p.accept(o) {
+				op = o
+				break
+			}
+		}
+		if op == "" {
+			return l, nil
+		}
+		r, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		if l.kind() != kindNumber || r.kind() != kindNumber {
+			return nil, fmt.Errorf("%s needs numbers, not %s and %s", op, l.kind(), r.kind())
+		}
+		l = binary{op: op, k: kindNumber, l: l, r: r}
+	}
+}
+
+func (p *parser) unary() (node, error) {
+	if p.accept("-") {
+		x, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		if x.kind() != kindNumber {
+			return nil, fmt.Errorf("- needs a number, not a %s", x.kind())
+		}
+		return neg{x}, nil
+	}
+	return p.primary()
+}
+
+func (p *parser) primary() (node, error) {
+	t := p.next()
+	switch t.typ {
+	case tokNumber:
+		d, err := decimal.NewFromString(t.text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q at offset %d", t.text, t.pos)
+		}
+		return literal{kindNumber, d}, nil
+	case tokString:
+		return literal{kindString, t.text}, nil
+	case tokIdent:
+		switch t.text {
+		case "true", "false":
+			return literal{kindBool, t.text == "true"}, nil
+		}
+		k, ok := p.vars[t.text]
+		if !ok {
+			return nil, fmt.Errorf("unknown variable %q at offset %d", t.text, t.pos)
+		}
+		return variable{t.text, k}, nil
+	case tokOp:
+		if t.text == "(" {
+			x, err := p.expr()
+			if err != nil {
+				return nil, err
+			}
+			return x, p.expect(")")
+		}
+	case tokEOF:
+		return nil, errors.New("unexpected end of condition")
+	}
+	return nil, fmt.Errorf("unexpected %q at offset %d", t.text, t.pos)
+}
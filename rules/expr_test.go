@@ -0,0 +1,79 @@
+package rules
+
+import (
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompileAndEval(t *testing.T) {
+	vars := map[string]kind{"amount": kindNumber, "source.currency": kindString, "source.balance": kindNumber}
+	e := env{"amount": decimal.RequireFromString("150.5"), "source.currency": "EUR", "source.balance": decimal.NewFromInt(1000)}
+
+	tests := []struct {
+		src  string
+		want bool
+	}{
+		{"amount > 100", true},
+		{"amount >= 150.50 && amount <= 150.5", true},
+		{"amount * 2 == 301", true},
+		{"amount > source.balance / 2 || source.currency == 'EUR'", true},
+		{"!(amount < 200)", false},
+		{"-amount < 0", true},
+		{"1 + 2 * 3 == 7", true},
+		{"(1 + 2) * 3 == 9", true},
+		{`source.currency in ["USD", "EUR"]`, true},
+		{"amount in [1, 2]", false},
+		{"source.currency != \"EUR\" && amount / 0 > 1", false}, // short-circuits before dividing
+		{"true", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			n, err := compile(tt.src, vars)
+			require.NoError(t, err)
+			got, err := n.eval(e)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	vars := map[string]kind{"amount": kindNumber, "source.currency": kindString}
+
+	tests := []struct {
+		src     string
+		wantErr string
+	}{
+		{"", "unexpected end of condition"},
+		{"amount", "condition is a number, not a boolean"},
+		{"amount > 'x'", "cannot compare a number with a string"},
+		{"source.currency < 'EUR'", "< needs numbers, not strings"},
+		{"source.currency + 1 > 0", "+ needs numbers, not string and number"},
+		{"amount > 1 && 2", "&& needs booleans, not boolean and number"},
+		{"!amount", "! needs a boolean, not a number"},
+		{"balance > 1", `unknown variable "balance" at offset 0`},
+		{"amount > 1 amount", `unexpected "amount" at offset 11`},
+		{"(amount > 1", `expected ")" at end of condition`},
+		{"amount in [1, 'x']", "list of number holds a string"},
+		{"amount > 1.2.3", `invalid number "1.2.3"`},
+		{"source.currency == 'EUR", "unterminated string at offset 19"},
+		{"amount > 1 ; true", `unexpected ';' at offset 11`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			_, err := compile(tt.src, vars)
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestEvalDivisionByZero(t *testing.T) {
+	n, err := compile("amount / 0 > 1", map[string]kind{"amount": kindNumber})
+	require.NoError(t, err)
+
+	_, err = n.eval(env{"amount": decimal.NewFromInt(1)})
+	assert.ErrorIs(t, err, errDivisionByZero)
+}
@@ -0,0 +1,203 @@
+// Package rules evaluates transfer rules: conditions over a transfer, its two
+// accounts and the time, each of which allows, denies or holds the transfer for
+// review. Rules are compiled when loaded and swapped in atomically, so they can
+// be reloaded while transfers are being evaluated.
+package rules
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"go-api-example/metrics"
+	"go-api-example/model"
+
+	"github.com/shopspring/decimal"
+	"gopkg.in/yaml.v3"
+)
+
+var evaluationErrors = metrics.NewCounter("rules_evaluation_errors_total", "Transfer rule conditions that failed to evaluate and were skipped.")
+
+// Input is what a rule condition sees of a transfer.
+type Input struct {
+	Request     model.TransactionRequest
+	Source      model.Account
+	Destination model.Account
+	Now         time.Time
+}
+
+// vars are the variables a condition may use. Each account is exposed under
+// "source." and "destination.".
+var vars = map[string]kind{
+	"amount":       kindNumber,
+	"time.hour":    kindNumber, // 0-23, UTC
+	"time.day":     kindNumber, // day of the month, UTC
+	"time.weekday": kindString, // "monday" ... "sunday", UTC
+}
+
+func init() {
+	for _, prefix := range []string{"source.", "destination."} {
+		vars[prefix+"id"] = kindNumber
+		vars[prefix+"balance"] = kindNumber
+		vars[prefix+"currency"] = kindString
+		vars[prefix+"status"] = kindString
+		vars[prefix+"age_days"] = kindNumber // whole days since the account was created
+	}
+}
+
+func (in Input) env() env {
+	now := in.Now.UTC()
+	e := env{
+		"amount":       in.Request.Amount,
+		"time.hour":    decimal.NewFromInt(int64(now.Hour())),
+		"time.day":     decimal.NewFromInt(int64(now.Day())),
+		"time.weekday": strings.ToLower(now.Weekday().String()),
+	}
+	for prefix, acc := range map[string]model.Account{"source.": in.Source, "destination.": in.Destination} {
+		var age int64
+		if !acc.CreatedAt.IsZero() {
+			age = int64(now.Sub(acc.CreatedAt) / (24 * time.Hour))
+		}
+		e[prefix+"id"] = decimal.NewFromInt(acc.AccountID)
+		e[prefix+"balance"] = acc.Balance
+		e[prefix+"currency"] = acc.Currency
+		e[prefix+"status"] = acc.Status
+		e[prefix+"age_days"] = decimal.NewFromInt(age)
+	}
+	return e
+}
+
+type compiled struct {
+	rule model.Rule
+	when node
+}
+
+// Engine holds the loaded rules. The zero value has none and allows every
+// transfer. It is safe for concurrent use.
+type Engine struct {
+	rules atomic.Pointer[[]compiled]
+}
+
+// Load compiles rules and, if all of them compile, replaces the loaded ones.
+// On error the rules loaded before stay in effect.
+func (e *Engine) Load(rules []model.Rule) error {
+	set := make([]compiled, 0, len(rules))
+	names := make(map[string]bool, len(rules))
+	for i, r := range rules {
+		var err error
+		switch {
+		case r.Name == "":
+			err = errors.New("name is required")
+		case names[r.Name]:
+			err = errors.New("name is not unique")
+		case r.Action != model.RuleAllow && r.Action != model.RuleDeny && r.Action != model.RuleReview:
+			err = fmt.Errorf("action must be one of allow, deny, review, not %q", r.Action)
+		case r.Action == model.RuleDeny && r.Code == "":
+			err = errors.New("deny rules need a code")
+		}
+		c := compiled{rule: r}
+		if err == nil {
+			c.when, err = compile(r.When, vars)
+		}
+		if err != nil {
+			return fmt.Errorf("rule %d (%s): %w", i+1, r.Name, err)
+		}
+		names[r.Name] = true
+		set = append(set, c)
+	}
+	e.rules.Store(&set)
+	return nil
+}
+
+// Rules returns the loaded rules in evaluation order.
+func (e *Engine) Rules() []model.Rule {
+	rules := []model.Rule{}
+	if set := e.rules.Load(); set != nil {
+		for _, c := range *set {
+			rules = append(rules, c.rule)
+		}
+	}
+	return rules
+}
+
+// Len returns the number of loaded rules.
+func (e *Engine) Len() int {
+	if set := e.rules.Load(); set != nil {
+		return len(*set)
+	}
+	return 0
+}
+
+// Decide evaluates the rules in order; the first whose condition holds decides.
+// A transfer no rule matches is allowed. A rule whose condition fails to
+// evaluate, such as by dividing by zero, is logged, and matches if it denies
+// or sends to review, so that a broken rule fails closed; an allow rule is
+// skipped instead.
+func (e *Engine) Decide(in Input) model.RuleDecision {
+	return e.evaluate(in, false)
+}
+
+// DryRun decides as Decide does, and also reports every rule that would fire
+// and every rule that failed to evaluate.
+func (e *Engine) DryRun(in Input) model.RuleDecision {
+	return e.evaluate(in, true)
+}
+
+func (e *Engine) evaluate(in Input, all bool) model.RuleDecision {
+	decision := model.RuleDecision{Action: model.RuleAllow}
+	set := e.rules.Load()
+	if set == nil {
+		return decision
+	}
+	env := in.env()
+	decided := false
+	for _, c := range *set {
+		v, err := c.when.eval(env)
+		if err != nil {
+			if all {
+				decision.Errors = append(decision.Errors, fmt.Sprintf("%s: %v", c.rule.Name, err))
+			} else {
+				evaluationErrors.Inc()
+				log.Printf("Transfer rule %s failed to evaluate: %v", c.rule.Name, err)
+			}
+			if c.rule.Action == model.RuleAllow {
+				continue
+			}
+		} else if !v.(bool) {
+			continue
+		}
+		if !decided {
+			decision.Action, decision.Rule, decision.Code = c.rule.Action, c.rule.Name, c.rule.Code
+			decided = true
+		}
+		if !all {
+			break
+		}
+		decision.Fired = append(decision.Fired, c.rule)
+	}
+	return decision
+}
+
+// LoadFile reads rules from a YAML file with a top-level "rules" list.
+func LoadFile(path string) ([]model.Rule, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var file struct {
+		Rules []model.Rule `yaml:"rules"`
+	}
+	dec := yaml.NewDecoder(f)
+	dec.KnownFields(true)
+	if err := dec.Decode(&file); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return file.Rules, nil
+}
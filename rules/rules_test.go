@@ -0,0 +1,114 @@
+package rules
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go-api-example/model"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testRules = []model.Rule{
+	{Name: "night-large", When: "amount > 1000 && (time.hour < 6 || time.hour >= 22)", Action: model.RuleDeny, Code: "NIGHT_LIMIT"},
+	{Name: "new-destination", When: "destination.age_days < 7 && amount > 100", Action: model.RuleReview},
+	{Name: "broken", When: "amount / source.balance > 0.5", Action: model.RuleReview},
+	{Name: "trusted", When: `source.status == "active" && source.currency == destination.currency`, Action: model.RuleAllow},
+}
+
+func input(amount string, hour int) Input {
+	now := time.Date(2026, 10, 12, hour, 30, 0, 0, time.UTC) // a Monday
+	return Input{
+		Request:     model.TransactionRequest{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.RequireFromString(amount)},
+		Source:      model.Account{AccountID: 1, Balance: decimal.Zero, Currency: "EUR", Status: model.AccountStatusActive, CreatedAt: now.AddDate(-1, 0, 0)},
+		Destination: model.Account{AccountID: 2, Currency: "EUR", Status: model.AccountStatusActive, CreatedAt: now.AddDate(0, 0, -3)},
+		Now:         now,
+	}
+}
+
+func TestEngineDecide(t *testing.T) {
+	var e Engine
+	assert.Equal(t, model.RuleDecision{Action: model.RuleAllow}, e.Decide(input("5000", 23)), "no rules allow everything")
+	require.NoError(t, e.Load(testRules))
+
+	t.Run("first matching rule decides", func(t *testing.T) {
+		d := e.Decide(input("5000", 23))
+		assert.Equal(t, model.RuleDecision{Action: model.RuleDeny, Rule: "night-large", Code: "NIGHT_LIMIT"}, d)
+		assert.Equal(t, model.RuleReview, e.Decide(input("5000", 12)).Action)
+	})
+
+	t.Run("rules that fail to evaluate fail closed", func(t *testing.T) {
+		d := e.Decide(input("50", 12))
+		assert.Equal(t, model.RuleDecision{Action: model.RuleReview, Rule: "broken"}, d)
+
+		var deny Engine
+		require.NoError(t, deny.Load([]model.Rule{
+			{Name: "broken-allow", When: "amount / source.balance > 0.5", Action: model.RuleAllow},
+			{Name: "broken-deny", When: "amount / source.balance > 0.5", Action: model.RuleDeny, Code: "RATIO"},
+		}))
+		assert.Equal(t, model.RuleDecision{Action: model.RuleDeny, Rule: "broken-deny", Code: "RATIO"}, deny.Decide(input("50", 12)),
+			"a broken allow rule is skipped, a broken deny rule denies")
+	})
+
+	t.Run("dry run reports every rule that fires", func(t *testing.T) {
+		d := e.DryRun(input("5000", 23))
+		assert.Equal(t, "night-large", d.Rule)
+		assert.Equal(t, []model.Rule{testRules[0], testRules[1], testRules[2], testRules[3]}, d.Fired)
+		assert.Equal(t, []string{"broken: division by zero"}, d.Errors)
+	})
+}
+
+func TestEngineLoad(t *testing.T) {
+	var e Engine
+	require.NoError(t, e.Load(testRules))
+
+	tests := []struct {
+		name    string
+		rule    model.Rule
+		wantErr string
+	}{
+		{"no name", model.Rule{When: "true", Action: model.RuleAllow}, "rule 2 (): name is required"},
+		{"duplicate name", model.Rule{Name: "a", When: "true", Action: model.RuleAllow}, "rule 2 (a): name is not unique"},
+		{"unknown action", model.Rule{Name: "b", When: "true", Action: "block"}, `rule 2 (b): action must be one of allow, deny, review, not "block"`},
+		{"deny without code", model.Rule{Name: "b", When: "true", Action: model.RuleDeny}, "rule 2 (b): deny rules need a code"},
+		{"invalid condition", model.Rule{Name: "b", When: "amount >", Action: model.RuleReview}, "rule 2 (b): unexpected end of condition"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := e.Load([]model.Rule{{Name: "a", When: "true", Action: model.RuleAllow}, tt.rule})
+			assert.EqualError(t, err, tt.wantErr)
+			assert.Equal(t, testRules, e.Rules(), "the rules in effect are kept")
+		})
+	}
+}
+
+func TestLoadFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+rules:
+  - name: weekend-review
+    when: time.weekday in ["saturday", "sunday"] && amount > 500
+    action: review
+  - name: frozen-destination
+    when: destination.status == "frozen"
+    action: deny
+    code: DESTINATION_FROZEN
+`), 0o600))
+
+	loaded, err := LoadFile(path)
+	require.NoError(t, err)
+	require.Len(t, loaded, 2)
+	assert.Equal(t, "DESTINATION_FROZEN", loaded[1].Code)
+
+	var e Engine
+	require.NoError(t, e.Load(loaded))
+	assert.Equal(t, 2, e.Len())
+
+	require.NoError(t, os.WriteFile(path, []byte("rules:\n  - name: x\n    condition: true\n"), 0o600))
+	_, err = LoadFile(path)
+	assert.ErrorContains(t, err, "field condition not found")
+}
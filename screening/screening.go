@@ -0,0 +1,226 @@
+// Package screening checks the accounts of a transfer against a blocklist of
+// account IDs and owner names, as sanctions screening requires. Owner names
+// match fuzzily, so that spelling variants and reordered names are caught. The
+// list is swapped in atomically, so it can be reloaded while transfers are
+// being screened.
+package screening
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"unicode"
+
+	"go-api-example/model"
+)
+
+// list is a loaded blocklist, indexed for screening.
+type list struct {
+	entries []model.BlocklistEntry
+	ids     map[int64][]model.BlocklistEntry
+	names   []name
+}
+
+type name struct {
+	entry      model.BlocklistEntry
+	normalized string
+	sorted     string
+}
+
+// Screener screens accounts against the loaded blocklist. The zero value has an
+// empty list and matches nothing. It is safe for concurrent use.
+type Screener struct {
+	// Threshold is the lowest name similarity, from 0 to 1, that is a match.
+	Threshold float64
+
+	list atomic.Pointer[list]
+}
+
+// New returns a Screener with an empty list that matches owner names at least
+// threshold similar.
+func New(threshold float64) *Screener {
+	return &Screener{Threshold: threshold}
+}
+
+// Load validates entries and, if all of them are valid, replaces the loaded
+// list. On error the list loaded before stays in effect.
+func (s *Screener) Load(entries []model.BlocklistEntry) error {
+	l := &list{entries: entries, ids: make(map[int64][]model.BlocklistEntry)}
+	for i, e := range entries {
+		norm := normalize(e.Name)
+		if e.AccountID <= 0 && norm == "" {
+			return fmt.Errorf("entry %d: needs a positive account ID or a name", i+1)
+		}
+		if e.AccountID > 0 {
+			l.ids[e.AccountID] = append(l.ids[e.AccountID], e)
+		}
+		if norm != "" {
+			l.names = append(l.names, name{entry: e, normalized: norm, sorted: sortTokens(norm)})
+		}
+	}
+	s.list.Store(l)
+	return nil
+}
+
+// Entries returns the loaded blocklist.
+func (s *Screener) Entries() []model.BlocklistEntry {
+	if l := s.list.Load(); l != nil {
+		return l.entries
+	}
+	return nil
+}
+
+// Len returns the number of loaded blocklist entries.
+func (s *Screener) Len() int {
+	return len(s.Entries())
+}
+
+// Screen returns every blocklist entry that accounts match: by account ID, or
+// by an owner name at least Threshold similar to the entry's name.
+func (s *Screener) Screen(accounts ...model.Account) []model.ScreeningMatch {
+	l := s.list.Load()
+	if l == nil {
+		return nil
+	}
+	var matches []model.ScreeningMatch
+	for _, acc := range accounts {
+		for _, e := range l.ids[acc.AccountID] {
+			matches = append(matches, model.ScreeningMatch{AccountID: acc.AccountID, Entry: e, Score: 1})
+		}
+		owner := normalize(acc.OwnerName)
+		if owner == "" {
+			continue
+		}
+		sorted := sortTokens(owner)
+		for _, n := range l.names {
+			score := max(similarity(owner, n.normalized), similarity(sorted, n.sorted))
+			if score >= s.Threshold {
+				matches = append(matches, model.ScreeningMatch{AccountID: acc.AccountID, Entry: n.entry, Score: score})
+			}
+		}
+	}
+	return matches
+}
+
+// normalize lower-cases a name and reduces it to its words of letters and
+// digits, separated by single spaces.
+func normalize(s string) string {
+	return strings.Join(strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}), " ")
+}
+
+// sortTokens sorts the words of a normalized name, so that "petrov ivan" and
+// "ivan petrov" compare equal.
+func sortTokens(s string) string {
+	words := strings.Fields(s)
+	sort.Strings(words)
+	return strings.Join(words, " ")
+}
+
+// similarity is the Jaro-Winkler similarity of a and b: 1 for identical
+// strings, 0 for strings with nothing in common, with common prefixes weighing
+// more.
+func similarity(a, b string) float64 {
+	if a == b {
+		return 1
+	}
+	s, t := []rune(a), []rune(b)
+	if len(s) == 0 || len(t) == 0 {
+		return 0
+	}
+	window := max(0, max(len(s), len(t))/2-1)
+	sMatched, tMatched := make([]bool, len(s)), make([]bool, len(t))
+	matches := 0
+	for i := range s {
+		for j := max(0, i-window); j < min(len(t), i+window+1); j++ {
+			if !tMatched[j] && s[i] == t[j] {
+				sMatched[i], tMatched[j] = true, true
+				matches++
+				break
+			}
+		}
+	}
+	if matches == 0 {
+		return 0
+	}
+	transpositions, j := 0, 0
+	for i := range s {
+		if !sMatched[i] {
+			continue
+		}
+		for !tMatched[j] {
+			j++
+		}
+		if s[i] != t[j] {
+			transpositions++
+		}
+		j++
+	}
+	m := float64(matches)
+	jaro := (m/float64(len(s)) + m/float64(len(t)) + (m-float64(transpositions)/2)/m) / 3
+
+	prefix := 0
+	for prefix < min(4, len(s), len(t)) && s[prefix] == t[prefix] {
+		prefix++
+	}
+	return jaro + float64(prefix)*0.1*(1-jaro)
+}
+
+// LoadFile reads a blocklist from a CSV file whose header names its columns:
+// account_id, name and list, in any order. The list column is optional; each
+// row needs an account ID or a name.
+func LoadFile(path string) ([]model.BlocklistEntry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	cr := csv.NewReader(f)
+	cr.FieldsPerRecord = -1
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("%s: could not read CSV header: %w", path, err)
+	}
+	columns := map[string]int{}
+	for i, h := range header {
+		columns[strings.ToLower(strings.TrimSpace(h))] = i
+	}
+	_, hasID := columns["account_id"]
+	_, hasName := columns["name"]
+	if !hasID && !hasName {
+		return nil, fmt.Errorf("%s: CSV header has neither an account_id nor a name column", path)
+	}
+
+	entries := []model.BlocklistEntry{}
+	for {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			return entries, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		field := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		e := model.BlocklistEntry{Name: field("name"), List: field("list")}
+		if id := field("account_id"); id != "" {
+			if e.AccountID, err = strconv.ParseInt(id, 10, 64); err != nil {
+				line, _ := cr.FieldPos(0)
+				return nil, fmt.Errorf("%s:%d: account_id must be an integer", path, line)
+			}
+		}
+		entries = append(entries, e)
+	}
+}
@@ -0,0 +1,89 @@
+package screening
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go-api-example/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSimilarity(t *testing.T) {
+	assert.Equal(t, 1.0, similarity("ivan petrov", "ivan petrov"))
+	assert.Equal(t, 0.0, similarity("abc", "xyz"))
+	assert.Equal(t, 0.0, similarity("", "abc"))
+	assert.InDelta(t, 0.961, similarity("martha", "marhta"), 0.001)
+	assert.InDelta(t, 0.840, similarity("dwayne", "duane"), 0.001)
+	assert.Equal(t, similarity("dixon", "dicksonx"), similarity("dicksonx", "dixon"))
+}
+
+func TestScreen(t *testing.T) {
+	s := New(0.9)
+	assert.Empty(t, s.Screen(model.Account{AccountID: 7}), "an empty list matches nothing")
+	require.NoError(t, s.Load([]model.BlocklistEntry{
+		{AccountID: 7, List: "internal"},
+		{Name: "Ivan Petrov", List: "SDN"},
+		{Name: "Acme Shell Holdings Ltd.", List: "SDN"},
+	}))
+
+	tests := []struct {
+		name    string
+		account model.Account
+		want    []string // lists of the entries matched
+	}{
+		{"account ID", model.Account{AccountID: 7, OwnerName: "Jane Doe"}, []string{"internal"}},
+		{"identical name", model.Account{AccountID: 1, OwnerName: "Ivan Petrov"}, []string{"SDN"}},
+		{"case and punctuation", model.Account{AccountID: 1, OwnerName: "PETROV, IVAN"}, []string{"SDN"}},
+		{"spelling variant", model.Account{AccountID: 1, OwnerName: "Ivan Petrof"}, []string{"SDN"}},
+		{"company", model.Account{AccountID: 1, OwnerName: "ACME Shell Holdings Limited"}, []string{"SDN"}},
+		{"different person", model.Account{AccountID: 1, OwnerName: "Maria Ivanova"}, nil},
+		{"no owner name", model.Account{AccountID: 1}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var lists []string
+			for _, m := range s.Screen(tt.account) {
+				assert.Equal(t, tt.account.AccountID, m.AccountID)
+				lists = append(lists, m.Entry.List)
+			}
+			assert.Equal(t, tt.want, lists)
+		})
+	}
+
+	t.Run("score", func(t *testing.T) {
+		m := s.Screen(model.Account{AccountID: 1, OwnerName: "Ivan Petrof"})
+		require.Len(t, m, 1)
+		assert.Greater(t, m[0].Score, 0.9)
+		assert.Less(t, m[0].Score, 1.0)
+	})
+}
+
+func TestLoad(t *testing.T) {
+	s := New(0.9)
+	entries := []model.BlocklistEntry{{AccountID: 7}}
+	require.NoError(t, s.Load(entries))
+
+	err := s.Load([]model.BlocklistEntry{{Name: "Ivan Petrov"}, {Name: " ,. ", List: "SDN"}})
+	assert.EqualError(t, err, "entry 2: needs a positive account ID or a name")
+	assert.Equal(t, entries, s.Entries(), "the list in effect is kept")
+}
+
+func TestLoadFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "blocklist.csv")
+	require.NoError(t, os.WriteFile(path, []byte("list,Account_ID,name\nSDN,,Ivan Petrov\ninternal,42,\n"), 0o600))
+
+	entries, err := LoadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, []model.BlocklistEntry{{Name: "Ivan Petrov", List: "SDN"}, {AccountID: 42, List: "internal"}}, entries)
+
+	require.NoError(t, os.WriteFile(path, []byte("account_id\n42\nx\n"), 0o600))
+	_, err = LoadFile(path)
+	assert.ErrorContains(t, err, ":3: account_id must be an integer")
+
+	require.NoError(t, os.WriteFile(path, []byte("id,owner\n"), 0o600))
+	_, err = LoadFile(path)
+	assert.ErrorContains(t, err, "neither an account_id nor a name column")
+}
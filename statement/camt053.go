@@ -0,0 +1,119 @@
+package statement
+
+import (
+	"encoding/xml"
+	"io"
+	"strconv"
+	"time"
+
+	"go-api-example/model"
+
+	"github.com/shopspring/decimal"
+)
+
+// camt053Namespace is the namespace of the bank-to-customer statement message.
+const camt053Namespace = "urn:iso:std:iso:20022:tech:xsd:camt.053.001.02"
+
+// camtTime renders a time as an ISO 20022 ISODateTime, in UTC.
+func camtTime(t time.Time) string {
+	return utc(t).Format(time.RFC3339)
+}
+
+// camt053Writer writes an ISO 20022 camt.053.001.02 statement. Amounts are
+// unsigned, with their sign carried by CdtDbtInd. The schema puts both
+// balances before the entries, which is why Begin gets them.
+type camt053Writer struct {
+	x    *xmlWriter
+	stmt *model.Statement
+}
+
+func newCAMT053Writer(w io.Writer) model.StatementWriter {
+	return &camt053Writer{x: newXMLWriter(w)}
+}
+
+func (c *camt053Writer) Begin(s *model.Statement) error {
+	c.stmt = s
+	x := c.x
+	account := strconv.FormatInt(s.AccountID, 10)
+	x.prolog(`xml version="1.0" encoding="UTF-8"`)
+	x.open("Document", xml.Attr{Name: xml.Name{Local: "xmlns"}, Value: camt053Namespace})
+	x.open("BkToCstmrStmt")
+	x.open("GrpHdr")
+	x.elem("MsgId", "STMT-"+account+"-"+utc(s.CreatedAt).Format("20060102150405"))
+	x.elem("CreDtTm", camtTime(s.CreatedAt))
+	x.close("GrpHdr")
+
+	x.open("Stmt")
+	x.elem("Id", account+"-"+utc(s.From).Format("20060102")+"-"+utc(s.To).Format("20060102"))
+	x.elem("CreDtTm", camtTime(s.CreatedAt))
+	x.open("FrToDt")
+	x.elem("FrDtTm", camtTime(s.From))
+	x.elem("ToDtTm", camtTime(s.To))
+	x.close("FrToDt")
+	x.open("Acct")
+	x.open("Id")
+	x.open("Othr")
+	x.elem("Id", account)
+	x.close("Othr")
+	x.close("Id")
+	x.elem("Ccy", s.Currency)
+	x.close("Acct")
+	c.balance("OPBD", s.OpeningBalance, s.From)
+	c.balance("CLBD", s.ClosingBalance, s.To)
+	return x.flush()
+}
+
+// balance writes a Bal element of the given type code.
+func (c *camt053Writer) balance(code string, amount decimal.Decimal, at time.Time) {
+	x := c.x
+	x.open("Bal")
+	x.open("Tp")
+	x.open("CdOrPrtry")
+	x.elem("Cd", code)
+	x.close("CdOrPrtry")
+	x.close("Tp")
+	c.amount(amount, c.stmt.Currency)
+	x.open("Dt")
+	x.elem("DtTm", camtTime(at))
+	x.close("Dt")
+	x.close("Bal")
+}
+
+// amount writes an unsigned Amt followed by its CdtDbtInd.
+func (c *camt053Writer) amount(amount decimal.Decimal, currency string) {
+	indicator := "CRDT"
+	if amount.IsNegative() {
+		indicator = "DBIT"
+	}
+	c.x.elem("Amt", formatAmount(amount.Abs()), xml.Attr{Name: xml.Name{Local: "Ccy"}, Value: currency})
+	c.x.elem("CdtDbtInd", indicator)
+}
+
+func (c *camt053Writer) Movement(p model.Posting) error {
+	x := c.x
+	x.open("Ntry")
+	x.elem("NtryRef", strconv.FormatInt(p.PostingID, 10))
+	c.amount(p.Amount, p.Currency)
+	x.elem("Sts", "BOOK")
+	x.open("BookgDt")
+	x.elem("DtTm", camtTime(p.CreatedAt))
+	x.close("BookgDt")
+	x.open("ValDt")
+	x.elem("DtTm", camtTime(p.CreatedAt))
+	x.close("ValDt")
+	x.elem("AcctSvcrRef", strconv.FormatInt(p.EntryID, 10))
+	x.open("BkTxCd")
+	x.open("Prtry")
+	x.elem("Cd", p.Kind)
+	x.close("Prtry")
+	x.close("BkTxCd")
+	x.close("Ntry")
+	return x.err
+}
+
+func (c *camt053Writer) End() error {
+	c.x.close("Stmt")
+	c.x.close("BkToCstmrStmt")
+	c.x.close("Document")
+	return c.x.end()
+}
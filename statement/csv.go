@@ -0,0 +1,58 @@
+package statement
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"go-api-example/model"
+
+	"github.com/shopspring/decimal"
+)
+
+// csvWriter writes one row per movement between an opening and a closing row.
+// Every row carries the running balance after it.
+type csvWriter struct {
+	w       *csv.Writer
+	stmt    *model.Statement
+	balance decimal.Decimal
+}
+
+func newCSVWriter(w io.Writer) model.StatementWriter {
+	return &csvWriter{w: csv.NewWriter(w)}
+}
+
+func (c *csvWriter) Begin(s *model.Statement) error {
+	c.stmt, c.balance = s, s.OpeningBalance
+	if err := c.w.Write([]string{"type", "date", "posting_id", "entry_id", "kind", "amount", "currency", "balance"}); err != nil {
+		return err
+	}
+	return c.w.Write([]string{"opening", utc(s.From).Format(time.RFC3339), "", "", "", "", s.Currency, formatAmount(s.OpeningBalance)})
+}
+
+func (c *csvWriter) Movement(p model.Posting) error {
+	c.balance = c.balance.Add(p.Amount)
+	return c.w.Write([]string{
+		"movement",
+		utc(p.CreatedAt).Format(time.RFC3339),
+		strconv.FormatInt(p.PostingID, 10),
+		strconv.FormatInt(p.EntryID, 10),
+		p.Kind,
+		formatAmount(p.Amount),
+		p.Currency,
+		formatAmount(c.balance),
+	})
+}
+
+func (c *csvWriter) End() error {
+	if !c.balance.Equal(c.stmt.ClosingBalance) {
+		return fmt.Errorf("movements add up to %s, not the closing balance %s", c.balance, c.stmt.ClosingBalance)
+	}
+	if err := c.w.Write([]string{"closing", utc(c.stmt.To).Format(time.RFC3339), "", "", "", "", c.stmt.Currency, formatAmount(c.stmt.ClosingBalance)}); err != nil {
+		return err
+	}
+	c.w.Flush()
+	return c.w.Error()
+}
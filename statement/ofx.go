@@ -0,0 +1,105 @@
+package statement
+
+import (
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-api-example/model"
+)
+
+// ofxBankID fills the mandatory BANKID of the account: there is no routing
+// number for an account in this ledger.
+const ofxBankID = "000000000"
+
+// ofxTime renders a time in the OFX datetime format, in UTC.
+func ofxTime(t time.Time) string {
+	return utc(t).Format("20060102150405.000") + "[0:GMT]"
+}
+
+// ofxWriter writes an OFX 2.2 bank statement response. OFX has no opening
+// balance of its own: the closing balance is the LEDGERBAL and the opening
+// balance is listed in BALLIST, both after the transactions.
+type ofxWriter struct {
+	x    *xmlWriter
+	stmt *model.Statement
+}
+
+func newOFXWriter(w io.Writer) model.StatementWriter {
+	return &ofxWriter{x: newXMLWriter(w)}
+}
+
+func (o *ofxWriter) Begin(s *model.Statement) error {
+	o.stmt = s
+	x := o.x
+	x.prolog(`xml version="1.0" encoding="UTF-8" standalone="no"`,
+		`OFX OFXHEADER="200" VERSION="220" SECURITY="NONE" OLDFILEUID="NONE" NEWFILEUID="NONE"`)
+	x.open("OFX")
+	x.open("SIGNONMSGSRSV1")
+	x.open("SONRS")
+	o.status()
+	x.elem("DTSERVER", ofxTime(s.CreatedAt))
+	x.elem("LANGUAGE", "ENG")
+	x.close("SONRS")
+	x.close("SIGNONMSGSRSV1")
+
+	x.open("BANKMSGSRSV1")
+	x.open("STMTTRNRS")
+	x.elem("TRNUID", "0")
+	o.status()
+	x.open("STMTRS")
+	x.elem("CURDEF", s.Currency)
+	x.open("BANKACCTFROM")
+	x.elem("BANKID", ofxBankID)
+	x.elem("ACCTID", strconv.FormatInt(s.AccountID, 10))
+	x.elem("ACCTTYPE", "CHECKING")
+	x.close("BANKACCTFROM")
+	x.open("BANKTRANLIST")
+	x.elem("DTSTART", ofxTime(s.From))
+	x.elem("DTEND", ofxTime(s.To))
+	return x.flush()
+}
+
+func (o *ofxWriter) status() {
+	o.x.open("STATUS")
+	o.x.elem("CODE", "0")
+	o.x.elem("SEVERITY", "INFO")
+	o.x.close("STATUS")
+}
+
+func (o *ofxWriter) Movement(p model.Posting) error {
+	x := o.x
+	x.open("STMTTRN")
+	x.elem("TRNTYPE", strings.ToUpper(p.Direction))
+	x.elem("DTPOSTED", ofxTime(p.CreatedAt))
+	x.elem("TRNAMT", formatAmount(p.Amount))
+	x.elem("FITID", strconv.FormatInt(p.PostingID, 10))
+	x.elem("NAME", p.Kind)
+	x.elem("MEMO", "Journal entry "+strconv.FormatInt(p.EntryID, 10))
+	x.close("STMTTRN")
+	return x.err
+}
+
+func (o *ofxWriter) End() error {
+	x, s := o.x, o.stmt
+	x.close("BANKTRANLIST")
+	x.open("LEDGERBAL")
+	x.elem("BALAMT", formatAmount(s.ClosingBalance))
+	x.elem("DTASOF", ofxTime(s.To))
+	x.close("LEDGERBAL")
+	x.open("BALLIST")
+	x.open("BAL")
+	x.elem("NAME", "Opening balance")
+	x.elem("DESC", "Balance at DTSTART")
+	x.elem("BALTYPE", "DOLLAR")
+	x.elem("VALUE", formatAmount(s.OpeningBalance))
+	x.elem("DTASOF", ofxTime(s.From))
+	x.close("BAL")
+	x.close("BALLIST")
+	x.close("STMTRS")
+	x.close("STMTTRNRS")
+	x.close("BANKMSGSRSV1")
+	x.close("OFX")
+	return x.end()
+}
@@ -0,0 +1,69 @@
+// Package statement renders account statements as CSV, OFX or ISO 20022
+// CAMT.053. Writers implement model.StatementWriter and stream their output:
+// each movement is written as package storage reads it.
+package statement
+
+import (
+	"io"
+	"slices"
+	"time"
+
+	"go-api-example/model"
+
+	"github.com/shopspring/decimal"
+)
+
+// Format describes an output format.
+type Format struct {
+	Name        string // value of the format query parameter
+	ContentType string
+	Extension   string // file name extension, without the dot
+	newWriter   func(io.Writer) model.StatementWriter
+}
+
+// NewWriter returns a writer rendering a statement to w in this format.
+func (f Format) NewWriter(w io.Writer) model.StatementWriter {
+	return f.newWriter(w)
+}
+
+// formats lists the supported formats; the first is the default.
+var formats = []Format{
+	{Name: "csv", ContentType: "text/csv", Extension: "csv", newWriter: newCSVWriter},
+	{Name: "ofx", ContentType: "application/x-ofx", Extension: "ofx", newWriter: newOFXWriter},
+	{Name: "camt053", ContentType: "application/xml", Extension: "xml", newWriter: newCAMT053Writer},
+}
+
+// Lookup returns the format with the given name; an empty name selects CSV.
+func Lookup(name string) (Format, bool) {
+	if name == "" {
+		return formats[0], true
+	}
+	i := slices.IndexFunc(formats, func(f Format) bool { return f.Name == name })
+	if i < 0 {
+		return Format{}, false
+	}
+	return formats[i], true
+}
+
+// Names returns the names of the supported formats.
+func Names() []string {
+	names := make([]string, len(formats))
+	for i, f := range formats {
+		names[i] = f.Name
+	}
+	return names
+}
+
+// formatAmount renders an amount with at least two decimal places and no more
+// than it has, working on the decimal directly so nothing is lost to floats.
+func formatAmount(d decimal.Decimal) string {
+	if d.Equal(d.Round(2)) {
+		return d.StringFixed(2)
+	}
+	return d.String()
+}
+
+// utc returns t in UTC, truncated to whole seconds for display.
+func utc(t time.Time) time.Time {
+	return t.UTC().Truncate(time.Second)
+}
@@ -0,0 +1,122 @@
+package statement
+
+import (
+	"bytes"
+	"encoding/xml"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go-api-example/model"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// sample is a statement with a debit, a credit with sub-cent precision and a
+// character that needs escaping in XML.
+func sample() (*model.Statement, []model.Posting) {
+	at := func(day, hour int) time.Time { return time.Date(2025, 1, day, hour, 0, 0, 0, time.UTC) }
+	stmt := &model.Statement{
+		AccountID:      1001,
+		Currency:       "EUR",
+		From:           at(1, 0),
+		To:             at(31, 0),
+		OpeningBalance: decimal.RequireFromString("1800.95"),
+		ClosingBalance: decimal.RequireFromString("1600.70005"),
+		CreatedAt:      at(31, 9),
+	}
+	postings := []model.Posting{
+		{PostingID: 11, EntryID: 6, Kind: model.EntryKindTransfer, AccountID: 1001,
+			Amount: decimal.RequireFromString("-250.25"), Currency: "EUR", CreatedAt: at(3, 10)},
+		{PostingID: 14, EntryID: 8, Kind: "fee & adjustment", AccountID: 1001,
+			Amount: decimal.RequireFromString("50.00005"), Currency: "EUR", CreatedAt: at(17, 15)},
+	}
+	for i := range postings {
+		postings[i].Direction = model.DirectionOf(postings[i].Amount)
+	}
+	return stmt, postings
+}
+
+func render(t *testing.T, format string, stmt *model.Statement, postings []model.Posting) []byte {
+	t.Helper()
+	f, ok := Lookup(format)
+	require.True(t, ok)
+	var buf bytes.Buffer
+	w := f.NewWriter(&buf)
+	require.NoError(t, w.Begin(stmt))
+	for _, p := range postings {
+		require.NoError(t, w.Movement(p))
+	}
+	require.NoError(t, w.End())
+	return buf.Bytes()
+}
+
+func TestWriters(t *testing.T) {
+	stmt, postings := sample()
+	for _, name := range Names() {
+		t.Run(name, func(t *testing.T) {
+			f, _ := Lookup(name)
+			got := render(t, name, stmt, postings)
+
+			golden := filepath.Join("testdata", "statement."+f.Extension)
+			if *update {
+				require.NoError(t, os.WriteFile(golden, got, 0o644))
+			}
+			want, err := os.ReadFile(golden)
+			require.NoError(t, err)
+			assert.Equal(t, string(want), string(got))
+
+			if f.Extension != "csv" {
+				dec := xml.NewDecoder(bytes.NewReader(got))
+				for {
+					_, err := dec.Token()
+					if err == io.EOF {
+						break
+					}
+					require.NoError(t, err, "output is not well-formed XML")
+				}
+			}
+		})
+	}
+}
+
+func TestLookup(t *testing.T) {
+	f, ok := Lookup("")
+	require.True(t, ok)
+	assert.Equal(t, "csv", f.Name)
+
+	_, ok = Lookup("pdf")
+	assert.False(t, ok)
+}
+
+func TestFormatAmount(t *testing.T) {
+	for in, want := range map[string]string{
+		"0":            "0.00",
+		"-250.25":      "-250.25",
+		"7":            "7.00",
+		"0.00001":      "0.00001",
+		"12.50000":     "12.50",
+		"99999999.999": "99999999.999",
+	} {
+		assert.Equal(t, want, formatAmount(decimal.RequireFromString(in)), in)
+	}
+}
+
+func TestCSVWriter_ClosingBalanceMustMatch(t *testing.T) {
+	stmt, postings := sample()
+	stmt.ClosingBalance = stmt.ClosingBalance.Add(decimal.New(1, -5))
+
+	w := newCSVWriter(io.Discard)
+	require.NoError(t, w.Begin(stmt))
+	for _, p := range postings {
+		require.NoError(t, w.Movement(p))
+	}
+	assert.Error(t, w.End())
+}
@@ -0,0 +1,68 @@
+package statement
+
+import (
+	"encoding/xml"
+	"io"
+)
+
+// xmlWriter streams indented XML, remembering the first error so that a
+// sequence of writes can be checked once.
+type xmlWriter struct {
+	w   io.Writer
+	enc *xml.Encoder
+	err error
+}
+
+func newXMLWriter(w io.Writer) *xmlWriter {
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	return &xmlWriter{w: w, enc: enc}
+}
+
+func (x *xmlWriter) token(t xml.Token) {
+	if x.err == nil {
+		x.err = x.enc.EncodeToken(t)
+	}
+}
+
+// prolog writes processing instructions such as <?xml ...?>, one per line. It
+// must come before any element; the encoder would put them on a single line.
+func (x *xmlWriter) prolog(instructions ...string) {
+	for _, inst := range instructions {
+		if x.err == nil {
+			_, x.err = io.WriteString(x.w, "<?"+inst+"?>\n")
+		}
+	}
+}
+
+func (x *xmlWriter) open(name string, attrs ...xml.Attr) {
+	x.token(xml.StartElement{Name: xml.Name{Local: name}, Attr: attrs})
+}
+
+func (x *xmlWriter) close(name string) {
+	x.token(xml.EndElement{Name: xml.Name{Local: name}})
+}
+
+// elem writes an element holding text, escaped as needed.
+func (x *xmlWriter) elem(name, text string, attrs ...xml.Attr) {
+	x.open(name, attrs...)
+	x.token(xml.CharData(text))
+	x.close(name)
+}
+
+// flush writes out buffered output and returns the first error.
+func (x *xmlWriter) flush() error {
+	if x.err == nil {
+		x.err = x.enc.Flush()
+	}
+	return x.err
+}
+
+// end flushes the document and terminates it with a newline.
+func (x *xmlWriter) end() error {
+	if err := x.flush(); err != nil {
+		return err
+	}
+	_, err := io.WriteString(x.w, "\n")
+	return err
+}
@@ -0,0 +1,155 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"go-api-example/model"
+)
+
+// ListAccounts returns one page of accounts matching filter, in filter.Sort order
+// with the account ID as tie-breaker. Pages are found by keyset: After and Before
+// compare (sort key, account_id) with the cursor, which the accounts_balance_idx and
+// accounts_created_at_idx indexes (and the primary key) serve as a range scan.
+// The page's Next and Prev are set only when a neighbouring page exists.
+//
+// The balance of a hot account is the sum of its shards, and with filter.AsOf set,
+// balances are computed as of that instant (see GetAccountAsOf); filtering or
+// sorting by such a balance cannot use an index.
+func (s *PostgresStore) ListAccounts(ctx context.Context, filter model.AccountFilter) (*model.AccountPage, error) {
+	limit := filter.Limit
+	if limit <= 0 || limit > model.MaxPageSize {
+		limit = model.MaxPageSize
+	}
+
+	var args []any
+	arg := func(v any) string {
+		args = append(args, v)
+		return "$" + strconv.Itoa(len(args))
+	}
+
+	var where []string
+	balance := cachedBalance
+	if !filter.AsOf.IsZero() {
+		p := arg(filter.AsOf)
+		balance = balanceAsOf(p)
+		where = append(where, "a.created_at <= "+p)
+	}
+	if filter.AfterID > 0 {
+		where = append(where, "a.account_id > "+arg(filter.AfterID))
+	}
+	if filter.Status != "" {
+		where = append(where, "a.status = "+arg(filter.Status))
+	}
+	if filter.Currency != "" {
+		where = append(where, "a.currency = "+arg(filter.Currency))
+	}
+	if filter.MinBalance != nil {
+		where = append(where, balance+" >= "+arg(*filter.MinBalance))
+	}
+	if filter.MaxBalance != nil {
+		where = append(where, balance+" <= "+arg(*filter.MaxBalance))
+	}
+	if !filter.CreatedFrom.IsZero() {
+		where = append(where, "a.created_at >= "+arg(filter.CreatedFrom))
+	}
+	if !filter.CreatedBefore.IsZero() {
+		where = append(where, "a.created_at < "+arg(filter.CreatedBefore))
+	}
+
+	// Walking backwards from a Before cursor scans in the opposite direction;
+	// the rows are reversed into sort order afterwards.
+	backward := filter.Before != nil
+	scanDesc := filter.Desc != backward
+	cursor := filter.After
+	if backward {
+		cursor = filter.Before
+	}
+
+	var sortKey string
+	var cursorKey func(c *model.AccountCursor) any
+	switch filter.Sort {
+	case model.SortBalance:
+		sortKey = balance
+		cursorKey = func(c *model.AccountCursor) any { return c.Balance }
+	case model.SortCreatedAt:
+		sortKey = "a.created_at"
+		cursorKey = func(c *model.AccountCursor) any { return c.CreatedAt }
+	case "", model.SortAccountID:
+	default:
+		return nil, fmt.Errorf("unknown sort order %q", filter.Sort)
+	}
+
+	op, dir := ">", "ASC"
+	if scanDesc {
+		op, dir = "<", "DESC"
+	}
+	orderBy := "a.account_id " + dir
+	if sortKey != "" {
+		orderBy = sortKey + " " + dir + ", " + orderBy
+	}
+	if cursor != nil {
+		if sortKey == "" {
+			where = append(where, "a.account_id "+op+" "+arg(cursor.AccountID))
+		} else {
+			where = append(where, fmt.Sprintf("(%s, a.account_id) %s (%s, %s)", sortKey, op, arg(cursorKey(cursor)), arg(cursor.AccountID)))
+		}
+	}
+
+	query := "SELECT a.account_id, " + balance + ", a.currency, a.owner_name, a.status, a.created_at FROM accounts a"
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	// Fetch one extra row to learn whether another page exists in the scan direction.
+	query += " ORDER BY " + orderBy + " LIMIT " + arg(limit+1)
+
+	rows, err := s.reader(ctx).Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("could not list accounts: %w", err)
+	}
+	defer rows.Close()
+
+	accounts := make([]model.Account, 0, limit+1)
+	for rows.Next() {
+		var acc model.Account
+		if err := rows.Scan(&acc.AccountID, &acc.Balance, &acc.Currency, &acc.OwnerName, &acc.Status, &acc.CreatedAt); err != nil {
+			return nil, fmt.Errorf("could not scan account row: %w", err)
+		}
+		accounts = append(accounts, acc)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	more := len(accounts) > limit
+	if more {
+		accounts = accounts[:limit]
+	}
+	if backward {
+		slices.Reverse(accounts)
+	}
+
+	page := &model.AccountPage{Accounts: accounts}
+	if len(accounts) == 0 {
+		return page, nil
+	}
+	first, last := model.CursorOf(accounts[0]), model.CursorOf(accounts[len(accounts)-1])
+	if backward {
+		// Rows after the Before cursor exist by definition.
+		page.Next = last
+		if more {
+			page.Prev = first
+		}
+	} else {
+		if more {
+			page.Next = last
+		}
+		if cursor != nil {
+			page.Prev = first
+		}
+	}
+	return page, nil
+}
@@ -0,0 +1,176 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"go-api-example/audit"
+	"go-api-example/model"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// approvalColumns selects a transfer_approvals row for scanApproval. A pending
+// approval past its expiry is reported as expired.
+const approvalColumns = `
+	approval_id, source_account_id, destination_account_id, amount,
+	CASE WHEN status = 'pending_approval' AND expires_at <= NOW() THEN 'expired' ELSE status END,
+	requested_by, requested_at, expires_at, COALESCE(decided_by, ''), decided_at, postings`
+
+func scanApproval(row pgx.Row) (*model.TransferApproval, error) {
+	var a model.TransferApproval
+	err := row.Scan(&a.ApprovalID, &a.SourceAccountID, &a.DestinationAccountID, &a.Amount,
+		&a.Status, &a.RequestedBy, &a.RequestedAt, &a.ExpiresAt, &a.DecidedBy, &a.DecidedAt, &a.Postings)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrApprovalNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// RequestTransferApproval holds a transfer until another principal approves or
+// rejects it, or until ttl has passed. The requesting principal is the actor in
+// ctx. The accounts are not checked here: the transfer is validated like any
+// other when it is approved.
+func (s *PostgresStore) RequestTransferApproval(ctx context.Context, req model.TransactionRequest, ttl time.Duration) (*model.TransferApproval, error) {
+	return s.requestApproval(ctx, req, nil, ttl)
+}
+
+// RequestJournalEntryApproval holds a journal entry as RequestTransferApproval
+// holds a transfer. It is posted like any other when it is approved.
+func (s *PostgresStore) RequestJournalEntryApproval(ctx context.Context, req model.JournalEntryRequest, ttl time.Duration) (*model.TransferApproval, error) {
+	return s.requestApproval(ctx, model.TransactionRequest{}, req.Postings, ttl)
+}
+
+// requestApproval holds the transfer req, or the journal entry with postings.
+func (s *PostgresStore) requestApproval(ctx context.Context, req model.TransactionRequest, postings []model.PostingRequest, ttl time.Duration) (*model.TransferApproval, error) {
+	var entry any // NULL for a transfer
+	if postings != nil {
+		entry = postings
+	}
+	var approval *model.TransferApproval
+	err := s.inTx(ctx, pgx.TxOptions{}, func(tx pgx.Tx) error {
+		query := `
+			INSERT INTO transfer_approvals (source_account_id, destination_account_id, amount, requested_by, expires_at, postings)
+			VALUES ($1, $2, $3, $4, NOW() + $5::interval, $6)
+			RETURNING ` + approvalColumns
+		var err error
+		approval, err = scanApproval(tx.QueryRow(ctx, query,
+			req.SourceAccountID, req.DestinationAccountID, req.Amount, audit.ActorFrom(ctx), ttl, entry))
+		if err != nil {
+			return fmt.Errorf("could not hold transfer for approval: %w", err)
+		}
+		return appendAudit(ctx, tx, audit.ActionApprovalRequest, nil, approval)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return approval, nil
+}
+
+// GetTransferApproval returns a transfer approval.
+func (s *PostgresStore) GetTransferApproval(ctx context.Context, id int64) (*model.TransferApproval, error) {
+	return scanApproval(s.db.QueryRow(ctx, "SELECT "+approvalColumns+" FROM transfer_approvals WHERE approval_id = $1", id))
+}
+
+// ListTransferApprovals returns a page of transfer approvals ordered by ID.
+func (s *PostgresStore) ListTransferApprovals(ctx context.Context, filter model.ApprovalFilter) ([]model.TransferApproval, error) {
+	limit := filter.Limit
+	if limit <= 0 || limit > model.MaxPageSize {
+		limit = model.MaxPageSize
+	}
+
+	args := []any{filter.AfterID}
+	where := "approval_id > $1"
+	switch filter.Status {
+	case "":
+	case model.ApprovalStatusPending:
+		where += " AND status = 'pending_approval' AND expires_at > NOW()"
+	case model.ApprovalStatusExpired:
+		where += " AND status = 'pending_approval' AND expires_at <= NOW()"
+	default:
+		args = append(args, filter.Status)
+		where += " AND status = $" + strconv.Itoa(len(args))
+	}
+	args = append(args, limit)
+	query := "SELECT " + approvalColumns + " FROM transfer_approvals WHERE " + where +
+		" ORDER BY approval_id LIMIT $" + strconv.Itoa(len(args))
+	rows, err := s.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("could not query transfer approvals: %w", err)
+	}
+	defer rows.Close()
+
+	approvals := []model.TransferApproval{}
+	for rows.Next() {
+		a, err := scanApproval(rows)
+		if err != nil {
+			return nil, fmt.Errorf("could not scan transfer approval: %w", err)
+		}
+		approvals = append(approvals, *a)
+	}
+	return approvals, rows.Err()
+}
+
+// DecideTransferApproval approves or rejects a pending transfer on behalf of the
+// actor in ctx, who must not be the principal who requested it. Approving
+// executes the transfer, or posts the journal entry, in the same transaction as
+// the decision: if the transfer fails (insufficient funds, frozen account, ...) the error is returned and the
+// approval stays pending, so it can be approved again later or rejected.
+// The decision is recorded with the deciding principal and time, and audited.
+func (s *PostgresStore) DecideTransferApproval(ctx context.Context, id int64, approve bool) (*model.TransferApproval, error) {
+	var after *model.TransferApproval
+	err := s.inTx(ctx, pgx.TxOptions{}, func(tx pgx.Tx) error {
+		before, err := scanApproval(tx.QueryRow(ctx,
+			"SELECT "+approvalColumns+" FROM transfer_approvals WHERE approval_id = $1 FOR UPDATE", id))
+		if err != nil {
+			return err
+		}
+		actor := audit.ActorFrom(ctx)
+		switch {
+		case before.Status == model.ApprovalStatusExpired:
+			return ErrApprovalExpired
+		case before.Status != model.ApprovalStatusPending:
+			return ErrApprovalNotPending
+		case before.RequestedBy == actor:
+			return ErrSelfApproval
+		}
+
+		status := model.ApprovalStatusRejected
+		if approve {
+			status = model.ApprovalStatusApproved
+			if err := applyApproved(ctx, tx, before); err != nil {
+				return err
+			}
+		}
+
+		query := `
+			UPDATE transfer_approvals SET status = $2, decided_by = $3, decided_at = NOW()
+			WHERE approval_id = $1
+			RETURNING ` + approvalColumns
+		after, err = scanApproval(tx.QueryRow(ctx, query, id, status, actor))
+		if err != nil {
+			return fmt.Errorf("could not record approval decision: %w", err)
+		}
+		return appendAudit(ctx, tx, audit.ActionApprovalDecide, before, after)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return after, nil
+}
+
+// applyApproved executes the transfer, or posts the journal entry, of a in tx.
+func applyApproved(ctx context.Context, tx pgx.Tx, a *model.TransferApproval) error {
+	if len(a.Postings) > 0 {
+		_, err := applyJournalEntry(ctx, tx, model.JournalEntryRequest{Postings: a.Postings})
+		return err
+	}
+	_, err := applyTransfer(ctx, tx, a.TransactionRequest)
+	return transferError(a.TransactionRequest, err)
+}
@@ -0,0 +1,122 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go-api-example/audit"
+	"go-api-example/model"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransferApproval(t *testing.T) {
+	ctx := context.Background()
+	truncateTables(t, ctx)
+	require.NoError(t, testStore.CreateAccount(ctx, model.Account{AccountID: 1, Balance: decimal.NewFromInt(100)}))
+	require.NoError(t, testStore.CreateAccount(ctx, model.Account{AccountID: 2, Balance: decimal.Zero}))
+	require.NoError(t, testStore.CreateAccount(ctx, model.Account{AccountID: 3, Balance: decimal.NewFromInt(50)}))
+	alice := audit.WithActor(ctx, "api:alice")
+	bob := audit.WithActor(ctx, "api:bob")
+	req := model.TransactionRequest{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromInt(150)}
+
+	// Arrange: a held transfer the source cannot yet afford
+	approval, err := testStore.RequestTransferApproval(alice, req, time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, model.ApprovalStatusPending, approval.Status)
+	assert.Equal(t, "api:alice", approval.RequestedBy)
+	assert.True(t, approval.ExpiresAt.After(approval.RequestedAt))
+
+	// Act & Assert: the requester cannot decide it
+	_, err = testStore.DecideTransferApproval(alice, approval.ApprovalID, true)
+	assert.ErrorIs(t, err, ErrSelfApproval)
+
+	// A failed transfer leaves the approval pending
+	_, err = testStore.DecideTransferApproval(bob, approval.ApprovalID, true)
+	assert.ErrorIs(t, err, ErrInsufficientFunds)
+	got, err := testStore.GetTransferApproval(ctx, approval.ApprovalID)
+	require.NoError(t, err)
+	assert.Equal(t, model.ApprovalStatusPending, got.Status)
+
+	// Once funded, approval executes the transfer
+	require.NoError(t, executeTransfer(ctx, model.TransactionRequest{SourceAccountID: 3, DestinationAccountID: 1, Amount: decimal.NewFromInt(50)}))
+	decided, err := testStore.DecideTransferApproval(bob, approval.ApprovalID, true)
+	require.NoError(t, err)
+	assert.Equal(t, model.ApprovalStatusApproved, decided.Status)
+	assert.Equal(t, "api:bob", decided.DecidedBy)
+	require.NotNil(t, decided.DecidedAt)
+
+	acc, err := testStore.GetAccount(ctx, 2)
+	require.NoError(t, err)
+	assert.True(t, decimal.NewFromInt(150).Equal(acc.Balance))
+
+	_, err = testStore.DecideTransferApproval(bob, approval.ApprovalID, false)
+	assert.ErrorIs(t, err, ErrApprovalNotPending)
+
+	_, err = testStore.GetTransferApproval(ctx, 999)
+	assert.ErrorIs(t, err, ErrApprovalNotFound)
+}
+
+func TestTransferApproval_JournalEntry(t *testing.T) {
+	ctx := context.Background()
+	truncateTables(t, ctx)
+	require.NoError(t, testStore.CreateAccount(ctx, model.Account{AccountID: 1, Balance: decimal.NewFromInt(100)}))
+	require.NoError(t, testStore.CreateAccount(ctx, model.Account{AccountID: 2, Balance: decimal.Zero}))
+	require.NoError(t, testStore.CreateAccount(ctx, model.Account{AccountID: 3, Balance: decimal.Zero}))
+	req := model.JournalEntryRequest{Postings: []model.PostingRequest{
+		{AccountID: 1, Amount: decimal.NewFromInt(-60), Currency: "XXX"},
+		{AccountID: 2, Amount: decimal.NewFromInt(50), Currency: "XXX"},
+		{AccountID: 3, Amount: decimal.NewFromInt(10), Currency: "XXX"},
+	}}
+
+	approval, err := testStore.RequestJournalEntryApproval(audit.WithActor(ctx, "api:alice"), req, time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, model.ApprovalStatusPending, approval.Status)
+	assert.Zero(t, approval.SourceAccountID)
+	assert.Equal(t, req.Postings, approval.Postings)
+	got, err := testStore.GetTransferApproval(ctx, approval.ApprovalID)
+	require.NoError(t, err)
+	require.Len(t, got.Postings, 3)
+	assert.True(t, decimal.NewFromInt(-60).Equal(got.Postings[0].Amount))
+
+	decided, err := testStore.DecideTransferApproval(audit.WithActor(ctx, "api:bob"), approval.ApprovalID, true)
+	require.NoError(t, err)
+	assert.Equal(t, model.ApprovalStatusApproved, decided.Status)
+	for id, balance := range map[int64]int64{1: 40, 2: 50, 3: 10} {
+		acc, err := testStore.GetAccount(ctx, id)
+		require.NoError(t, err)
+		assert.True(t, decimal.NewFromInt(balance).Equal(acc.Balance), "account %d", id)
+	}
+
+	// Transfer approvals still have no postings
+	transfer, err := testStore.RequestTransferApproval(ctx, model.TransactionRequest{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromInt(5)}, time.Hour)
+	require.NoError(t, err)
+	assert.Nil(t, transfer.Postings)
+}
+
+func TestTransferApproval_Expiry(t *testing.T) {
+	ctx := audit.WithActor(context.Background(), "api:alice")
+	truncateTables(t, ctx)
+
+	expired, err := testStore.RequestTransferApproval(ctx, model.TransactionRequest{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromInt(5)}, time.Millisecond)
+	require.NoError(t, err)
+	pending, err := testStore.RequestTransferApproval(ctx, model.TransactionRequest{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromInt(5)}, time.Hour)
+	require.NoError(t, err)
+	time.Sleep(10 * time.Millisecond)
+
+	_, err = testStore.DecideTransferApproval(audit.WithActor(ctx, "api:bob"), expired.ApprovalID, false)
+	assert.ErrorIs(t, err, ErrApprovalExpired)
+
+	list, err := testStore.ListTransferApprovals(ctx, model.ApprovalFilter{Status: model.ApprovalStatusExpired})
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	assert.Equal(t, expired.ApprovalID, list[0].ApprovalID)
+
+	list, err = testStore.ListTransferApprovals(ctx, model.ApprovalFilter{Status: model.ApprovalStatusPending})
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	assert.Equal(t, pending.ApprovalID, list[0].ApprovalID)
+}
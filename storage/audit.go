@@ -0,0 +1,219 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"go-api-example/audit"
+	"go-api-example/model"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/shopspring/decimal"
+)
+
+// statusChange is the audited state of an account status change.
+type statusChange struct {
+	AccountID int64  `json:"account_id"`
+	Status    string `json:"status"`
+}
+
+// accountBalance is the audited balance of one account.
+type accountBalance struct {
+	AccountID int64           `json:"account_id"`
+	Balance   decimal.Decimal `json:"balance"`
+}
+
+// entryState is the audited state around a journal entry: the balances of the
+// accounts it touches and, afterwards, the entry itself.
+type entryState struct {
+	EntryID  int64            `json:"entry_id,omitempty"`
+	Kind     string           `json:"kind,omitempty"`
+	Postings []model.Posting  `json:"postings,omitempty"`
+	Balances []accountBalance `json:"balances"`
+}
+
+// auditChange is the state before and after one audited change.
+type auditChange struct {
+	before, after any
+}
+
+// auditChainBatch bounds the records chainAudit chains in one transaction.
+const auditChainBatch = 1000
+
+// auditChainInterval is how often a store chains the audit records of
+// committed changes in the background.
+const auditChainInterval = time.Second
+
+// appendAudit adds a record of a change to audit_pending inside tx, so the
+// record commits or rolls back together with the change it describes. The
+// actor and request ID come from ctx. It takes no lock: chainAudit links the
+// records of committed changes into audit_log afterwards, so that concurrent
+// changes, such as credits to the shards of a hot account, do not queue up on
+// the head of the chain.
+func appendAudit(ctx context.Context, tx pgx.Tx, action string, before, after any) error {
+	return appendAudits(ctx, tx, action, []auditChange{{before: before, after: after}})
+}
+
+// appendAudits is appendAudit for a batch of changes with the same action,
+// written with COPY and chained in order.
+func appendAudits(ctx context.Context, tx pgx.Tx, action string, changes []auditChange) error {
+	if len(changes) == 0 {
+		return nil
+	}
+	createdAt := time.Now().UTC().Truncate(time.Microsecond) // the precision of TIMESTAMPTZ
+	rows := make([][]any, 0, len(changes))
+	for _, ch := range changes {
+		before, err := json.Marshal(ch.before)
+		if err != nil {
+			return fmt.Errorf("could not encode audit record: %w", err)
+		}
+		after, err := json.Marshal(ch.after)
+		if err != nil {
+			return fmt.Errorf("could not encode audit record: %w", err)
+		}
+		rows = append(rows, []any{createdAt, audit.ActorFrom(ctx), audit.RequestIDFrom(ctx), action, string(before), string(after)})
+	}
+
+	columns := []string{"created_at", "actor", "request_id", "action", "before", "after"}
+	if _, err := tx.CopyFrom(ctx, pgx.Identifier{"audit_pending"}, columns, pgx.CopyFromRows(rows)); err != nil {
+		return fmt.Errorf("could not write audit records: %w", err)
+	}
+	return nil
+}
+
+// chainAudit moves the records of committed changes from audit_pending to the
+// end of the audit chain, in the order they were appended, under the row lock
+// on audit_head. It runs every auditChainInterval and before the audit log is
+// read, so readers always see every committed change.
+func (s *PostgresStore) chainAudit(ctx context.Context) error {
+	for {
+		var chained int
+		err := s.inTx(ctx, pgx.TxOptions{}, func(tx pgx.Tx) error {
+			var err error
+			chained, err = chainAuditBatch(ctx, tx)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		if chained < auditChainBatch {
+			return nil
+		}
+	}
+}
+
+// chainAuditBatch chains up to auditChainBatch pending records inside tx and
+// returns how many it chained.
+func chainAuditBatch(ctx context.Context, tx pgx.Tx) (int, error) {
+	var seq int64
+	var prevHash string
+	if err := tx.QueryRow(ctx, "SELECT seq, hash FROM audit_head WHERE id = 1 FOR UPDATE").Scan(&seq, &prevHash); err != nil {
+		return 0, fmt.Errorf("could not lock audit chain: %w", err)
+	}
+	query := `
+		SELECT id, created_at, actor, request_id, action, before::text, after::text
+		FROM audit_pending
+		ORDER BY id
+		LIMIT $1`
+	pending, err := tx.Query(ctx, query, auditChainBatch)
+	if err != nil {
+		return 0, fmt.Errorf("could not query pending audit records: %w", err)
+	}
+	var ids []int64
+	var rows [][]any
+	for pending.Next() {
+		var id int64
+		var rec model.AuditRecord
+		var before, after string
+		if err := pending.Scan(&id, &rec.CreatedAt, &rec.Actor, &rec.RequestID, &rec.Action, &before, &after); err != nil {
+			pending.Close()
+			return 0, fmt.Errorf("could not scan pending audit record: %w", err)
+		}
+		rec.Before, rec.After = json.RawMessage(before), json.RawMessage(after)
+		seq++
+		rec.Seq = seq
+		rec.PrevHash = prevHash
+		rec.Hash = audit.Hash(rec)
+		prevHash = rec.Hash
+		ids = append(ids, id)
+		rows = append(rows, []any{rec.Seq, rec.CreatedAt, rec.Actor, rec.RequestID, rec.Action,
+			before, after, rec.PrevHash, rec.Hash})
+	}
+	if err := pending.Err(); err != nil {
+		return 0, fmt.Errorf("could not query pending audit records: %w", err)
+	}
+	if len(rows) == 0 {
+		return 0, nil
+	}
+
+	columns := []string{"seq", "created_at", "actor", "request_id", "action", "before", "after", "prev_hash", "hash"}
+	if _, err := tx.CopyFrom(ctx, pgx.Identifier{"audit_log"}, columns, pgx.CopyFromRows(rows)); err != nil {
+		return 0, fmt.Errorf("could not write audit records: %w", err)
+	}
+	if _, err := tx.Exec(ctx, "SELECT set_config('audit.chaining', 'on', true)"); err != nil {
+		return 0, fmt.Errorf("could not remove chained audit records: %w", err)
+	}
+	if _, err := tx.Exec(ctx, "DELETE FROM audit_pending WHERE id = ANY($1)", ids); err != nil {
+		return 0, fmt.Errorf("could not remove chained audit records: %w", err)
+	}
+	if _, err := tx.Exec(ctx, "UPDATE audit_head SET seq = $1, hash = $2 WHERE id = 1", seq, prevHash); err != nil {
+		return 0, fmt.Errorf("could not advance audit chain: %w", err)
+	}
+	return len(rows), nil
+}
+
+// chainAuditEvery runs chainAudit every interval until ctx is cancelled.
+func (s *PostgresStore) chainAuditEvery(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if err := s.chainAudit(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("Could not chain audit records: %v", err)
+		}
+	}
+}
+
+// AuditLog returns a page of audit records in sequence order, starting after
+// filter.AfterSeq. The records of every change committed so far are chained
+// first.
+func (s *PostgresStore) AuditLog(ctx context.Context, filter model.AuditFilter) ([]model.AuditRecord, error) {
+	if err := s.chainAudit(ctx); err != nil {
+		return nil, err
+	}
+	limit := filter.Limit
+	if limit <= 0 || limit > model.MaxPageSize {
+		limit = model.MaxPageSize
+	}
+
+	query := `
+		SELECT seq, created_at, actor, request_id, action, before::text, after::text, prev_hash, hash
+		FROM audit_log
+		WHERE seq > $1
+		ORDER BY seq
+		LIMIT $2`
+	rows, err := s.db.Query(ctx, query, filter.AfterSeq, limit)
+	if err != nil {
+		return nil, fmt.Errorf("could not query audit log: %w", err)
+	}
+	defer rows.Close()
+
+	records := make([]model.AuditRecord, 0, limit)
+	for rows.Next() {
+		var rec model.AuditRecord
+		var before, after string
+		if err := rows.Scan(&rec.Seq, &rec.CreatedAt, &rec.Actor, &rec.RequestID, &rec.Action, &before, &after, &rec.PrevHash, &rec.Hash); err != nil {
+			return nil, fmt.Errorf("could not scan audit row: %w", err)
+		}
+		rec.Before, rec.After = json.RawMessage(before), json.RawMessage(after)
+		records = append(records, rec)
+	}
+	return records, rows.Err()
+}
@@ -0,0 +1,102 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go-api-example/audit"
+	"go-api-example/model"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuditLog(t *testing.T) {
+	truncateTables(t, context.Background())
+	ctx := audit.WithRequestID(audit.WithActor(context.Background(), "cli:test"), "req-42")
+
+	// Arrange: one of each audited change, plus an idempotent create and a failed transfer
+	require.NoError(t, testStore.CreateAccount(ctx, model.Account{AccountID: 1, Balance: decimal.NewFromInt(100)}))
+	require.NoError(t, testStore.CreateAccount(ctx, model.Account{AccountID: 2, Balance: decimal.Zero}))
+	require.NoError(t, testStore.CreateAccount(ctx, model.Account{AccountID: 2, Balance: decimal.Zero}))
+	require.NoError(t, executeTransfer(ctx, model.TransactionRequest{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromInt(40)}))
+	require.Error(t, executeTransfer(ctx, model.TransactionRequest{SourceAccountID: 2, DestinationAccountID: 1, Amount: decimal.NewFromInt(400)}))
+	require.NoError(t, testStore.SetAccountStatus(ctx, 2, model.AccountStatusFrozen))
+
+	// Act
+	records, err := testStore.AuditLog(ctx, model.AuditFilter{})
+	require.NoError(t, err)
+
+	// Assert
+	require.Len(t, records, 4)
+	assert.Equal(t, []string{audit.ActionAccountCreate, audit.ActionAccountCreate, audit.ActionEntryPost, audit.ActionAccountStatus},
+		[]string{records[0].Action, records[1].Action, records[2].Action, records[3].Action})
+	assert.Equal(t, "cli:test", records[2].Actor)
+	assert.Equal(t, "req-42", records[2].RequestID)
+	assert.JSONEq(t, `{"account_id":2,"status":"active"}`, string(records[3].Before))
+	assert.JSONEq(t, `{"account_id":2,"status":"frozen"}`, string(records[3].After))
+	assert.Contains(t, string(records[2].After), `"balance":"60"`)
+
+	v := audit.NewVerifier()
+	for _, rec := range records {
+		require.NoError(t, v.Check(rec))
+	}
+
+	t.Run("records cannot be edited", func(t *testing.T) {
+		_, err := testStore.db.Exec(ctx, "UPDATE audit_log SET actor = 'mallory' WHERE seq = 2")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "immutable")
+	})
+
+	t.Run("pending records cannot be edited or removed", func(t *testing.T) {
+		for _, stmt := range []string{
+			"UPDATE audit_pending SET actor = 'mallory' WHERE id = $1",
+			"DELETE FROM audit_pending WHERE id = $1",
+		} {
+			tx, err := testStore.db.Begin(ctx)
+			require.NoError(t, err)
+			var id int64
+			require.NoError(t, tx.QueryRow(ctx, `INSERT INTO audit_pending (created_at, actor, request_id, action, before, after)
+				VALUES (NOW(), 'alice', 'req-1', 'account.create', '{}', '{}') RETURNING id`).Scan(&id))
+			_, err = tx.Exec(ctx, stmt, id)
+			require.Error(t, err, stmt)
+			assert.Contains(t, err.Error(), "immutable")
+			require.NoError(t, tx.Rollback(ctx))
+		}
+	})
+}
+
+func TestAuditLog_ChangesDoNotWaitForTheChain(t *testing.T) {
+	ctx := context.Background()
+	truncateTables(t, ctx)
+	require.NoError(t, testStore.CreateAccount(ctx, model.Account{AccountID: 1, Balance: decimal.NewFromInt(100)}))
+	require.NoError(t, testStore.CreateAccount(ctx, model.Account{AccountID: 2, Balance: decimal.Zero}))
+
+	// Arrange: hold the head of the chain, as chainAudit does
+	tx, err := testStore.db.Begin(ctx)
+	require.NoError(t, err)
+	defer tx.Rollback(ctx)
+	_, err = tx.Exec(ctx, "SELECT 1 FROM audit_head WHERE id = 1 FOR UPDATE")
+	require.NoError(t, err)
+
+	// Act: the transfer commits without waiting for it
+	transferCtx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+	require.NoError(t, executeTransfer(transferCtx, model.TransactionRequest{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromInt(40)}))
+	require.NoError(t, tx.Rollback(ctx))
+
+	// Assert: its record is chained before the log is read
+	records, err := testStore.AuditLog(ctx, model.AuditFilter{})
+	require.NoError(t, err)
+	require.Len(t, records, 3)
+	assert.Equal(t, audit.ActionEntryPost, records[2].Action)
+	v := audit.NewVerifier()
+	for _, rec := range records {
+		require.NoError(t, v.Check(rec))
+	}
+	var pending int
+	require.NoError(t, testStore.db.QueryRow(ctx, "SELECT COUNT(*) FROM audit_pending").Scan(&pending))
+	assert.Zero(t, pending)
+}
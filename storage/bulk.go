@@ -0,0 +1,102 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+
+	"go-api-example/audit"
+	"go-api-example/model"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// CreateAccounts creates a batch of accounts with the same per-row semantics as
+// CreateAccount: an account whose ID already exists is left untouched. It reports,
+// in input order, whether each account was created. An ID repeated within the batch
+// is created by its first occurrence.
+//
+// The batch is written with COPY into a temporary table and a single
+// INSERT ... ON CONFLICT DO NOTHING, in one transaction: either every new account
+// of the batch is committed, together with its audit record, or none is.
+func (s *PostgresStore) CreateAccounts(ctx context.Context, accounts []model.Account) ([]bool, error) {
+	if len(accounts) == 0 {
+		return []bool{}, nil
+	}
+	var created []bool
+	err := s.inTx(ctx, pgx.TxOptions{}, func(tx pgx.Tx) error {
+		var err error
+		created, err = insertAccounts(ctx, tx, accounts)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+// insertAccounts creates a batch of accounts in tx for CreateAccounts.
+func insertAccounts(ctx context.Context, tx pgx.Tx, accounts []model.Account) ([]bool, error) {
+	_, err := tx.Exec(ctx, `
+		CREATE TEMPORARY TABLE accounts_import (
+			ord INT NOT NULL,
+			account_id BIGINT NOT NULL,
+			balance NUMERIC(19, 5) NOT NULL,
+			currency TEXT NOT NULL,
+			owner_name TEXT NOT NULL
+		) ON COMMIT DROP`)
+	if err != nil {
+		return nil, fmt.Errorf("could not create import table: %w", err)
+	}
+
+	rows := make([][]any, len(accounts))
+	for i, acc := range accounts {
+		if acc.Currency == "" {
+			acc.Currency = model.DefaultCurrency
+		}
+		rows[i] = []any{i, acc.AccountID, acc.Balance, acc.Currency, acc.OwnerName}
+	}
+	columns := []string{"ord", "account_id", "balance", "currency", "owner_name"}
+	if _, err := tx.CopyFrom(ctx, pgx.Identifier{"accounts_import"}, columns, pgx.CopyFromRows(rows)); err != nil {
+		return nil, fmt.Errorf("could not copy accounts: %w", err)
+	}
+
+	// DISTINCT ON keeps the first occurrence of a repeated ID; ON CONFLICT skips IDs
+	// that already exist.
+	query := `
+		INSERT INTO accounts (account_id, balance, opening_balance, currency, owner_name)
+		SELECT account_id, balance, balance, currency, owner_name
+		FROM (SELECT DISTINCT ON (account_id) * FROM accounts_import ORDER BY account_id, ord) AS batch
+		ON CONFLICT (account_id) DO NOTHING
+		RETURNING account_id`
+	inserted, err := tx.Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("could not insert accounts: %w", err)
+	}
+	ids, err := pgx.CollectRows(inserted, pgx.RowTo[int64])
+	if err != nil {
+		return nil, fmt.Errorf("could not insert accounts: %w", err)
+	}
+
+	created := make([]bool, len(accounts))
+	isNew := make(map[int64]bool, len(ids))
+	for _, id := range ids {
+		isNew[id] = true
+	}
+	changes := make([]auditChange, 0, len(ids))
+	for i, acc := range accounts {
+		if !isNew[acc.AccountID] {
+			continue
+		}
+		isNew[acc.AccountID] = false // later occurrences already existed
+		created[i] = true
+		if acc.Currency == "" {
+			acc.Currency = model.DefaultCurrency
+		}
+		acc.Status = model.AccountStatusActive
+		changes = append(changes, auditChange{after: acc})
+	}
+	if err := appendAudits(ctx, tx, audit.ActionAccountCreate, changes); err != nil {
+		return nil, err
+	}
+	return created, nil
+}
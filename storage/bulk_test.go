@@ -0,0 +1,55 @@
+package storage
+
+import (
+	"context"
+	"testing"
+
+	"go-api-example/audit"
+	"go-api-example/model"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateAccounts(t *testing.T) {
+	ctx := context.Background()
+	truncateTables(t, ctx)
+	require.NoError(t, testStore.CreateAccount(ctx, model.Account{AccountID: 2, Balance: decimal.NewFromInt(5)}))
+
+	// Act: a new account, an existing one, a repeated ID and another currency
+	created, err := testStore.CreateAccounts(ctx, []model.Account{
+		{AccountID: 1, Balance: decimal.NewFromInt(10)},
+		{AccountID: 2, Balance: decimal.NewFromInt(20)},
+		{AccountID: 3, Balance: decimal.NewFromInt(30)},
+		{AccountID: 3, Balance: decimal.NewFromInt(99)},
+		{AccountID: 4, Balance: decimal.NewFromInt(40), Currency: "EUR"},
+	})
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, []bool{true, false, true, false, true}, created)
+
+	acc, err := testStore.GetAccount(ctx, 2)
+	require.NoError(t, err)
+	assert.True(t, decimal.NewFromInt(5).Equal(acc.Balance), "existing accounts are left untouched")
+	acc, err = testStore.GetAccount(ctx, 3)
+	require.NoError(t, err)
+	assert.True(t, decimal.NewFromInt(30).Equal(acc.Balance), "the first occurrence of a repeated ID wins")
+	acc, err = testStore.GetAccount(ctx, 4)
+	require.NoError(t, err)
+	assert.Equal(t, "EUR", acc.Currency)
+
+	report, err := testStore.Reconcile(ctx, model.ReconcileOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, report.Mismatches)
+
+	records, err := testStore.AuditLog(ctx, model.AuditFilter{})
+	require.NoError(t, err)
+	require.Len(t, records, 4, "one audit record per created account")
+	v := audit.NewVerifier()
+	for _, rec := range records {
+		assert.Equal(t, audit.ActionAccountCreate, rec.Action)
+		require.NoError(t, v.Check(rec))
+	}
+}
@@ -0,0 +1,226 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go-api-example/audit"
+	"go-api-example/model"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// chargeFee charges the fee of the transfer recorded as entry: a journal entry
+// of its own, in the currency of the transfer, that debits the source account
+// and credits the schedule's revenue account. It is linked to the transfer in
+// transfer_fees. A fee that comes to zero is not charged, and nil is returned.
+func chargeFee(ctx context.Context, tx pgx.Tx, fees *model.AccountFees, req model.TransactionRequest, entry *model.JournalEntry) (*model.FeeBreakdown, error) {
+	f := fees.Effective.Fee(req.Amount)
+	f.Source = model.FeeSourceAccount
+	if fees.Override == nil {
+		f.Source, f.Tier = model.FeeSourceTier, fees.Tier
+	}
+	if !f.Amount.IsPositive() {
+		return nil, nil
+	}
+
+	currency := entry.Postings[0].Currency
+	feeEntry, err := applyEntry(ctx, tx, model.EntryKindFee, []model.Posting{
+		{AccountID: req.SourceAccountID, Amount: f.Amount.Neg(), Currency: currency},
+		{AccountID: f.RevenueAccountID, Amount: f.Amount, Currency: currency},
+	})
+	if err != nil {
+		return nil, err
+	}
+	f.EntryID = feeEntry.EntryID
+
+	query := "INSERT INTO transfer_fees (entry_id, fee_entry_id, breakdown) VALUES ($1, $2, $3)"
+	if _, err := tx.Exec(ctx, query, entry.EntryID, f.EntryID, f); err != nil {
+		return nil, fmt.Errorf("could not record transfer fee: %w", err)
+	}
+	return &f, nil
+}
+
+// lockAccounts takes the row locks of the given accounts as applyEntry does;
+// see lockAccountRows. Accounts that do not exist are skipped.
+func lockAccounts(ctx context.Context, tx pgx.Tx, ids ...int64) error {
+	_, err := lockAccountRows(ctx, tx, ids)
+	return err
+}
+
+// accountFees returns the tier, own schedule and effective schedule of an
+// account, whether or not it exists. The tier is the one its limits assign.
+func accountFees(ctx context.Context, tx pgx.Tx, id int64) (*model.AccountFees, error) {
+	fees := &model.AccountFees{AccountID: id}
+	var tier *model.FeeSchedule
+	query := `
+		SELECT COALESCE(l.tier, $2), a.schedule, t.schedule
+		FROM (SELECT $1::BIGINT AS account_id) x
+		LEFT JOIN account_limits l ON l.account_id = x.account_id
+		LEFT JOIN account_fees a ON a.account_id = x.account_id
+		LEFT JOIN tier_fees t ON t.tier = COALESCE(l.tier, $2)`
+	if err := tx.QueryRow(ctx, query, id, model.DefaultTier).Scan(&fees.Tier, &fees.Override, &tier); err != nil {
+		return nil, fmt.Errorf("could not load fee schedule of account %d: %w", id, err)
+	}
+	fees.Effective = override(fees.Override, tier)
+	return fees, nil
+}
+
+// checkRevenueAccount reports a schedule whose revenue account does not exist,
+// or is held in a currency other than those of the accounts the schedule
+// charges, since fees are credited in the currency of the transfer.
+func checkRevenueAccount(ctx context.Context, tx pgx.Tx, schedule *model.FeeSchedule, currencies []string) error {
+	if schedule == nil {
+		return nil
+	}
+	var currency string
+	err := tx.QueryRow(ctx, "SELECT currency FROM accounts WHERE account_id = $1", schedule.RevenueAccountID).Scan(&currency)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return &AccountError{AccountID: schedule.RevenueAccountID, Err: ErrNotFound}
+	}
+	if err != nil {
+		return fmt.Errorf("could not load revenue account: %w", err)
+	}
+	for _, c := range currencies {
+		if c != currency {
+			return &AccountError{AccountID: schedule.RevenueAccountID, Err: ErrCurrencyMismatch}
+		}
+	}
+	return nil
+}
+
+// tierCurrencies returns the currencies of the accounts a tier's fee schedule
+// charges: those in the tier without a schedule of their own.
+func tierCurrencies(ctx context.Context, tx pgx.Tx, tier string) ([]string, error) {
+	query := `
+		SELECT DISTINCT a.currency
+		FROM accounts a
+		LEFT JOIN account_limits l ON l.account_id = a.account_id
+		LEFT JOIN account_fees f ON f.account_id = a.account_id
+		WHERE COALESCE(l.tier, $2) = $1 AND f.account_id IS NULL
+		ORDER BY a.currency`
+	rows, err := tx.Query(ctx, query, tier, model.DefaultTier)
+	if err != nil {
+		return nil, fmt.Errorf("could not query currencies of tier %s: %w", tier, err)
+	}
+	currencies, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, fmt.Errorf("could not query currencies of tier %s: %w", tier, err)
+	}
+	return currencies, nil
+}
+
+// ListTierFees returns the fee schedule of every tier that has one, ordered by tier.
+func (s *PostgresStore) ListTierFees(ctx context.Context) ([]model.TierFees, error) {
+	rows, err := s.db.Query(ctx, "SELECT tier, schedule FROM tier_fees ORDER BY tier")
+	if err != nil {
+		return nil, fmt.Errorf("could not query tier fees: %w", err)
+	}
+	defer rows.Close()
+
+	tiers := []model.TierFees{}
+	for rows.Next() {
+		var t model.TierFees
+		if err := rows.Scan(&t.Tier, &t.Schedule); err != nil {
+			return nil, fmt.Errorf("could not scan tier fees: %w", err)
+		}
+		tiers = append(tiers, t)
+	}
+	return tiers, rows.Err()
+}
+
+// SetTierFees replaces the fee schedule of a tier, or removes it if schedule is nil.
+func (s *PostgresStore) SetTierFees(ctx context.Context, tier string, schedule *model.FeeSchedule) error {
+	return s.inTx(ctx, pgx.TxOptions{}, func(tx pgx.Tx) error {
+		currencies, err := tierCurrencies(ctx, tx, tier)
+		if err != nil {
+			return err
+		}
+		if err := checkRevenueAccount(ctx, tx, schedule, currencies); err != nil {
+			return err
+		}
+		var before, after *model.TierFees
+		old := model.TierFees{Tier: tier}
+		err = tx.QueryRow(ctx, "SELECT schedule FROM tier_fees WHERE tier = $1 FOR UPDATE", tier).Scan(&old.Schedule)
+		switch {
+		case err == nil:
+			before = &old
+		case !errors.Is(err, pgx.ErrNoRows):
+			return fmt.Errorf("could not lock tier fees: %w", err)
+		}
+
+		if schedule == nil {
+			_, err = tx.Exec(ctx, "DELETE FROM tier_fees WHERE tier = $1", tier)
+		} else {
+			after = &model.TierFees{Tier: tier, Schedule: *schedule}
+			query := "INSERT INTO tier_fees (tier, schedule) VALUES ($1, $2) ON CONFLICT (tier) DO UPDATE SET schedule = $2"
+			_, err = tx.Exec(ctx, query, tier, schedule)
+		}
+		if err != nil {
+			return fmt.Errorf("could not set tier fees: %w", err)
+		}
+		return appendAudit(ctx, tx, audit.ActionFeesTier, before, after)
+	})
+}
+
+// GetAccountFees returns the fee schedules of an account.
+func (s *PostgresStore) GetAccountFees(ctx context.Context, id int64) (*model.AccountFees, error) {
+	tx, err := s.db.BeginTx(ctx, pgx.TxOptions{AccessMode: pgx.ReadOnly})
+	if err != nil {
+		return nil, fmt.Errorf("could not begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var exists bool
+	if err := tx.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM accounts WHERE account_id = $1)", id).Scan(&exists); err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, &AccountError{AccountID: id, Err: ErrNotFound}
+	}
+	return accountFees(ctx, tx, id)
+}
+
+// SetAccountFees replaces the account's own fee schedule, or removes it if
+// schedule is nil, so that its tier's schedule applies.
+func (s *PostgresStore) SetAccountFees(ctx context.Context, id int64, schedule *model.FeeSchedule) (*model.AccountFees, error) {
+	var after *model.AccountFees
+	err := s.inTx(ctx, pgx.TxOptions{}, func(tx pgx.Tx) error {
+		// Lock the account, so the change is ordered with its transfers.
+		var currency string
+		if err := tx.QueryRow(ctx, "SELECT currency FROM accounts WHERE account_id = $1 FOR UPDATE", id).Scan(&currency); err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return &AccountError{AccountID: id, Err: ErrNotFound}
+			}
+			return fmt.Errorf("could not lock account: %w", err)
+		}
+		before, err := accountFees(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+
+		if schedule == nil {
+			_, err = tx.Exec(ctx, "DELETE FROM account_fees WHERE account_id = $1", id)
+		} else {
+			query := "INSERT INTO account_fees (account_id, schedule) VALUES ($1, $2) ON CONFLICT (account_id) DO UPDATE SET schedule = $2"
+			_, err = tx.Exec(ctx, query, id, schedule)
+		}
+		if err != nil {
+			return fmt.Errorf("could not set account fees: %w", err)
+		}
+		after, err = accountFees(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		// Removing the account's schedule makes its tier's apply to it.
+		if err := checkRevenueAccount(ctx, tx, after.Effective, []string{currency}); err != nil {
+			return err
+		}
+		return appendAudit(ctx, tx, audit.ActionFeesAccount, before, after)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return after, nil
+}
@@ -0,0 +1,96 @@
+package storage
+
+import (
+	"context"
+	"testing"
+
+	"go-api-example/model"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransferFees(t *testing.T) {
+	ctx := context.Background()
+	truncateTables(t, ctx)
+	for id, balance := range map[int64]int64{1: 1000, 2: 1000, 3: 10, 9: 0} {
+		require.NoError(t, testStore.CreateAccount(ctx, model.Account{AccountID: id, Balance: decimal.NewFromInt(balance)}))
+	}
+
+	// Arrange: the standard tier pays 1% with a minimum of 2; account 2 pays a flat 0.5
+	minFee := decimal.NewFromInt(2)
+	require.NoError(t, testStore.SetTierFees(ctx, model.DefaultTier, &model.FeeSchedule{
+		Type: model.FeePercentage, RevenueAccountID: 9, Percent: decimal.NewFromInt(1), Min: &minFee,
+	}))
+	fees, err := testStore.SetAccountFees(ctx, 2, &model.FeeSchedule{Type: model.FeeFlat, RevenueAccountID: 9, Flat: decimal.RequireFromString("0.5")})
+	require.NoError(t, err)
+	require.NotNil(t, fees.Override)
+	assert.Equal(t, model.FeeFlat, fees.Effective.Type)
+
+	// Act
+	tr, err := testStore.ExecuteTransfer(ctx, transfer(1, 2, "500"))
+
+	// Assert: the tier's schedule charges 1% of 500 on top of the amount
+	require.NoError(t, err)
+	require.NotNil(t, tr.Fee)
+	assert.Equal(t, model.FeeSourceTier, tr.Fee.Source)
+	assert.Equal(t, model.DefaultTier, tr.Fee.Tier)
+	assert.Equal(t, "5", tr.Fee.Amount.String())
+	assert.Equal(t, "505", tr.Total.String())
+	assertBalance := func(id int64, want string) {
+		t.Helper()
+		acc, err := testStore.GetAccount(ctx, id)
+		require.NoError(t, err)
+		assert.Equal(t, want, acc.Balance.String(), "balance of account %d", id)
+	}
+	assertBalance(1, "495")
+	assertBalance(9, "5")
+
+	// The fee is a journal entry of its own, linked to the transfer
+	ledger, err := testStore.GetAccountPostings(ctx, 1, model.PostingFilter{})
+	require.NoError(t, err)
+	require.Len(t, ledger.Postings, 2)
+	assert.Equal(t, model.EntryKindFee, ledger.Postings[1].Kind)
+	assert.Equal(t, tr.Fee.EntryID, ledger.Postings[1].EntryID)
+	var stored model.FeeBreakdown
+	require.NoError(t, testStore.db.QueryRow(ctx, "SELECT breakdown FROM transfer_fees WHERE entry_id = $1", tr.EntryID).Scan(&stored))
+	assert.Equal(t, tr.Fee.EntryID, stored.EntryID)
+	assert.True(t, tr.Fee.Amount.Equal(stored.Amount))
+
+	// The account's own schedule replaces the tier's
+	tr, err = testStore.ExecuteTransfer(ctx, transfer(2, 1, "100"))
+	require.NoError(t, err)
+	assert.Equal(t, model.FeeSourceAccount, tr.Fee.Source)
+	assert.Equal(t, "0.5", tr.Fee.Amount.String())
+
+	// The source must cover the amount plus the fee; the minimum makes the fee 2
+	_, err = testStore.ExecuteTransfer(ctx, transfer(3, 1, "9"))
+	assert.ErrorIs(t, err, ErrInsufficientFunds)
+	assertBalance(3, "10")
+	_, err = testStore.ExecuteTransfer(ctx, transfer(3, 1, "8"))
+	require.NoError(t, err)
+	assertBalance(3, "0")
+
+	// Without a schedule no fee is charged
+	require.NoError(t, testStore.SetTierFees(ctx, model.DefaultTier, nil))
+	tr, err = testStore.ExecuteTransfer(ctx, transfer(1, 2, "10"))
+	require.NoError(t, err)
+	assert.Nil(t, tr.Fee)
+	assert.Equal(t, "10", tr.Total.String())
+
+	// A schedule needs an existing revenue account
+	err = testStore.SetTierFees(ctx, model.DefaultTier, &model.FeeSchedule{Type: model.FeeFlat, RevenueAccountID: 99, Flat: decimal.NewFromInt(1)})
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	// and one held in the currency of the accounts it charges
+	require.NoError(t, testStore.CreateAccount(ctx, model.Account{AccountID: 8, Balance: decimal.Zero, Currency: "EUR"}))
+	eur := &model.FeeSchedule{Type: model.FeeFlat, RevenueAccountID: 8, Flat: decimal.NewFromInt(1)}
+	err = testStore.SetTierFees(ctx, model.DefaultTier, eur)
+	assert.ErrorIs(t, err, ErrCurrencyMismatch)
+	_, err = testStore.SetAccountFees(ctx, 1, eur)
+	assert.ErrorIs(t, err, ErrCurrencyMismatch)
+	tiers, err := testStore.ListTierFees(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, tiers)
+}
@@ -0,0 +1,307 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go-api-example/audit"
+	"go-api-example/interest"
+	"go-api-example/model"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/shopspring/decimal"
+)
+
+// interestRate returns the interest rate of an account, whether or not it
+// exists, with the interest it has accrued and not posted yet. An account
+// without a rate has a zero ACT/365 rate.
+func interestRate(ctx context.Context, tx pgx.Tx, id int64) (*model.InterestRate, error) {
+	rate := &model.InterestRate{AccountID: id, DayCount: model.DayCountACT365}
+	query := "SELECT annual_rate, day_count, carry FROM interest_rates WHERE account_id = $1"
+	err := tx.QueryRow(ctx, query, id).Scan(&rate.AnnualRate, &rate.DayCount, &rate.Carry)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("could not load interest rate of account %d: %w", id, err)
+	}
+	accruals, err := unpostedAccruals(ctx, tx, id, time.Time{}, time.Time{})
+	if err != nil {
+		return nil, err
+	}
+	rate.Accrued = interest.Sum(accruals)
+	return rate, nil
+}
+
+// unpostedAccruals returns the accruals of an account that have not been
+// posted, for the days in [from, to); a zero bound leaves that side open.
+func unpostedAccruals(ctx context.Context, tx pgx.Tx, id int64, from, to time.Time) ([]interest.Accrual, error) {
+	query := `
+		SELECT balance, annual_rate, days, year FROM interest_accruals
+		WHERE account_id = $1 AND posted_at IS NULL
+		  AND ($2::DATE IS NULL OR day >= $2) AND ($3::DATE IS NULL OR day < $3)
+		ORDER BY day`
+	rows, err := tx.Query(ctx, query, id, nullTime(from), nullTime(to))
+	if err != nil {
+		return nil, fmt.Errorf("could not query interest accruals: %w", err)
+	}
+	defer rows.Close()
+
+	var accruals []interest.Accrual
+	for rows.Next() {
+		var a interest.Accrual
+		if err := rows.Scan(&a.Balance, &a.AnnualRate, &a.Days, &a.Year); err != nil {
+			return nil, fmt.Errorf("could not scan interest accrual: %w", err)
+		}
+		accruals = append(accruals, a)
+	}
+	return accruals, rows.Err()
+}
+
+// nullTime returns nil for the zero time, so it is bound as SQL NULL.
+func nullTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+// GetInterestRate returns the interest rate of an account.
+func (s *PostgresStore) GetInterestRate(ctx context.Context, id int64) (*model.InterestRate, error) {
+	tx, err := s.db.BeginTx(ctx, pgx.TxOptions{AccessMode: pgx.ReadOnly})
+	if err != nil {
+		return nil, fmt.Errorf("could not begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var exists bool
+	if err := tx.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM accounts WHERE account_id = $1)", id).Scan(&exists); err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, &AccountError{AccountID: id, Err: ErrNotFound}
+	}
+	return interestRate(ctx, tx, id)
+}
+
+// SetInterestRate replaces the interest rate of an account. It applies from the
+// next day accrued; days accrued before keep the rate they accrued at.
+func (s *PostgresStore) SetInterestRate(ctx context.Context, id int64, req model.InterestRateRequest) (*model.InterestRate, error) {
+	var after *model.InterestRate
+	err := s.inTx(ctx, pgx.TxOptions{}, func(tx pgx.Tx) error {
+		if err := tx.QueryRow(ctx, "SELECT account_id FROM accounts WHERE account_id = $1 FOR UPDATE", id).Scan(&id); err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return &AccountError{AccountID: id, Err: ErrNotFound}
+			}
+			return fmt.Errorf("could not lock account: %w", err)
+		}
+		before, err := interestRate(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+
+		query := `
+			INSERT INTO interest_rates (account_id, annual_rate, day_count) VALUES ($1, $2, $3)
+			ON CONFLICT (account_id) DO UPDATE SET annual_rate = $2, day_count = $3`
+		if _, err := tx.Exec(ctx, query, id, req.AnnualRate, req.DayCount); err != nil {
+			return fmt.Errorf("could not set interest rate: %w", err)
+		}
+		after, err = interestRate(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		return appendAudit(ctx, tx, audit.ActionInterestRate, before, after)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return after, nil
+}
+
+// AccrueInterest records the interest of the UTC calendar day of day for every
+// account with a positive rate and a positive balance at the end of that day,
+// at its current rate. Accounts that have accrued the day already, and those
+// created after it, are skipped, so a day can be accrued again after a partial
+// run. It returns the number of accruals recorded. The day must have ended by
+// the store's clock, Options.Now.
+func (s *PostgresStore) AccrueInterest(ctx context.Context, day time.Time) (int, error) {
+	day = interest.Day(day)
+	end := day.AddDate(0, 0, 1)
+	if end.After(s.clock()) {
+		return 0, fmt.Errorf("cannot accrue interest for %s before it has ended", day.Format(time.DateOnly))
+	}
+
+	var n int
+	err := s.inTx(ctx, pgx.TxOptions{}, func(tx pgx.Tx) error {
+		var err error
+		n, err = accrueInterest(ctx, tx, day)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// accrueInterest records the interest of day in tx for AccrueInterest.
+func accrueInterest(ctx context.Context, tx pgx.Tx, day time.Time) (int, error) {
+	end := day.AddDate(0, 0, 1)
+
+	// Postings are timestamped to the microsecond, so the balance at the end of
+	// the day is the balance as of the last microsecond before midnight.
+	query := `
+		SELECT account_id, annual_rate, day_count, balance FROM (
+			SELECT a.account_id, r.annual_rate, r.day_count, ` + balanceAsOf("$2") + ` AS balance
+			FROM interest_rates r JOIN accounts a ON a.account_id = r.account_id
+			WHERE r.annual_rate > 0 AND a.created_at <= $2
+			  AND NOT EXISTS (SELECT 1 FROM interest_accruals i WHERE i.account_id = a.account_id AND i.day = $1)
+		) b
+		WHERE balance > 0
+		ORDER BY account_id`
+	rows, err := tx.Query(ctx, query, day, end.Add(-time.Microsecond))
+	if err != nil {
+		return 0, fmt.Errorf("could not query interest-bearing accounts: %w", err)
+	}
+	type accrual struct {
+		accountID int64
+		interest.Accrual
+	}
+	var accruals []accrual
+	for rows.Next() {
+		var id int64
+		var rate, balance decimal.Decimal
+		var dayCount string
+		if err := rows.Scan(&id, &rate, &dayCount, &balance); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("could not scan interest-bearing account: %w", err)
+		}
+		a, err := interest.Accrue(balance, rate, dayCount, day)
+		if err != nil {
+			rows.Close()
+			return 0, &AccountError{AccountID: id, Err: err}
+		}
+		accruals = append(accruals, accrual{accountID: id, Accrual: a})
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	batch := &pgx.Batch{}
+	query = `
+		INSERT INTO interest_accruals (account_id, day, balance, annual_rate, days, year)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (account_id, day) DO NOTHING`
+	for _, a := range accruals {
+		batch.Queue(query, a.accountID, day, a.Balance, a.AnnualRate, a.Days, a.Year)
+	}
+	if err := tx.SendBatch(ctx, batch).Close(); err != nil {
+		return 0, fmt.Errorf("could not record interest accruals: %w", err)
+	}
+	return len(accruals), nil
+}
+
+// PostInterest posts the unposted interest of every account for each UTC month
+// before the month of before, oldest month first. The interest of a month is
+// the exact sum of its accruals plus the carry of the previous posting, rounded
+// down to model.MaxDecimalPlaces; what is rounded off is carried over. It is
+// posted as a transfer from expenseAccountID, subject to the same checks, fees
+// and limits as any other, and each account and month commits on its own.
+//
+// A transfer that fails is reported in the posting's Error and the month is
+// left unposted, so a later run posts it; a transient error stops the run and
+// is returned with the postings made so far. Postings are stamped with the
+// store's clock, and before must not be after it.
+func (s *PostgresStore) PostInterest(ctx context.Context, before time.Time, expenseAccountID int64) ([]model.InterestPosting, error) {
+	if interest.Day(before).After(s.clock()) {
+		return nil, fmt.Errorf("cannot post interest before %s, it has not begun", interest.Day(before).Format(time.DateOnly))
+	}
+	query := `
+		SELECT DISTINCT account_id, date_trunc('month', day)::DATE FROM interest_accruals
+		WHERE posted_at IS NULL AND day < date_trunc('month', $1::DATE)
+		ORDER BY 1, 2`
+	rows, err := s.db.Query(ctx, query, interest.Day(before))
+	if err != nil {
+		return nil, fmt.Errorf("could not query unposted interest: %w", err)
+	}
+	type month struct {
+		accountID int64
+		start     time.Time
+	}
+	months, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (month, error) {
+		var m month
+		err := row.Scan(&m.accountID, &m.start)
+		return m, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not scan unposted interest: %w", err)
+	}
+
+	postings := []model.InterestPosting{}
+	for _, m := range months {
+		p, err := s.postInterest(ctx, m.accountID, m.start, expenseAccountID)
+		if err != nil {
+			if isTransient(ctx, err) {
+				return postings, err
+			}
+			p.Error = err.Error()
+		}
+		postings = append(postings, p)
+	}
+	return postings, nil
+}
+
+// postInterest posts the interest of an account for the month starting at month.
+func (s *PostgresStore) postInterest(ctx context.Context, id int64, month time.Time, expenseAccountID int64) (model.InterestPosting, error) {
+	var p model.InterestPosting
+	var entryID *int64
+	err := s.inTx(ctx, pgx.TxOptions{}, func(tx pgx.Tx) error {
+		p, entryID = model.InterestPosting{AccountID: id, Month: month.Format("2006-01")}, nil
+
+		// The rate row serializes postings of the account, so the carry of one
+		// month is read after the posting of the one before has committed.
+		var carry decimal.Decimal
+		if err := tx.QueryRow(ctx, "SELECT carry FROM interest_rates WHERE account_id = $1 FOR UPDATE", id).Scan(&carry); err != nil {
+			return fmt.Errorf("could not lock interest rate: %w", err)
+		}
+		accruals, err := unpostedAccruals(ctx, tx, id, month, month.AddDate(0, 1, 0))
+		if err != nil {
+			return err
+		}
+		if len(accruals) == 0 {
+			// Posted by a concurrent run.
+			return nil
+		}
+		total := interest.Sum(accruals).Add(carry)
+		p.Amount = total.RoundDown(model.MaxDecimalPlaces)
+
+		if p.Amount.IsPositive() {
+			// Interest is not a transfer: it is charged no fee and does not
+			// count towards the limits of the expense account.
+			req := model.TransactionRequest{SourceAccountID: expenseAccountID, DestinationAccountID: id, Amount: p.Amount}
+			ctx := audit.WithRequestID(ctx, fmt.Sprintf("interest:%d:%s", id, p.Month))
+			entry, err := applyEntry(ctx, tx, model.EntryKindInterest, transferPostings(req))
+			if err != nil {
+				return transferError(req, err)
+			}
+			entryID = &entry.EntryID
+		}
+
+		query := `
+			UPDATE interest_accruals SET entry_id = $4, posted_at = $5
+			WHERE account_id = $1 AND posted_at IS NULL AND day >= $2 AND day < $3`
+		if _, err := tx.Exec(ctx, query, id, month, month.AddDate(0, 1, 0), entryID, s.clock()); err != nil {
+			return fmt.Errorf("could not mark interest accruals posted: %w", err)
+		}
+		if _, err := tx.Exec(ctx, "UPDATE interest_rates SET carry = $2 WHERE account_id = $1", id, total.Sub(p.Amount)); err != nil {
+			return fmt.Errorf("could not update interest carry: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return p, err
+	}
+	if entryID != nil {
+		p.EntryID = *entryID
+	}
+	return p, nil
+}
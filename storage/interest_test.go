@@ -0,0 +1,138 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go-api-example/interest"
+	"go-api-example/model"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInterest(t *testing.T) {
+	ctx := context.Background()
+	truncateTables(t, ctx)
+	for id, balance := range map[int64]int64{1: 3650, 2: 1000, 3: 1000, 900: 1_000_000} {
+		require.NoError(t, testStore.CreateAccount(ctx, model.Account{AccountID: id, Balance: decimal.NewFromInt(balance)}))
+	}
+	// The accounts have existed for the whole of last month.
+	_, err := testStore.db.Exec(ctx, "UPDATE accounts SET created_at = created_at - INTERVAL '70 days'")
+	require.NoError(t, err)
+
+	// Arrange: 10% of 3650 is 1 a day, 1% of 1000 is not a round amount
+	act365 := func(rate string) model.InterestRateRequest {
+		return model.InterestRateRequest{AnnualRate: decimal.RequireFromString(rate), DayCount: model.DayCountACT365}
+	}
+	for id, rate := range map[int64]string{1: "10", 2: "1", 3: "1"} {
+		_, err := testStore.SetInterestRate(ctx, id, act365(rate))
+		require.NoError(t, err)
+	}
+	require.NoError(t, testStore.SetAccountStatus(ctx, 3, model.AccountStatusFrozen))
+
+	// Act: accrue every day of last month, twice
+	thisMonth := interest.Month(time.Now())
+	lastMonth := thisMonth.AddDate(0, -1, 0)
+	days := 0
+	for day := lastMonth; day.Before(thisMonth); day = day.AddDate(0, 0, 1) {
+		n, err := testStore.AccrueInterest(ctx, day)
+		require.NoError(t, err)
+		assert.Equal(t, 3, n)
+		n, err = testStore.AccrueInterest(ctx, day)
+		require.NoError(t, err)
+		assert.Zero(t, n, "a day is accrued once")
+		days++
+	}
+	_, err = testStore.AccrueInterest(ctx, time.Now())
+	assert.Error(t, err, "today has not ended")
+
+	rate, err := testStore.GetInterestRate(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, decimal.NewFromInt(int64(days)).String(), rate.Accrued.String())
+
+	// Interest is not a transfer: the expense account's fees and limits do not apply
+	require.NoError(t, testStore.CreateAccount(ctx, model.Account{AccountID: 901, Balance: decimal.Zero}))
+	_, err = testStore.SetAccountFees(ctx, 900, &model.FeeSchedule{Type: model.FeeFlat, RevenueAccountID: 901, Flat: decimal.NewFromInt(1)})
+	require.NoError(t, err)
+	maxAmount := decimal.NewFromInt(1)
+	_, err = testStore.SetAccountLimits(ctx, 900, model.AccountLimitsRequest{Overrides: model.TransferLimits{MaxAmount: &maxAmount}})
+	require.NoError(t, err)
+
+	// Act: post last month
+	postings, err := testStore.PostInterest(ctx, time.Now(), 900)
+
+	// Assert
+	require.NoError(t, err)
+	require.Len(t, postings, 3)
+	assert.Equal(t, lastMonth.Format("2006-01"), postings[0].Month)
+	assert.Equal(t, decimal.NewFromInt(int64(days)).String(), postings[0].Amount.String())
+	assert.NotZero(t, postings[0].EntryID)
+	ledger, err := testStore.GetAccountPostings(ctx, 1, model.PostingFilter{})
+	require.NoError(t, err)
+	require.Len(t, ledger.Postings, 1)
+	assert.Equal(t, model.EntryKindInterest, ledger.Postings[0].Kind)
+	revenue, err := testStore.GetAccount(ctx, 901)
+	require.NoError(t, err)
+	assert.True(t, revenue.Balance.IsZero(), "no fee is charged on interest")
+
+	// 1000 × 1% × days/365, rounded down, and the rest carried over
+	exact := decimal.NewFromInt(int64(10*days)).DivRound(decimal.NewFromInt(365), interest.Places)
+	assert.Equal(t, exact.RoundDown(model.MaxDecimalPlaces).String(), postings[1].Amount.String())
+	rate, err = testStore.GetInterestRate(ctx, 2)
+	require.NoError(t, err)
+	assert.True(t, rate.Accrued.IsZero())
+	assert.Equal(t, exact.Sub(postings[1].Amount).String(), rate.Carry.String())
+
+	// A frozen account cannot be paid: the month stays unposted
+	assert.Contains(t, postings[2].Error, ErrAccountFrozen.Error())
+	assert.Zero(t, postings[2].EntryID)
+	acc, err := testStore.GetAccount(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, decimal.NewFromInt(int64(3650+days)).String(), acc.Balance.String())
+
+	require.NoError(t, testStore.SetAccountStatus(ctx, 3, model.AccountStatusActive))
+	postings, err = testStore.PostInterest(ctx, time.Now(), 900)
+	require.NoError(t, err)
+	require.Len(t, postings, 1, "posted months are not posted again")
+	assert.Equal(t, int64(3), postings[0].AccountID)
+	assert.Empty(t, postings[0].Error)
+}
+
+func TestInterest_Clock(t *testing.T) {
+	ctx := context.Background()
+	truncateTables(t, ctx)
+	for id, balance := range map[int64]int64{1: 3650, 900: 1_000_000} {
+		require.NoError(t, testStore.CreateAccount(ctx, model.Account{AccountID: id, Balance: decimal.NewFromInt(balance)}))
+	}
+	_, err := testStore.db.Exec(ctx, "UPDATE accounts SET created_at = '2024-01-01'")
+	require.NoError(t, err)
+	_, err = testStore.SetInterestRate(ctx, 1, model.InterestRateRequest{AnnualRate: decimal.NewFromInt(10), DayCount: model.DayCountACT365})
+	require.NoError(t, err)
+
+	// Half an hour into the day after a leap day
+	at := time.Date(2024, time.March, 1, 0, 30, 0, 0, time.UTC)
+	store := &PostgresStore{db: testStore.db, retry: testStore.retry, now: func() time.Time { return at }}
+
+	for day := time.Date(2024, time.February, 1, 0, 0, 0, 0, time.UTC); day.Before(interest.Day(at)); day = day.AddDate(0, 0, 1) {
+		n, err := store.AccrueInterest(ctx, day)
+		require.NoError(t, err)
+		assert.Equal(t, 1, n, day.Format(time.DateOnly))
+	}
+	_, err = store.AccrueInterest(ctx, at)
+	assert.Error(t, err, "March 1 has not ended")
+	_, err = store.PostInterest(ctx, at.AddDate(0, 0, 1), 900)
+	assert.Error(t, err, "March 2 has not begun")
+
+	postings, err := store.PostInterest(ctx, at, 900)
+	require.NoError(t, err)
+	require.Len(t, postings, 1)
+	assert.Equal(t, "2024-02", postings[0].Month)
+	assert.Equal(t, "29", postings[0].Amount.String(), "10% of 3650 is 1 a day, for 29 days")
+
+	var posted int
+	require.NoError(t, testStore.db.QueryRow(ctx, "SELECT COUNT(*) FROM interest_accruals WHERE posted_at = $1", at).Scan(&posted))
+	assert.Equal(t, 29, posted, "the accruals are stamped with the store's clock")
+}
@@ -0,0 +1,214 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go-api-example/audit"
+	"go-api-example/model"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// CreateTransferJob stores a job with its lines in one transaction, using COPY
+// for the lines. Lines with an empty status are queued as pending; lines already
+// marked failed (they did not pass validation) are stored as they are.
+func (s *PostgresStore) CreateTransferJob(ctx context.Context, lines []model.TransferJobLine) (*model.TransferJob, error) {
+	var job *model.TransferJob
+	err := s.inTx(ctx, pgx.TxOptions{}, func(tx pgx.Tx) error {
+		job = &model.TransferJob{TotalLines: int64(len(lines))}
+		if err := tx.QueryRow(ctx, "INSERT INTO transfer_jobs DEFAULT VALUES RETURNING job_id, created_at").Scan(&job.JobID, &job.CreatedAt); err != nil {
+			return fmt.Errorf("could not create transfer job: %w", err)
+		}
+
+		rows := make([][]any, len(lines))
+		for i, l := range lines {
+			row := []any{job.JobID, l.Line, nil, nil, nil, model.LineStatusPending, l.Error, nil}
+			if l.Status == model.LineStatusFailed {
+				row[5], row[7] = l.Status, job.CreatedAt
+				job.Failed++
+			} else {
+				row[2], row[3], row[4] = l.SourceAccountID, l.DestinationAccountID, l.Amount
+				job.Pending++
+			}
+			rows[i] = row
+		}
+		columns := []string{"job_id", "line", "source_account_id", "destination_account_id", "amount", "status", "error", "processed_at"}
+		if _, err := tx.CopyFrom(ctx, pgx.Identifier{"transfer_job_lines"}, columns, pgx.CopyFromRows(rows)); err != nil {
+			return fmt.Errorf("could not store transfer job lines: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	job.Status = model.JobStatusPending
+	if job.Pending == 0 {
+		job.Status, job.FinishedAt = model.JobStatusCompleted, &job.CreatedAt
+	}
+	return job, nil
+}
+
+// GetTransferJob returns a job with its progress, counted from its lines.
+func (s *PostgresStore) GetTransferJob(ctx context.Context, id int64) (*model.TransferJob, error) {
+	job := &model.TransferJob{JobID: id}
+	var lastProcessed *time.Time
+	query := `
+		SELECT j.created_at, j.started_at,
+			count(l.line),
+			count(l.line) FILTER (WHERE l.status = 'pending'),
+			count(l.line) FILTER (WHERE l.status = 'succeeded'),
+			count(l.line) FILTER (WHERE l.status = 'failed'),
+			max(l.processed_at)
+		FROM transfer_jobs j LEFT JOIN transfer_job_lines l ON l.job_id = j.job_id
+		WHERE j.job_id = $1
+		GROUP BY j.job_id`
+	err := s.db.QueryRow(ctx, query, id).Scan(&job.CreatedAt, &job.StartedAt,
+		&job.TotalLines, &job.Pending, &job.Succeeded, &job.Failed, &lastProcessed)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrJobNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case job.Pending == 0:
+		job.Status, job.FinishedAt = model.JobStatusCompleted, lastProcessed
+		if job.FinishedAt == nil {
+			job.FinishedAt = &job.CreatedAt
+		}
+	case job.StartedAt != nil:
+		job.Status = model.JobStatusRunning
+	default:
+		job.Status = model.JobStatusPending
+	}
+	return job, nil
+}
+
+// TransferJobLines returns a page of a job's lines in line order.
+func (s *PostgresStore) TransferJobLines(ctx context.Context, id int64, filter model.TransferJobLineFilter) ([]model.TransferJobLine, error) {
+	limit := filter.Limit
+	if limit <= 0 || limit > model.MaxPageSize {
+		limit = model.MaxPageSize
+	}
+
+	query := `
+		SELECT line, COALESCE(source_account_id, 0), COALESCE(destination_account_id, 0), COALESCE(amount, 0), status, error
+		FROM transfer_job_lines
+		WHERE job_id = $1 AND line > $2
+		ORDER BY line
+		LIMIT $3`
+	rows, err := s.db.Query(ctx, query, id, filter.AfterLine, limit)
+	if err != nil {
+		return nil, fmt.Errorf("could not query transfer job lines: %w", err)
+	}
+	defer rows.Close()
+
+	lines := make([]model.TransferJobLine, 0, limit)
+	for rows.Next() {
+		var l model.TransferJobLine
+		if err := rows.Scan(&l.Line, &l.SourceAccountID, &l.DestinationAccountID, &l.Amount, &l.Status, &l.Error); err != nil {
+			return nil, fmt.Errorf("could not scan transfer job line: %w", err)
+		}
+		lines = append(lines, l)
+	}
+	return lines, rows.Err()
+}
+
+// ProcessNextTransferJobLine executes the oldest pending line of any job and
+// returns it with its outcome, or nil if no line is pending.
+//
+// The line is claimed with FOR UPDATE SKIP LOCKED, so concurrent workers, in this
+// process or another, each get a different line. The transfer and the line's new
+// status commit in the same transaction: a line is either still pending, or done
+// together with its transfer, and is never executed twice, even across crashes.
+// A transfer that fails (insufficient funds, unknown account, ...) is rolled back
+// to a savepoint and recorded as the line's error. Serialization failures and
+// deadlocks roll back the whole transaction, which is retried with another
+// claim; other transient database errors (timeouts), and those that outlast the
+// retries, are returned instead and leave the line pending, to be retried.
+func (s *PostgresStore) ProcessNextTransferJobLine(ctx context.Context) (*model.TransferJobLine, error) {
+	var line *model.TransferJobLine
+	err := s.inTx(ctx, pgx.TxOptions{}, func(tx pgx.Tx) error {
+		var err error
+		line, err = processTransferJobLine(ctx, tx)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return line, nil
+}
+
+// processTransferJobLine claims and executes a line in tx for
+// ProcessNextTransferJobLine.
+func processTransferJobLine(ctx context.Context, tx pgx.Tx) (*model.TransferJobLine, error) {
+	var jobID int64
+	var line model.TransferJobLine
+	query := `
+		SELECT job_id, line, source_account_id, destination_account_id, amount
+		FROM transfer_job_lines
+		WHERE status = 'pending'
+		ORDER BY job_id, line
+		LIMIT 1
+		FOR UPDATE SKIP LOCKED`
+	err := tx.QueryRow(ctx, query).Scan(&jobID, &line.Line, &line.SourceAccountID, &line.DestinationAccountID, &line.Amount)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not claim transfer job line: %w", err)
+	}
+	if _, err := tx.Exec(ctx, "UPDATE transfer_jobs SET started_at = NOW() WHERE job_id = $1 AND started_at IS NULL", jobID); err != nil {
+		return nil, fmt.Errorf("could not start transfer job: %w", err)
+	}
+
+	ctx = audit.WithRequestID(ctx, fmt.Sprintf("transfer-job:%d:%d", jobID, line.Line))
+	sp, err := tx.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not create savepoint: %w", err)
+	}
+	_, err = applyTransfer(ctx, sp, line.TransactionRequest)
+	if err == nil {
+		err = sp.Commit(ctx)
+	}
+	line.Status = model.LineStatusSucceeded
+	if err = transferError(line.TransactionRequest, err); err != nil {
+		if isTransient(ctx, err) {
+			return nil, err
+		}
+		if rbErr := sp.Rollback(ctx); rbErr != nil {
+			return nil, rbErr
+		}
+		line.Status, line.Error = model.LineStatusFailed, err.Error()
+	}
+
+	query = "UPDATE transfer_job_lines SET status = $3, error = $4, processed_at = NOW() WHERE job_id = $1 AND line = $2"
+	if _, err := tx.Exec(ctx, query, jobID, line.Line, line.Status, line.Error); err != nil {
+		return nil, fmt.Errorf("could not record transfer job line: %w", err)
+	}
+	return &line, nil
+}
+
+// isTransient reports whether err is worth retrying rather than recording as the
+// outcome of an operation: a cancelled context, or a PostgreSQL serialization
+// failure, deadlock, lock timeout or statement timeout.
+func isTransient(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return true
+	}
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return false
+	}
+	switch pgErr.Code {
+	case "40001", "40P01", "55P03", "57014":
+		return true
+	}
+	return false
+}
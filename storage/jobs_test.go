@@ -0,0 +1,115 @@
+package storage
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"go-api-example/model"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func transferLine(line int, from, to int64, amount int64) model.TransferJobLine {
+	return model.TransferJobLine{Line: line, TransactionRequest: model.TransactionRequest{
+		SourceAccountID: from, DestinationAccountID: to, Amount: decimal.NewFromInt(amount),
+	}}
+}
+
+func TestTransferJob(t *testing.T) {
+	ctx := context.Background()
+	truncateTables(t, ctx)
+	require.NoError(t, testStore.CreateAccount(ctx, model.Account{AccountID: 1, Balance: decimal.NewFromInt(100)}))
+	require.NoError(t, testStore.CreateAccount(ctx, model.Account{AccountID: 2, Balance: decimal.Zero}))
+
+	// Arrange: a good line, one without funds, one rejected on upload and one for a missing account
+	job, err := testStore.CreateTransferJob(ctx, []model.TransferJobLine{
+		transferLine(2, 1, 2, 60),
+		transferLine(3, 1, 2, 60),
+		{Line: 4, Status: model.LineStatusFailed, Error: "amount: must be positive"},
+		transferLine(5, 1, 9, 10),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, model.JobStatusPending, job.Status)
+	assert.Equal(t, int64(3), job.Pending)
+	assert.Equal(t, int64(1), job.Failed)
+
+	// Act: process until nothing is pending
+	var processed []model.TransferJobLine
+	for {
+		line, err := testStore.ProcessNextTransferJobLine(ctx)
+		require.NoError(t, err)
+		if line == nil {
+			break
+		}
+		processed = append(processed, *line)
+	}
+
+	// Assert
+	require.Len(t, processed, 3)
+	assert.Equal(t, model.LineStatusSucceeded, processed[0].Status)
+	assert.Equal(t, model.LineStatusFailed, processed[1].Status)
+	assert.Equal(t, "account 1: insufficient funds", processed[1].Error)
+	assert.Equal(t, "account 9: account not found", processed[2].Error)
+
+	job, err = testStore.GetTransferJob(ctx, job.JobID)
+	require.NoError(t, err)
+	assert.Equal(t, model.JobStatusCompleted, job.Status)
+	assert.Equal(t, int64(4), job.TotalLines)
+	assert.Equal(t, int64(1), job.Succeeded)
+	assert.Equal(t, int64(3), job.Failed)
+	assert.NotNil(t, job.StartedAt)
+	assert.NotNil(t, job.FinishedAt)
+
+	lines, err := testStore.TransferJobLines(ctx, job.JobID, model.TransferJobLineFilter{AfterLine: 2, Limit: 2})
+	require.NoError(t, err)
+	require.Len(t, lines, 2)
+	assert.Equal(t, 3, lines[0].Line)
+	assert.Equal(t, "amount: must be positive", lines[1].Error)
+
+	acc, err := testStore.GetAccount(ctx, 2)
+	require.NoError(t, err)
+	assert.True(t, decimal.NewFromInt(60).Equal(acc.Balance))
+
+	_, err = testStore.GetTransferJob(ctx, 999)
+	assert.ErrorIs(t, err, ErrJobNotFound)
+}
+
+func TestTransferJob_ConcurrentWorkersNeverRepeatALine(t *testing.T) {
+	ctx := context.Background()
+	truncateTables(t, ctx)
+	require.NoError(t, testStore.CreateAccount(ctx, model.Account{AccountID: 1, Balance: decimal.NewFromInt(1000)}))
+	require.NoError(t, testStore.CreateAccount(ctx, model.Account{AccountID: 2, Balance: decimal.Zero}))
+
+	const n = 50
+	lines := make([]model.TransferJobLine, n)
+	for i := range lines {
+		lines[i] = transferLine(i+2, 1, 2, 1)
+	}
+	job, err := testStore.CreateTransferJob(ctx, lines)
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	for w := 0; w < 5; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				line, err := testStore.ProcessNextTransferJobLine(ctx)
+				if err != nil || line == nil {
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	job, err = testStore.GetTransferJob(ctx, job.JobID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(n), job.Succeeded)
+	acc, err := testStore.GetAccount(ctx, 2)
+	require.NoError(t, err)
+	assert.True(t, decimal.NewFromInt(n).Equal(acc.Balance), "every line is executed exactly once")
+}
@@ -0,0 +1,277 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go-api-example/audit"
+	"go-api-example/model"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/shopspring/decimal"
+)
+
+// postEntry appends a journal entry with the given postings inside tx and applies
+// each posting to the cached balance of its account. A posting's amount is signed:
+// negative debits the account, positive credits it. The postings must sum to zero
+// in each currency. The caller must already hold row locks on every account involved.
+// The postings are filled in with their IDs, entry, kind and direction. The balances
+// of the accounts in hot are left to the caller, which applies them to their shards.
+func postEntry(ctx context.Context, tx pgx.Tx, kind string, postings []model.Posting, hot map[int64]bool) (int64, error) {
+	sums := map[string]decimal.Decimal{}
+	for _, p := range postings {
+		sums[p.Currency] = sums[p.Currency].Add(p.Amount)
+	}
+	for _, sum := range sums {
+		if !sum.IsZero() {
+			return 0, ErrUnbalancedEntry
+		}
+	}
+
+	var entryID int64
+	err := tx.QueryRow(ctx, "INSERT INTO journal_entries (kind) VALUES ($1) RETURNING entry_id", kind).Scan(&entryID)
+	if err != nil {
+		return 0, fmt.Errorf("could not create journal entry: %w", err)
+	}
+
+	for i := range postings {
+		p := &postings[i]
+		insertQuery := `
+			INSERT INTO postings (entry_id, account_id, amount, currency) VALUES ($1, $2, $3, $4)
+			RETURNING posting_id, created_at`
+		if err := tx.QueryRow(ctx, insertQuery, entryID, p.AccountID, p.Amount, p.Currency).Scan(&p.PostingID, &p.CreatedAt); err != nil {
+			return 0, fmt.Errorf("could not record posting for account %d: %w", p.AccountID, err)
+		}
+		if !hot[p.AccountID] {
+			updateQuery := "UPDATE accounts SET balance = balance + $1 WHERE account_id = $2"
+			if _, err := tx.Exec(ctx, updateQuery, p.Amount, p.AccountID); err != nil {
+				return 0, fmt.Errorf("could not update balance of account %d: %w", p.AccountID, err)
+			}
+		}
+		p.EntryID = entryID
+		p.Kind = kind
+		p.Direction = model.DirectionOf(p.Amount)
+	}
+	return entryID, nil
+}
+
+// applyEntry locks every account named in postings, checks that each can take
+// part in the entry and records it inside tx, together with its audit record.
+// Rows are locked in a consistent order (by ID) to prevent deadlocks; see
+// lockAccountRows. A posting without a currency takes its account's currency.
+// A hot account is debited from the shards that lockShards locks to cover its
+// net movement, and credited to one free shard.
+//
+// Checks run in this order: frozen or read-only accounts, missing accounts (in posting order),
+// currency mismatches, then insufficient funds. Funds are checked per account
+// against the account's net movement, so an entry may debit and credit the same account.
+func applyEntry(ctx context.Context, tx pgx.Tx, kind string, postings []model.Posting) (*model.JournalEntry, error) {
+	ids := make([]int64, len(postings))
+	for i, p := range postings {
+		ids[i] = p.AccountID
+	}
+
+	locked, err := lockAccountRows(ctx, tx, ids)
+	if err != nil {
+		return nil, err
+	}
+	accounts := make(map[int64]lockedAccount, len(locked))
+	hot := map[int64]bool{}
+	for _, acc := range locked {
+		switch acc.Status {
+		case model.AccountStatusFrozen:
+			return nil, &AccountError{AccountID: acc.AccountID, Err: ErrAccountFrozen}
+		case model.AccountStatusReadOnly:
+			return nil, &AccountError{AccountID: acc.AccountID, Err: ErrAccountReadOnly}
+		}
+		accounts[acc.AccountID] = acc
+		hot[acc.AccountID] = acc.shards > 0
+	}
+
+	net := make(map[int64]decimal.Decimal, len(accounts))
+	for i := range postings {
+		p := &postings[i]
+		acc, ok := accounts[p.AccountID]
+		if !ok {
+			return nil, &AccountError{AccountID: p.AccountID, Err: ErrNotFound}
+		}
+		if p.Currency == "" {
+			p.Currency = acc.Currency
+		} else if p.Currency != acc.Currency {
+			return nil, &AccountError{AccountID: p.AccountID, Err: ErrCurrencyMismatch}
+		}
+		net[p.AccountID] = net[p.AccountID].Add(p.Amount)
+	}
+
+	takes := map[int64]map[int]decimal.Decimal{} // what to take from each shard of a hot account
+	for _, p := range postings {
+		n, acc := net[p.AccountID], accounts[p.AccountID]
+		if !n.IsNegative() {
+			continue
+		}
+		if !hot[p.AccountID] {
+			if acc.Balance.Add(n).IsNegative() {
+				return nil, &AccountError{AccountID: p.AccountID, Err: ErrInsufficientFunds}
+			}
+			continue
+		}
+		if _, ok := takes[p.AccountID]; ok {
+			continue
+		}
+		take, ok, err := lockShards(ctx, tx, p.AccountID, acc.shards, n.Neg())
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, &AccountError{AccountID: p.AccountID, Err: ErrInsufficientFunds}
+		}
+		takes[p.AccountID] = take
+	}
+
+	entryID, err := postEntry(ctx, tx, kind, postings, hot)
+	if err != nil {
+		return nil, err
+	}
+	for _, acc := range locked {
+		switch n := net[acc.AccountID]; {
+		case !hot[acc.AccountID]:
+		case n.IsNegative():
+			err = debitShards(ctx, tx, acc.AccountID, takes[acc.AccountID])
+		case n.IsPositive():
+			err = creditShard(ctx, tx, acc.AccountID, acc.shards, n)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// The balance of a hot account is the snapshot lockAccountRows read:
+	// concurrent entries may credit its other shards.
+	before := entryState{}
+	after := entryState{EntryID: entryID, Kind: kind, Postings: postings}
+	for _, acc := range locked {
+		before.Balances = append(before.Balances, accountBalance{acc.AccountID, acc.Balance})
+		after.Balances = append(after.Balances, accountBalance{acc.AccountID, acc.Balance.Add(net[acc.AccountID])})
+	}
+	if err := appendAudit(ctx, tx, audit.ActionEntryPost, before, after); err != nil {
+		return nil, err
+	}
+	return &model.JournalEntry{EntryID: entryID, Kind: kind, CreatedAt: postings[0].CreatedAt, Postings: postings}, nil
+}
+
+// PostJournalEntry records an entry with any number of postings across accounts.
+// The postings must sum to zero in each currency, and no account may end up negative.
+// The transfer limits of every debited account apply; see checkEntryLimits.
+func (s *PostgresStore) PostJournalEntry(ctx context.Context, req model.JournalEntryRequest) (*model.JournalEntry, error) {
+	var entry *model.JournalEntry
+	err := s.inTx(ctx, pgx.TxOptions{}, func(tx pgx.Tx) error {
+		var err error
+		entry, err = applyJournalEntry(ctx, tx, req)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// applyJournalEntry records req in tx for PostJournalEntry.
+func applyJournalEntry(ctx context.Context, tx pgx.Tx, req model.JournalEntryRequest) (*model.JournalEntry, error) {
+	postings := make([]model.Posting, len(req.Postings))
+	for i, p := range req.Postings {
+		postings[i] = model.Posting{AccountID: p.AccountID, Amount: p.Amount, Currency: p.Currency}
+	}
+	entry, err := applyEntry(ctx, tx, model.EntryKindJournal, postings)
+	if err != nil {
+		return nil, err
+	}
+	return entry, checkEntryLimits(ctx, tx, entry)
+}
+
+// GetAccountPostings returns the opening balance, the cached balance and a page of
+// the postings that explain it, oldest first. Opening balance plus the amounts of
+// all postings equals the balance.
+func (s *PostgresStore) GetAccountPostings(ctx context.Context, id int64, filter model.PostingFilter) (*model.AccountLedger, error) {
+	limit := filter.Limit
+	if limit <= 0 || limit > model.MaxPageSize {
+		limit = model.MaxPageSize
+	}
+
+	tx, err := s.reader(ctx).BeginTx(ctx, pgx.TxOptions{AccessMode: pgx.ReadOnly, IsoLevel: pgx.RepeatableRead})
+	if err != nil {
+		return nil, fmt.Errorf("could not begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	ledger := &model.AccountLedger{AccountID: id, Postings: []model.Posting{}}
+	query := "SELECT a.opening_balance, " + cachedBalance + " FROM accounts a WHERE a.account_id = $1"
+	if err := tx.QueryRow(ctx, query, id).Scan(&ledger.OpeningBalance, &ledger.Balance); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, &AccountError{AccountID: id, Err: ErrNotFound}
+		}
+		return nil, err
+	}
+
+	// Fetch one extra row to learn whether another page exists.
+	query = `
+		SELECT p.posting_id, p.entry_id, j.kind, p.amount, p.currency, p.created_at
+		FROM postings p JOIN journal_entries j ON j.entry_id = p.entry_id
+		WHERE p.account_id = $1 AND p.posting_id > $2
+		ORDER BY p.posting_id
+		LIMIT $3`
+	rows, err := tx.Query(ctx, query, id, filter.AfterID, limit+1)
+	if err != nil {
+		return nil, fmt.Errorf("could not query postings: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		p := model.Posting{AccountID: id}
+		if err := rows.Scan(&p.PostingID, &p.EntryID, &p.Kind, &p.Amount, &p.Currency, &p.CreatedAt); err != nil {
+			return nil, fmt.Errorf("could not scan posting row: %w", err)
+		}
+		p.Direction = model.DirectionOf(p.Amount)
+		ledger.Postings = append(ledger.Postings, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(ledger.Postings) > limit {
+		ledger.Postings = ledger.Postings[:limit]
+		next := ledger.Postings[limit-1].PostingID
+		ledger.NextAfter = &next
+	}
+	return ledger, nil
+}
+
+// balanceAsOf returns the SQL expression for the balance of accounts row "a" as of
+// the instant bound to param: its opening balance plus every posting made up to then.
+// It is served by postings_account_created_at_idx.
+func balanceAsOf(param string) string {
+	return `a.opening_balance + COALESCE((
+			SELECT SUM(p.amount) FROM postings p
+			WHERE p.account_id = a.account_id AND p.created_at <= ` + param + `), 0)`
+}
+
+// GetAccountAsOf returns an account with its balance as it stood at asOf, computed
+// from the opening balance plus all postings up to and including that instant.
+// Status, currency and owner name are the account's current ones. An account that did not
+// exist yet at asOf is reported as not found.
+func (s *PostgresStore) GetAccountAsOf(ctx context.Context, id int64, asOf time.Time) (*model.Account, error) {
+	acc := &model.Account{AccountID: id}
+	query := `
+		SELECT ` + balanceAsOf("$2") + `, a.currency, a.owner_name, a.status, a.created_at
+		FROM accounts a
+		WHERE a.account_id = $1 AND a.created_at <= $2`
+	err := s.reader(ctx).QueryRow(ctx, query, id, asOf).Scan(&acc.Balance, &acc.Currency, &acc.OwnerName, &acc.Status, &acc.CreatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, &AccountError{AccountID: id, Err: ErrNotFound}
+		}
+		return nil, err
+	}
+	return acc, nil
+}
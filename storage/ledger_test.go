@@ -0,0 +1,233 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go-api-example/model"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExecuteTransfer_RecordsBalancedJournalEntry(t *testing.T) {
+	ctx := context.Background()
+	truncateTables(t, ctx)
+
+	// Arrange
+	require.NoError(t, testStore.CreateAccount(ctx, model.Account{AccountID: 1, Balance: decimal.NewFromInt(100)}))
+	require.NoError(t, testStore.CreateAccount(ctx, model.Account{AccountID: 2, Balance: decimal.NewFromInt(50)}))
+
+	// Act
+	require.NoError(t, executeTransfer(ctx, model.TransactionRequest{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromInt(30)}))
+	require.NoError(t, executeTransfer(ctx, model.TransactionRequest{SourceAccountID: 2, DestinationAccountID: 1, Amount: decimal.NewFromInt(5)}))
+
+	// Assert: each account's balance is its opening balance plus its postings
+	for _, id := range []int64{1, 2} {
+		ledger, err := testStore.GetAccountPostings(ctx, id, model.PostingFilter{})
+		require.NoError(t, err)
+		require.Len(t, ledger.Postings, 2)
+
+		sum := ledger.OpeningBalance
+		for _, p := range ledger.Postings {
+			sum = sum.Add(p.Amount)
+		}
+		assert.True(t, sum.Equal(ledger.Balance), "account %d: opening + postings = %s, balance = %s", id, sum, ledger.Balance)
+	}
+
+	ledger, err := testStore.GetAccountPostings(ctx, 1, model.PostingFilter{})
+	require.NoError(t, err)
+	assert.Equal(t, model.DirectionDebit, ledger.Postings[0].Direction)
+	assert.True(t, decimal.NewFromInt(-30).Equal(ledger.Postings[0].Amount))
+	assert.Equal(t, model.EntryKindTransfer, ledger.Postings[0].Kind)
+
+	// Every entry sums to zero
+	var unbalanced int
+	err = testStore.db.QueryRow(ctx, "SELECT COUNT(*) FROM (SELECT entry_id FROM postings GROUP BY entry_id HAVING SUM(amount) <> 0) u").Scan(&unbalanced)
+	require.NoError(t, err)
+	assert.Zero(t, unbalanced)
+}
+
+func TestGetAccountPostings_Paging(t *testing.T) {
+	ctx := context.Background()
+	truncateTables(t, ctx)
+
+	require.NoError(t, testStore.CreateAccount(ctx, model.Account{AccountID: 1, Balance: decimal.NewFromInt(100)}))
+	require.NoError(t, testStore.CreateAccount(ctx, model.Account{AccountID: 2, Balance: decimal.Zero}))
+	for i := 0; i < 3; i++ {
+		require.NoError(t, executeTransfer(ctx, model.TransactionRequest{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromInt(1)}))
+	}
+
+	page1, err := testStore.GetAccountPostings(ctx, 2, model.PostingFilter{Limit: 2})
+	require.NoError(t, err)
+	require.Len(t, page1.Postings, 2)
+	require.NotNil(t, page1.NextAfter)
+
+	page2, err := testStore.GetAccountPostings(ctx, 2, model.PostingFilter{AfterID: *page1.NextAfter, Limit: 2})
+	require.NoError(t, err)
+	assert.Len(t, page2.Postings, 1)
+	assert.Nil(t, page2.NextAfter)
+
+	_, err = testStore.GetAccountPostings(ctx, 999, model.PostingFilter{})
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestPostJournalEntry(t *testing.T) {
+	ctx := context.Background()
+	truncateTables(t, ctx)
+
+	require.NoError(t, testStore.CreateAccount(ctx, model.Account{AccountID: 1, Balance: decimal.NewFromInt(100), Currency: "EUR"}))
+	require.NoError(t, testStore.CreateAccount(ctx, model.Account{AccountID: 2, Balance: decimal.Zero, Currency: "EUR"}))
+	require.NoError(t, testStore.CreateAccount(ctx, model.Account{AccountID: 3, Balance: decimal.Zero, Currency: "EUR"}))
+	require.NoError(t, testStore.CreateAccount(ctx, model.Account{AccountID: 4, Balance: decimal.NewFromInt(50), Currency: "USD"}))
+	require.NoError(t, testStore.CreateAccount(ctx, model.Account{AccountID: 5, Balance: decimal.Zero, Currency: "USD"}))
+
+	posting := func(id int64, amount int64, currency string) model.PostingRequest {
+		return model.PostingRequest{AccountID: id, Amount: decimal.NewFromInt(amount), Currency: currency}
+	}
+	balance := func(id int64) decimal.Decimal {
+		acc, err := testStore.GetAccount(ctx, id)
+		require.NoError(t, err)
+		return acc.Balance
+	}
+
+	t.Run("three-leg entry with a fee", func(t *testing.T) {
+		entry, err := testStore.PostJournalEntry(ctx, model.JournalEntryRequest{Postings: []model.PostingRequest{
+			posting(1, -30, "EUR"), posting(2, 29, "EUR"), posting(3, 1, "EUR"),
+		}})
+		require.NoError(t, err)
+
+		assert.Equal(t, model.EntryKindJournal, entry.Kind)
+		require.Len(t, entry.Postings, 3)
+		assert.NotZero(t, entry.Postings[0].PostingID)
+		assert.Equal(t, model.DirectionDebit, entry.Postings[0].Direction)
+		assert.True(t, decimal.NewFromInt(70).Equal(balance(1)))
+		assert.True(t, decimal.NewFromInt(29).Equal(balance(2)))
+		assert.True(t, decimal.NewFromInt(1).Equal(balance(3)))
+	})
+
+	t.Run("entry spanning two currencies", func(t *testing.T) {
+		_, err := testStore.PostJournalEntry(ctx, model.JournalEntryRequest{Postings: []model.PostingRequest{
+			posting(1, -10, "EUR"), posting(2, 10, "EUR"), posting(4, -11, "USD"), posting(5, 11, "USD"),
+		}})
+		require.NoError(t, err)
+		assert.True(t, decimal.NewFromInt(39).Equal(balance(4)))
+	})
+
+	t.Run("every leg is checked for funds", func(t *testing.T) {
+		_, err := testStore.PostJournalEntry(ctx, model.JournalEntryRequest{Postings: []model.PostingRequest{
+			posting(1, -10, "EUR"), posting(3, -5, "EUR"), posting(2, 15, "EUR"),
+		}})
+		var accErr *AccountError
+		require.ErrorAs(t, err, &accErr)
+		assert.ErrorIs(t, err, ErrInsufficientFunds)
+		assert.Equal(t, int64(3), accErr.AccountID)
+		assert.True(t, decimal.NewFromInt(60).Equal(balance(1)), "a rejected entry must not move money")
+	})
+
+	t.Run("currency must match the account", func(t *testing.T) {
+		_, err := testStore.PostJournalEntry(ctx, model.JournalEntryRequest{Postings: []model.PostingRequest{
+			posting(1, -10, "USD"), posting(5, 10, "USD"),
+		}})
+		assert.ErrorIs(t, err, ErrCurrencyMismatch)
+	})
+
+	t.Run("unknown account", func(t *testing.T) {
+		_, err := testStore.PostJournalEntry(ctx, model.JournalEntryRequest{Postings: []model.PostingRequest{
+			posting(1, -10, "EUR"), posting(99, 10, "EUR"),
+		}})
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+
+	t.Run("transfer between currencies is rejected", func(t *testing.T) {
+		err := executeTransfer(ctx, model.TransactionRequest{SourceAccountID: 1, DestinationAccountID: 4, Amount: decimal.NewFromInt(1)})
+		assert.ErrorIs(t, err, ErrCurrencyMismatch)
+	})
+}
+
+func TestGetAccountAsOf(t *testing.T) {
+	ctx := context.Background()
+	truncateTables(t, ctx)
+
+	// Arrange: two transfers out of account 1, remembering when the first was posted
+	require.NoError(t, testStore.CreateAccount(ctx, model.Account{AccountID: 1, Balance: decimal.NewFromInt(100)}))
+	require.NoError(t, testStore.CreateAccount(ctx, model.Account{AccountID: 2, Balance: decimal.Zero}))
+	require.NoError(t, executeTransfer(ctx, model.TransactionRequest{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromInt(30)}))
+	var firstPosted time.Time
+	require.NoError(t, testStore.db.QueryRow(ctx, "SELECT MAX(created_at) FROM postings").Scan(&firstPosted))
+	time.Sleep(10 * time.Millisecond)
+	require.NoError(t, executeTransfer(ctx, model.TransactionRequest{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromInt(5)}))
+
+	t.Run("balance after the first transfer only", func(t *testing.T) {
+		acc, err := testStore.GetAccountAsOf(ctx, 1, firstPosted)
+		require.NoError(t, err)
+		assert.True(t, decimal.NewFromInt(70).Equal(acc.Balance), "got %s", acc.Balance)
+	})
+
+	t.Run("balance now matches the cached balance", func(t *testing.T) {
+		acc, err := testStore.GetAccountAsOf(ctx, 1, time.Now().Add(time.Hour))
+		require.NoError(t, err)
+		assert.True(t, decimal.NewFromInt(65).Equal(acc.Balance), "got %s", acc.Balance)
+	})
+
+	t.Run("account did not exist yet", func(t *testing.T) {
+		_, err := testStore.GetAccountAsOf(ctx, 1, firstPosted.Add(-24*time.Hour))
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+
+	t.Run("listing as of an instant", func(t *testing.T) {
+		page, err := testStore.ListAccounts(ctx, model.AccountFilter{AsOf: firstPosted})
+		require.NoError(t, err)
+		accounts := page.Accounts
+		require.Len(t, accounts, 2)
+		assert.True(t, decimal.NewFromInt(70).Equal(accounts[0].Balance))
+		assert.True(t, decimal.NewFromInt(30).Equal(accounts[1].Balance))
+	})
+}
+
+func TestLedgerInvariants(t *testing.T) {
+	ctx := context.Background()
+	truncateTables(t, ctx)
+
+	require.NoError(t, testStore.CreateAccount(ctx, model.Account{AccountID: 1, Balance: decimal.NewFromInt(100)}))
+	require.NoError(t, testStore.CreateAccount(ctx, model.Account{AccountID: 2, Balance: decimal.Zero}))
+	require.NoError(t, executeTransfer(ctx, model.TransactionRequest{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromInt(10)}))
+
+	t.Run("postEntry rejects unbalanced postings", func(t *testing.T) {
+		tx, err := testStore.db.Begin(ctx)
+		require.NoError(t, err)
+		defer tx.Rollback(ctx)
+
+		_, err = postEntry(ctx, tx, model.EntryKindTransfer, []model.Posting{
+			{AccountID: 1, Amount: decimal.NewFromInt(-10)},
+			{AccountID: 2, Amount: decimal.NewFromInt(9)},
+		}, nil)
+		assert.ErrorIs(t, err, ErrUnbalancedEntry)
+	})
+
+	t.Run("database rejects an unbalanced entry at commit", func(t *testing.T) {
+		tx, err := testStore.db.Begin(ctx)
+		require.NoError(t, err)
+		defer tx.Rollback(ctx)
+
+		var entryID int64
+		require.NoError(t, tx.QueryRow(ctx, "INSERT INTO journal_entries (kind) VALUES ('transfer') RETURNING entry_id").Scan(&entryID))
+		_, err = tx.Exec(ctx, "INSERT INTO postings (entry_id, account_id, amount) VALUES ($1, 1, -5)", entryID)
+		require.NoError(t, err)
+
+		err = tx.Commit(ctx)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "unbalanced")
+	})
+
+	t.Run("postings cannot be edited", func(t *testing.T) {
+		_, err := testStore.db.Exec(ctx, "UPDATE postings SET amount = 0")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "immutable")
+
+		_, err = testStore.db.Exec(ctx, "DELETE FROM journal_entries")
+		require.Error(t, err)
+	})
+}
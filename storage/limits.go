@@ -0,0 +1,277 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+
+	"go-api-example/audit"
+	"go-api-example/model"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/shopspring/decimal"
+)
+
+// LimitError reports the transfer limit a transfer would exceed and how much of
+// it is left: an amount, or for model.LimitHourlyCount a number of transfers.
+// It unwraps to ErrLimitExceeded.
+type LimitError struct {
+	Limit     string
+	Remaining decimal.Decimal
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("%v: %s (remaining %s)", ErrLimitExceeded, e.Limit, e.Remaining)
+}
+
+func (e *LimitError) Unwrap() error {
+	return ErrLimitExceeded
+}
+
+// applyTransfer records a transfer inside tx, as applyEntry does, enforces the
+// transfer limits of the source account and then charges the fee of its
+// schedule. applyEntry has locked the source row by then, so concurrent
+// transfers from one account are checked one after the other, each seeing the
+// postings of those committed before it. Fees are not transfers, so they do not
+// count towards the limits.
+func applyTransfer(ctx context.Context, tx pgx.Tx, req model.TransactionRequest) (*model.Transfer, error) {
+	fees, err := accountFees(ctx, tx, req.SourceAccountID)
+	if err != nil {
+		return nil, err
+	}
+	if fees.Effective != nil {
+		// Lock the revenue account together with the others, in ID order, rather
+		// than after them when the fee is charged.
+		if err := lockAccounts(ctx, tx, req.SourceAccountID, req.DestinationAccountID, fees.Effective.RevenueAccountID); err != nil {
+			return nil, err
+		}
+	}
+	entry, err := applyEntry(ctx, tx, model.EntryKindTransfer, transferPostings(req))
+	if err != nil {
+		return nil, err
+	}
+	if err := checkLimits(ctx, tx, req.SourceAccountID, req.Amount, entry.EntryID); err != nil {
+		return nil, err
+	}
+
+	t := &model.Transfer{EntryID: entry.EntryID, TransactionRequest: req, Total: req.Amount, CreatedAt: entry.CreatedAt}
+	if fees.Effective != nil {
+		if t.Fee, err = chargeFee(ctx, tx, fees, req, entry); err != nil {
+			return nil, err
+		}
+		if t.Fee != nil {
+			t.Total = t.Total.Add(t.Fee.Amount)
+		}
+	}
+	return t, nil
+}
+
+// checkEntryLimits checks the limits of every account the journal entry
+// debits, in ID order, as checkLimits does for the source of a transfer: the
+// entry's debits from an account count as one transfer of their sum.
+func checkEntryLimits(ctx context.Context, tx pgx.Tx, entry *model.JournalEntry) error {
+	debits := map[int64]decimal.Decimal{}
+	for _, p := range entry.Postings {
+		if p.Amount.IsNegative() {
+			debits[p.AccountID] = debits[p.AccountID].Sub(p.Amount)
+		}
+	}
+	for _, id := range slices.Sorted(maps.Keys(debits)) {
+		if err := checkLimits(ctx, tx, id, debits[id], entry.EntryID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkLimits returns a LimitError, wrapped in an AccountError for account id,
+// if debiting it amount in the entry recorded as entryID breaks one of its
+// limits. Limits are checked in the order max amount, hourly count, daily,
+// monthly.
+func checkLimits(ctx context.Context, tx pgx.Tx, id int64, amount decimal.Decimal, entryID int64) error {
+	limits, err := accountLimits(ctx, tx, id)
+	if err != nil {
+		return err
+	}
+	l := limits.Effective
+	if l.IsZero() {
+		return nil
+	}
+	exceeded := func(limit string, remaining decimal.Decimal) error {
+		return &AccountError{AccountID: id, Err: &LimitError{Limit: limit, Remaining: decimal.Max(remaining, decimal.Zero)}}
+	}
+	if l.MaxAmount != nil && amount.GreaterThan(*l.MaxAmount) {
+		return exceeded(model.LimitMaxAmount, *l.MaxAmount)
+	}
+
+	// Outgoing transfers and journal entries before this one; fees and
+	// interest do not count. The scan starts at the earlier of the month and the hour windows,
+	// which differ only in the first hour of a month.
+	var day, month decimal.Decimal
+	var hour int64
+	query := `
+		SELECT
+			COALESCE(SUM(-p.amount) FILTER (WHERE p.created_at >= date_trunc('day', NOW(), 'UTC')), 0),
+			COALESCE(SUM(-p.amount) FILTER (WHERE p.created_at >= date_trunc('month', NOW(), 'UTC')), 0),
+			COUNT(DISTINCT p.entry_id) FILTER (WHERE p.created_at > NOW() - INTERVAL '1 hour')
+		FROM postings p JOIN journal_entries j ON j.entry_id = p.entry_id
+		WHERE p.account_id = $1 AND p.amount < 0 AND j.kind <> ALL($2) AND p.entry_id <> $3
+		  AND p.created_at >= LEAST(date_trunc('month', NOW(), 'UTC'), NOW() - INTERVAL '1 hour')`
+	if err := tx.QueryRow(ctx, query, id, []string{model.EntryKindFee, model.EntryKindInterest}, entryID).Scan(&day, &month, &hour); err != nil {
+		return fmt.Errorf("could not sum outgoing transfers: %w", err)
+	}
+
+	switch {
+	case l.HourlyCount != nil && hour+1 > *l.HourlyCount:
+		return exceeded(model.LimitHourlyCount, decimal.NewFromInt(*l.HourlyCount-hour))
+	case l.DailyAmount != nil && day.Add(amount).GreaterThan(*l.DailyAmount):
+		return exceeded(model.LimitDailyAmount, l.DailyAmount.Sub(day))
+	case l.MonthlyAmount != nil && month.Add(amount).GreaterThan(*l.MonthlyAmount):
+		return exceeded(model.LimitMonthlyAmount, l.MonthlyAmount.Sub(month))
+	}
+	return nil
+}
+
+// accountLimits returns the tier, overrides and effective limits of an account,
+// whether or not it exists.
+func accountLimits(ctx context.Context, tx pgx.Tx, id int64) (*model.AccountLimits, error) {
+	limits := &model.AccountLimits{AccountID: id}
+	var tier model.TransferLimits
+	query := `
+		SELECT COALESCE(a.tier, $2),
+			a.max_amount, a.daily_amount, a.monthly_amount, a.hourly_count,
+			t.max_amount, t.daily_amount, t.monthly_amount, t.hourly_count
+		FROM (SELECT $1::BIGINT AS account_id) x
+		LEFT JOIN account_limits a ON a.account_id = x.account_id
+		LEFT JOIN tier_limits t ON t.tier = COALESCE(a.tier, $2)`
+	o := &limits.Overrides
+	err := tx.QueryRow(ctx, query, id, model.DefaultTier).Scan(&limits.Tier,
+		&o.MaxAmount, &o.DailyAmount, &o.MonthlyAmount, &o.HourlyCount,
+		&tier.MaxAmount, &tier.DailyAmount, &tier.MonthlyAmount, &tier.HourlyCount)
+	if err != nil {
+		return nil, fmt.Errorf("could not load transfer limits of account %d: %w", id, err)
+	}
+	limits.Effective = model.TransferLimits{
+		MaxAmount:     override(o.MaxAmount, tier.MaxAmount),
+		DailyAmount:   override(o.DailyAmount, tier.DailyAmount),
+		MonthlyAmount: override(o.MonthlyAmount, tier.MonthlyAmount),
+		HourlyCount:   override(o.HourlyCount, tier.HourlyCount),
+	}
+	return limits, nil
+}
+
+func override[T any](account, tier *T) *T {
+	if account != nil {
+		return account
+	}
+	return tier
+}
+
+// ListTierLimits returns the limits of every tier that has any, ordered by tier.
+func (s *PostgresStore) ListTierLimits(ctx context.Context) ([]model.TierLimits, error) {
+	query := "SELECT tier, max_amount, daily_amount, monthly_amount, hourly_count FROM tier_limits ORDER BY tier"
+	rows, err := s.db.Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("could not query tier limits: %w", err)
+	}
+	defer rows.Close()
+
+	tiers := []model.TierLimits{}
+	for rows.Next() {
+		var t model.TierLimits
+		l := &t.Limits
+		if err := rows.Scan(&t.Tier, &l.MaxAmount, &l.DailyAmount, &l.MonthlyAmount, &l.HourlyCount); err != nil {
+			return nil, fmt.Errorf("could not scan tier limits: %w", err)
+		}
+		tiers = append(tiers, t)
+	}
+	return tiers, rows.Err()
+}
+
+// SetTierLimits replaces the limits of a tier. Limits left nil are removed.
+func (s *PostgresStore) SetTierLimits(ctx context.Context, tier model.TierLimits) error {
+	return s.inTx(ctx, pgx.TxOptions{}, func(tx pgx.Tx) error {
+		var before *model.TierLimits
+		old := model.TierLimits{Tier: tier.Tier}
+		l := &old.Limits
+		query := "SELECT max_amount, daily_amount, monthly_amount, hourly_count FROM tier_limits WHERE tier = $1 FOR UPDATE"
+		err := tx.QueryRow(ctx, query, tier.Tier).Scan(&l.MaxAmount, &l.DailyAmount, &l.MonthlyAmount, &l.HourlyCount)
+		switch {
+		case err == nil:
+			before = &old
+		case !errors.Is(err, pgx.ErrNoRows):
+			return fmt.Errorf("could not lock tier limits: %w", err)
+		}
+
+		l = &tier.Limits
+		query = `
+			INSERT INTO tier_limits (tier, max_amount, daily_amount, monthly_amount, hourly_count)
+			VALUES ($1, $2, $3, $4, $5)
+			ON CONFLICT (tier) DO UPDATE SET max_amount = $2, daily_amount = $3, monthly_amount = $4, hourly_count = $5`
+		if _, err := tx.Exec(ctx, query, tier.Tier, l.MaxAmount, l.DailyAmount, l.MonthlyAmount, l.HourlyCount); err != nil {
+			return fmt.Errorf("could not set tier limits: %w", err)
+		}
+		return appendAudit(ctx, tx, audit.ActionLimitsTier, before, tier)
+	})
+}
+
+// GetAccountLimits returns the transfer limits of an account.
+func (s *PostgresStore) GetAccountLimits(ctx context.Context, id int64) (*model.AccountLimits, error) {
+	tx, err := s.db.BeginTx(ctx, pgx.TxOptions{AccessMode: pgx.ReadOnly})
+	if err != nil {
+		return nil, fmt.Errorf("could not begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var exists bool
+	if err := tx.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM accounts WHERE account_id = $1)", id).Scan(&exists); err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, &AccountError{AccountID: id, Err: ErrNotFound}
+	}
+	return accountLimits(ctx, tx, id)
+}
+
+// SetAccountLimits assigns an account its tier and replaces its overrides.
+// Overrides left nil fall back to the tier's limits.
+func (s *PostgresStore) SetAccountLimits(ctx context.Context, id int64, req model.AccountLimitsRequest) (*model.AccountLimits, error) {
+	if req.Tier == "" {
+		req.Tier = model.DefaultTier
+	}
+	var after *model.AccountLimits
+	err := s.inTx(ctx, pgx.TxOptions{}, func(tx pgx.Tx) error {
+		// Lock the account, so the change is ordered with its transfers.
+		if err := tx.QueryRow(ctx, "SELECT account_id FROM accounts WHERE account_id = $1 FOR UPDATE", id).Scan(&id); err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return &AccountError{AccountID: id, Err: ErrNotFound}
+			}
+			return fmt.Errorf("could not lock account: %w", err)
+		}
+		before, err := accountLimits(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+
+		o := req.Overrides
+		query := `
+			INSERT INTO account_limits (account_id, tier, max_amount, daily_amount, monthly_amount, hourly_count)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			ON CONFLICT (account_id) DO UPDATE
+			SET tier = $2, max_amount = $3, daily_amount = $4, monthly_amount = $5, hourly_count = $6`
+		if _, err := tx.Exec(ctx, query, id, req.Tier, o.MaxAmount, o.DailyAmount, o.MonthlyAmount, o.HourlyCount); err != nil {
+			return fmt.Errorf("could not set account limits: %w", err)
+		}
+		after, err = accountLimits(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		return appendAudit(ctx, tx, audit.ActionLimitsAccount, before, after)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return after, nil
+}
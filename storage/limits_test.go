@@ -0,0 +1,139 @@
+package storage
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"go-api-example/model"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func transfer(from, to int64, amount string) model.TransactionRequest {
+	return model.TransactionRequest{SourceAccountID: from, DestinationAccountID: to, Amount: decimal.RequireFromString(amount)}
+}
+
+// executeTransfer executes req against testStore and returns only its error.
+func executeTransfer(ctx context.Context, req model.TransactionRequest) error {
+	_, err := testStore.ExecuteTransfer(ctx, req)
+	return err
+}
+
+func TestTransferLimits(t *testing.T) {
+	ctx := context.Background()
+	truncateTables(t, ctx)
+	require.NoError(t, testStore.CreateAccount(ctx, model.Account{AccountID: 1, Balance: decimal.NewFromInt(1000)}))
+	require.NoError(t, testStore.CreateAccount(ctx, model.Account{AccountID: 2, Balance: decimal.NewFromInt(1000)}))
+
+	// Arrange: the standard tier caps single transfers and the daily total; account 1 raises the daily total
+	maxAmount, daily, override := decimal.NewFromInt(100), decimal.NewFromInt(150), decimal.NewFromInt(250)
+	require.NoError(t, testStore.SetTierLimits(ctx, model.TierLimits{Tier: model.DefaultTier,
+		Limits: model.TransferLimits{MaxAmount: &maxAmount, DailyAmount: &daily}}))
+	limits, err := testStore.SetAccountLimits(ctx, 1, model.AccountLimitsRequest{Overrides: model.TransferLimits{DailyAmount: &override}})
+	require.NoError(t, err)
+	assert.Equal(t, model.DefaultTier, limits.Tier)
+	assert.True(t, maxAmount.Equal(*limits.Effective.MaxAmount))
+	assert.True(t, override.Equal(*limits.Effective.DailyAmount))
+
+	// Act & Assert
+	err = executeTransfer(ctx, transfer(2, 1, "100.01"))
+	var limitErr *LimitError
+	require.ErrorAs(t, err, &limitErr)
+	assert.ErrorIs(t, err, ErrLimitExceeded)
+	assert.Equal(t, model.LimitMaxAmount, limitErr.Limit)
+
+	require.NoError(t, executeTransfer(ctx, transfer(2, 1, "100")))
+	err = executeTransfer(ctx, transfer(2, 1, "60"))
+	require.ErrorAs(t, err, &limitErr)
+	assert.Equal(t, model.LimitDailyAmount, limitErr.Limit)
+	assert.Equal(t, "50", limitErr.Remaining.String())
+
+	// Incoming transfers do not count; account 1 has its own daily limit
+	require.NoError(t, executeTransfer(ctx, transfer(1, 2, "100")))
+	require.NoError(t, executeTransfer(ctx, transfer(1, 2, "100")))
+	err = executeTransfer(ctx, transfer(1, 2, "100"))
+	require.ErrorAs(t, err, &limitErr)
+	assert.Equal(t, "50", limitErr.Remaining.String())
+
+	// A rejected transfer leaves no trace
+	acc, err := testStore.GetAccount(ctx, 1)
+	require.NoError(t, err)
+	assert.True(t, decimal.NewFromInt(900).Equal(acc.Balance))
+
+	_, err = testStore.GetAccountLimits(ctx, 99)
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestTransferLimits_JournalEntries(t *testing.T) {
+	ctx := context.Background()
+	truncateTables(t, ctx)
+	for id := int64(1); id <= 3; id++ {
+		require.NoError(t, testStore.CreateAccount(ctx, model.Account{AccountID: id, Balance: decimal.NewFromInt(1000)}))
+	}
+	daily := decimal.NewFromInt(150)
+	require.NoError(t, testStore.SetTierLimits(ctx, model.TierLimits{Tier: model.DefaultTier, Limits: model.TransferLimits{DailyAmount: &daily}}))
+	// entry posts amounts to the accounts named by the keys.
+	entry := func(amounts map[int64]string) error {
+		req := model.JournalEntryRequest{}
+		for id, a := range amounts {
+			req.Postings = append(req.Postings, model.PostingRequest{AccountID: id, Amount: decimal.RequireFromString(a)})
+		}
+		_, err := testStore.PostJournalEntry(ctx, req)
+		return err
+	}
+	require.NoError(t, executeTransfer(ctx, transfer(1, 2, "100")))
+
+	// Act & Assert: the entry's debits from account 1 count against its limit
+	err := entry(map[int64]string{1: "-60", 2: "30", 3: "30"})
+	var limitErr *LimitError
+	require.ErrorAs(t, err, &limitErr)
+	assert.Equal(t, model.LimitDailyAmount, limitErr.Limit)
+	assert.Equal(t, "50", limitErr.Remaining.String())
+	var accErr *AccountError
+	require.ErrorAs(t, err, &accErr)
+	assert.Equal(t, int64(1), accErr.AccountID)
+
+	require.NoError(t, entry(map[int64]string{1: "-50", 2: "50"}))
+	err = executeTransfer(ctx, transfer(1, 2, "1"))
+	assert.ErrorIs(t, err, ErrLimitExceeded, "journal entries count towards transfer limits")
+
+	// Every debited account is checked
+	require.NoError(t, entry(map[int64]string{2: "-100", 3: "100"}))
+	err = entry(map[int64]string{2: "-100", 3: "100"})
+	require.ErrorAs(t, err, &accErr)
+	assert.Equal(t, int64(2), accErr.AccountID)
+}
+
+func TestTransferLimits_ConcurrentTransfersCannotBypass(t *testing.T) {
+	ctx := context.Background()
+	truncateTables(t, ctx)
+	require.NoError(t, testStore.CreateAccount(ctx, model.Account{AccountID: 1, Balance: decimal.NewFromInt(1000)}))
+	require.NoError(t, testStore.CreateAccount(ctx, model.Account{AccountID: 2, Balance: decimal.Zero}))
+	count := int64(5)
+	_, err := testStore.SetAccountLimits(ctx, 1, model.AccountLimitsRequest{Tier: "retail", Overrides: model.TransferLimits{HourlyCount: &count}})
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	succeeded := 0
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if executeTransfer(ctx, transfer(1, 2, "1")) == nil {
+				mu.Lock()
+				succeeded++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, 5, succeeded)
+	acc, err := testStore.GetAccount(ctx, 2)
+	require.NoError(t, err)
+	assert.True(t, decimal.NewFromInt(5).Equal(acc.Balance))
+}
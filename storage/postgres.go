@@ -6,9 +6,12 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strconv"
 	"time"
 
+	"go-api-example/audit"
 	"go-api-example/model"
+	"go-api-example/ratelimit"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
@@ -19,79 +22,520 @@ import (
 var (
 	ErrNotFound          = errors.New("account not found")
 	ErrInsufficientFunds = errors.New("insufficient funds")
+	ErrAccountFrozen     = errors.New("account is frozen")
+	ErrAccountReadOnly   = errors.New("account is read-only pending reconciliation")
+	ErrUnbalancedEntry   = errors.New("journal entry postings do not sum to zero")
+	ErrCurrencyMismatch  = errors.New("posting currency does not match account currency")
+	ErrJobNotFound       = errors.New("transfer job not found")
+
+	ErrApprovalNotFound   = errors.New("transfer approval not found")
+	ErrApprovalNotPending = errors.New("transfer approval has already been decided")
+	ErrApprovalExpired    = errors.New("transfer approval has expired")
+	ErrSelfApproval       = errors.New("a transfer cannot be approved or rejected by the principal who requested it")
+
+	ErrLimitExceeded = errors.New("transfer limit exceeded")
 )
 
+// AccountError attaches the offending account ID to a storage error.
+// It unwraps to the underlying error, so errors.Is(err, ErrNotFound) keeps working.
+type AccountError struct {
+	AccountID int64
+	Err       error
+}
+
+func (e *AccountError) Error() string {
+	return fmt.Sprintf("account %d: %v", e.AccountID, e.Err)
+}
+
+func (e *AccountError) Unwrap() error {
+	return e.Err
+}
+
 // Store defines the interface for database operations.
 type Store interface {
 	CreateAccount(ctx context.Context, acc model.Account) error
+	CreateAccounts(ctx context.Context, accounts []model.Account) ([]bool, error)
 	GetAccount(ctx context.Context, id int64) (*model.Account, error)
-	ExecuteTransfer(ctx context.Context, req model.TransactionRequest) error
+	ExecuteTransfer(ctx context.Context, req model.TransactionRequest) (*model.Transfer, error)
+	ExecuteTransferBatch(ctx context.Context, transfers []BatchedTransfer) ([]BatchResult, error)
+	ListAccounts(ctx context.Context, filter model.AccountFilter) (*model.AccountPage, error)
+	SetAccountStatus(ctx context.Context, id int64, status string) error
+	GetAccountShards(ctx context.Context, id int64) (*model.AccountShards, error)
+	SetAccountShards(ctx context.Context, id int64, shards int) (*model.AccountShards, error)
+	Reconcile(ctx context.Context, opts model.ReconcileOptions) (*model.ReconciliationReport, error)
+	GetAccountPostings(ctx context.Context, id int64, filter model.PostingFilter) (*model.AccountLedger, error)
+	PostJournalEntry(ctx context.Context, req model.JournalEntryRequest) (*model.JournalEntry, error)
+	GetAccountAsOf(ctx context.Context, id int64, asOf time.Time) (*model.Account, error)
+	WriteStatement(ctx context.Context, id int64, period model.StatementPeriod, w model.StatementWriter) error
+	AuditLog(ctx context.Context, filter model.AuditFilter) ([]model.AuditRecord, error)
+	CreateTransferJob(ctx context.Context, lines []model.TransferJobLine) (*model.TransferJob, error)
+	GetTransferJob(ctx context.Context, id int64) (*model.TransferJob, error)
+	TransferJobLines(ctx context.Context, id int64, filter model.TransferJobLineFilter) ([]model.TransferJobLine, error)
+	ProcessNextTransferJobLine(ctx context.Context) (*model.TransferJobLine, error)
+	RequestTransferApproval(ctx context.Context, req model.TransactionRequest, ttl time.Duration) (*model.TransferApproval, error)
+	RequestJournalEntryApproval(ctx context.Context, req model.JournalEntryRequest, ttl time.Duration) (*model.TransferApproval, error)
+	GetTransferApproval(ctx context.Context, id int64) (*model.TransferApproval, error)
+	ListTransferApprovals(ctx context.Context, filter model.ApprovalFilter) ([]model.TransferApproval, error)
+	DecideTransferApproval(ctx context.Context, id int64, approve bool) (*model.TransferApproval, error)
+	ListTierLimits(ctx context.Context) ([]model.TierLimits, error)
+	SetTierLimits(ctx context.Context, tier model.TierLimits) error
+	GetAccountLimits(ctx context.Context, id int64) (*model.AccountLimits, error)
+	SetAccountLimits(ctx context.Context, id int64, req model.AccountLimitsRequest) (*model.AccountLimits, error)
+	TransferRules(ctx context.Context) ([]model.Rule, error)
+	RecordScreeningHit(ctx context.Context, hit model.ScreeningHit) error
+	ListTierFees(ctx context.Context) ([]model.TierFees, error)
+	SetTierFees(ctx context.Context, tier string, schedule *model.FeeSchedule) error
+	GetAccountFees(ctx context.Context, id int64) (*model.AccountFees, error)
+	SetAccountFees(ctx context.Context, id int64, schedule *model.FeeSchedule) (*model.AccountFees, error)
+	GetInterestRate(ctx context.Context, id int64) (*model.InterestRate, error)
+	SetInterestRate(ctx context.Context, id int64, req model.InterestRateRequest) (*model.InterestRate, error)
+	AccrueInterest(ctx context.Context, day time.Time) (int, error)
+	PostInterest(ctx context.Context, before time.Time, expenseAccountID int64) ([]model.InterestPosting, error)
+	TakeRateLimitToken(ctx context.Context, key string, limit ratelimit.Limit) (bool, float64, error)
+	PruneRateLimitBuckets(ctx context.Context) (int64, error)
+	ConsistencyToken(ctx context.Context) (string, error)
 }
 
 // PostgresStore implements the Store interface for PostgreSQL.
 type PostgresStore struct {
-	db *pgxpool.Pool
+	db      *pgxpool.Pool
+	retry   txRetry
+	replica *replica           // nil without a read replica
+	stop    context.CancelFunc // stops chaining audit records and monitoring the replica
+	now     func() time.Time   // clock of interest accrual and posting; nil for time.Now
+}
+
+// Options tune the connection pool. The zero value of a field means "use the default".
+type Options struct {
+	ConnectRetries       int              // connection attempts at startup (default 5)
+	ConnectRetryInterval time.Duration    // wait between attempts (default 1s)
+	MaxConns             int32            // pool size (default: pgxpool's default)
+	MinConns             int32            // idle connections kept open
+	StatementTimeout     time.Duration    // PostgreSQL statement_timeout for every connection
+	LockTimeout          time.Duration    // PostgreSQL lock_timeout for every connection
+	TxAttempts           int              // attempts of a write transaction aborted by a serialization failure or deadlock (default 4)
+	TxRetryBaseDelay     time.Duration    // backoff before the first retry, doubled for each further one (default 10ms)
+	TxRetryMaxDelay      time.Duration    // longest backoff between retries (default 500ms)
+	ReadURL              string           // read replica that reads of balances can ask for; empty for none
+	ReplicaCheckInterval time.Duration    // how often the replica's health is checked (default 5s)
+	ReplicaMaxLag        time.Duration    // a replica lagging further behind is unhealthy; 0 for no limit
+	Now                  func() time.Time // clock of interest accrual and posting (default time.Now)
+}
+
+// clock returns the current time of the store's clock.
+func (s *PostgresStore) clock() time.Time {
+	if s.now == nil {
+		return time.Now()
+	}
+	return s.now()
+}
+
+// txRetry returns the retry budget of write transactions, with defaults.
+func (o Options) txRetry() txRetry {
+	r := txRetry{attempts: o.TxAttempts, baseDelay: o.TxRetryBaseDelay, maxDelay: o.TxRetryMaxDelay}
+	if r.attempts <= 0 {
+		r.attempts = defaultTxAttempts
+	}
+	if r.baseDelay <= 0 {
+		r.baseDelay = defaultTxRetryBaseDelay
+	}
+	if r.maxDelay <= 0 {
+		r.maxDelay = defaultTxRetryMaxDelay
+	}
+	return r
+}
+
+// poolConfig returns the configuration of a connection pool to connString.
+func (o Options) poolConfig(connString string) (*pgxpool.Config, error) {
+	poolConfig, err := pgxpool.ParseConfig(connString)
+	if err != nil {
+		return nil, err
+	}
+	if o.MaxConns > 0 {
+		poolConfig.MaxConns = o.MaxConns
+	}
+	if o.MinConns > 0 {
+		poolConfig.MinConns = o.MinConns
+	}
+	if o.StatementTimeout > 0 {
+		poolConfig.ConnConfig.RuntimeParams["statement_timeout"] = strconv.FormatInt(o.StatementTimeout.Milliseconds(), 10)
+	}
+	if o.LockTimeout > 0 {
+		poolConfig.ConnConfig.RuntimeParams["lock_timeout"] = strconv.FormatInt(o.LockTimeout.Milliseconds(), 10)
+	}
+	return poolConfig, nil
 }
 
 // NewPostgresStore creates a new PostgresStore, connects to the database, and initializes the schema.
-func NewPostgresStore(ctx context.Context, connString string) (*PostgresStore, error) {
-	var pool *pgxpool.Pool
-	var err error
-
-	// Retry connecting to the database for a few seconds
-	for i := 0; i < 5; i++ {
-		pool, err = pgxpool.New(ctx, connString)
-		if err == nil {
-			if err := pool.Ping(ctx); err == nil {
-				break
-			}
+// If opts names a read replica, it is connected too; see WithReadConsistency.
+func NewPostgresStore(ctx context.Context, connString string, opts Options) (*PostgresStore, error) {
+	poolConfig, err := opts.poolConfig(connString)
+	if err != nil {
+		return nil, fmt.Errorf("invalid database connection string: %w", err)
+	}
+
+	retries := opts.ConnectRetries
+	if retries <= 0 {
+		retries = 5
+	}
+	interval := opts.ConnectRetryInterval
+	if interval <= 0 {
+		interval = time.Second
+	}
+
+	pool, err := pgxpool.NewWithConfig(ctx, poolConfig)
+	if err != nil {
+		return nil, fmt.Errorf("could not create connection pool: %w", err)
+	}
+
+	// Retry connecting to the database, as it may still be starting up
+	for i := 0; i < retries; i++ {
+		if err = pool.Ping(ctx); err == nil {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			pool.Close()
+			return nil, ctx.Err()
+		case <-time.After(interval):
 		}
-		time.Sleep(1 * time.Second)
 	}
 	if err != nil {
-		return nil, fmt.Errorf("could not connect to database after retries: %w", err)
+		pool.Close()
+		return nil, fmt.Errorf("could not connect to database after %d attempts: %w", retries, err)
 	}
 
-	store := &PostgresStore{db: pool}
+	store := &PostgresStore{db: pool, retry: opts.txRetry(), now: opts.Now}
 	if err := store.initSchema(ctx); err != nil {
+		pool.Close()
 		return nil, fmt.Errorf("could not initialize schema: %w", err)
 	}
+	background, stop := context.WithCancel(context.WithoutCancel(ctx))
+	if opts.ReadURL != "" {
+		if err := store.openReplica(ctx, background, opts); err != nil {
+			stop()
+			pool.Close()
+			return nil, err
+		}
+	}
+	store.stop = stop
+	go store.chainAuditEvery(background, auditChainInterval)
 
 	return store, nil
 }
 
+// Close stops the store's background work and releases all database
+// connections held by the store. Audit records not chained yet stay in
+// audit_pending until the next store chains them.
+func (s *PostgresStore) Close() {
+	if s.stop != nil {
+		s.stop()
+	}
+	if s.replica != nil {
+		s.replica.db.Close()
+	}
+	s.db.Close()
+}
+
 // initSchema creates the necessary tables if they don't exist.
+//
+// Balances are a cached projection of an immutable double-entry journal:
+// every transfer is a journal entry whose postings sum to zero in each currency, and
+// accounts.balance always equals opening_balance plus the account's postings.
+// Journal rows cannot be updated or deleted, and an entry whose postings do not
+// sum to zero is rejected when its transaction commits.
+//
+// The balance of a hot account is spread over its rows in account_shards.
+// Every state change also appends a record to audit_pending, from which it is
+// chained into audit_log, a hash chain whose head (the last sequence number and
+// hash) is kept in the single row of audit_head.
+// Transfer jobs and their lines are kept in transfer_jobs and transfer_job_lines,
+// transfers held for approval in transfer_approvals, and the transfer limits of
+// tiers and accounts in tier_limits and account_limits, and their fee schedules
+// in tier_fees and account_fees. transfer_rules holds the transfer rules when
+// they are loaded from the database. Interest rates are kept in interest_rates
+// and the daily accruals in interest_accruals. rate_limit_buckets holds the
+// rate limit buckets shared by all replicas; it is unlogged, as losing them in
+// a crash only refills them.
 func (s *PostgresStore) initSchema(ctx context.Context) error {
 	query := `
     CREATE TABLE IF NOT EXISTS accounts (
         account_id BIGINT PRIMARY KEY,
         balance NUMERIC(19, 5) NOT NULL,
         created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
-    );`
+    );
+    ALTER TABLE accounts ADD COLUMN IF NOT EXISTS status TEXT NOT NULL DEFAULT 'active';
+    ALTER TABLE accounts ADD COLUMN IF NOT EXISTS opening_balance NUMERIC(19, 5);
+    -- Accounts created before the journal existed start their history at their current balance.
+    UPDATE accounts SET opening_balance = balance WHERE opening_balance IS NULL;
+    ALTER TABLE accounts ALTER COLUMN opening_balance SET NOT NULL;
+    ALTER TABLE accounts ADD COLUMN IF NOT EXISTS currency TEXT NOT NULL DEFAULT 'XXX';
+    ALTER TABLE accounts ADD COLUMN IF NOT EXISTS owner_name TEXT NOT NULL DEFAULT '';
+    -- Keyset pagination for each sort order of ListAccounts: a page is an index range scan
+    -- starting at the cursor, so deep pages cost the same as the first one.
+    CREATE INDEX IF NOT EXISTS accounts_balance_idx ON accounts (balance, account_id);
+    CREATE INDEX IF NOT EXISTS accounts_created_at_idx ON accounts (created_at, account_id);
+    -- A hot account (shards > 0) keeps its balance in account_shards, not in the account row.
+    ALTER TABLE accounts ADD COLUMN IF NOT EXISTS shards INT NOT NULL DEFAULT 0;
+    CREATE TABLE IF NOT EXISTS account_shards (
+        account_id BIGINT NOT NULL REFERENCES accounts (account_id),
+        shard INT NOT NULL,
+        balance NUMERIC(19, 5) NOT NULL CHECK (balance >= 0),
+        PRIMARY KEY (account_id, shard)
+    );
+
+    CREATE TABLE IF NOT EXISTS journal_entries (
+        entry_id BIGSERIAL PRIMARY KEY,
+        kind TEXT NOT NULL,
+        created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+    );
+    CREATE TABLE IF NOT EXISTS postings (
+        posting_id BIGSERIAL PRIMARY KEY,
+        entry_id BIGINT NOT NULL REFERENCES journal_entries (entry_id),
+        account_id BIGINT NOT NULL REFERENCES accounts (account_id),
+        amount NUMERIC(19, 5) NOT NULL,
+        created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+    );
+    ALTER TABLE postings ADD COLUMN IF NOT EXISTS currency TEXT NOT NULL DEFAULT 'XXX';
+    CREATE INDEX IF NOT EXISTS postings_account_id_idx ON postings (account_id, posting_id);
+    -- Point-in-time balances sum an account's postings up to an instant. Including amount
+    -- lets that be an index-only range scan; postings are never updated, so the pages stay all-visible.
+    CREATE INDEX IF NOT EXISTS postings_account_created_at_idx ON postings (account_id, created_at) INCLUDE (amount);
+
+    CREATE OR REPLACE FUNCTION ledger_reject_change() RETURNS trigger AS $$
+    BEGIN
+        RAISE EXCEPTION 'ledger rows are immutable: % on % is not allowed', TG_OP, TG_TABLE_NAME;
+    END $$ LANGUAGE plpgsql;
+
+    CREATE OR REPLACE FUNCTION ledger_check_balanced() RETURNS trigger AS $$
+    BEGIN
+        IF EXISTS (SELECT 1 FROM postings WHERE entry_id = NEW.entry_id GROUP BY currency HAVING SUM(amount) <> 0) THEN
+            RAISE EXCEPTION 'journal entry % is unbalanced', NEW.entry_id USING ERRCODE = 'check_violation';
+        END IF;
+        RETURN NULL;
+    END $$ LANGUAGE plpgsql;
+
+    DROP TRIGGER IF EXISTS journal_entries_immutable ON journal_entries;
+    CREATE TRIGGER journal_entries_immutable BEFORE UPDATE OR DELETE ON journal_entries
+        FOR EACH ROW EXECUTE FUNCTION ledger_reject_change();
+    DROP TRIGGER IF EXISTS postings_immutable ON postings;
+    CREATE TRIGGER postings_immutable BEFORE UPDATE OR DELETE ON postings
+        FOR EACH ROW EXECUTE FUNCTION ledger_reject_change();
+    DROP TRIGGER IF EXISTS postings_balanced ON postings;
+    CREATE CONSTRAINT TRIGGER postings_balanced AFTER INSERT ON postings
+        DEFERRABLE INITIALLY DEFERRED
+        FOR EACH ROW EXECUTE FUNCTION ledger_check_balanced();
+
+    CREATE TABLE IF NOT EXISTS reconciliation_reports (
+        report_id BIGSERIAL PRIMARY KEY,
+        accounts_checked BIGINT NOT NULL,
+        mismatch_count INT NOT NULL,
+        report JSONB NOT NULL,
+        created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+    );
+
+    -- before and after are JSON, not JSONB, so the exact text that was hashed is kept.
+    CREATE TABLE IF NOT EXISTS audit_log (
+        seq BIGINT PRIMARY KEY,
+        created_at TIMESTAMPTZ NOT NULL,
+        actor TEXT NOT NULL,
+        request_id TEXT NOT NULL,
+        action TEXT NOT NULL,
+        before JSON NOT NULL,
+        after JSON NOT NULL,
+        prev_hash TEXT NOT NULL,
+        hash TEXT NOT NULL
+    );
+    CREATE TABLE IF NOT EXISTS audit_head (
+        id INT PRIMARY KEY CHECK (id = 1),
+        seq BIGINT NOT NULL,
+        hash TEXT NOT NULL
+    );
+    INSERT INTO audit_head (id, seq, hash) VALUES (1, 0, repeat('0', 64)) ON CONFLICT (id) DO NOTHING;
+    -- Records of committed changes not chained into audit_log yet.
+    CREATE TABLE IF NOT EXISTS audit_pending (
+        id BIGSERIAL PRIMARY KEY,
+        created_at TIMESTAMPTZ NOT NULL,
+        actor TEXT NOT NULL,
+        request_id TEXT NOT NULL,
+        action TEXT NOT NULL,
+        before JSON NOT NULL,
+        after JSON NOT NULL
+    );
+    DROP TRIGGER IF EXISTS audit_log_immutable ON audit_log;
+    CREATE TRIGGER audit_log_immutable BEFORE UPDATE OR DELETE ON audit_log
+        FOR EACH ROW EXECUTE FUNCTION ledger_reject_change();
+    -- Pending records are never edited, and only removed by chainAuditBatch once chained,
+    -- which sets audit.chaining for its transaction.
+    CREATE OR REPLACE FUNCTION audit_pending_reject_change() RETURNS trigger AS $$
+    BEGIN
+        IF TG_OP = 'DELETE' AND current_setting('audit.chaining', true) = 'on' THEN
+            RETURN OLD;
+        END IF;
+        RAISE EXCEPTION 'pending audit records are immutable: % on % is not allowed', TG_OP, TG_TABLE_NAME;
+    END $$ LANGUAGE plpgsql;
+    DROP TRIGGER IF EXISTS audit_pending_immutable ON audit_pending;
+    CREATE TRIGGER audit_pending_immutable BEFORE UPDATE OR DELETE ON audit_pending
+        FOR EACH ROW EXECUTE FUNCTION audit_pending_reject_change();
+
+    -- Transfer jobs: uploaded transfer files executed line by line by background workers.
+    -- Amounts are unconstrained NUMERIC so an out-of-range amount fails its line, not the upload.
+    CREATE TABLE IF NOT EXISTS transfer_jobs (
+        job_id BIGSERIAL PRIMARY KEY,
+        created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+        started_at TIMESTAMPTZ
+    );
+    CREATE TABLE IF NOT EXISTS transfer_job_lines (
+        job_id BIGINT NOT NULL REFERENCES transfer_jobs (job_id),
+        line INT NOT NULL,
+        source_account_id BIGINT,
+        destination_account_id BIGINT,
+        amount NUMERIC,
+        status TEXT NOT NULL,
+        error TEXT NOT NULL DEFAULT '',
+        processed_at TIMESTAMPTZ,
+        PRIMARY KEY (job_id, line)
+    );
+    -- Workers claim the oldest pending line; the partial index only holds the backlog.
+    CREATE INDEX IF NOT EXISTS transfer_job_lines_pending_idx ON transfer_job_lines (job_id, line) WHERE status = 'pending';
+
+    -- Transfers above the approval threshold, held until a second principal decides.
+    -- An expired approval keeps status 'pending_approval'; expiry is derived from expires_at.
+    CREATE TABLE IF NOT EXISTS transfer_approvals (
+        approval_id BIGSERIAL PRIMARY KEY,
+        source_account_id BIGINT NOT NULL,
+        destination_account_id BIGINT NOT NULL,
+        amount NUMERIC(19, 5) NOT NULL,
+        status TEXT NOT NULL DEFAULT 'pending_approval',
+        requested_by TEXT NOT NULL,
+        requested_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+        expires_at TIMESTAMPTZ NOT NULL,
+        decided_by TEXT,
+        decided_at TIMESTAMPTZ
+    );
+    CREATE INDEX IF NOT EXISTS transfer_approvals_status_idx ON transfer_approvals (status, approval_id);
+    -- The postings of a held journal entry, whose transfer columns are zero.
+    ALTER TABLE transfer_approvals ADD COLUMN IF NOT EXISTS postings JSONB;
+
+    -- Transfer limits; NULL is no limit. An account without an account_limits row is in
+    -- the 'standard' tier, and a tier without a tier_limits row has no limits.
+    CREATE TABLE IF NOT EXISTS tier_limits (
+        tier TEXT PRIMARY KEY,
+        max_amount NUMERIC(19, 5),
+        daily_amount NUMERIC(19, 5),
+        monthly_amount NUMERIC(19, 5),
+        hourly_count BIGINT
+    );
+    CREATE TABLE IF NOT EXISTS account_limits (
+        account_id BIGINT PRIMARY KEY REFERENCES accounts (account_id),
+        tier TEXT NOT NULL DEFAULT 'standard',
+        max_amount NUMERIC(19, 5),
+        daily_amount NUMERIC(19, 5),
+        monthly_amount NUMERIC(19, 5),
+        hourly_count BIGINT
+    );
+
+    -- Transfer rules, evaluated in position order; see package rules.
+    CREATE TABLE IF NOT EXISTS transfer_rules (
+        position INT PRIMARY KEY,
+        name TEXT NOT NULL UNIQUE,
+        when_expr TEXT NOT NULL,
+        action TEXT NOT NULL CHECK (action IN ('allow', 'deny', 'review')),
+        code TEXT NOT NULL DEFAULT '',
+        enabled BOOLEAN NOT NULL DEFAULT TRUE
+    );
+
+    -- Fee schedules (model.FeeSchedule as JSON). An account's own schedule replaces that of
+    -- the tier its account_limits row assigns. transfer_fees links a transfer's journal entry
+    -- to the entry of its fee, with the breakdown of how the fee was computed.
+    CREATE TABLE IF NOT EXISTS tier_fees (
+        tier TEXT PRIMARY KEY,
+        schedule JSONB NOT NULL
+    );
+    CREATE TABLE IF NOT EXISTS account_fees (
+        account_id BIGINT PRIMARY KEY REFERENCES accounts (account_id),
+        schedule JSONB NOT NULL
+    );
+    CREATE TABLE IF NOT EXISTS transfer_fees (
+        entry_id BIGINT PRIMARY KEY REFERENCES journal_entries (entry_id),
+        fee_entry_id BIGINT NOT NULL UNIQUE REFERENCES journal_entries (entry_id),
+        breakdown JSONB NOT NULL
+    );
+    DROP TRIGGER IF EXISTS transfer_fees_immutable ON transfer_fees;
+    CREATE TRIGGER transfer_fees_immutable BEFORE UPDATE OR DELETE ON transfer_fees
+        FOR EACH ROW EXECUTE FUNCTION ledger_reject_change();
+
+    -- Interest. carry is the interest left over below the scale of a balance by the last
+    -- posting. An accrual row keeps the inputs of a day's interest (see interest.Accrual),
+    -- so that a month is summed exactly; posted_at is set when its month is posted, and
+    -- entry_id links the transfer, unless the interest rounded down to nothing.
+    CREATE TABLE IF NOT EXISTS interest_rates (
+        account_id BIGINT PRIMARY KEY REFERENCES accounts (account_id),
+        annual_rate NUMERIC NOT NULL CHECK (annual_rate >= 0),
+        day_count TEXT NOT NULL,
+        carry NUMERIC NOT NULL DEFAULT 0
+    );
+    CREATE TABLE IF NOT EXISTS interest_accruals (
+        account_id BIGINT NOT NULL REFERENCES interest_rates (account_id),
+        day DATE NOT NULL,
+        balance NUMERIC(19, 5) NOT NULL,
+        annual_rate NUMERIC NOT NULL,
+        days BIGINT NOT NULL,
+        year BIGINT NOT NULL,
+        entry_id BIGINT REFERENCES journal_entries (entry_id),
+        posted_at TIMESTAMPTZ,
+        PRIMARY KEY (account_id, day)
+    );
+    CREATE INDEX IF NOT EXISTS interest_accruals_unposted_idx ON interest_accruals (day) WHERE posted_at IS NULL;
+
+    -- Rate limit token buckets (see package ratelimit); full_at is when a bucket will have refilled.
+    CREATE UNLOGGED TABLE IF NOT EXISTS rate_limit_buckets (
+        key TEXT PRIMARY KEY,
+        tokens FLOAT8 NOT NULL,
+        allowed BOOLEAN NOT NULL,
+        updated_at TIMESTAMPTZ NOT NULL,
+        full_at TIMESTAMPTZ NOT NULL
+    );
+    CREATE INDEX IF NOT EXISTS rate_limit_buckets_full_at_idx ON rate_limit_buckets (full_at);`
 	_, err := s.db.Exec(ctx, query)
 	return err
 }
 
 // CreateAccount creates a new account in the database.
+// The initial balance is recorded as the account's opening balance, the starting point of its journal history.
 // CreateAccount function is idempotent: if an account with the same ID already exists, it does nothing and returns nil.
+// Accounts without a currency get model.DefaultCurrency. Only an account that is actually created is audited.
 func (s *PostgresStore) CreateAccount(ctx context.Context, acc model.Account) error {
-	query := `
-		INSERT INTO accounts (account_id, balance) 
-		VALUES ($1, $2) 
-		ON CONFLICT (account_id) DO NOTHING`
-	_, err := s.db.Exec(ctx, query, acc.AccountID, acc.Balance)
-	return err
+	if acc.Currency == "" {
+		acc.Currency = model.DefaultCurrency
+	}
+	acc.Status = model.AccountStatusActive
+
+	return s.inTx(ctx, pgx.TxOptions{}, func(tx pgx.Tx) error {
+		query := `
+			INSERT INTO accounts (account_id, balance, opening_balance, currency, owner_name) 
+			VALUES ($1, $2, $2, $3, $4) 
+			ON CONFLICT (account_id) DO NOTHING`
+		tag, err := tx.Exec(ctx, query, acc.AccountID, acc.Balance, acc.Currency, acc.OwnerName)
+		if err != nil {
+			return err
+		}
+		if tag.RowsAffected() == 0 {
+			return nil
+		}
+		return appendAudit(ctx, tx, audit.ActionAccountCreate, nil, acc)
+	})
 }
 
 // GetAccount retrieves a single account by its ID.
 func (s *PostgresStore) GetAccount(ctx context.Context, id int64) (*model.Account, error) {
 	acc := &model.Account{AccountID: id}
-	query := "SELECT balance FROM accounts WHERE account_id = $1"
-	err := s.db.QueryRow(ctx, query, id).Scan(&acc.Balance)
+	query := "SELECT " + cachedBalance + ", a.currency, a.owner_name, a.status, a.created_at FROM accounts a WHERE a.account_id = $1"
+	err := s.reader(ctx).QueryRow(ctx, query, id).Scan(&acc.Balance, &acc.Currency, &acc.OwnerName, &acc.Status, &acc.CreatedAt)
 
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
-			return nil, ErrNotFound
+			return nil, &AccountError{AccountID: id, Err: ErrNotFound}
 		}
 		return nil, err
 	}
@@ -99,63 +543,53 @@ func (s *PostgresStore) GetAccount(ctx context.Context, id int64) (*model.Accoun
 }
 
 // ExecuteTransfer performs a financial transfer between two accounts within a database transaction.
-// It locks the rows for the source and destination accounts to prevent race conditions.
-func (s *PostgresStore) ExecuteTransfer(ctx context.Context, req model.TransactionRequest) error {
-	tx, err := s.db.Begin(ctx)
+// It is a journal entry with a debit from the source and a matching credit to the destination,
+// so both accounts must hold the same currency. The fee of the source account's schedule, if
+// any, is charged in the same transaction, and the source must cover the amount plus the fee.
+func (s *PostgresStore) ExecuteTransfer(ctx context.Context, req model.TransactionRequest) (*model.Transfer, error) {
+	var t *model.Transfer
+	err := s.inTx(ctx, pgx.TxOptions{}, func(tx pgx.Tx) error {
+		var err error
+		t, err = applyTransfer(ctx, tx, req)
+		return err
+	})
 	if err != nil {
-		return fmt.Errorf("could not begin transaction: %w", err)
+		return nil, transferError(req, err)
 	}
-	defer tx.Rollback(ctx) // Rollback is a no-op if the transaction has been committed.
-
-	// Lock accounts in a consistent order (by ID) to prevent deadlocks.
-	var sourceAccount model.Account
-	var foundSource, foundDest bool
+	return t, nil
+}
 
-	query := `
-        SELECT account_id, balance FROM accounts 
-        WHERE account_id = $1 OR account_id = $2 
-        ORDER BY account_id FOR UPDATE`
+// transferPostings returns the two legs of a transfer.
+func transferPostings(req model.TransactionRequest) []model.Posting {
+	return []model.Posting{
+		{AccountID: req.SourceAccountID, Amount: req.Amount.Neg()},
+		{AccountID: req.DestinationAccountID, Amount: req.Amount},
+	}
+}
 
-	rows, err := tx.Query(ctx, query, req.SourceAccountID, req.DestinationAccountID)
-	if err != nil {
-		return fmt.Errorf("could not query accounts for update: %w", err)
+// transferError reports an unbalanced transfer as what it is: the postings
+// inherit their accounts' currencies, which differ.
+func transferError(req model.TransactionRequest, err error) error {
+	if errors.Is(err, ErrUnbalancedEntry) {
+		return &AccountError{AccountID: req.DestinationAccountID, Err: ErrCurrencyMismatch}
 	}
-	defer rows.Close()
+	return err
+}
 
-	for rows.Next() {
-		var acc model.Account
-		if err := rows.Scan(&acc.AccountID, &acc.Balance); err != nil {
-			return fmt.Errorf("could not scan account row: %w", err)
-		}
-		if acc.AccountID == req.SourceAccountID {
-			sourceAccount = acc
-			foundSource = true
+// SetAccountStatus changes the status of an account, e.g. to freeze it.
+// Frozen accounts can neither send nor receive transfers.
+func (s *PostgresStore) SetAccountStatus(ctx context.Context, id int64, status string) error {
+	return s.inTx(ctx, pgx.TxOptions{}, func(tx pgx.Tx) error {
+		var old string
+		if err := tx.QueryRow(ctx, "SELECT status FROM accounts WHERE account_id = $1 FOR UPDATE", id).Scan(&old); err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return &AccountError{AccountID: id, Err: ErrNotFound}
+			}
+			return fmt.Errorf("could not lock account: %w", err)
 		}
-		if acc.AccountID == req.DestinationAccountID {
-			// destAccount = acc
-			foundDest = true
+		if _, err := tx.Exec(ctx, "UPDATE accounts SET status = $1 WHERE account_id = $2", status, id); err != nil {
+			return fmt.Errorf("could not update account status: %w", err)
 		}
-	}
-
-	if !foundSource || !foundDest {
-		return ErrNotFound
-	}
-
-	if sourceAccount.Balance.LessThan(req.Amount) {
-		return ErrInsufficientFunds
-	}
-
-	// Debit source account
-	updateQuery := "UPDATE accounts SET balance = balance - $1 WHERE account_id = $2"
-	if _, err := tx.Exec(ctx, updateQuery, req.Amount, req.SourceAccountID); err != nil {
-		return fmt.Errorf("could not debit source account: %w", err)
-	}
-
-	// Credit destination account
-	updateQuery = "UPDATE accounts SET balance = balance + $1 WHERE account_id = $2"
-	if _, err := tx.Exec(ctx, updateQuery, req.Amount, req.DestinationAccountID); err != nil {
-		return fmt.Errorf("could not credit destination account: %w", err)
-	}
-
-	return tx.Commit(ctx)
+		return appendAudit(ctx, tx, audit.ActionAccountStatus, statusChange{id, old}, statusChange{id, status})
+	})
 }
@@ -3,6 +3,7 @@ package storage
 
 import (
 	"context"
+	"fmt"
 	"log"
 	"os"
 	"sync"
@@ -20,25 +21,18 @@ import (
 	"github.com/testcontainers/testcontainers-go/wait"
 )
 
-var testStore *PostgresStore
+var (
+	testStore      *PostgresStore
+	testConnString string
+)
 
 // TestMain sets up the test database container and runs the tests.
 func TestMain(m *testing.M) {
 	ctx := context.Background()
 
-	// Create PostgreSQL container using the new API
-	pgContainer, err := postgres.RunContainer(ctx,
-		testcontainers.WithImage("postgres:14-alpine"),
-		postgres.WithDatabase("testdb"),
-		postgres.WithUsername("testuser"),
-		postgres.WithPassword("testpassword"),
-		testcontainers.WithWaitStrategy(
-			wait.ForLog("database system is ready to accept connections").
-				WithOccurrence(2).
-				WithStartupTimeout(30*time.Second)),
-	)
+	pgContainer, connString, err := runPostgres(ctx)
 	if err != nil {
-		log.Fatalf("could not start postgres container: %s", err)
+		log.Fatal(err)
 	}
 
 	// Clean up the container after the tests are finished
@@ -47,12 +41,7 @@ func TestMain(m *testing.M) {
 			log.Fatalf("could not terminate postgres container: %s", err)
 		}
 	}()
-
-	// Get the connection string
-	connString, err := pgContainer.ConnectionString(ctx, "sslmode=disable")
-	if err != nil {
-		log.Fatalf("could not get connection string: %s", err)
-	}
+	testConnString = connString
 
 	// Connect to the test database
 	pool, err := pgxpool.New(ctx, connString)
@@ -61,7 +50,7 @@ func TestMain(m *testing.M) {
 	}
 	defer pool.Close()
 
-	testStore = &PostgresStore{db: pool}
+	testStore = &PostgresStore{db: pool, retry: Options{}.txRetry()}
 	if err := testStore.initSchema(ctx); err != nil {
 		log.Fatalf("could not initialize schema: %s", err)
 	}
@@ -71,10 +60,36 @@ func TestMain(m *testing.M) {
 	os.Exit(code)
 }
 
-// truncateTables clears the accounts table between tests to ensure isolation.
-func truncateTables(t *testing.T, ctx context.Context) {
+// runPostgres starts a PostgreSQL container and returns its connection string.
+func runPostgres(ctx context.Context) (*postgres.PostgresContainer, string, error) {
+	// Create PostgreSQL container using the new API
+	pgContainer, err := postgres.RunContainer(ctx,
+		testcontainers.WithImage("postgres:14-alpine"),
+		postgres.WithDatabase("testdb"),
+		postgres.WithUsername("testuser"),
+		postgres.WithPassword("testpassword"),
+		testcontainers.WithWaitStrategy(
+			wait.ForLog("database system is ready to accept connections").
+				WithOccurrence(2).
+				WithStartupTimeout(30*time.Second)),
+	)
+	if err != nil {
+		return nil, "", fmt.Errorf("could not start postgres container: %w", err)
+	}
+
+	// Get the connection string
+	connString, err := pgContainer.ConnectionString(ctx, "sslmode=disable")
+	if err != nil {
+		pgContainer.Terminate(ctx)
+		return nil, "", fmt.Errorf("could not get connection string: %w", err)
+	}
+	return pgContainer, connString, nil
+}
+
+// truncateTables clears the accounts table, and every table referencing it, between tests to ensure isolation.
+func truncateTables(t testing.TB, ctx context.Context) {
 	t.Helper()
-	_, err := testStore.db.Exec(ctx, "TRUNCATE TABLE accounts RESTART IDENTITY")
+	_, err := testStore.db.Exec(ctx, "TRUNCATE TABLE accounts, account_shards, journal_entries, reconciliation_reports, audit_log, audit_pending, transfer_jobs, transfer_approvals, tier_limits, account_limits, transfer_rules, tier_fees, account_fees, interest_rates, interest_accruals, rate_limit_buckets RESTART IDENTITY CASCADE; UPDATE audit_head SET seq = 0, hash = repeat('0', 64)")
 	require.NoError(t, err, "failed to truncate tables")
 }
 
@@ -221,7 +236,7 @@ func TestExecuteTransfer_Success(t *testing.T) {
 	}
 
 	// Act
-	err := testStore.ExecuteTransfer(ctx, req)
+	err := executeTransfer(ctx, req)
 	require.NoError(t, err)
 
 	// Assert
@@ -251,7 +266,7 @@ func TestExecuteTransfer_FailureCases(t *testing.T) {
 		req := model.TransactionRequest{
 			SourceAccountID: 30, DestinationAccountID: 40, Amount: decimal.NewFromInt(100),
 		}
-		err := testStore.ExecuteTransfer(ctx, req)
+		err := executeTransfer(ctx, req)
 		assert.ErrorIs(t, err, ErrInsufficientFunds)
 	})
 
@@ -259,7 +274,7 @@ func TestExecuteTransfer_FailureCases(t *testing.T) {
 		req := model.TransactionRequest{
 			SourceAccountID: 999, DestinationAccountID: 40, Amount: decimal.NewFromInt(10),
 		}
-		err := testStore.ExecuteTransfer(ctx, req)
+		err := executeTransfer(ctx, req)
 		assert.ErrorIs(t, err, ErrNotFound)
 	})
 
@@ -267,15 +282,19 @@ func TestExecuteTransfer_FailureCases(t *testing.T) {
 		req := model.TransactionRequest{
 			SourceAccountID: 30, DestinationAccountID: 999, Amount: decimal.NewFromInt(10),
 		}
-		err := testStore.ExecuteTransfer(ctx, req)
+		err := executeTransfer(ctx, req)
 		assert.ErrorIs(t, err, ErrNotFound)
+
+		var accErr *AccountError
+		require.ErrorAs(t, err, &accErr)
+		assert.Equal(t, int64(999), accErr.AccountID)
 	})
 
 	t.Run("both accounts not found", func(t *testing.T) {
 		req := model.TransactionRequest{
 			SourceAccountID: 888, DestinationAccountID: 999, Amount: decimal.NewFromInt(10),
 		}
-		err := testStore.ExecuteTransfer(ctx, req)
+		err := executeTransfer(ctx, req)
 		assert.ErrorIs(t, err, ErrNotFound)
 	})
 
@@ -283,7 +302,7 @@ func TestExecuteTransfer_FailureCases(t *testing.T) {
 		req := model.TransactionRequest{
 			SourceAccountID: 30, DestinationAccountID: 40, Amount: decimal.Zero,
 		}
-		err := testStore.ExecuteTransfer(ctx, req)
+		err := executeTransfer(ctx, req)
 		require.NoError(t, err) // Zero transfers should be allowed
 
 		// Verify balances remain unchanged
@@ -297,7 +316,7 @@ func TestExecuteTransfer_FailureCases(t *testing.T) {
 		req := model.TransactionRequest{
 			SourceAccountID: 30, DestinationAccountID: 40, Amount: decimal.NewFromInt(-10),
 		}
-		err := testStore.ExecuteTransfer(ctx, req)
+		err := executeTransfer(ctx, req)
 		// This should either fail or be handled as a reverse transfer
 		// depending on business logic - currently it will succeed as a reverse transfer
 		require.NoError(t, err)
@@ -312,7 +331,7 @@ func TestExecuteTransfer_FailureCases(t *testing.T) {
 		req := model.TransactionRequest{
 			SourceAccountID: 30, DestinationAccountID: 30, Amount: decimal.NewFromInt(10),
 		}
-		err = testStore.ExecuteTransfer(ctx, req)
+		err = executeTransfer(ctx, req)
 		require.NoError(t, err) // Self transfers should work
 
 		// Balance should remain unchanged for self transfers
@@ -330,7 +349,7 @@ func TestExecuteTransfer_FailureCases(t *testing.T) {
 		req := model.TransactionRequest{
 			SourceAccountID: 50, DestinationAccountID: 40, Amount: decimal.NewFromInt(25),
 		}
-		err := testStore.ExecuteTransfer(ctx, req)
+		err := executeTransfer(ctx, req)
 		require.NoError(t, err)
 
 		// Source should have zero balance
@@ -362,14 +381,14 @@ func TestExecuteTransfer_ConcurrentTransfers(t *testing.T) {
 		go func() { // Acc 100 -> Acc 200
 			defer wg.Done()
 			req := model.TransactionRequest{SourceAccountID: 100, DestinationAccountID: 200, Amount: transferAmount}
-			if err := testStore.ExecuteTransfer(context.Background(), req); err != nil {
+			if err := executeTransfer(context.Background(), req); err != nil {
 				errs <- err
 			}
 		}()
 		go func() { // Acc 200 -> Acc 100
 			defer wg.Done()
 			req := model.TransactionRequest{SourceAccountID: 200, DestinationAccountID: 100, Amount: transferAmount}
-			if err := testStore.ExecuteTransfer(context.Background(), req); err != nil {
+			if err := executeTransfer(context.Background(), req); err != nil {
 				errs <- err
 			}
 		}()
@@ -421,28 +440,28 @@ func TestExecuteTransfer_DeadlockPrevention(t *testing.T) {
 		go func() {
 			defer wg.Done()
 			req := model.TransactionRequest{SourceAccountID: 1001, DestinationAccountID: 1002, Amount: transferAmount}
-			if err := testStore.ExecuteTransfer(context.Background(), req); err != nil {
+			if err := executeTransfer(context.Background(), req); err != nil {
 				errs <- err
 			}
 		}()
 		go func() {
 			defer wg.Done()
 			req := model.TransactionRequest{SourceAccountID: 1002, DestinationAccountID: 1003, Amount: transferAmount}
-			if err := testStore.ExecuteTransfer(context.Background(), req); err != nil {
+			if err := executeTransfer(context.Background(), req); err != nil {
 				errs <- err
 			}
 		}()
 		go func() {
 			defer wg.Done()
 			req := model.TransactionRequest{SourceAccountID: 1003, DestinationAccountID: 1004, Amount: transferAmount}
-			if err := testStore.ExecuteTransfer(context.Background(), req); err != nil {
+			if err := executeTransfer(context.Background(), req); err != nil {
 				errs <- err
 			}
 		}()
 		go func() {
 			defer wg.Done()
 			req := model.TransactionRequest{SourceAccountID: 1004, DestinationAccountID: 1001, Amount: transferAmount}
-			if err := testStore.ExecuteTransfer(context.Background(), req); err != nil {
+			if err := executeTransfer(context.Background(), req); err != nil {
 				errs <- err
 			}
 		}()
@@ -479,7 +498,7 @@ func TestExecuteTransfer_LargeAmounts(t *testing.T) {
 	}
 
 	// Act
-	err := testStore.ExecuteTransfer(ctx, req)
+	err := executeTransfer(ctx, req)
 	require.NoError(t, err)
 
 	// Assert
@@ -514,9 +533,158 @@ func TestExecuteTransfer_ContextCancellation(t *testing.T) {
 	}
 
 	// Act
-	err := testStore.ExecuteTransfer(cancelCtx, req)
+	err := executeTransfer(cancelCtx, req)
 
 	// Assert - should fail due to context cancellation
 	require.Error(t, err)
 	assert.Contains(t, err.Error(), "context canceled")
 }
+
+func TestListAccounts(t *testing.T) {
+	ctx := context.Background()
+	truncateTables(t, ctx)
+
+	// Arrange
+	for id := int64(1); id <= 5; id++ {
+		require.NoError(t, testStore.CreateAccount(ctx, model.Account{AccountID: id, Balance: decimal.NewFromInt(id)}))
+	}
+
+	// Act: page through two at a time
+	var ids []int64
+	filter := model.AccountFilter{Limit: 2}
+	for {
+		page, err := testStore.ListAccounts(ctx, filter)
+		require.NoError(t, err)
+		for _, acc := range page.Accounts {
+			ids = append(ids, acc.AccountID)
+			assert.Equal(t, model.AccountStatusActive, acc.Status)
+			assert.False(t, acc.CreatedAt.IsZero())
+		}
+		if page.Next == nil {
+			break
+		}
+		filter.After = page.Next
+	}
+
+	// Assert
+	assert.Equal(t, []int64{1, 2, 3, 4, 5}, ids)
+}
+
+func TestListAccountsFilterAndSort(t *testing.T) {
+	ctx := context.Background()
+	truncateTables(t, ctx)
+
+	balances := map[int64]int64{1: 50, 2: 10, 3: 30, 4: 10, 5: 70}
+	for id := int64(1); id <= 5; id++ {
+		require.NoError(t, testStore.CreateAccount(ctx, model.Account{AccountID: id, Balance: decimal.NewFromInt(balances[id])}))
+	}
+	require.NoError(t, testStore.CreateAccount(ctx, model.Account{AccountID: 6, Balance: decimal.NewFromInt(20), Currency: "EUR"}))
+	require.NoError(t, testStore.SetAccountStatus(ctx, 3, model.AccountStatusFrozen))
+
+	ids := func(page *model.AccountPage) []int64 {
+		var out []int64
+		for _, acc := range page.Accounts {
+			out = append(out, acc.AccountID)
+		}
+		return out
+	}
+
+	t.Run("filters combine", func(t *testing.T) {
+		low, high := decimal.NewFromInt(10), decimal.NewFromInt(50)
+		page, err := testStore.ListAccounts(ctx, model.AccountFilter{
+			Status: model.AccountStatusActive, Currency: model.DefaultCurrency, MinBalance: &low, MaxBalance: &high,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []int64{1, 2, 4}, ids(page))
+		assert.Nil(t, page.Next)
+		assert.Nil(t, page.Prev)
+	})
+
+	t.Run("created_at range", func(t *testing.T) {
+		acc, err := testStore.GetAccount(ctx, 6)
+		require.NoError(t, err)
+		page, err := testStore.ListAccounts(ctx, model.AccountFilter{CreatedFrom: acc.CreatedAt})
+		require.NoError(t, err)
+		assert.Equal(t, []int64{6}, ids(page))
+		page, err = testStore.ListAccounts(ctx, model.AccountFilter{CreatedBefore: acc.CreatedAt})
+		require.NoError(t, err)
+		assert.NotContains(t, ids(page), int64(6))
+	})
+
+	t.Run("pages forward and back by balance descending", func(t *testing.T) {
+		filter := model.AccountFilter{Currency: model.DefaultCurrency, Sort: model.SortBalance, Desc: true, Limit: 2}
+
+		first, err := testStore.ListAccounts(ctx, filter)
+		require.NoError(t, err)
+		assert.Equal(t, []int64{5, 1}, ids(first))
+		assert.Nil(t, first.Prev)
+		require.NotNil(t, first.Next)
+
+		filter.After = first.Next
+		second, err := testStore.ListAccounts(ctx, filter)
+		require.NoError(t, err)
+		assert.Equal(t, []int64{3, 4}, ids(second), "ties on balance are broken by account ID in sort direction")
+		require.NotNil(t, second.Next)
+		require.NotNil(t, second.Prev)
+
+		filter.After = second.Next
+		third, err := testStore.ListAccounts(ctx, filter)
+		require.NoError(t, err)
+		assert.Equal(t, []int64{2}, ids(third))
+		assert.Nil(t, third.Next)
+
+		filter.After, filter.Before = nil, second.Prev
+		back, err := testStore.ListAccounts(ctx, filter)
+		require.NoError(t, err)
+		assert.Equal(t, []int64{5, 1}, ids(back))
+		assert.Nil(t, back.Prev)
+		assert.NotNil(t, back.Next)
+	})
+}
+
+func TestSetAccountStatus(t *testing.T) {
+	ctx := context.Background()
+	truncateTables(t, ctx)
+
+	require.NoError(t, testStore.CreateAccount(ctx, model.Account{AccountID: 1, Balance: decimal.NewFromInt(100)}))
+	require.NoError(t, testStore.CreateAccount(ctx, model.Account{AccountID: 2, Balance: decimal.NewFromInt(100)}))
+
+	t.Run("frozen account cannot receive", func(t *testing.T) {
+		require.NoError(t, testStore.SetAccountStatus(ctx, 2, model.AccountStatusFrozen))
+
+		err := executeTransfer(ctx, model.TransactionRequest{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromInt(1)})
+
+		assert.ErrorIs(t, err, ErrAccountFrozen)
+		var accErr *AccountError
+		require.ErrorAs(t, err, &accErr)
+		assert.Equal(t, int64(2), accErr.AccountID)
+	})
+
+	t.Run("unfrozen account can receive again", func(t *testing.T) {
+		require.NoError(t, testStore.SetAccountStatus(ctx, 2, model.AccountStatusActive))
+
+		err := executeTransfer(ctx, model.TransactionRequest{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromInt(1)})
+		require.NoError(t, err)
+	})
+
+	t.Run("unknown account", func(t *testing.T) {
+		err := testStore.SetAccountStatus(ctx, 999, model.AccountStatusFrozen)
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+}
+
+func TestReconcile(t *testing.T) {
+	ctx := context.Background()
+	truncateTables(t, ctx)
+
+	require.NoError(t, testStore.CreateAccount(ctx, model.Account{AccountID: 1, Balance: decimal.NewFromInt(100)}))
+	require.NoError(t, testStore.CreateAccount(ctx, model.Account{AccountID: 2, Balance: decimal.NewFromInt(-5)}))
+
+	report, err := testStore.Reconcile(ctx, model.ReconcileOptions{})
+	require.NoError(t, err)
+
+	assert.Equal(t, int64(2), report.AccountsChecked)
+	assert.True(t, decimal.NewFromInt(95).Equal(report.TotalBalance))
+	require.Len(t, report.Mismatches, 1)
+	assert.Equal(t, int64(2), report.Mismatches[0].AccountID)
+}
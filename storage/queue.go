@@ -0,0 +1,276 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"go-api-example/audit"
+	"go-api-example/metrics"
+	"go-api-example/model"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/shopspring/decimal"
+)
+
+// Default settings of a TransferQueue.
+const (
+	defaultQueueBatchSize = 32
+	defaultQueueInbox     = 1024
+	actorIdleTimeout      = time.Minute
+)
+
+// Transfer queue metrics, scraped from GET /metrics.
+var (
+	queueBatches       = metrics.NewCounter("transfer_queue_batches_total", "Commits of queued transfers.")
+	queueTransfers     = metrics.NewCounter("transfer_queue_transfers_total", "Transfers executed through the transfer queue.")
+	queueActors        = metrics.NewGauge("transfer_queue_actors", "Source accounts with a running transfer queue.")
+	queueBatchFailures = metrics.NewCounter("transfer_queue_batch_failures_total", "Batches of queued transfers that failed as a whole and were executed one by one.")
+)
+
+// BatchedTransfer is a transfer of a batch, with the audit actor and request ID
+// of the request that submitted it.
+type BatchedTransfer struct {
+	Request   model.TransactionRequest
+	Actor     string
+	RequestID string
+}
+
+// BatchResult is the outcome of a transfer of a batch: the transfer, or the
+// error ExecuteTransfer would have returned for it.
+type BatchResult struct {
+	Transfer *model.Transfer
+	Err      error
+}
+
+// ExecuteTransferBatch executes transfers in order in a single transaction, each
+// as ExecuteTransfer would, so that they share one commit. A transfer that fails
+// is rolled back to a savepoint and reported in its result without affecting the
+// others. The error is for the batch as a whole: when it is not nil, none of the
+// transfers was executed.
+func (s *PostgresStore) ExecuteTransferBatch(ctx context.Context, transfers []BatchedTransfer) ([]BatchResult, error) {
+	var results []BatchResult
+	err := s.inTx(ctx, pgx.TxOptions{}, func(tx pgx.Tx) error {
+		results = make([]BatchResult, len(transfers))
+		for i, bt := range transfers {
+			ctx := audit.WithRequestID(audit.WithActor(ctx, bt.Actor), bt.RequestID)
+			sp, err := tx.Begin(ctx)
+			if err != nil {
+				return fmt.Errorf("could not create savepoint: %w", err)
+			}
+			t, err := applyTransfer(ctx, sp, bt.Request)
+			if err == nil {
+				err = sp.Commit(ctx)
+			}
+			if err = transferError(bt.Request, err); err != nil {
+				if isTransient(ctx, err) {
+					return err
+				}
+				if rbErr := sp.Rollback(ctx); rbErr != nil {
+					return rbErr
+				}
+				results[i].Err = err
+				continue
+			}
+			results[i].Transfer = t
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// QueueOptions configures a TransferQueue.
+type QueueOptions struct {
+	BatchSize      int             // transfers per commit (default 32)
+	BatchMaxAmount decimal.Decimal // transfers above this amount commit alone; 0 batches every transfer
+}
+
+// TransferQueue is a Store that executes transfers through in-memory queues,
+// one per source account, instead of letting them compete for the account's
+// row lock. Each queue is an actor: a goroutine that owns the account's
+// transfers and executes them in arrival order, committing the small ones
+// that have queued up in the meantime together with ExecuteTransferBatch.
+// An actor stops when its account has been idle for a while.
+//
+// The queues only order the transfers submitted to this process. Row locks
+// still guard every account, so transfers from other replicas, transfer jobs,
+// approvals and the CLI stay correct; when several replicas run, routing each
+// transfer to the replica that owns its source account keeps them from
+// contending. Every other method goes straight to the underlying Store.
+type TransferQueue struct {
+	Store
+	batchSize      int
+	batchMaxAmount decimal.Decimal
+
+	mu     sync.Mutex
+	actors map[int64]*accountActor
+}
+
+// accountActor is the queue of a source account. pending counts the transfers
+// sent to it and not answered yet, under TransferQueue.mu.
+type accountActor struct {
+	inbox   chan *queuedTransfer
+	pending int
+}
+
+type queuedTransfer struct {
+	ctx    context.Context
+	req    model.TransactionRequest
+	result chan BatchResult
+}
+
+// NewTransferQueue returns a TransferQueue executing transfers on store.
+func NewTransferQueue(store Store, opts QueueOptions) *TransferQueue {
+	q := &TransferQueue{
+		Store:          store,
+		batchSize:      opts.BatchSize,
+		batchMaxAmount: opts.BatchMaxAmount,
+		actors:         map[int64]*accountActor{},
+	}
+	if q.batchSize <= 0 {
+		q.batchSize = defaultQueueBatchSize
+	}
+	return q
+}
+
+// ExecuteTransfer queues the transfer behind the other transfers from its
+// source account and waits for its outcome, which is the same as that of
+// Store.ExecuteTransfer. If ctx is cancelled before the transfer is picked up,
+// it is dropped and ctx's error returned; once picked up, it runs to the end.
+func (q *TransferQueue) ExecuteTransfer(ctx context.Context, req model.TransactionRequest) (*model.Transfer, error) {
+	qt := &queuedTransfer{ctx: ctx, req: req, result: make(chan BatchResult, 1)}
+
+	q.mu.Lock()
+	a, ok := q.actors[req.SourceAccountID]
+	if !ok {
+		a = &accountActor{inbox: make(chan *queuedTransfer, defaultQueueInbox)}
+		q.actors[req.SourceAccountID] = a
+		queueActors.Set(float64(len(q.actors)))
+		go q.run(req.SourceAccountID, a)
+	}
+	a.pending++
+	q.mu.Unlock()
+
+	select {
+	case a.inbox <- qt:
+	case <-ctx.Done():
+		q.answered(a, 1)
+		return nil, ctx.Err()
+	}
+	res := <-qt.result
+	return res.Transfer, res.Err
+}
+
+// run is the actor of account id. It takes the transfers that have queued up,
+// executes them and answers them, until it has been idle for actorIdleTimeout
+// with nothing pending.
+func (q *TransferQueue) run(id int64, a *accountActor) {
+	idle := time.NewTimer(actorIdleTimeout)
+	defer idle.Stop()
+	for {
+		var first *queuedTransfer
+		select {
+		case first = <-a.inbox:
+		case <-idle.C:
+			q.mu.Lock()
+			if a.pending == 0 {
+				delete(q.actors, id)
+				queueActors.Set(float64(len(q.actors)))
+				q.mu.Unlock()
+				return
+			}
+			q.mu.Unlock()
+			idle.Reset(actorIdleTimeout)
+			continue
+		}
+
+		batch := []*queuedTransfer{first}
+	drain:
+		for len(batch) < q.batchSize {
+			select {
+			case qt := <-a.inbox:
+				batch = append(batch, qt)
+			default:
+				break drain
+			}
+		}
+		q.execute(batch)
+		q.answered(a, len(batch))
+		idle.Reset(actorIdleTimeout)
+	}
+}
+
+// answered records that n transfers sent to a have been answered.
+func (q *TransferQueue) answered(a *accountActor, n int) {
+	q.mu.Lock()
+	a.pending -= n
+	q.mu.Unlock()
+}
+
+// execute runs the transfers taken from a queue, in order: runs of small
+// transfers share a commit, and a large transfer commits alone. Transfers
+// whose caller gave up are answered with the context's error.
+func (q *TransferQueue) execute(batch []*queuedTransfer) {
+	var run []*queuedTransfer
+	for _, qt := range batch {
+		if err := qt.ctx.Err(); err != nil {
+			qt.result <- BatchResult{Err: err}
+			continue
+		}
+		if q.batchMaxAmount.IsPositive() && qt.req.Amount.GreaterThan(q.batchMaxAmount) {
+			q.commit(run)
+			run = nil
+			q.commit([]*queuedTransfer{qt})
+			continue
+		}
+		run = append(run, qt)
+	}
+	q.commit(run)
+}
+
+// commit executes transfers in one transaction and answers them. A single
+// transfer goes through Store.ExecuteTransfer. If the batch fails as a whole,
+// for example by running out of retries or into statement_timeout, each
+// transfer is executed again on its own, so that it gets the outcome it would
+// have had without the queue. Transfers run without the callers'
+// cancellation: they are waiting for the outcome.
+func (q *TransferQueue) commit(transfers []*queuedTransfer) {
+	if len(transfers) == 0 {
+		return
+	}
+	queueBatches.Inc()
+	queueTransfers.Add(uint64(len(transfers)))
+	if len(transfers) == 1 {
+		q.executeAlone(transfers[0])
+		return
+	}
+
+	batch := make([]BatchedTransfer, len(transfers))
+	for i, qt := range transfers {
+		batch[i] = BatchedTransfer{Request: qt.req, Actor: audit.ActorFrom(qt.ctx), RequestID: audit.RequestIDFrom(qt.ctx)}
+	}
+	results, err := q.Store.ExecuteTransferBatch(context.Background(), batch)
+	if err != nil {
+		queueBatchFailures.Inc()
+		log.Printf("A batch of %d queued transfers failed, executing them one by one: %v", len(transfers), err)
+		for _, qt := range transfers {
+			q.executeAlone(qt)
+		}
+		return
+	}
+	for i, qt := range transfers {
+		qt.result <- results[i]
+	}
+}
+
+// executeAlone executes a queued transfer through Store.ExecuteTransfer and
+// answers it.
+func (q *TransferQueue) executeAlone(qt *queuedTransfer) {
+	t, err := q.Store.ExecuteTransfer(context.WithoutCancel(qt.ctx), qt.req)
+	qt.result <- BatchResult{Transfer: t, Err: err}
+}
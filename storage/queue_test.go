@@ -0,0 +1,309 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go-api-example/audit"
+	"go-api-example/model"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExecuteTransferBatch(t *testing.T) {
+	ctx := context.Background()
+	truncateTables(t, ctx)
+	require.NoError(t, testStore.CreateAccount(ctx, model.Account{AccountID: 1, Balance: decimal.NewFromInt(100)}))
+	require.NoError(t, testStore.CreateAccount(ctx, model.Account{AccountID: 2, Balance: decimal.NewFromInt(0)}))
+	transfer := func(amount int64, requestID string) BatchedTransfer {
+		return BatchedTransfer{
+			Request: model.TransactionRequest{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromInt(amount)},
+			Actor:   "api", RequestID: requestID,
+		}
+	}
+
+	// Act: the second transfer fails, the others commit together.
+	results, err := testStore.ExecuteTransferBatch(ctx, []BatchedTransfer{
+		transfer(60, "req-1"), transfer(60, "req-2"), transfer(40, "req-3"),
+		{Request: model.TransactionRequest{SourceAccountID: 1, DestinationAccountID: 9, Amount: decimal.NewFromInt(1)}},
+	})
+
+	// Assert
+	require.NoError(t, err)
+	require.Len(t, results, 4)
+	require.NoError(t, results[0].Err)
+	assert.True(t, decimal.NewFromInt(60).Equal(results[0].Transfer.Amount))
+	assert.ErrorIs(t, results[1].Err, ErrInsufficientFunds)
+	assert.Nil(t, results[1].Transfer)
+	require.NoError(t, results[2].Err)
+	assert.ErrorIs(t, results[3].Err, ErrNotFound)
+
+	acc1, err := testStore.GetAccount(ctx, 1)
+	require.NoError(t, err)
+	assert.True(t, acc1.Balance.IsZero(), "account 1 balance is %s", acc1.Balance)
+	acc2, err := testStore.GetAccount(ctx, 2)
+	require.NoError(t, err)
+	assert.True(t, decimal.NewFromInt(100).Equal(acc2.Balance))
+
+	records, err := testStore.AuditLog(ctx, model.AuditFilter{})
+	require.NoError(t, err)
+	var requestIDs []string
+	for _, rec := range records {
+		if rec.Action == audit.ActionEntryPost {
+			requestIDs = append(requestIDs, rec.RequestID)
+		}
+	}
+	assert.Equal(t, []string{"req-1", "req-3"}, requestIDs, "each transfer is audited under its own request")
+	report, err := testStore.Reconcile(ctx, model.ReconcileOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, report.Mismatches)
+}
+
+func TestTransferQueue(t *testing.T) {
+	ctx := context.Background()
+	truncateTables(t, ctx)
+	require.NoError(t, testStore.CreateAccount(ctx, model.Account{AccountID: 1, Balance: decimal.NewFromInt(100)}))
+	for id := int64(2); id <= 6; id++ {
+		require.NoError(t, testStore.CreateAccount(ctx, model.Account{AccountID: id, Balance: decimal.Zero}))
+	}
+	queue := NewTransferQueue(testStore, QueueOptions{BatchSize: 16})
+	batches, transfers := queueBatches.Value(), queueTransfers.Value()
+
+	// Act: more transfers from account 1 than it can cover.
+	const n = 150
+	var wg sync.WaitGroup
+	var succeeded, insufficient atomic.Int64
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx := audit.WithRequestID(ctx, fmt.Sprintf("req-%d", i))
+			tr, err := queue.ExecuteTransfer(ctx, model.TransactionRequest{
+				SourceAccountID: 1, DestinationAccountID: int64(2 + i%5), Amount: decimal.NewFromInt(1),
+			})
+			switch {
+			case err == nil && tr.EntryID > 0:
+				succeeded.Add(1)
+			case err != nil && errors.Is(err, ErrInsufficientFunds):
+				insufficient.Add(1)
+			default:
+				errs <- err
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	// Assert
+	for err := range errs {
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, int64(100), succeeded.Load())
+	assert.Equal(t, int64(n-100), insufficient.Load())
+	assert.Equal(t, transfers+n, queueTransfers.Value())
+	assert.Less(t, queueBatches.Value()-batches, uint64(n), "some transfers should have shared a commit")
+
+	acc, err := testStore.GetAccount(ctx, 1)
+	require.NoError(t, err)
+	assert.True(t, acc.Balance.IsZero())
+	report, err := testStore.Reconcile(ctx, model.ReconcileOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, report.Mismatches)
+}
+
+// queueStore records how a TransferQueue executes transfers. ExecuteTransfer
+// signals started and waits for release when they are set, and
+// ExecuteTransferBatch fails with batchErr when it is set.
+type queueStore struct {
+	Store
+	started  chan struct{}
+	release  chan struct{}
+	batchErr error
+	mu       sync.Mutex
+	commits  [][]model.TransactionRequest
+}
+
+func (s *queueStore) ExecuteTransfer(ctx context.Context, req model.TransactionRequest) (*model.Transfer, error) {
+	if s.started != nil {
+		s.started <- struct{}{}
+		s.started = nil
+		<-s.release
+	}
+	s.record(req)
+	return &model.Transfer{TransactionRequest: req, Total: req.Amount}, nil
+}
+
+func (s *queueStore) ExecuteTransferBatch(ctx context.Context, transfers []BatchedTransfer) ([]BatchResult, error) {
+	if s.batchErr != nil {
+		return nil, s.batchErr
+	}
+	reqs := make([]model.TransactionRequest, len(transfers))
+	results := make([]BatchResult, len(transfers))
+	for i, bt := range transfers {
+		reqs[i] = bt.Request
+		results[i].Transfer = &model.Transfer{TransactionRequest: bt.Request, Total: bt.Request.Amount}
+	}
+	s.record(reqs...)
+	return results, nil
+}
+
+func (s *queueStore) record(reqs ...model.TransactionRequest) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.commits = append(s.commits, reqs)
+}
+
+func TestTransferQueue_Batching(t *testing.T) {
+	ctx := context.Background()
+	store := &queueStore{started: make(chan struct{}), release: make(chan struct{})}
+	queue := NewTransferQueue(store, QueueOptions{BatchSize: 3, BatchMaxAmount: decimal.NewFromInt(100)})
+	amounts := []int64{1, 2, 3, 4, 500, 6, 7}
+	queued := func() int {
+		queue.mu.Lock()
+		defer queue.mu.Unlock()
+		return len(queue.actors[1].inbox)
+	}
+
+	// The first transfer holds the queue while the others arrive in order.
+	var wg sync.WaitGroup
+	submit := func(amount int64) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := queue.ExecuteTransfer(ctx, model.TransactionRequest{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromInt(amount)})
+			assert.NoError(t, err)
+		}()
+	}
+	submit(amounts[0])
+	<-store.started
+	for i, amount := range amounts[1:] {
+		submit(amount)
+		require.Eventually(t, func() bool { return queued() == i+1 }, time.Second, time.Millisecond)
+	}
+	close(store.release)
+	wg.Wait()
+
+	var got [][]int64
+	for _, commit := range store.commits {
+		var c []int64
+		for _, req := range commit {
+			c = append(c, req.Amount.IntPart())
+		}
+		got = append(got, c)
+	}
+	assert.Equal(t, [][]int64{{1}, {2, 3, 4}, {500}, {6, 7}}, got,
+		"transfers commit in order, at most 3 at a time, and a large one alone")
+}
+
+func TestTransferQueue_BatchFailure(t *testing.T) {
+	ctx := context.Background()
+	store := &queueStore{started: make(chan struct{}), release: make(chan struct{}), batchErr: errors.New("canceling statement due to statement timeout")}
+	queue := NewTransferQueue(store, QueueOptions{})
+	failures := queueBatchFailures.Value()
+
+	var wg sync.WaitGroup
+	submit := func(amount int64) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			tr, err := queue.ExecuteTransfer(ctx, model.TransactionRequest{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromInt(amount)})
+			if assert.NoError(t, err, "a failed batch is not the transfer's outcome") {
+				assert.Equal(t, amount, tr.Amount.IntPart())
+			}
+		}()
+	}
+	submit(1)
+	<-store.started
+	for i, amount := range []int64{2, 3, 4} {
+		submit(amount)
+		require.Eventually(t, func() bool {
+			queue.mu.Lock()
+			defer queue.mu.Unlock()
+			return len(queue.actors[1].inbox) == i+1
+		}, time.Second, time.Millisecond)
+	}
+	close(store.release)
+	wg.Wait()
+
+	assert.Equal(t, [][]model.TransactionRequest{
+		{{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromInt(1)}},
+		{{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromInt(2)}},
+		{{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromInt(3)}},
+		{{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromInt(4)}},
+	}, store.commits, "the batch is executed again one transfer at a time, in order")
+	assert.Equal(t, failures+1, queueBatchFailures.Value())
+}
+
+func TestTransferQueue_CancelledBeforePickup(t *testing.T) {
+	store := &queueStore{started: make(chan struct{}), release: make(chan struct{})}
+	queue := NewTransferQueue(store, QueueOptions{})
+	req := model.TransactionRequest{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromInt(1)}
+
+	done := make(chan error)
+	go func() {
+		_, err := queue.ExecuteTransfer(context.Background(), req)
+		done <- err
+	}()
+	<-store.started
+
+	// A transfer queued behind the first gives up before it is picked up.
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		assert.Eventually(t, func() bool {
+			queue.mu.Lock()
+			defer queue.mu.Unlock()
+			return len(queue.actors[1].inbox) == 1
+		}, time.Second, time.Millisecond)
+		cancel()
+		close(store.release)
+	}()
+	_, err := queue.ExecuteTransfer(ctx, req)
+
+	assert.ErrorIs(t, err, context.Canceled)
+	require.NoError(t, <-done)
+	assert.Len(t, store.commits, 1, "the cancelled transfer was not executed")
+}
+
+// BenchmarkTransferQueue compares transfers executed directly, contending for
+// the row locks of their source accounts, with the same transfers executed
+// through a TransferQueue. Run it with
+// go test ./storage -run '^$' -bench TransferQueue.
+func BenchmarkTransferQueue(b *testing.B) {
+	ctx := context.Background()
+	const sources, destinations = 4, 64
+
+	for _, mode := range []string{"locking", "queue"} {
+		b.Run(mode, func(b *testing.B) {
+			truncateTables(b, ctx)
+			for id := int64(1); id <= sources+destinations; id++ {
+				require.NoError(b, testStore.CreateAccount(ctx, model.Account{AccountID: id, Balance: decimal.NewFromInt(1_000_000_000)}))
+			}
+			var store Store = testStore
+			if mode == "queue" {
+				store = NewTransferQueue(testStore, QueueOptions{})
+			}
+
+			var next atomic.Int64
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					i := next.Add(1)
+					if _, err := store.ExecuteTransfer(ctx, model.TransactionRequest{
+						SourceAccountID: 1 + i%sources, DestinationAccountID: sources + 1 + i%destinations, Amount: decimal.NewFromInt(1),
+					}); err != nil {
+						b.Error(err)
+						return
+					}
+				}
+			})
+		})
+	}
+}
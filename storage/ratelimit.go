@@ -0,0 +1,40 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+
+	"go-api-example/ratelimit"
+)
+
+// TakeRateLimitToken takes a token from the rate limit bucket named key, so that
+// every replica draws on the same buckets; it has the signature of a
+// ratelimit.BackendFunc. The bucket is refilled and taken from in one
+// statement, by the database clock, and the row lock orders concurrent takes.
+func (s *PostgresStore) TakeRateLimitToken(ctx context.Context, key string, limit ratelimit.Limit) (bool, float64, error) {
+	query := `
+		INSERT INTO rate_limit_buckets AS b (key, tokens, allowed, updated_at, full_at)
+		VALUES ($1, $3::FLOAT8 - 1, TRUE, statement_timestamp(), statement_timestamp() + make_interval(secs => 1 / $2::FLOAT8))
+		ON CONFLICT (key) DO UPDATE SET (tokens, allowed, updated_at, full_at) = (
+			SELECT t - taken, taken = 1, statement_timestamp(),
+			       statement_timestamp() + make_interval(secs => ($3 - (t - taken)) / $2)
+			FROM (SELECT t, CASE WHEN t >= 1 THEN 1 ELSE 0 END AS taken
+			      FROM (SELECT LEAST($3::FLOAT8, b.tokens + EXTRACT(EPOCH FROM statement_timestamp() - b.updated_at)::FLOAT8 * $2) AS t) r) x)
+		RETURNING allowed, tokens`
+	var ok bool
+	var tokens float64
+	if err := s.db.QueryRow(ctx, query, key, limit.Rate, limit.Burst).Scan(&ok, &tokens); err != nil {
+		return false, 0, fmt.Errorf("could not take rate limit token: %w", err)
+	}
+	return ok, tokens, nil
+}
+
+// PruneRateLimitBuckets deletes the rate limit buckets that have refilled. A
+// bucket that does not exist is created full, so this changes no limit.
+func (s *PostgresStore) PruneRateLimitBuckets(ctx context.Context) (int64, error) {
+	tag, err := s.db.Exec(ctx, "DELETE FROM rate_limit_buckets WHERE full_at <= NOW()")
+	if err != nil {
+		return 0, fmt.Errorf("could not prune rate limit buckets: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
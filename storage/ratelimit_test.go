@@ -0,0 +1,45 @@
+package storage
+
+import (
+	"context"
+	"testing"
+
+	"go-api-example/ratelimit"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimitBuckets(t *testing.T) {
+	ctx := context.Background()
+	truncateTables(t, ctx)
+	slow := ratelimit.Limit{Rate: 0.001, Burst: 2}
+
+	// A new bucket starts full, and a take leaves one token fewer
+	ok, tokens, err := testStore.TakeRateLimitToken(ctx, "read:client:alice", slow)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.InDelta(t, 1, tokens, 0.01)
+	ok, _, err = testStore.TakeRateLimitToken(ctx, "read:client:alice", slow)
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	// An empty bucket refuses until it has refilled a whole token
+	ok, tokens, err = testStore.TakeRateLimitToken(ctx, "read:client:alice", slow)
+	require.NoError(t, err)
+	assert.False(t, ok)
+	assert.Less(t, tokens, 1.0)
+
+	ok, _, err = testStore.TakeRateLimitToken(ctx, "read:client:bob", slow)
+	require.NoError(t, err)
+	assert.True(t, ok, "keys have buckets of their own")
+
+	// A fast bucket refills at once; only refilled buckets are pruned
+	_, _, err = testStore.TakeRateLimitToken(ctx, "read:client:carol", ratelimit.Limit{Rate: 1e6, Burst: 1})
+	require.NoError(t, err)
+	_, err = testStore.db.Exec(ctx, "SELECT pg_sleep(0.01)")
+	require.NoError(t, err)
+	pruned, err := testStore.PruneRateLimitBuckets(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), pruned)
+}
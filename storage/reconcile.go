@@ -0,0 +1,144 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"go-api-example/audit"
+	"go-api-example/model"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Reconcile recomputes every balance from its opening balance and journal postings
+// and compares it with the cached accounts.balance. It also checks that no balance
+// is negative and that, per currency, the total money held equals the total of the
+// opening balances, since transfers only move money between accounts.
+//
+// The checks run on one consistent snapshot. The report, including its mismatches,
+// is stored in reconciliation_reports. With opts.ReadOnly, active accounts with a
+// mismatch are switched to read-only, and audited, in the same transaction.
+func (s *PostgresStore) Reconcile(ctx context.Context, opts model.ReconcileOptions) (*model.ReconciliationReport, error) {
+	var report *model.ReconciliationReport
+	err := s.inTx(ctx, pgx.TxOptions{IsoLevel: pgx.RepeatableRead}, func(tx pgx.Tx) error {
+		report = &model.ReconciliationReport{Totals: []model.CurrencyTotal{}, Mismatches: []model.ReconciliationMismatch{}}
+		query := "SELECT COUNT(*), COALESCE(SUM(" + cachedBalance + "), 0) FROM accounts a"
+		if err := tx.QueryRow(ctx, query).Scan(&report.AccountsChecked, &report.TotalBalance); err != nil {
+			return fmt.Errorf("could not total balances: %w", err)
+		}
+
+		if err := reconcileAccounts(ctx, tx, report); err != nil {
+			return err
+		}
+		if err := reconcileTotals(ctx, tx, report); err != nil {
+			return err
+		}
+
+		if opts.ReadOnly {
+			var ids []int64
+			for _, m := range report.Mismatches {
+				if m.AccountID != 0 {
+					ids = append(ids, m.AccountID)
+				}
+			}
+			rows, err := tx.Query(ctx, `
+				UPDATE accounts SET status = $1
+				WHERE account_id = ANY($2) AND status = $3
+				RETURNING account_id`, model.AccountStatusReadOnly, ids, model.AccountStatusActive)
+			if err != nil {
+				return fmt.Errorf("could not mark accounts read-only: %w", err)
+			}
+			report.ReadOnlyAccounts, err = pgx.CollectRows(rows, pgx.RowTo[int64])
+			if err != nil {
+				return fmt.Errorf("could not mark accounts read-only: %w", err)
+			}
+			for _, id := range report.ReadOnlyAccounts {
+				before := statusChange{id, model.AccountStatusActive}
+				after := statusChange{id, model.AccountStatusReadOnly}
+				if err := appendAudit(ctx, tx, audit.ActionAccountStatus, before, after); err != nil {
+					return err
+				}
+			}
+		}
+
+		body, err := json.Marshal(report)
+		if err != nil {
+			return err
+		}
+		query = `
+			INSERT INTO reconciliation_reports (accounts_checked, mismatch_count, report)
+			VALUES ($1, $2, $3)
+			RETURNING report_id, created_at`
+		err = tx.QueryRow(ctx, query, report.AccountsChecked, len(report.Mismatches), body).Scan(&report.ReportID, &report.CreatedAt)
+		if err != nil {
+			return fmt.Errorf("could not store reconciliation report: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// reconcileAccounts reports accounts whose cached balance differs from the ledger
+// or is negative.
+func reconcileAccounts(ctx context.Context, tx pgx.Tx, report *model.ReconciliationReport) error {
+	query := `
+		SELECT account_id, currency, balance, expected FROM (
+			SELECT a.account_id, a.currency, ` + cachedBalance + ` AS balance,
+				a.opening_balance + COALESCE((SELECT SUM(p.amount) FROM postings p WHERE p.account_id = a.account_id), 0) AS expected
+			FROM accounts a
+		) b
+		WHERE balance <> expected OR balance < 0
+		ORDER BY account_id`
+	rows, err := tx.Query(ctx, query)
+	if err != nil {
+		return fmt.Errorf("could not recompute balances: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var m model.ReconciliationMismatch
+		if err := rows.Scan(&m.AccountID, &m.Currency, &m.StoredBalance, &m.ExpectedBalance); err != nil {
+			return fmt.Errorf("could not scan account row: %w", err)
+		}
+		m.Reason = model.ReasonLedgerDrift
+		if m.StoredBalance.Equal(m.ExpectedBalance) {
+			m.Reason = model.ReasonNegativeBalance
+		}
+		report.Mismatches = append(report.Mismatches, m)
+	}
+	return rows.Err()
+}
+
+// reconcileTotals reports currencies whose total balance differs from the total of
+// the opening balances, which only an unbalanced or edited journal can cause.
+func reconcileTotals(ctx context.Context, tx pgx.Tx, report *model.ReconciliationReport) error {
+	query := `
+		SELECT a.currency, SUM(a.opening_balance), SUM(` + cachedBalance + `)
+		FROM accounts a GROUP BY a.currency ORDER BY a.currency`
+	rows, err := tx.Query(ctx, query)
+	if err != nil {
+		return fmt.Errorf("could not total balances: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var t model.CurrencyTotal
+		if err := rows.Scan(&t.Currency, &t.OpeningBalance, &t.Balance); err != nil {
+			return fmt.Errorf("could not scan total row: %w", err)
+		}
+		report.Totals = append(report.Totals, t)
+		if !t.Balance.Equal(t.OpeningBalance) {
+			report.Mismatches = append(report.Mismatches, model.ReconciliationMismatch{
+				Currency:        t.Currency,
+				StoredBalance:   t.Balance,
+				ExpectedBalance: t.OpeningBalance,
+				Reason:          model.ReasonTotalChanged,
+			})
+		}
+	}
+	return rows.Err()
+}
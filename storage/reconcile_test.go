@@ -0,0 +1,50 @@
+package storage
+
+import (
+	"context"
+	"testing"
+
+	"go-api-example/model"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReconcile_LedgerDrift(t *testing.T) {
+	ctx := context.Background()
+	truncateTables(t, ctx)
+
+	// Arrange: a clean history, then a balance edited behind the ledger's back
+	require.NoError(t, testStore.CreateAccount(ctx, model.Account{AccountID: 1, Balance: decimal.NewFromInt(100)}))
+	require.NoError(t, testStore.CreateAccount(ctx, model.Account{AccountID: 2, Balance: decimal.NewFromInt(50)}))
+	require.NoError(t, executeTransfer(ctx, model.TransactionRequest{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromInt(30)}))
+
+	clean, err := testStore.Reconcile(ctx, model.ReconcileOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, clean.Mismatches)
+
+	_, err = testStore.db.Exec(ctx, "UPDATE accounts SET balance = balance + 7 WHERE account_id = 2")
+	require.NoError(t, err)
+
+	// Act
+	report, err := testStore.Reconcile(ctx, model.ReconcileOptions{ReadOnly: true})
+	require.NoError(t, err)
+
+	// Assert
+	require.Len(t, report.Mismatches, 2)
+	assert.Equal(t, int64(2), report.Mismatches[0].AccountID)
+	assert.Equal(t, model.ReasonLedgerDrift, report.Mismatches[0].Reason)
+	assert.True(t, decimal.NewFromInt(87).Equal(report.Mismatches[0].StoredBalance))
+	assert.True(t, decimal.NewFromInt(80).Equal(report.Mismatches[0].ExpectedBalance))
+	assert.Equal(t, model.ReasonTotalChanged, report.Mismatches[1].Reason)
+	assert.Equal(t, model.DefaultCurrency, report.Mismatches[1].Currency)
+
+	assert.Equal(t, []int64{2}, report.ReadOnlyAccounts)
+	err = executeTransfer(ctx, model.TransactionRequest{SourceAccountID: 2, DestinationAccountID: 1, Amount: decimal.NewFromInt(1)})
+	assert.ErrorIs(t, err, ErrAccountReadOnly)
+
+	var stored int
+	require.NoError(t, testStore.db.QueryRow(ctx, "SELECT mismatch_count FROM reconciliation_reports WHERE report_id = $1", report.ReportID).Scan(&stored))
+	assert.Equal(t, 2, stored)
+}
@@ -0,0 +1,238 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"go-api-example/metrics"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Read consistency levels a read can ask for with WithReadConsistency.
+const (
+	// ConsistencyStrong reads from the primary. It is the default.
+	ConsistencyStrong = "strong"
+	// ConsistencyEventual reads from the replica, which may lag behind.
+	ConsistencyEventual = "eventual"
+	// ConsistencyReadYourWrites reads from the replica once it has replayed
+	// the write whose token the read carries, and from the primary until then.
+	ConsistencyReadYourWrites = "read-your-writes"
+)
+
+// defaultReplicaCheckInterval is how often the replica's health is checked by default.
+const defaultReplicaCheckInterval = 5 * time.Second
+
+// Read replica metrics, scraped from GET /metrics.
+var (
+	replicaReads     = metrics.NewCounter("db_replica_reads_total", "Reads served by the read replica.")
+	replicaFallbacks = metrics.NewCounter("db_replica_fallbacks_total", "Reads that could use the read replica but were served by the primary.")
+	replicaHealthy   = metrics.NewGauge("db_replica_healthy", "1 if the read replica is healthy, 0 if not.")
+	replicaLag       = metrics.NewGauge("db_replica_lag_seconds", "How far the read replica lags behind the primary at the last check.")
+)
+
+// ReadConsistency is the consistency a read asks for. Token is the consistency
+// token of a write, from ConsistencyToken, that a read-your-writes read must see.
+type ReadConsistency struct {
+	Level string
+	Token string
+}
+
+type readConsistencyKey struct{}
+
+// WithReadConsistency returns a context whose reads of balances ask for c.
+// Reads without one go to the primary.
+func WithReadConsistency(ctx context.Context, c ReadConsistency) context.Context {
+	return context.WithValue(ctx, readConsistencyKey{}, c)
+}
+
+// ReadConsistencyFrom returns the consistency set by WithReadConsistency, if any.
+func ReadConsistencyFrom(ctx context.Context) (ReadConsistency, bool) {
+	c, ok := ctx.Value(readConsistencyKey{}).(ReadConsistency)
+	return c, ok
+}
+
+// ParseLSN parses a PostgreSQL WAL location such as "16/B374D848", the form of
+// a consistency token.
+func ParseLSN(s string) (uint64, error) {
+	hi, lo, ok := strings.Cut(s, "/")
+	if ok {
+		h, err := strconv.ParseUint(hi, 16, 32)
+		if err == nil {
+			var l uint64
+			if l, err = strconv.ParseUint(lo, 16, 32); err == nil {
+				return h<<32 | l, nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("invalid WAL location %q", s)
+}
+
+// replica is the read replica of a PostgresStore and what its last health
+// check found.
+type replica struct {
+	db       *pgxpool.Pool
+	maxLag   time.Duration
+	healthy  atomic.Bool
+	replayed atomic.Uint64 // WAL location the replica had replayed
+}
+
+// replicaLSN is the WAL location a server has replayed, as a replica, or
+// written, as a primary; a "replica" that is not in recovery, such as another
+// pool on the primary, is always up to date.
+const replicaLSN = "(CASE WHEN pg_is_in_recovery() THEN pg_last_wal_replay_lsn() ELSE pg_current_wal_lsn() END)::text"
+
+// openReplica connects the read replica at opts.ReadURL and checks its health
+// every opts.ReplicaCheckInterval until background is cancelled. A replica that
+// cannot be reached does not keep the store from opening: reads fall back to
+// the primary until it can.
+func (s *PostgresStore) openReplica(ctx, background context.Context, opts Options) error {
+	poolConfig, err := opts.poolConfig(opts.ReadURL)
+	if err != nil {
+		return fmt.Errorf("invalid read replica connection string: %w", err)
+	}
+	pool, err := pgxpool.NewWithConfig(ctx, poolConfig)
+	if err != nil {
+		return fmt.Errorf("could not create read replica connection pool: %w", err)
+	}
+	interval := opts.ReplicaCheckInterval
+	if interval <= 0 {
+		interval = defaultReplicaCheckInterval
+	}
+
+	s.replica = &replica{db: pool, maxLag: opts.ReplicaMaxLag}
+	s.updateReplicaHealth(ctx, true)
+	go s.monitorReplica(background, interval)
+	return nil
+}
+
+// reader returns the pool a read of balances with ctx goes to: the replica,
+// if the read's consistency allows it and the replica is healthy and, for
+// read-your-writes, has replayed the read's token; the primary otherwise.
+func (s *PostgresStore) reader(ctx context.Context) *pgxpool.Pool {
+	c, _ := ReadConsistencyFrom(ctx)
+	if s.replica == nil || (c.Level != ConsistencyEventual && c.Level != ConsistencyReadYourWrites) {
+		return s.db
+	}
+	if !s.replica.healthy.Load() || (c.Level == ConsistencyReadYourWrites && !s.replayed(ctx, c.Token)) {
+		replicaFallbacks.Inc()
+		return s.db
+	}
+	replicaReads.Inc()
+	return s.replica.db
+}
+
+// replayed reports whether the replica has replayed the write of token,
+// asking it again if it had not at the last check.
+func (s *PostgresStore) replayed(ctx context.Context, token string) bool {
+	want, err := ParseLSN(token)
+	if err != nil {
+		return false
+	}
+	if s.replica.replayed.Load() >= want {
+		return true
+	}
+	var lsn string
+	if err := s.replica.db.QueryRow(ctx, "SELECT "+replicaLSN).Scan(&lsn); err != nil {
+		return false
+	}
+	got, err := ParseLSN(lsn)
+	if err != nil {
+		return false
+	}
+	s.replica.replayed.Store(max(s.replica.replayed.Load(), got))
+	return got >= want
+}
+
+// ConsistencyToken returns a token for the writes committed so far, to be
+// read back with ConsistencyReadYourWrites: the primary's current WAL
+// location. It returns "" when there is no replica.
+func (s *PostgresStore) ConsistencyToken(ctx context.Context) (string, error) {
+	if s.replica == nil {
+		return "", nil
+	}
+	var lsn string
+	if err := s.db.QueryRow(ctx, "SELECT pg_current_wal_lsn()::text").Scan(&lsn); err != nil {
+		return "", fmt.Errorf("could not read WAL location: %w", err)
+	}
+	return lsn, nil
+}
+
+// checkReplica checks that the replica answers and, when a maximum lag is
+// set, that it lags behind the primary by no more: by nothing if it has
+// replayed everything the primary wrote, and otherwise by the age of the last
+// transaction it replayed.
+func (s *PostgresStore) checkReplica(ctx context.Context) error {
+	r := s.replica
+	var primary, replayed string
+	var age float64
+	if err := s.db.QueryRow(ctx, "SELECT pg_current_wal_lsn()::text").Scan(&primary); err != nil {
+		return fmt.Errorf("could not read primary WAL location: %w", err)
+	}
+	query := "SELECT " + replicaLSN + ", COALESCE(EXTRACT(EPOCH FROM NOW() - pg_last_xact_replay_timestamp()), 0)::float8"
+	if err := r.db.QueryRow(ctx, query).Scan(&replayed, &age); err != nil {
+		return fmt.Errorf("could not query replica: %w", err)
+	}
+	p, err := ParseLSN(primary)
+	if err != nil {
+		return err
+	}
+	got, err := ParseLSN(replayed)
+	if err != nil {
+		return err
+	}
+	r.replayed.Store(max(r.replayed.Load(), got))
+
+	lag := time.Duration(0)
+	if got < p {
+		lag = time.Duration(age * float64(time.Second))
+	}
+	replicaLag.Set(lag.Seconds())
+	if r.maxLag > 0 && lag > r.maxLag {
+		return fmt.Errorf("replica lags %s behind the primary, more than %s", lag.Round(time.Millisecond), r.maxLag)
+	}
+	return nil
+}
+
+// monitorReplica checks the replica every interval until ctx is cancelled,
+// marking it unhealthy, so that reads fall back to the primary, while a check
+// fails. Changes of health are logged.
+func (s *PostgresStore) monitorReplica(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		s.updateReplicaHealth(ctx, false)
+	}
+}
+
+// updateReplicaHealth runs one check of the replica and records its outcome,
+// logging it if it changed or this is the first check.
+func (s *PostgresStore) updateReplicaHealth(ctx context.Context, first bool) {
+	err := s.checkReplica(ctx)
+	if ctx.Err() != nil {
+		return
+	}
+	healthy := err == nil
+	if was := s.replica.healthy.Swap(healthy); first || was != healthy {
+		if healthy {
+			log.Println("Read replica is healthy; serving reads from it")
+		} else {
+			log.Printf("Read replica is unhealthy, serving reads from the primary: %v", err)
+		}
+	}
+	if healthy {
+		replicaHealthy.Set(1)
+	} else {
+		replicaHealthy.Set(0)
+	}
+}
@@ -0,0 +1,28 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+
+	"go-api-example/model"
+)
+
+// TransferRules returns the enabled transfer rules in evaluation order.
+func (s *PostgresStore) TransferRules(ctx context.Context) ([]model.Rule, error) {
+	query := "SELECT name, when_expr, action, code FROM transfer_rules WHERE enabled ORDER BY position"
+	rows, err := s.db.Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("could not query transfer rules: %w", err)
+	}
+	defer rows.Close()
+
+	rules := []model.Rule{}
+	for rows.Next() {
+		var r model.Rule
+		if err := rows.Scan(&r.Name, &r.When, &r.Action, &r.Code); err != nil {
+			return nil, fmt.Errorf("could not scan transfer rule: %w", err)
+		}
+		rules = append(rules, r)
+	}
+	return rules, rows.Err()
+}
@@ -0,0 +1,29 @@
+package storage
+
+import (
+	"context"
+	"testing"
+
+	"go-api-example/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransferRules(t *testing.T) {
+	ctx := context.Background()
+	truncateTables(t, ctx)
+	_, err := testStore.db.Exec(ctx, `
+		INSERT INTO transfer_rules (position, name, when_expr, action, code, enabled) VALUES
+			(20, 'review-large', 'amount > 1000', 'review', '', TRUE),
+			(10, 'deny-frozen', 'destination.status == "frozen"', 'deny', 'FROZEN', TRUE),
+			(30, 'disabled', 'true', 'deny', 'OFF', FALSE)`)
+	require.NoError(t, err)
+
+	rules, err := testStore.TransferRules(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []model.Rule{
+		{Name: "deny-frozen", When: `destination.status == "frozen"`, Action: model.RuleDeny, Code: "FROZEN"},
+		{Name: "review-large", When: "amount > 1000", Action: model.RuleReview},
+	}, rules)
+}
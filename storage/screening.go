@@ -0,0 +1,17 @@
+package storage
+
+import (
+	"context"
+
+	"go-api-example/audit"
+	"go-api-example/model"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// RecordScreeningHit appends a transfer stopped by screening to the audit log.
+func (s *PostgresStore) RecordScreeningHit(ctx context.Context, hit model.ScreeningHit) error {
+	return s.inTx(ctx, pgx.TxOptions{}, func(tx pgx.Tx) error {
+		return appendAudit(ctx, tx, audit.ActionScreeningHit, nil, hit)
+	})
+}
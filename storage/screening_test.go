@@ -0,0 +1,36 @@
+package storage
+
+import (
+	"context"
+	"testing"
+
+	"go-api-example/audit"
+	"go-api-example/model"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordScreeningHit(t *testing.T) {
+	truncateTables(t, context.Background())
+	ctx := audit.WithActor(context.Background(), "api:alice")
+	require.NoError(t, testStore.CreateAccount(ctx, model.Account{AccountID: 1, Balance: decimal.NewFromInt(100), OwnerName: "Ivan Petrov"}))
+	acc, err := testStore.GetAccount(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, "Ivan Petrov", acc.OwnerName)
+
+	hit := model.ScreeningHit{
+		TransactionRequest: model.TransactionRequest{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromInt(10)},
+		Action:             model.ScreeningBlock,
+		Matches:            []model.ScreeningMatch{{AccountID: 1, Entry: model.BlocklistEntry{Name: "Petrov, Ivan", List: "SDN"}, Score: 1}},
+	}
+	require.NoError(t, testStore.RecordScreeningHit(ctx, hit))
+
+	records, err := testStore.AuditLog(ctx, model.AuditFilter{})
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+	assert.Equal(t, audit.ActionScreeningHit, records[1].Action)
+	assert.Equal(t, "api:alice", records[1].Actor)
+	assert.Contains(t, string(records[1].After), `"list":"SDN"`)
+}
@@ -0,0 +1,211 @@
+package storage
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"slices"
+
+	"go-api-example/audit"
+	"go-api-example/model"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/shopspring/decimal"
+)
+
+// cachedBalance is the SQL expression for the cached balance of accounts row
+// "a": the balance in the row, or, for a hot account, the sum of its shards.
+const cachedBalance = `(CASE WHEN a.shards = 0 THEN a.balance
+	ELSE (SELECT COALESCE(SUM(s.balance), 0) FROM account_shards s WHERE s.account_id = a.account_id) END)`
+
+// lockedAccount is an account locked by lockAccountRows, with its number of
+// shards (0 unless it is hot).
+type lockedAccount struct {
+	model.Account
+	shards int
+}
+
+// lockAccountRows takes the row locks of the given accounts and returns them,
+// in ID order, with their cached balances. Accounts that do not exist are
+// skipped. Accounts that are not hot are locked FOR UPDATE, in ID order, so
+// that entries touching the same accounts queue up without deadlocking. Hot
+// accounts are then locked FOR SHARE: that keeps their status, currency and
+// shards from changing, while concurrent entries credit and debit their shard
+// rows. The balance of a hot account is read without locking its shards, so it
+// is only a snapshot.
+func lockAccountRows(ctx context.Context, tx pgx.Tx, ids []int64) ([]lockedAccount, error) {
+	lock := func(query string, args ...any) ([]lockedAccount, error) {
+		rows, err := tx.Query(ctx, query, args...)
+		if err != nil {
+			return nil, fmt.Errorf("could not query accounts for update: %w", err)
+		}
+		accounts, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (lockedAccount, error) {
+			var acc lockedAccount
+			err := row.Scan(&acc.AccountID, &acc.Balance, &acc.Currency, &acc.Status, &acc.shards)
+			return acc, err
+		})
+		if err != nil {
+			return nil, fmt.Errorf("could not scan account row: %w", err)
+		}
+		return accounts, nil
+	}
+
+	columns := "a.account_id, " + cachedBalance + ", a.currency, a.status, a.shards"
+	accounts, err := lock(`
+		SELECT `+columns+` FROM accounts a
+		WHERE a.account_id = ANY($1) AND a.shards = 0
+		ORDER BY a.account_id FOR UPDATE`, ids)
+	if err != nil {
+		return nil, err
+	}
+	locked := make([]int64, len(accounts))
+	for i, acc := range accounts {
+		locked[i] = acc.AccountID
+	}
+	// An account whose shards changed in between is locked here too; if it is
+	// no longer hot, updating its balance upgrades the lock, which at worst
+	// deadlocks and is retried.
+	hot, err := lock(`
+		SELECT `+columns+` FROM accounts a
+		WHERE a.account_id = ANY($1) AND a.account_id <> ALL($2)
+		ORDER BY a.account_id FOR SHARE`, ids, locked)
+	if err != nil {
+		return nil, err
+	}
+	accounts = append(accounts, hot...)
+	slices.SortFunc(accounts, func(a, b lockedAccount) int { return cmp.Compare(a.AccountID, b.AccountID) })
+	return accounts, nil
+}
+
+// lockShards locks the shards of hot account id in shard order until their
+// balances cover amount, and returns how much to take from each shard; ok is
+// false if all of them together do not cover it. Always locking from the first
+// shard keeps debits from deadlocking one another.
+func lockShards(ctx context.Context, tx pgx.Tx, id int64, shards int, amount decimal.Decimal) (take map[int]decimal.Decimal, ok bool, err error) {
+	take = map[int]decimal.Decimal{}
+	remaining := amount
+	for shard := 0; shard < shards && remaining.IsPositive(); shard++ {
+		var balance decimal.Decimal
+		query := "SELECT balance FROM account_shards WHERE account_id = $1 AND shard = $2 FOR UPDATE"
+		if err := tx.QueryRow(ctx, query, id, shard).Scan(&balance); err != nil {
+			return nil, false, fmt.Errorf("could not lock shard %d of account %d: %w", shard, id, err)
+		}
+		if t := decimal.Min(balance, remaining); t.IsPositive() {
+			take[shard] = t
+			remaining = remaining.Sub(t)
+		}
+	}
+	return take, !remaining.IsPositive(), nil
+}
+
+// debitShards takes from the shards of hot account id what lockShards returned.
+func debitShards(ctx context.Context, tx pgx.Tx, id int64, take map[int]decimal.Decimal) error {
+	for shard, amount := range take {
+		query := "UPDATE account_shards SET balance = balance - $3 WHERE account_id = $1 AND shard = $2"
+		if _, err := tx.Exec(ctx, query, id, shard, amount); err != nil {
+			return fmt.Errorf("could not update balance of account %d: %w", id, err)
+		}
+	}
+	return nil
+}
+
+// creditShard adds amount to a random shard of hot account id that no other
+// transaction holds, so that concurrent credits do not wait for each other. If
+// every shard is held it waits for a random one.
+func creditShard(ctx context.Context, tx pgx.Tx, id int64, shards int, amount decimal.Decimal) error {
+	query := `
+		UPDATE account_shards SET balance = balance + $2
+		WHERE (account_id, shard) = (
+			SELECT account_id, shard FROM account_shards WHERE account_id = $1
+			ORDER BY random() LIMIT 1 FOR UPDATE SKIP LOCKED)`
+	tag, err := tx.Exec(ctx, query, id, amount)
+	if err == nil && tag.RowsAffected() == 0 {
+		query = "UPDATE account_shards SET balance = balance + $3 WHERE account_id = $1 AND shard = $2"
+		_, err = tx.Exec(ctx, query, id, rand.IntN(shards), amount)
+	}
+	if err != nil {
+		return fmt.Errorf("could not update balance of account %d: %w", id, err)
+	}
+	return nil
+}
+
+// accountShards returns how the balance of an account is spread over its shards.
+func accountShards(ctx context.Context, tx pgx.Tx, id int64) (*model.AccountShards, error) {
+	sh := &model.AccountShards{AccountID: id, ShardBalances: []decimal.Decimal{}}
+	query := "SELECT a.shards, " + cachedBalance + " FROM accounts a WHERE a.account_id = $1"
+	if err := tx.QueryRow(ctx, query, id).Scan(&sh.Shards, &sh.Balance); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, &AccountError{AccountID: id, Err: ErrNotFound}
+		}
+		return nil, err
+	}
+	rows, err := tx.Query(ctx, "SELECT balance FROM account_shards WHERE account_id = $1 ORDER BY shard", id)
+	if err != nil {
+		return nil, fmt.Errorf("could not query account shards: %w", err)
+	}
+	balances, err := pgx.CollectRows(rows, pgx.RowTo[decimal.Decimal])
+	if err != nil {
+		return nil, fmt.Errorf("could not scan account shard: %w", err)
+	}
+	sh.ShardBalances = append(sh.ShardBalances, balances...)
+	return sh, nil
+}
+
+// GetAccountShards returns how the balance of an account is spread over shards.
+func (s *PostgresStore) GetAccountShards(ctx context.Context, id int64) (*model.AccountShards, error) {
+	tx, err := s.reader(ctx).BeginTx(ctx, pgx.TxOptions{AccessMode: pgx.ReadOnly, IsoLevel: pgx.RepeatableRead})
+	if err != nil {
+		return nil, fmt.Errorf("could not begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	return accountShards(ctx, tx, id)
+}
+
+// SetAccountShards spreads the balance of an account over shards rows, making
+// it hot, or, with zero shards, moves it back into the account row. The whole
+// balance starts in the first shard; credits then spread over all of them. The
+// account lock waits for the entries in flight on its shards.
+func (s *PostgresStore) SetAccountShards(ctx context.Context, id int64, shards int) (*model.AccountShards, error) {
+	var after *model.AccountShards
+	err := s.inTx(ctx, pgx.TxOptions{}, func(tx pgx.Tx) error {
+		if err := tx.QueryRow(ctx, "SELECT account_id FROM accounts WHERE account_id = $1 FOR UPDATE", id).Scan(&id); err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return &AccountError{AccountID: id, Err: ErrNotFound}
+			}
+			return fmt.Errorf("could not lock account: %w", err)
+		}
+		before, err := accountShards(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec(ctx, "DELETE FROM account_shards WHERE account_id = $1", id); err != nil {
+			return fmt.Errorf("could not remove account shards: %w", err)
+		}
+		if shards == 0 {
+			_, err = tx.Exec(ctx, "UPDATE accounts SET balance = $2, shards = 0 WHERE account_id = $1", id, before.Balance)
+		} else {
+			query := `
+				INSERT INTO account_shards (account_id, shard, balance)
+				SELECT $1, shard, CASE WHEN shard = 0 THEN $3 ELSE 0 END FROM generate_series(0, $2 - 1) AS shard`
+			if _, err = tx.Exec(ctx, query, id, shards, before.Balance); err == nil {
+				_, err = tx.Exec(ctx, "UPDATE accounts SET balance = 0, shards = $2 WHERE account_id = $1", id, shards)
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("could not set account shards: %w", err)
+		}
+		after, err = accountShards(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		return appendAudit(ctx, tx, audit.ActionAccountShards, before, after)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return after, nil
+}
@@ -0,0 +1,192 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"go-api-example/model"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAccountShards(t *testing.T) {
+	ctx := context.Background()
+	truncateTables(t, ctx)
+	require.NoError(t, testStore.CreateAccount(ctx, model.Account{AccountID: 1, Balance: decimal.NewFromInt(100)}))
+	require.NoError(t, testStore.CreateAccount(ctx, model.Account{AccountID: 2, Balance: decimal.NewFromInt(1000)}))
+	transfer := func(from, to int64, amount int64) error {
+		_, err := testStore.ExecuteTransfer(ctx, model.TransactionRequest{
+			SourceAccountID: from, DestinationAccountID: to, Amount: decimal.NewFromInt(amount),
+		})
+		return err
+	}
+	balance := func(id int64) decimal.Decimal {
+		acc, err := testStore.GetAccount(ctx, id)
+		require.NoError(t, err)
+		return acc.Balance
+	}
+
+	t.Run("make an account hot", func(t *testing.T) {
+		sh, err := testStore.SetAccountShards(ctx, 1, 4)
+		require.NoError(t, err)
+
+		assert.Equal(t, 4, sh.Shards)
+		assert.True(t, decimal.NewFromInt(100).Equal(sh.Balance))
+		require.Len(t, sh.ShardBalances, 4)
+		assert.True(t, decimal.NewFromInt(100).Equal(sh.ShardBalances[0]), "the balance starts in the first shard")
+		assert.True(t, decimal.NewFromInt(100).Equal(balance(1)))
+	})
+
+	t.Run("credits and debits", func(t *testing.T) {
+		for i := 0; i < 20; i++ {
+			require.NoError(t, transfer(2, 1, 10))
+		}
+		require.NoError(t, transfer(1, 2, 250))
+
+		assert.True(t, decimal.NewFromInt(50).Equal(balance(1)), "account 1 balance is %s", balance(1))
+		assert.True(t, decimal.NewFromInt(1050).Equal(balance(2)))
+		sh, err := testStore.GetAccountShards(ctx, 1)
+		require.NoError(t, err)
+		sum := decimal.Zero
+		for _, b := range sh.ShardBalances {
+			assert.False(t, b.IsNegative())
+			sum = sum.Add(b)
+		}
+		assert.True(t, decimal.NewFromInt(50).Equal(sum))
+	})
+
+	t.Run("insufficient funds across all shards", func(t *testing.T) {
+		err := transfer(1, 2, 51)
+		assert.ErrorIs(t, err, ErrInsufficientFunds)
+		require.NoError(t, transfer(1, 2, 50))
+		assert.True(t, balance(1).IsZero())
+	})
+
+	t.Run("listing, postings and reconciliation see the shards", func(t *testing.T) {
+		require.NoError(t, transfer(2, 1, 30))
+
+		thirty := decimal.NewFromInt(30)
+		page, err := testStore.ListAccounts(ctx, model.AccountFilter{MinBalance: &thirty, MaxBalance: &thirty})
+		require.NoError(t, err)
+		require.Len(t, page.Accounts, 1)
+		assert.Equal(t, int64(1), page.Accounts[0].AccountID)
+		ledger, err := testStore.GetAccountPostings(ctx, 1, model.PostingFilter{})
+		require.NoError(t, err)
+		assert.True(t, decimal.NewFromInt(30).Equal(ledger.Balance))
+		report, err := testStore.Reconcile(ctx, model.ReconcileOptions{})
+		require.NoError(t, err)
+		assert.Empty(t, report.Mismatches)
+	})
+
+	t.Run("move the balance back into the account", func(t *testing.T) {
+		sh, err := testStore.SetAccountShards(ctx, 1, 0)
+		require.NoError(t, err)
+
+		assert.Equal(t, 0, sh.Shards)
+		assert.Empty(t, sh.ShardBalances)
+		assert.True(t, decimal.NewFromInt(30).Equal(balance(1)))
+		require.NoError(t, transfer(1, 2, 30))
+		assert.True(t, balance(1).IsZero())
+	})
+
+	t.Run("unknown account", func(t *testing.T) {
+		_, err := testStore.SetAccountShards(ctx, 9, 4)
+		assert.ErrorIs(t, err, ErrNotFound)
+		_, err = testStore.GetAccountShards(ctx, 9)
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+}
+
+func TestAccountShards_Contention(t *testing.T) {
+	ctx := context.Background()
+	truncateTables(t, ctx)
+	require.NoError(t, testStore.CreateAccount(ctx, model.Account{AccountID: 1, Balance: decimal.NewFromInt(1000)}))
+	for id := int64(2); id <= 11; id++ {
+		require.NoError(t, testStore.CreateAccount(ctx, model.Account{AccountID: id, Balance: decimal.NewFromInt(1000)}))
+	}
+	_, err := testStore.SetAccountShards(ctx, 1, 8)
+	require.NoError(t, err)
+
+	// Act: every other account credits the hot account while it pays back
+	// into them.
+	var wg sync.WaitGroup
+	errs := make(chan error, 400)
+	for i := 0; i < 20; i++ {
+		for id := int64(2); id <= 11; id++ {
+			wg.Add(2)
+			go func() {
+				defer wg.Done()
+				if _, err := testStore.ExecuteTransfer(ctx, model.TransactionRequest{
+					SourceAccountID: id, DestinationAccountID: 1, Amount: decimal.NewFromInt(5),
+				}); err != nil {
+					errs <- err
+				}
+			}()
+			go func() {
+				defer wg.Done()
+				if _, err := testStore.ExecuteTransfer(ctx, model.TransactionRequest{
+					SourceAccountID: 1, DestinationAccountID: id, Amount: decimal.NewFromInt(3),
+				}); err != nil {
+					errs <- err
+				}
+			}()
+		}
+	}
+	wg.Wait()
+	close(errs)
+
+	// Assert
+	for err := range errs {
+		assert.NoError(t, err)
+	}
+	acc, err := testStore.GetAccount(ctx, 1)
+	require.NoError(t, err)
+	assert.True(t, decimal.NewFromInt(1400).Equal(acc.Balance), "hot account balance is %s", acc.Balance)
+	report, err := testStore.Reconcile(ctx, model.ReconcileOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, report.Mismatches)
+}
+
+// BenchmarkHotAccountCredits measures transfers from many accounts into one,
+// with the destination an ordinary account and a hot one. Run it with
+// go test ./storage -run '^$' -bench HotAccount.
+func BenchmarkHotAccountCredits(b *testing.B) {
+	ctx := context.Background()
+	const sources = 64
+
+	for _, shards := range []int{0, 8} {
+		b.Run(fmt.Sprintf("shards=%d", shards), func(b *testing.B) {
+			truncateTables(b, ctx)
+			accounts := []model.Account{{AccountID: 1, Balance: decimal.Zero}}
+			for id := int64(2); id < 2+sources; id++ {
+				accounts = append(accounts, model.Account{AccountID: id, Balance: decimal.NewFromInt(1_000_000_000)})
+			}
+			for _, acc := range accounts {
+				require.NoError(b, testStore.CreateAccount(ctx, acc))
+			}
+			if shards > 0 {
+				_, err := testStore.SetAccountShards(ctx, 1, shards)
+				require.NoError(b, err)
+			}
+
+			var next atomic.Int64
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					source := 2 + next.Add(1)%sources
+					if _, err := testStore.ExecuteTransfer(ctx, model.TransactionRequest{
+						SourceAccountID: source, DestinationAccountID: 1, Amount: decimal.NewFromInt(1),
+					}); err != nil {
+						b.Error(err)
+						return
+					}
+				}
+			})
+		})
+	}
+}
@@ -0,0 +1,129 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"go-api-example/model"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/shopspring/decimal"
+)
+
+// WriteStatement renders the statement of an account for a period through w.
+//
+// Everything is read in one repeatable-read transaction, so the balances and the
+// movements come from the same snapshot however long the output takes. Both
+// balances are known before the first movement is written, as CAMT.053 needs
+// them up front. Movements are fetched a page at a time rather than with one
+// long-running query, so a slow reader does not run into statement_timeout.
+func (s *PostgresStore) WriteStatement(ctx context.Context, id int64, period model.StatementPeriod, w model.StatementWriter) error {
+	tx, err := s.reader(ctx).BeginTx(ctx, pgx.TxOptions{AccessMode: pgx.ReadOnly, IsoLevel: pgx.RepeatableRead})
+	if err != nil {
+		return fmt.Errorf("could not begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	stmt := &model.Statement{AccountID: id, From: period.From, To: period.To}
+	var balance decimal.Decimal
+	var created time.Time
+	query := "SELECT a.opening_balance, " + cachedBalance + ", a.currency, a.created_at, NOW() FROM accounts a WHERE a.account_id = $1"
+	if err := tx.QueryRow(ctx, query, id).Scan(&stmt.OpeningBalance, &balance, &stmt.Currency, &created, &stmt.CreatedAt); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return &AccountError{AccountID: id, Err: ErrNotFound}
+		}
+		return err
+	}
+	if stmt.From.IsZero() {
+		stmt.From = created
+	}
+
+	// Postings in the period; every query below starts from these conditions.
+	args := []any{id, stmt.From}
+	inPeriod := "p.account_id = $1 AND p.created_at >= $2"
+	if !period.To.IsZero() {
+		args = append(args, period.To)
+		inPeriod += " AND p.created_at < $3"
+	}
+
+	// The opening balance is the initial balance plus every posting before the
+	// period. Without an end, the closing balance is the stored balance;
+	// otherwise it is the opening balance plus the postings in the period.
+	var before, during decimal.Decimal
+	query = "SELECT COALESCE(SUM(p.amount), 0) FROM postings p WHERE p.account_id = $1 AND p.created_at < $2"
+	if err := tx.QueryRow(ctx, query, id, stmt.From).Scan(&before); err != nil {
+		return fmt.Errorf("could not sum postings: %w", err)
+	}
+	query = "SELECT COALESCE(SUM(p.amount), 0) FROM postings p WHERE " + inPeriod
+	if err := tx.QueryRow(ctx, query, args...).Scan(&during); err != nil {
+		return fmt.Errorf("could not sum postings: %w", err)
+	}
+	stmt.OpeningBalance = stmt.OpeningBalance.Add(before)
+	stmt.ClosingBalance = stmt.OpeningBalance.Add(during)
+	if period.To.IsZero() {
+		if !stmt.ClosingBalance.Equal(balance) {
+			return fmt.Errorf("account %d: stored balance %s does not match its ledger balance %s; run reconcile",
+				id, balance, stmt.ClosingBalance)
+		}
+		stmt.To = stmt.CreatedAt
+	}
+
+	if err := w.Begin(stmt); err != nil {
+		return err
+	}
+	var last *model.Posting
+	for {
+		page, err := statementPage(ctx, tx, inPeriod, args, last)
+		if err != nil {
+			return err
+		}
+		for _, p := range page {
+			if err := w.Movement(p); err != nil {
+				return err
+			}
+		}
+		if len(page) < model.MaxPageSize {
+			break
+		}
+		last = &page[len(page)-1]
+	}
+	return w.End()
+}
+
+// statementPage returns the next page of the postings matching inPeriod, ordered
+// by creation time and ID, starting after last (or from the beginning when nil).
+func statementPage(ctx context.Context, tx pgx.Tx, inPeriod string, args []any, last *model.Posting) ([]model.Posting, error) {
+	arg := func(v any) string {
+		args = append(args, v)
+		return "$" + strconv.Itoa(len(args))
+	}
+	where := inPeriod
+	if last != nil {
+		where += fmt.Sprintf(" AND (p.created_at, p.posting_id) > (%s, %s)", arg(last.CreatedAt), arg(last.PostingID))
+	}
+	query := `
+		SELECT p.posting_id, p.entry_id, j.kind, p.account_id, p.amount, p.currency, p.created_at
+		FROM postings p JOIN journal_entries j ON j.entry_id = p.entry_id
+		WHERE ` + where + `
+		ORDER BY p.created_at, p.posting_id
+		LIMIT ` + arg(model.MaxPageSize)
+	rows, err := tx.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("could not query postings: %w", err)
+	}
+	defer rows.Close()
+
+	page := make([]model.Posting, 0, model.MaxPageSize)
+	for rows.Next() {
+		var p model.Posting
+		if err := rows.Scan(&p.PostingID, &p.EntryID, &p.Kind, &p.AccountID, &p.Amount, &p.Currency, &p.CreatedAt); err != nil {
+			return nil, fmt.Errorf("could not scan posting row: %w", err)
+		}
+		p.Direction = model.DirectionOf(p.Amount)
+		page = append(page, p)
+	}
+	return page, rows.Err()
+}
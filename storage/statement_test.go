@@ -0,0 +1,100 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go-api-example/model"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingWriter is a model.StatementWriter that keeps what it is given.
+type recordingWriter struct {
+	stmt      *model.Statement
+	movements []model.Posting
+	ended     bool
+}
+
+func (w *recordingWriter) Begin(s *model.Statement) error {
+	w.stmt = s
+	return nil
+}
+
+func (w *recordingWriter) Movement(p model.Posting) error {
+	w.movements = append(w.movements, p)
+	return nil
+}
+
+func (w *recordingWriter) End() error {
+	w.ended = true
+	return nil
+}
+
+func TestWriteStatement(t *testing.T) {
+	ctx := context.Background()
+	truncateTables(t, ctx)
+
+	// Arrange: two transfers out of account 1, with a gap between them
+	require.NoError(t, testStore.CreateAccount(ctx, model.Account{AccountID: 1, Balance: decimal.NewFromInt(100), Currency: "EUR"}))
+	require.NoError(t, testStore.CreateAccount(ctx, model.Account{AccountID: 2, Balance: decimal.Zero, Currency: "EUR"}))
+	require.NoError(t, executeTransfer(ctx, model.TransactionRequest{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromInt(30)}))
+	var firstPosted time.Time
+	require.NoError(t, testStore.db.QueryRow(ctx, "SELECT MAX(created_at) FROM postings").Scan(&firstPosted))
+	time.Sleep(10 * time.Millisecond)
+	require.NoError(t, executeTransfer(ctx, model.TransactionRequest{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.RequireFromString("5.00001")}))
+
+	t.Run("whole history closes at the stored balance", func(t *testing.T) {
+		var w recordingWriter
+		require.NoError(t, testStore.WriteStatement(ctx, 1, model.StatementPeriod{}, &w))
+
+		require.True(t, w.ended)
+		assert.Equal(t, "EUR", w.stmt.Currency)
+		assert.True(t, decimal.NewFromInt(100).Equal(w.stmt.OpeningBalance), "got %s", w.stmt.OpeningBalance)
+		assert.True(t, decimal.RequireFromString("64.99999").Equal(w.stmt.ClosingBalance), "got %s", w.stmt.ClosingBalance)
+		require.Len(t, w.movements, 2)
+		assert.True(t, decimal.NewFromInt(-30).Equal(w.movements[0].Amount))
+		assert.Equal(t, model.DirectionDebit, w.movements[1].Direction)
+	})
+
+	t.Run("period starting after the first transfer", func(t *testing.T) {
+		var w recordingWriter
+		period := model.StatementPeriod{From: firstPosted.Add(time.Microsecond)}
+		require.NoError(t, testStore.WriteStatement(ctx, 1, period, &w))
+
+		assert.True(t, decimal.NewFromInt(70).Equal(w.stmt.OpeningBalance), "got %s", w.stmt.OpeningBalance)
+		require.Len(t, w.movements, 1)
+		assert.True(t, decimal.RequireFromString("64.99999").Equal(w.stmt.ClosingBalance))
+	})
+
+	t.Run("period ending before the second transfer", func(t *testing.T) {
+		var w recordingWriter
+		period := model.StatementPeriod{To: firstPosted.Add(time.Microsecond)}
+		require.NoError(t, testStore.WriteStatement(ctx, 1, period, &w))
+
+		assert.Equal(t, period.To, w.stmt.To)
+		require.Len(t, w.movements, 1)
+		assert.True(t, decimal.NewFromInt(70).Equal(w.stmt.ClosingBalance), "got %s", w.stmt.ClosingBalance)
+	})
+
+	t.Run("hot account", func(t *testing.T) {
+		_, err := testStore.SetAccountShards(ctx, 2, 4)
+		require.NoError(t, err)
+		require.NoError(t, executeTransfer(ctx, model.TransactionRequest{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromInt(10)}))
+
+		var w recordingWriter
+		require.NoError(t, testStore.WriteStatement(ctx, 2, model.StatementPeriod{}, &w), "the closing balance sums the shards")
+		assert.True(t, decimal.RequireFromString("45.00001").Equal(w.stmt.ClosingBalance), "got %s", w.stmt.ClosingBalance)
+		assert.Len(t, w.movements, 3)
+	})
+
+	t.Run("account not found", func(t *testing.T) {
+		var w recordingWriter
+		err := testStore.WriteStatement(ctx, 99, model.StatementPeriod{}, &w)
+		assert.ErrorIs(t, err, ErrNotFound)
+		assert.Nil(t, w.stmt, "nothing is written for a missing account")
+	})
+}
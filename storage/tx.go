@@ -0,0 +1,111 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	"go-api-example/metrics"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Default retry budget of a write transaction.
+const (
+	defaultTxAttempts       = 4
+	defaultTxRetryBaseDelay = 10 * time.Millisecond
+	defaultTxRetryMaxDelay  = 500 * time.Millisecond
+)
+
+// Transaction retry metrics, scraped from GET /metrics.
+var (
+	txSerializationRetries = metrics.NewCounter("db_tx_serialization_retries_total", "Transactions retried after a serialization failure.")
+	txDeadlockRetries      = metrics.NewCounter("db_tx_deadlock_retries_total", "Transactions retried after a deadlock.")
+	txRetriesExhausted     = metrics.NewCounter("db_tx_retries_exhausted_total", "Transactions that still failed after the last attempt.")
+)
+
+// txRetry is the retry budget of write transactions.
+type txRetry struct {
+	attempts  int           // attempts per transaction, including the first
+	baseDelay time.Duration // backoff cap before the first retry
+	maxDelay  time.Duration // backoff cap for later retries
+}
+
+// backoff returns how long to wait before retry n, counting from 1: a random
+// duration up to baseDelay doubled n-1 times, capped at maxDelay ("full
+// jitter"), so that transactions aborted together do not collide again.
+func (r txRetry) backoff(n int) time.Duration {
+	ceiling := r.baseDelay
+	for i := 1; i < n && ceiling < r.maxDelay; i++ {
+		ceiling *= 2
+	}
+	ceiling = min(ceiling, r.maxDelay)
+	if ceiling <= 0 {
+		return 0
+	}
+	return rand.N(ceiling + 1)
+}
+
+// retryableCode returns the SQLSTATE of err if PostgreSQL aborted the
+// transaction because of contention, so that running it again can succeed: a
+// serialization failure (40001) or a deadlock (40P01).
+func retryableCode(err error) (string, bool) {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return "", false
+	}
+	switch pgErr.Code {
+	case "40001", "40P01":
+		return pgErr.Code, true
+	}
+	return "", false
+}
+
+// inTx runs fn in a transaction and commits it if fn returns nil. When
+// PostgreSQL aborts the transaction with a serialization failure or a deadlock,
+// in fn or at commit, the whole transaction is run again after a jittered
+// backoff, up to the store's retry budget. fn must therefore only have effects
+// through tx, and set its results afresh on every call. Waiting stops when ctx
+// is cancelled, and the last error is returned.
+func (s *PostgresStore) inTx(ctx context.Context, opts pgx.TxOptions, fn func(tx pgx.Tx) error) error {
+	for attempt := 1; ; attempt++ {
+		err := s.runTx(ctx, opts, fn)
+		code, ok := retryableCode(err)
+		if !ok {
+			return err
+		}
+		if attempt >= s.retry.attempts {
+			txRetriesExhausted.Inc()
+			return err
+		}
+		timer := time.NewTimer(s.retry.backoff(attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+		if code == "40P01" {
+			txDeadlockRetries.Inc()
+		} else {
+			txSerializationRetries.Inc()
+		}
+	}
+}
+
+// runTx runs fn in a single transaction.
+func (s *PostgresStore) runTx(ctx context.Context, opts pgx.TxOptions, fn func(tx pgx.Tx) error) error {
+	tx, err := s.db.BeginTx(ctx, opts)
+	if err != nil {
+		return fmt.Errorf("could not begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
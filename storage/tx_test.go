@@ -0,0 +1,221 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"go-api-example/model"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTxRetryBackoff(t *testing.T) {
+	r := txRetry{attempts: 10, baseDelay: 10 * time.Millisecond, maxDelay: 50 * time.Millisecond}
+	for i := 0; i < 100; i++ {
+		assert.LessOrEqual(t, r.backoff(1), 10*time.Millisecond)
+		assert.LessOrEqual(t, r.backoff(2), 20*time.Millisecond)
+		assert.LessOrEqual(t, r.backoff(4), 50*time.Millisecond)
+		assert.LessOrEqual(t, r.backoff(100), 50*time.Millisecond)
+		assert.GreaterOrEqual(t, r.backoff(100), time.Duration(0))
+	}
+}
+
+func TestInTx_RetriesSerializationFailure(t *testing.T) {
+	ctx := context.Background()
+	truncateTables(t, ctx)
+	require.NoError(t, testStore.CreateAccount(ctx, model.Account{AccountID: 1, Balance: decimal.NewFromInt(100)}))
+	retries := txSerializationRetries.Value()
+
+	attempts := 0
+	err := testStore.inTx(ctx, pgx.TxOptions{IsoLevel: pgx.RepeatableRead}, func(tx pgx.Tx) error {
+		attempts++
+		var name string
+		if err := tx.QueryRow(ctx, "SELECT owner_name FROM accounts WHERE account_id = 1").Scan(&name); err != nil {
+			return err
+		}
+		if attempts == 1 {
+			// Another transaction changes the row after this one took its snapshot.
+			if _, err := testStore.db.Exec(ctx, "UPDATE accounts SET owner_name = 'concurrent' WHERE account_id = 1"); err != nil {
+				return err
+			}
+		}
+		_, err := tx.Exec(ctx, "UPDATE accounts SET owner_name = 'retried' WHERE account_id = 1")
+		return err
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, 2, attempts)
+	assert.Equal(t, retries+1, txSerializationRetries.Value())
+	acc, err := testStore.GetAccount(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, "retried", acc.OwnerName)
+}
+
+func TestInTx_RetriesDeadlock(t *testing.T) {
+	ctx := context.Background()
+	truncateTables(t, ctx)
+	require.NoError(t, testStore.CreateAccount(ctx, model.Account{AccountID: 1, Balance: decimal.NewFromInt(100)}))
+	require.NoError(t, testStore.CreateAccount(ctx, model.Account{AccountID: 2, Balance: decimal.NewFromInt(100)}))
+	retries := txDeadlockRetries.Value()
+
+	// Each transaction locks one account, waits until the other has locked the
+	// other account, and then tries to lock it too.
+	var locked sync.WaitGroup
+	locked.Add(2)
+	lockBoth := func(first, second int64) error {
+		attempts := 0
+		return testStore.inTx(ctx, pgx.TxOptions{}, func(tx pgx.Tx) error {
+			attempts++
+			if _, err := tx.Exec(ctx, "SELECT 1 FROM accounts WHERE account_id = $1 FOR UPDATE", first); err != nil {
+				return err
+			}
+			if attempts == 1 {
+				locked.Done()
+				locked.Wait()
+			}
+			_, err := tx.Exec(ctx, "SELECT 1 FROM accounts WHERE account_id = $1 FOR UPDATE", second)
+			return err
+		})
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, 2)
+	wg.Add(2)
+	go func() { defer wg.Done(); errs[0] = lockBoth(1, 2) }()
+	go func() { defer wg.Done(); errs[1] = lockBoth(2, 1) }()
+	wg.Wait()
+
+	assert.NoError(t, errs[0])
+	assert.NoError(t, errs[1])
+	assert.Equal(t, retries+1, txDeadlockRetries.Value(), "the deadlock victim should have been retried once")
+}
+
+func TestInTx_GivesUpAfterBudget(t *testing.T) {
+	ctx := context.Background()
+	store := &PostgresStore{db: testStore.db, retry: txRetry{attempts: 3, baseDelay: time.Millisecond, maxDelay: time.Millisecond}}
+	exhausted := txRetriesExhausted.Value()
+
+	attempts := 0
+	err := store.inTx(ctx, pgx.TxOptions{}, func(tx pgx.Tx) error {
+		attempts++
+		return &pgconn.PgError{Code: "40001"}
+	})
+
+	var pgErr *pgconn.PgError
+	require.ErrorAs(t, err, &pgErr)
+	assert.Equal(t, "40001", pgErr.Code)
+	assert.Equal(t, 3, attempts)
+	assert.Equal(t, exhausted+1, txRetriesExhausted.Value())
+}
+
+func TestInTx_DoesNotRetryOtherErrors(t *testing.T) {
+	ctx := context.Background()
+
+	for _, want := range []error{ErrNotFound, &pgconn.PgError{Code: "23505"}, &pgconn.PgError{Code: "55P03"}} {
+		attempts := 0
+		err := testStore.inTx(ctx, pgx.TxOptions{}, func(tx pgx.Tx) error {
+			attempts++
+			return want
+		})
+		assert.ErrorIs(t, err, want)
+		assert.Equal(t, 1, attempts, "%v should not be retried", want)
+	}
+}
+
+func TestInTx_StopsWaitingWhenContextIsCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	store := &PostgresStore{db: testStore.db, retry: txRetry{attempts: 5, baseDelay: time.Hour, maxDelay: time.Hour}}
+
+	attempts := 0
+	start := time.Now()
+	err := store.inTx(ctx, pgx.TxOptions{}, func(tx pgx.Tx) error {
+		attempts++
+		time.AfterFunc(10*time.Millisecond, cancel)
+		return &pgconn.PgError{Code: "40P01"}
+	})
+
+	require.Error(t, err)
+	assert.Equal(t, 1, attempts)
+	assert.Less(t, time.Since(start), 10*time.Second)
+}
+
+func TestExecuteTransfer_Contention(t *testing.T) {
+	ctx := context.Background()
+	truncateTables(t, ctx)
+	require.NoError(t, testStore.CreateAccount(ctx, model.Account{AccountID: 1, Balance: decimal.NewFromInt(1000)}))
+	require.NoError(t, testStore.CreateAccount(ctx, model.Account{AccountID: 2, Balance: decimal.NewFromInt(1000)}))
+
+	// Act: transfers in both directions between the same accounts, while
+	// reconciliation, which runs in a repeatable-read transaction that writes,
+	// takes snapshots of them.
+	const transfers = 100
+	var wg sync.WaitGroup
+	errs := make(chan error, 2*transfers+10)
+	for i := 0; i < transfers; i++ {
+		wg.Add(2)
+		for _, req := range []model.TransactionRequest{
+			{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromInt(3)},
+			{SourceAccountID: 2, DestinationAccountID: 1, Amount: decimal.NewFromInt(2)},
+		} {
+			go func() {
+				defer wg.Done()
+				if _, err := testStore.ExecuteTransfer(ctx, req); err != nil {
+					errs <- err
+				}
+			}()
+		}
+	}
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := testStore.Reconcile(ctx, model.ReconcileOptions{}); err != nil {
+				errs <- err
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	// Assert: every transfer committed exactly once.
+	for err := range errs {
+		assert.NoError(t, err)
+	}
+	acc1, err := testStore.GetAccount(ctx, 1)
+	require.NoError(t, err)
+	acc2, err := testStore.GetAccount(ctx, 2)
+	require.NoError(t, err)
+	assert.True(t, decimal.NewFromInt(900).Equal(acc1.Balance), "account 1 balance is %s", acc1.Balance)
+	assert.True(t, decimal.NewFromInt(1100).Equal(acc2.Balance), "account 2 balance is %s", acc2.Balance)
+
+	report, err := testStore.Reconcile(ctx, model.ReconcileOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, report.Mismatches)
+}
+
+func TestRetryableCode(t *testing.T) {
+	for _, tc := range []struct {
+		err  error
+		code string
+		ok   bool
+	}{
+		{&pgconn.PgError{Code: "40001"}, "40001", true},
+		{&pgconn.PgError{Code: "40P01"}, "40P01", true},
+		{&AccountError{AccountID: 1, Err: &pgconn.PgError{Code: "40P01"}}, "40P01", true},
+		{&pgconn.PgError{Code: "57014"}, "", false},
+		{context.Canceled, "", false},
+		{errors.New("boom"), "", false},
+	} {
+		code, ok := retryableCode(tc.err)
+		assert.Equal(t, tc.code, code, "%v", tc.err)
+		assert.Equal(t, tc.ok, ok, "%v", tc.err)
+	}
+}